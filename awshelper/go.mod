@@ -2,9 +2,9 @@ module github.com/TrueBlocks/trueblocks-key/awshelper
 
 go 1.22
 
-require github.com/aws/aws-secretsmanager-caching-go v1.1.2
-
 require (
-	github.com/aws/aws-sdk-go v1.44.287 // indirect
-	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/aws/aws-sdk-go v1.44.287
+	github.com/aws/aws-secretsmanager-caching-go v1.1.2
 )
+
+require github.com/jmespath/go-jmespath v0.4.0 // indirect
@@ -0,0 +1,43 @@
+package awshelper
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
+)
+
+// rdsSession is created once and shared, because tokens are built concurrently (e.g. by
+// pgxpool's BeforeConnect)
+var rdsSession *session.Session
+var rdsSessionErr error
+var rdsSessionOnce sync.Once
+
+// BuildRdsAuthToken returns IAM authentication token that can be used as database password.
+// Tokens are valid for 15 minutes.
+func BuildRdsAuthToken(host string, port int, region string, user string) (token string, err error) {
+	if os.Getenv("AWS_SAM_LOCAL") == "true" {
+		log.Println("Returning TEST password as RDS auth token")
+		return TestSecret.Password, nil
+	}
+
+	rdsSessionOnce.Do(func() {
+		rdsSession, rdsSessionErr = session.NewSession()
+	})
+	if rdsSessionErr != nil {
+		err = fmt.Errorf("awshelper/rds: init session: %w", rdsSessionErr)
+		return
+	}
+	if region == "" {
+		region = aws.StringValue(rdsSession.Config.Region)
+	}
+
+	endpoint := net.JoinHostPort(host, strconv.Itoa(port))
+	return rdsutils.BuildAuthToken(endpoint, region, user, rdsSession.Config.Credentials)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/knadh/koanf/providers/env"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
+	"github.com/mitchellh/mapstructure"
 )
 
 const prefix = "KY_"
@@ -21,7 +22,10 @@ type ConfigFile struct {
 	Query           queryGroup
 	QnProvision     qnProvisionGroup `koanf:"qnprovision"`
 	Convert         convertGroup
+	Manifest        manifestGroup
+	Enrich          enrichGroup
 	DirectCustomers directCustomersGroup `koanf:"directcustomers"`
+	Usage           usageGroup
 	Misc            miscGroup
 }
 
@@ -38,6 +42,20 @@ type databaseGroup struct {
 	Password  string
 	Database  string
 	AwsSecret string
+	// AppearancesPartitionSize is the number of blocks per appearances partition,
+	// 0 if the table is not partitioned
+	AppearancesPartitionSize uint32
+	// MaxConnections > 0 makes binaries keep a connection pool between invocations,
+	// 0 means a new connection per invocation (use with RDS Proxy)
+	MaxConnections int32
+	// StatementTimeout in milliseconds, 0 means server default
+	StatementTimeout int
+	// IamAuth makes binaries authenticate as User with RDS IAM auth tokens instead of password
+	IamAuth bool
+	// AwsRegion of the database, used by IamAuth. Defaults to AWS_REGION
+	AwsRegion string
+	// Replicas are read replicas ("host" or "host:port"), sharing credentials with the primary
+	Replicas []string
 }
 
 type sqsGroup struct {
@@ -47,6 +65,26 @@ type sqsGroup struct {
 
 type queryGroup struct {
 	MaxLimit uint
+	// Sqlite is the path of a SQLite store (see sqliteStore.Open) that the query API
+	// reads from instead of the "default" database, e.g. when running Key on a laptop
+	Sqlite string
+	Cache  queryCacheGroup
+	// Timeout limits time (in milliseconds) spent on a single request, 0 means no limit
+	Timeout int
+	// Timeouts overrides Timeout for specific methods. Keys are method names without
+	// "tb_" prefix, e.g. getAppearances (case insensitive).
+	Timeouts map[string]int
+}
+
+type queryCacheGroup struct {
+	// Type is "memory", "memcached" or empty (cache disabled)
+	Type string
+	// Size is max number of responses kept by "memory" cache
+	Size int
+	// Address is host:port of memcached server
+	Address string
+	// Expiration in seconds, used by "memcached" cache
+	Expiration int
 }
 
 type qnProvisionGroup struct {
@@ -57,10 +95,28 @@ type qnProvisionGroup struct {
 }
 
 type convertGroup struct {
-	BatchSize      int
+	// BatchSize is the number of appearances inserted in a single batch
+	BatchSize int
+	// MaxConnections is the number of chunks converted at once, each one using
+	// its own database connection
 	MaxConnections int
 }
 
+type manifestGroup struct {
+	// Gateway is IPFS gateway URL prefix used to download index chunks, CID is appended to it
+	Gateway string
+	// Workers is the number of files downloaded at once
+	Workers int
+}
+
+type enrichGroup struct {
+	// RpcUrl of Ethereum JSON-RPC provider used to read block timestamps and transaction
+	// hashes. Empty disables enrichment.
+	RpcUrl string
+	// TxHashes enables storing transaction hashes
+	TxHashes bool
+}
+
 type directCustomersGroup struct {
 	TableName         string
 	EmailIndexName    string
@@ -72,6 +128,28 @@ type directCustomersGroup struct {
 	DefaultApiKeyName string
 }
 
+type usageGroup struct {
+	// TableName is DynamoDB table keeping usage counters (partition key Account, sort key
+	// Period). Empty disables usage metering and quotas.
+	TableName string
+	// Quotas maps plan slugs ("direct" for direct customers) to limits enforced by
+	// authorizers. Case and hyphens are ignored. Plans without quota are not limited.
+	Quotas map[string]usageQuotaGroup
+}
+
+// usageQuotaGroup limits requests and returned rows per UTC day and month. Zero means
+// no limit. Exceeding a soft limit is only reported, requests over a hard limit are denied.
+type usageQuotaGroup struct {
+	SoftDailyRequests   uint64
+	HardDailyRequests   uint64
+	SoftDailyRows       uint64
+	HardDailyRows       uint64
+	SoftMonthlyRequests uint64
+	HardMonthlyRequests uint64
+	SoftMonthlyRows     uint64
+	HardMonthlyRows     uint64
+}
+
 type miscGroup struct {
 	DirecCustomersApiUrl string
 }
@@ -105,7 +183,18 @@ func Get(configPath string) (*ConfigFile, error) {
 	}
 
 	var out ConfigFile
-	if err := k.Unmarshal("", &out); err != nil {
+	unmarshalConf := koanf.UnmarshalConf{
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				// lists can be set in env as comma-separated values
+				mapstructure.StringToSliceHookFunc(","),
+			),
+			Result:           &out,
+			WeaklyTypedInput: true,
+		},
+	}
+	if err := k.UnmarshalWithConf("", &out, unmarshalConf); err != nil {
 		return nil, fmt.Errorf("config: unmarshal: %w", err)
 	}
 	cached = &out
@@ -7,5 +7,9 @@ var defaultConfig = map[string]any{
 	},
 	"Convert.BatchSize":         100,
 	"Convert.MaxConnections":    20,
+	"Manifest.Gateway":          "https://ipfs.unchainedindex.io/ipfs/",
+	"Manifest.Workers":          4,
+	"Query.Cache.Size":          10000,
+	"Query.Timeout":             20000,
 	"DirectCustomers.TableName": "key-prod-direct-customers",
 }
@@ -2,42 +2,97 @@ package database
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
 	"github.com/jackc/pgx/v5"
 )
 
-func FetchAddressesInTx(ctx context.Context, c *Connection, blockNumber int, transactionIndex int) (results []string, err error) {
-	rows, err := c.conn.Query(
+// AddressInBlock is a single address appearing in a transaction of a block
+type AddressInBlock struct {
+	TransactionIndex uint32
+	Address          string
+}
+
+// FetchAddressesInTx returns up to limit addresses, ordered, starting after afterAddress
+// (empty string to start from the beginning)
+func FetchAddressesInTx(ctx context.Context, c *Connection, blockNumber int, transactionIndex int, afterAddress string, limit uint) (results []string, err error) {
+	afterAddressArg, err := c.addressArg(afterAddress)
+	if err != nil {
+		return
+	}
+	rows, err := c.reader(ctx, uint(blockNumber), 0).Query(
 		ctx,
 		sql.SelectAddressesInTx(c.AppearancesTableName(), c.AddressesTableName()),
 		pgx.NamedArgs{
 			"blockNumber":      blockNumber,
 			"transactionIndex": transactionIndex,
+			"afterAddress":     afterAddressArg,
+			"pageSize":         limit,
 		},
 	)
 	if err != nil {
 		return
 	}
 
-	results, err = pgx.CollectRows[string](rows, pgx.RowTo[string])
+	addresses, err := pgx.CollectRows[dbAddress](rows, pgx.RowTo[dbAddress])
+	if err != nil {
+		return
+	}
+	results = addressStrings(addresses)
 
 	return
 }
 
-func FetchAddressesInBlock(ctx context.Context, c *Connection, blockNumber int) (results []string, err error) {
-	rows, err := c.conn.Query(
+// FetchAddressesInBlock returns up to limit addresses ordered by transaction index and address,
+// starting after (afterTransactionIndex, afterAddress). Pass empty afterAddress to start
+// from the beginning.
+func FetchAddressesInBlock(ctx context.Context, c *Connection, blockNumber int, afterTransactionIndex uint32, afterAddress string, limit uint) (results []AddressInBlock, err error) {
+	afterAddressArg, err := c.addressArg(afterAddress)
+	if err != nil {
+		return
+	}
+	rows, err := c.reader(ctx, uint(blockNumber), 0).Query(
 		ctx,
 		sql.SelectAddressesInBlock(c.AppearancesTableName(), c.AddressesTableName()),
 		pgx.NamedArgs{
-			"blockNumber": blockNumber,
+			"blockNumber":           blockNumber,
+			"afterTransactionIndex": afterTransactionIndex,
+			"afterAddress":          afterAddressArg,
+			"pageSize":              limit,
 		},
 	)
 	if err != nil {
 		return
 	}
 
-	results, err = pgx.CollectRows[string](rows, pgx.RowTo[string])
+	results, err = pgx.CollectRows[AddressInBlock](rows, func(row pgx.CollectableRow) (result AddressInBlock, err error) {
+		var address dbAddress
+		err = row.Scan(&result.TransactionIndex, &address)
+		result.Address = string(address)
+		return
+	})
 
 	return
 }
+
+// PublicAddressesInTx groups addresses appearing in the same transaction
+type PublicAddressesInTx struct {
+	TransactionIndex string   `json:"transactionIndex"`
+	Addresses        []string `json:"addresses"`
+}
+
+// GroupAddressesByTx expects items ordered by transaction index
+func GroupAddressesByTx(items []AddressInBlock) []PublicAddressesInTx {
+	result := make([]PublicAddressesInTx, 0)
+	for i, item := range items {
+		if i == 0 || item.TransactionIndex != items[i-1].TransactionIndex {
+			result = append(result, PublicAddressesInTx{
+				TransactionIndex: strconv.FormatUint(uint64(item.TransactionIndex), 10),
+			})
+		}
+		last := &result[len(result)-1]
+		last.Addresses = append(last.Addresses, item.Address)
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+)
+
+// Addresses are hex strings outside of this package. Depending on the schema, the database
+// keeps them as lowercase hex VARCHAR or 20-byte BYTEA (see sql.CreateTableAddresses).
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+const addressLength = 20
+
+// detectAddressFormat checks how addresses are stored, so that we work with tables
+// created before and after binary addresses were introduced
+func (c *Connection) detectAddressFormat(ctx context.Context) error {
+	if err := c.conn.QueryRow(ctx, sql.SelectAddressesBinary(c.AddressesTableName())).Scan(&c.binaryAddresses); err != nil {
+		return err
+	}
+	c.addressFormatKnown = true
+	return nil
+}
+
+// BinaryAddresses returns true if addresses are stored as BYTEA
+func (c *Connection) BinaryAddresses() bool {
+	return c.binaryAddresses
+}
+
+// addressArg converts hex address to the query argument matching address column type.
+// Empty address (used as "from the beginning" by cursors) is allowed.
+func (c *Connection) addressArg(address string) (any, error) {
+	address = strings.ToLower(address)
+	if !c.binaryAddresses {
+		return address, nil
+	}
+	if address == "" {
+		return []byte{}, nil
+	}
+
+	decoded, err := hex.DecodeString(strings.TrimPrefix(address, "0x"))
+	if err != nil || len(decoded) != addressLength {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAddress, address)
+	}
+	return decoded, nil
+}
+
+// dbAddress scans address column of any type into lowercase hex
+type dbAddress string
+
+func (a *dbAddress) Scan(src any) error {
+	switch value := src.(type) {
+	case string:
+		*a = dbAddress(value)
+	case []byte:
+		*a = dbAddress("0x" + hex.EncodeToString(value))
+	default:
+		return fmt.Errorf("cannot scan %T into address", src)
+	}
+	return nil
+}
+
+func addressStrings(addresses []dbAddress) []string {
+	result := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		result = append(result, string(address))
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestConnection_addressArg(t *testing.T) {
+	address := "0xF503017D7Baf7fbc0fff7492b751025c6a78179b"
+
+	text := &Connection{}
+	if arg, err := text.addressArg(address); err != nil || arg != "0xf503017d7baf7fbc0fff7492b751025c6a78179b" {
+		t.Fatal("wrong text arg:", arg, err)
+	}
+
+	binary := &Connection{binaryAddresses: true}
+	arg, err := binary.addressArg(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := arg.([]byte); len(b) != 20 || b[0] != 0xf5 || b[19] != 0x9b {
+		t.Fatal("wrong binary arg:", b)
+	}
+	if arg, err := binary.addressArg(""); err != nil || !bytes.Equal(arg.([]byte), []byte{}) {
+		t.Fatal("wrong empty arg:", arg, err)
+	}
+	if _, err := binary.addressArg("0x1234"); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatal("expected ErrInvalidAddress, got", err)
+	}
+}
+
+func Test_dbAddressScan(t *testing.T) {
+	expected := "0xf503017d7baf7fbc0fff7492b751025c6a78179b"
+	binary := []byte{
+		0xf5, 0x03, 0x01, 0x7d, 0x7b, 0xaf, 0x7f, 0xbc, 0x0f, 0xff,
+		0x74, 0x92, 0xb7, 0x51, 0x02, 0x5c, 0x6a, 0x78, 0x17, 0x9b,
+	}
+
+	for _, src := range []any{expected, binary} {
+		var a dbAddress
+		if err := a.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if string(a) != expected {
+			t.Fatal("wrong address:", a)
+		}
+	}
+}
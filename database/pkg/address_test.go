@@ -0,0 +1,34 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupAddressesByTx(t *testing.T) {
+	items := []AddressInBlock{
+		{TransactionIndex: 1, Address: "0x209c4784ab1e8183cf58ca33cb740efbf3fc18ef"},
+		{TransactionIndex: 1, Address: "0xf503017d7baf7fbc0fff7492b751025c6a78179b"},
+		{TransactionIndex: 2, Address: "0x209c4784ab1e8183cf58ca33cb740efbf3fc18ef"},
+	}
+	expected := []PublicAddressesInTx{
+		{
+			TransactionIndex: "1",
+			Addresses: []string{
+				"0x209c4784ab1e8183cf58ca33cb740efbf3fc18ef",
+				"0xf503017d7baf7fbc0fff7492b751025c6a78179b",
+			},
+		},
+		{
+			TransactionIndex: "2",
+			Addresses:        []string{"0x209c4784ab1e8183cf58ca33cb740efbf3fc18ef"},
+		},
+	}
+
+	if result := GroupAddressesByTx(items); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("wrong result: %+v", result)
+	}
+	if result := GroupAddressesByTx(nil); len(result) != 0 || result == nil {
+		t.Fatal("expected empty slice, got", result)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
 	"strings"
 
@@ -19,7 +20,11 @@ type Appearance struct {
 	TransactionIndex uint32 `json:"transactionIndex"`
 }
 
-func FetchAppearancesFirstPage(ctx context.Context, c *Connection, earliest bool, address string, lastBlock uint, limit uint) (results []Appearance, err error) {
+// FetchAppearancesFirstPage returns the latest (or the earliest) page together with the
+// watermark that the following pages of the walk should be pinned to (see FetchWatermark).
+// The page itself is not filtered by the watermark: it stops moving while any write
+// transaction is open, but the first page should always show the newest appearances.
+func FetchAppearancesFirstPage(ctx context.Context, c *Connection, earliest bool, address string, firstBlock uint, lastBlock uint, limit uint) (results []Appearance, watermark uint64, err error) {
 	if limit > hardFetchLimit {
 		log.Printf("database/FetchAppearances: limit too large (%d),setting it to %d\n", limit, hardFetchLimit)
 	}
@@ -31,27 +36,38 @@ func FetchAppearancesFirstPage(ctx context.Context, c *Connection, earliest bool
 		sqlString = sql.SelectAppearancesFirstPage(c.AppearancesTableName(), c.AddressesTableName())
 	}
 
-	rows, err := c.conn.Query(
+	addressArg, err := c.addressArg(address)
+	if err != nil {
+		return
+	}
+	reader := c.reader(ctx, lastBlock, 0)
+	// Everything up to the watermark is committed before we read the page, so the
+	// following pages see the same appearances (or fewer, if they were inserted later)
+	if watermark, err = fetchWatermark(ctx, reader); err != nil {
+		return
+	}
+	rows, err := reader.Query(
 		ctx,
 		sqlString,
 		pgx.NamedArgs{
-			"address":   strings.ToLower(address),
-			"lastBlock": lastBlock,
-			"pageSize":  limit,
+			"address":    addressArg,
+			"firstBlock": firstBlock,
+			"lastBlock":  lastBlock,
+			"pageSize":   limit,
 		},
 	)
 	if err != nil {
 		return
 	}
 
-	log.Println("address =", strings.ToLower(address), "limit =", limit, "lastBlock =", lastBlock)
+	log.Println("address =", strings.ToLower(address), "limit =", limit, "lastBlock =", lastBlock, "watermark =", watermark)
 
 	results, err = pgx.CollectRows[Appearance](rows, pgx.RowToStructByPos[Appearance])
 
 	return
 }
 
-func FetchAppearancesPage(ctx context.Context, c *Connection, nextPage bool, address string, lastBlock uint, limit uint, appBlockNumber uint, appTransactionIndex uint) (results []Appearance, err error) {
+func FetchAppearancesPage(ctx context.Context, c *Connection, nextPage bool, address string, firstBlock uint, lastBlock uint, limit uint, appBlockNumber uint, appTransactionIndex uint, watermark uint64) (results []Appearance, err error) {
 	if limit > hardFetchLimit {
 		log.Printf("database/FetchAppearances: limit too large (%d),setting it to %d\n", limit, hardFetchLimit)
 	}
@@ -62,15 +78,21 @@ func FetchAppearancesPage(ctx context.Context, c *Connection, nextPage bool, add
 	} else {
 		sqlString = sql.SelectAppearancesPreviousPage(c.AppearancesTableName(), c.AddressesTableName())
 	}
-	rows, err := c.conn.Query(
+	addressArg, err := c.addressArg(address)
+	if err != nil {
+		return
+	}
+	rows, err := c.reader(ctx, lastBlock, watermark).Query(
 		ctx,
 		sqlString,
 		pgx.NamedArgs{
-			"address":             strings.ToLower(address),
+			"address":             addressArg,
+			"firstBlock":          firstBlock,
 			"lastBlock":           lastBlock,
 			"pageSize":            limit,
 			"appBlockNumber":      appBlockNumber,
 			"appTransactionIndex": appTransactionIndex,
+			"watermark":           watermarkArg(watermark),
 		},
 	)
 	if err != nil {
@@ -84,6 +106,26 @@ func FetchAppearancesPage(ctx context.Context, c *Connection, nextPage bool, add
 	return
 }
 
+// FetchWatermark returns the current ingestion watermark. Passing it to FetchAppearancesPage
+// hides appearances inserted later, even if they belong to past blocks (e.g. backfills).
+func FetchWatermark(ctx context.Context, c *Connection) (result uint64, err error) {
+	return fetchWatermark(ctx, c.conn)
+}
+
+func fetchWatermark(ctx context.Context, q Querier) (result uint64, err error) {
+	err = q.QueryRow(ctx, sql.SelectWatermark()).Scan(&result)
+	return
+}
+
+// watermarkArg translates zero watermark (e.g. coming from a page id created before
+// watermarks were introduced) to "no limit"
+func watermarkArg(watermark uint64) uint64 {
+	if watermark == 0 {
+		return math.MaxInt64
+	}
+	return watermark
+}
+
 type AppearancesDatasetBounds struct {
 	Latest   Appearance `json:"latest"`
 	Earliest Appearance `json:"earliest"`
@@ -97,13 +139,20 @@ func (a *AppearancesDatasetBounds) IsEarliest(appearance *Appearance) bool {
 	return appearance.BlockNumber == a.Earliest.BlockNumber && appearance.TransactionIndex == a.Earliest.TransactionIndex
 }
 
-func FetchAppearancesDatasetBounds(ctx context.Context, c *Connection, address string, lastBlock uint) (bounds AppearancesDatasetBounds, err error) {
-	rows, err := c.conn.Query(
+// FetchAppearancesDatasetBounds returns the latest and the earliest appearance. Like the
+// first page, bounds are not filtered by the watermark.
+func FetchAppearancesDatasetBounds(ctx context.Context, c *Connection, address string, firstBlock uint, lastBlock uint) (bounds AppearancesDatasetBounds, err error) {
+	addressArg, err := c.addressArg(address)
+	if err != nil {
+		return
+	}
+	rows, err := c.reader(ctx, lastBlock, 0).Query(
 		ctx,
 		sql.SelectAppearancesDatasetBounds(c.AppearancesTableName(), c.AddressesTableName()),
 		pgx.NamedArgs{
-			"address":   strings.ToLower(address),
-			"lastBlock": lastBlock,
+			"address":    addressArg,
+			"firstBlock": firstBlock,
+			"lastBlock":  lastBlock,
 		},
 	)
 	if err != nil {
@@ -138,35 +187,108 @@ func PublicBounds(bounds *AppearancesDatasetBounds) *PublicAppearancesDatasetBou
 	}
 }
 
+// InsertAppearanceBatch inserts appearances and then moves status' last indexed block and
+// bumps the data version
 func InsertAppearanceBatch(ctx context.Context, c *Connection, apps []queueItem.Appearance) (err error) {
+	if len(apps) == 0 {
+		return
+	}
+
 	batch := &pgx.Batch{}
+	var lastBlock uint32
+
+	for _, app := range apps {
+		if app.BlockNumber > lastBlock {
+			lastBlock = app.BlockNumber
+		}
+	}
+	if err = EnsureAppearancesPartitions(ctx, c, lastBlock); err != nil {
+		return
+	}
 
 	for _, app := range apps {
+		addressArg, err := c.addressArg(app.Address)
+		if err != nil {
+			return err
+		}
 		batch.Queue(
 			sql.InsertAppearance(c.AppearancesTableName(), c.AddressesTableName()),
-			strings.ToLower(app.Address),
+			addressArg,
 			app.BlockNumber,
 			app.TransactionIndex,
 		)
 	}
 
-	return c.conn.SendBatch(ctx, batch).Close()
+	if err = c.conn.SendBatch(ctx, batch).Close(); err != nil {
+		return
+	}
+	if err = updateStatusLastIndexedBlock(ctx, c, lastBlock); err != nil {
+		return
+	}
+	return bumpDataVersion(ctx, c)
 }
 
 func (a *Appearance) Insert(ctx context.Context, c *Connection, address string) (err error) {
-	_, err = c.conn.Exec(ctx,
+	addressArg, err := c.addressArg(address)
+	if err != nil {
+		return
+	}
+	if err = EnsureAppearancesPartitions(ctx, c, a.BlockNumber); err != nil {
+		return
+	}
+	batch := &pgx.Batch{}
+	batch.Queue(
 		sql.InsertAppearance(c.AppearancesTableName(), c.AddressesTableName()),
-		strings.ToLower(address),
+		addressArg,
 		a.BlockNumber,
 		a.TransactionIndex,
 	)
 
-	return
+	if err = c.conn.SendBatch(ctx, batch).Close(); err != nil {
+		return
+	}
+	if err = updateStatusLastIndexedBlock(ctx, c, a.BlockNumber); err != nil {
+		return
+	}
+	return bumpDataVersion(ctx, c)
+}
+
+// AddressAppearance is an appearance together with the address it belongs to
+type AddressAppearance struct {
+	Address string
+	Appearance
+}
+
+// FetchAppearancesInRange returns all appearances between firstBlock and lastBlock
+// (inclusive) ordered by address, block number and transaction index. It's meant for
+// building index chunks, so the range should be chunk-sized.
+func FetchAppearancesInRange(ctx context.Context, c *Connection, firstBlock uint32, lastBlock uint32) (results []AddressAppearance, err error) {
+	rows, err := c.conn.Query(
+		ctx,
+		sql.SelectAppearancesInRange(c.AppearancesTableName(), c.AddressesTableName()),
+		pgx.NamedArgs{
+			"firstBlock": firstBlock,
+			"lastBlock":  lastBlock,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows[AddressAppearance](rows, func(row pgx.CollectableRow) (result AddressAppearance, err error) {
+		var address dbAddress
+		err = row.Scan(&address, &result.BlockNumber, &result.TransactionIndex)
+		result.Address = string(address)
+		return
+	})
 }
 
 type PublicAppearance struct {
 	BlockNumber      string `json:"blockNumber"`
 	TransactionIndex string `json:"transactionIndex"`
+	// Timestamp and TxHash are only set when the user asks for them (and we have them)
+	Timestamp string `json:"timestamp,omitempty"`
+	TxHash    string `json:"txHash,omitempty"`
 }
 
 func AppearanceToPublic(a *Appearance) *PublicAppearance {
@@ -0,0 +1,321 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+	"github.com/jackc/pgx/v5"
+)
+
+// Block holds data used to enrich appearances
+type Block struct {
+	Number    uint32
+	Timestamp uint64
+	// TransactionHashes are ordered by transaction index. Empty if we don't store hashes.
+	TransactionHashes []string
+}
+
+// InsertBlockBatch inserts block timestamps and transaction hashes. Blocks that are
+// already present are ignored.
+func InsertBlockBatch(ctx context.Context, c *Connection, blocks []Block) (err error) {
+	if len(blocks) == 0 {
+		return
+	}
+
+	batch := &pgx.Batch{}
+	for _, block := range blocks {
+		batch.Queue(
+			sql.InsertBlock(c.BlocksTableName()),
+			block.Number,
+			block.Timestamp,
+		)
+		for txId, hash := range block.TransactionHashes {
+			batch.Queue(
+				sql.InsertTransaction(c.TransactionsTableName()),
+				block.Number,
+				txId,
+				hash,
+			)
+		}
+	}
+
+	if err = c.conn.SendBatch(ctx, batch).Close(); err != nil {
+		return
+	}
+	// enriched appearances change, so cached responses have to go
+	return bumpDataVersion(ctx, c)
+}
+
+// FetchMissingBlocks returns these of blockNumbers that have no timestamp stored
+func FetchMissingBlocks(ctx context.Context, c *Connection, blockNumbers []uint32) (results []uint32, err error) {
+	rows, err := c.conn.Query(
+		ctx,
+		sql.SelectMissingBlocks(c.BlocksTableName()),
+		pgx.NamedArgs{
+			"blockNumbers": blockNumbers,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows[uint32](rows, pgx.RowTo[uint32])
+}
+
+// FetchMissingBlocksInRange returns block numbers between firstBlock and lastBlock
+// (inclusive) that have no timestamp stored
+func FetchMissingBlocksInRange(ctx context.Context, c *Connection, firstBlock uint32, lastBlock uint32) (results []uint32, err error) {
+	rows, err := c.conn.Query(
+		ctx,
+		sql.SelectMissingBlocksInRange(c.BlocksTableName()),
+		pgx.NamedArgs{
+			"firstBlock": firstBlock,
+			"lastBlock":  lastBlock,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows[uint32](rows, pgx.RowTo[uint32])
+}
+
+// FetchBlockTimestamps returns map of block number to timestamp. Blocks without
+// timestamp are not present in the map.
+func FetchBlockTimestamps(ctx context.Context, c *Connection, blockNumbers []uint32) (results map[uint32]uint64, err error) {
+	rows, err := c.reader(ctx, 0, 0).Query(
+		ctx,
+		sql.SelectBlockTimestamps(c.BlocksTableName()),
+		pgx.NamedArgs{
+			"blockNumbers": blockNumbers,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	results = make(map[uint32]uint64, len(blockNumbers))
+	var blockNumber uint32
+	var timestamp uint64
+	_, err = pgx.ForEachRow(rows, []any{&blockNumber, &timestamp}, func() error {
+		results[blockNumber] = timestamp
+		return nil
+	})
+	return
+}
+
+// FetchTransactionHashes returns map of appearance to its transaction hash. Appearances
+// without hash (e.g. block rewards or not enriched yet) are not present in the map.
+func FetchTransactionHashes(ctx context.Context, c *Connection, apps []Appearance) (results map[Appearance]string, err error) {
+	blockNumbers := make([]uint32, 0, len(apps))
+	txIds := make([]uint32, 0, len(apps))
+	for _, app := range apps {
+		blockNumbers = append(blockNumbers, app.BlockNumber)
+		txIds = append(txIds, app.TransactionIndex)
+	}
+
+	rows, err := c.reader(ctx, 0, 0).Query(
+		ctx,
+		sql.SelectTransactionHashes(c.TransactionsTableName()),
+		pgx.NamedArgs{
+			"blockNumbers": blockNumbers,
+			"txIds":        txIds,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	results = make(map[Appearance]string, len(apps))
+	var app Appearance
+	var hash string
+	_, err = pgx.ForEachRow(rows, []any{&app.BlockNumber, &app.TransactionIndex, &hash}, func() error {
+		results[app] = hash
+		return nil
+	})
+	return
+}
+
+// PublicBlock is the block returned to API users
+type PublicBlock struct {
+	BlockNumber string `json:"blockNumber"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// ErrTimestampNotCovered is returned when a timestamp cannot be translated to a block
+// reliably, because timestamps of the neighbouring blocks are not stored (only enriched
+// blocks have timestamps, see `dbadmin enrich`)
+var ErrTimestampNotCovered = errors.New("block timestamps are not available for the requested range")
+
+// BlockTimestampIndex finds blocks by timestamp. Only enriched blocks are stored, so
+// ResolveBlockByTimestamp uses neighbours and bounds of stored blocks to check that
+// the block found is the right one.
+type BlockTimestampIndex interface {
+	// BlockAtTimestamp returns the latest stored block mined at or before timestamp or, if
+	// after is true, the earliest one mined at or after timestamp. neighbourStored tells if
+	// the next (previous, if after is true) block is stored too.
+	BlockAtTimestamp(ctx context.Context, timestamp uint64, after bool) (block Block, neighbourStored bool, found bool, err error)
+	// StoredBlocksBounds returns the lowest and the highest stored block
+	StoredBlocksBounds(ctx context.Context) (first uint32, last uint32, found bool, err error)
+}
+
+// FetchBlockByTimestamp returns the latest block mined at or before timestamp or, if
+// after is true, the earliest block mined at or after timestamp. found is false if there
+// is no such block up to lastIndexedBlock. ErrTimestampNotCovered is returned if the answer
+// depends on blocks that have not been enriched.
+func FetchBlockByTimestamp(ctx context.Context, c *Connection, timestamp uint64, after bool, lastIndexedBlock uint) (result *PublicBlock, found bool, err error) {
+	return ResolveBlockByTimestamp(ctx, c.blockTimestampIndex(ctx), timestamp, after, lastIndexedBlock)
+}
+
+// ResolveBlockByTimestamp works like FetchBlockByTimestamp for any BlockTimestampIndex
+func ResolveBlockByTimestamp(ctx context.Context, index BlockTimestampIndex, timestamp uint64, after bool, lastIndexedBlock uint) (result *PublicBlock, found bool, err error) {
+	block, found, err := resolveBlockByTimestamp(ctx, index, timestamp, after, 0, lastIndexedBlock)
+	if err != nil || !found {
+		return
+	}
+	result = &PublicBlock{
+		BlockNumber: strconv.FormatUint(uint64(block.Number), 10),
+		Timestamp:   strconv.FormatUint(block.Timestamp, 10),
+	}
+	return
+}
+
+// FetchBlockRangeByTimestamps translates timestamp range (inclusive) to block range.
+// nil timestamp means no limit, and the respective block is returned unchanged.
+// empty is true if no block has been mined in the range. ErrTimestampNotCovered
+// is returned if the blocks between firstBlock and lastBlock are not enriched enough
+// to translate the timestamps.
+func FetchBlockRangeByTimestamps(ctx context.Context, c *Connection, fromTimestamp *uint64, toTimestamp *uint64, firstBlock uint, lastBlock uint) (from uint, to uint, empty bool, err error) {
+	return ResolveBlockRangeByTimestamps(ctx, c.blockTimestampIndex(ctx), fromTimestamp, toTimestamp, firstBlock, lastBlock)
+}
+
+// ResolveBlockRangeByTimestamps works like FetchBlockRangeByTimestamps for any BlockTimestampIndex
+func ResolveBlockRangeByTimestamps(ctx context.Context, index BlockTimestampIndex, fromTimestamp *uint64, toTimestamp *uint64, firstBlock uint, lastBlock uint) (from uint, to uint, empty bool, err error) {
+	from, to = firstBlock, lastBlock
+
+	if fromTimestamp != nil {
+		block, found, fetchErr := resolveBlockByTimestamp(ctx, index, *fromTimestamp, true, firstBlock, lastBlock)
+		if fetchErr != nil {
+			err = fetchErr
+			return
+		}
+		if !found {
+			empty = true
+			return
+		}
+		from = max(from, uint(block.Number))
+	}
+
+	if toTimestamp != nil {
+		block, found, fetchErr := resolveBlockByTimestamp(ctx, index, *toTimestamp, false, firstBlock, lastBlock)
+		if fetchErr != nil {
+			err = fetchErr
+			return
+		}
+		if !found {
+			empty = true
+			return
+		}
+		to = min(to, uint(block.Number))
+	}
+
+	empty = from > to
+	return
+}
+
+// resolveBlockByTimestamp finds the block and checks that the result is right for blocks between
+// firstBlock and lastBlock, even though not every block has its timestamp stored
+func resolveBlockByTimestamp(ctx context.Context, index BlockTimestampIndex, timestamp uint64, after bool, firstBlock uint, lastBlock uint) (block Block, found bool, err error) {
+	block, neighbourStored, found, err := index.BlockAtTimestamp(ctx, timestamp, after)
+	if err != nil {
+		return
+	}
+
+	if found {
+		if !foundBlockCovered(after, uint(block.Number), neighbourStored, firstBlock, lastBlock) {
+			err = ErrTimestampNotCovered
+		}
+		return
+	}
+
+	minStored, maxStored, anyStored, err := index.StoredBlocksBounds(ctx)
+	if err != nil {
+		return
+	}
+	if !anyStored || !missingBlockCovered(after, uint(minStored), uint(maxStored), firstBlock, lastBlock) {
+		err = ErrTimestampNotCovered
+	}
+	return
+}
+
+// postgresBlockTimestampIndex is BlockTimestampIndex reading from a single node, so that
+// the block and the bounds are consistent
+type postgresBlockTimestampIndex struct {
+	c      *Connection
+	reader Querier
+}
+
+func (c *Connection) blockTimestampIndex(ctx context.Context) *postgresBlockTimestampIndex {
+	return &postgresBlockTimestampIndex{c: c, reader: c.reader(ctx, 0, 0)}
+}
+
+func (p *postgresBlockTimestampIndex) BlockAtTimestamp(ctx context.Context, timestamp uint64, after bool) (block Block, neighbourStored bool, found bool, err error) {
+	var sqlString string
+	if after {
+		sqlString = sql.SelectBlockAtOrAfterTimestamp(p.c.BlocksTableName())
+	} else {
+		sqlString = sql.SelectBlockAtOrBeforeTimestamp(p.c.BlocksTableName())
+	}
+
+	rows, err := p.reader.Query(
+		ctx,
+		sqlString,
+		pgx.NamedArgs{
+			"timestamp": timestamp,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = pgx.ForEachRow(rows, []any{&block.Number, &block.Timestamp, &neighbourStored}, func() error {
+		found = true
+		return nil
+	})
+	return
+}
+
+func (p *postgresBlockTimestampIndex) StoredBlocksBounds(ctx context.Context) (first uint32, last uint32, found bool, err error) {
+	var minStored, maxStored *uint32
+	if err = p.reader.QueryRow(ctx, sql.SelectBlocksBounds(p.c.BlocksTableName())).Scan(&minStored, &maxStored); err != nil {
+		return
+	}
+	if minStored == nil {
+		return
+	}
+	return *minStored, *maxStored, true, nil
+}
+
+// foundBlockCovered tells if the block found by timestamp is the right one. It is if its
+// neighbour is stored (then no block in between is missing) or if the block is outside
+// of firstBlock - lastBlock range (then the range limit is used anyway).
+func foundBlockCovered(after bool, number uint, neighbourStored bool, firstBlock uint, lastBlock uint) bool {
+	if neighbourStored {
+		return true
+	}
+	if after {
+		return number <= firstBlock
+	}
+	return number >= lastBlock
+}
+
+// missingBlockCovered tells if there really is no block at or after (at or before) the timestamp
+// between firstBlock and lastBlock, given the lowest and the highest stored block
+func missingBlockCovered(after bool, minStored uint, maxStored uint, firstBlock uint, lastBlock uint) bool {
+	if after {
+		return maxStored >= lastBlock
+	}
+	return minStored <= firstBlock
+}
@@ -0,0 +1,41 @@
+package database
+
+import "testing"
+
+func Test_foundBlockCovered(t *testing.T) {
+	tests := []struct {
+		name            string
+		after           bool
+		number          uint
+		neighbourStored bool
+		want            bool
+	}{
+		{"after, previous stored", true, 150, true, true},
+		{"after, previous missing", true, 150, false, false},
+		{"after, before range", true, 50, false, true},
+		{"before, next stored", false, 150, true, true},
+		{"before, next missing", false, 150, false, false},
+		{"before, after range", false, 250, false, true},
+	}
+	for _, tt := range tests {
+		if v := foundBlockCovered(tt.after, tt.number, tt.neighbourStored, 100, 200); v != tt.want {
+			t.Fatal("wrong result for", tt.name, v)
+		}
+	}
+}
+
+func Test_missingBlockCovered(t *testing.T) {
+	// blocks from 100 to 200 are enriched
+	if !missingBlockCovered(true, 100, 200, 0, 200) {
+		t.Fatal("after: expected covered when all blocks up to lastBlock are stored")
+	}
+	if missingBlockCovered(true, 100, 200, 0, 300) {
+		t.Fatal("after: expected not covered when later blocks are missing")
+	}
+	if !missingBlockCovered(false, 100, 200, 100, 300) {
+		t.Fatal("before: expected covered when blocks from firstBlock are stored")
+	}
+	if missingBlockCovered(false, 100, 200, 0, 300) {
+		t.Fatal("before: expected not covered when earlier blocks are missing")
+	}
+}
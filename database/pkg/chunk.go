@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 
 	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
 	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
@@ -14,8 +15,14 @@ type Chunk struct {
 	Author string `json:"author"`
 }
 
+// InsertChunkBatch inserts chunks and moves status' last chunk range
 func InsertChunkBatch(ctx context.Context, c *Connection, chunks []queueItem.Chunk) (err error) {
+	if len(chunks) == 0 {
+		return
+	}
+
 	batch := &pgx.Batch{}
+	var lastRange string
 
 	for _, chunk := range chunks {
 		batch.Queue(
@@ -24,13 +31,18 @@ func InsertChunkBatch(ctx context.Context, c *Connection, chunks []queueItem.Chu
 			chunk.Range,
 			chunk.Author,
 		)
+		// ranges are zero-padded, so string comparison works
+		if chunk.Range > lastRange {
+			lastRange = chunk.Range
+		}
 	}
+	queueStatusLastChunk(batch, c, lastRange)
 
 	return c.conn.SendBatch(ctx, batch).Close()
 }
 
 func FetchDuplicatedChunks(ctx context.Context, c *Connection) (results []string, err error) {
-	rows, err := c.conn.Query(
+	rows, err := c.reader(ctx, 0, 0).Query(
 		ctx,
 		sql.SelectDuplicatedChunks(c.ChunksTableName()),
 	)
@@ -42,7 +54,7 @@ func FetchDuplicatedChunks(ctx context.Context, c *Connection) (results []string
 }
 
 func CountChunks(ctx context.Context, c *Connection) (result int, err error) {
-	rows, err := c.conn.Query(
+	rows, err := c.reader(ctx, 0, 0).Query(
 		ctx,
 		sql.CountChunks(c.ChunksTableName()),
 	)
@@ -52,3 +64,22 @@ func CountChunks(ctx context.Context, c *Connection) (result int, err error) {
 
 	return pgx.CollectOneRow[int](rows, pgx.RowTo[int])
 }
+
+// FetchLastChunk returns the latest chunk. found is false if the table is empty.
+func FetchLastChunk(ctx context.Context, c *Connection) (result Chunk, found bool, err error) {
+	rows, err := c.reader(ctx, 0, 0).Query(
+		ctx,
+		sql.SelectLastChunk(c.ChunksTableName()),
+	)
+	if err != nil {
+		return
+	}
+
+	result, err = pgx.CollectOneRow(rows, pgx.RowToStructByPos[Chunk])
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = nil
+		return
+	}
+	found = err == nil
+	return
+}
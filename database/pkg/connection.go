@@ -2,21 +2,66 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Querier is implemented by both pgx.Conn and pgxpool.Pool
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
 type Connection struct {
-	Host      string
-	Port      int
-	User      string
-	Password  string
-	Database  string
-	Chain     string
-	conn      *pgx.Conn
-	batchSize int
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+	Chain    string
+
+	// AppearancesPartitionSize is the number of blocks in a single appearances partition.
+	// Zero means that appearances table is not partitioned.
+	AppearancesPartitionSize uint32
+	// AppearancesAddressIndex makes Setup create index by address (see sql.CreateAppearancesAddressIndex)
+	AppearancesAddressIndex bool
+
+	// MaxConnections makes Connect open a pool of up to MaxConnections connections.
+	// Zero means a single connection (preferred behind RDS Proxy).
+	MaxConnections int32
+	// StatementTimeout is set as statement_timeout of every connection, zero means server default
+	StatementTimeout time.Duration
+	// PasswordFunc, if set, is called before opening each connection to get a fresh
+	// password (e.g. IAM auth token). It takes precedence over Password.
+	PasswordFunc func(ctx context.Context) (string, error)
+	// Replicas are read replicas (host or host:port) sharing credentials with the primary.
+	// Fetch functions serving API requests read from them (see reader).
+	Replicas []string
+
+	conn Querier
+	// replicas are shared with other connections when Connection comes from Provider
+	replicas *replicaSet
+	// ownsReplicas is true when Connect created replicas, so Close has to close them
+	ownsReplicas bool
+	// partitions are shared with other connections when Connection comes from Provider
+	partitions *appearancesPartitions
+	batchSize    int
+	// binaryAddresses is true when addresses are stored as BYTEA
+	binaryAddresses bool
+	// addressFormatKnown is true when binaryAddresses is already detected (Provider copies
+	// it to new connections, so that we don't query the catalog on every request)
+	addressFormatKnown bool
 }
 
 func (c *Connection) Connect(ctx context.Context) (err error) {
@@ -26,26 +71,89 @@ func (c *Connection) Connect(ctx context.Context) (err error) {
 	if c.batchSize == 0 {
 		c.batchSize = 5000
 	}
+	if c.partitions == nil {
+		c.partitions = &appearancesPartitions{}
+	}
+	if c.replicas == nil {
+		if c.replicas, err = newReplicaSet(c.Replicas, c.Port); err != nil {
+			return fmt.Errorf("connection.Connect: %w", err)
+		}
+		c.ownsReplicas = c.replicas != nil
+	}
+	if c.MaxConnections > 0 {
+		err = c.connectPool(ctx)
+	} else {
+		err = c.connectSingle(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("connection.Connect: %w", err)
+	}
+	if c.addressFormatKnown {
+		return
+	}
+	if err = c.detectAddressFormat(ctx); err != nil {
+		return fmt.Errorf("connection.Connect: detecting address format: %w", err)
+	}
+	return
+}
+
+func (c *Connection) connectSingle(ctx context.Context) (err error) {
 	connConfig, err := pgx.ParseConfig(c.dsn())
 	if err != nil {
-		return fmt.Errorf("connection.Connect: parsing db config: %w", err)
+		return fmt.Errorf("parsing db config: %w", err)
+	}
+	c.configure(connConfig)
+	if c.PasswordFunc != nil {
+		if connConfig.Password, err = c.PasswordFunc(ctx); err != nil {
+			return fmt.Errorf("getting password: %w", err)
+		}
 	}
-	// TODO: enabling this can protect us from RDS Proxy pinning, but it has downsides.
-	// TODO: Is it needed?
-	connConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
-	// c.conn, err = pgx.Connect(ctx, c.dsn())
 	c.conn, err = pgx.ConnectConfig(ctx, connConfig)
+	return
+}
+
+func (c *Connection) connectPool(ctx context.Context) (err error) {
+	poolConfig, err := pgxpool.ParseConfig(c.dsn())
 	if err != nil {
-		return fmt.Errorf("connection.Connect: %w", err)
+		return fmt.Errorf("parsing db pool config: %w", err)
 	}
+	poolConfig.MaxConns = c.MaxConnections
+	c.configure(poolConfig.ConnConfig)
+	if c.PasswordFunc != nil {
+		// Tokens expire, so we need a new one for every new connection
+		poolConfig.BeforeConnect = func(ctx context.Context, connConfig *pgx.ConnConfig) (err error) {
+			connConfig.Password, err = c.PasswordFunc(ctx)
+			return
+		}
+	}
+	c.conn, err = pgxpool.NewWithConfig(ctx, poolConfig)
 	return
 }
 
+func (c *Connection) configure(connConfig *pgx.ConnConfig) {
+	// TODO: enabling this can protect us from RDS Proxy pinning, but it has downsides.
+	// TODO: Is it needed?
+	connConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+	if c.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(c.StatementTimeout.Milliseconds(), 10)
+	}
+}
+
 func (c *Connection) Close(ctx context.Context) error {
-	return c.conn.Close(ctx)
+	if c.ownsReplicas {
+		c.replicas.close(ctx)
+	}
+	switch conn := c.conn.(type) {
+	case *pgxpool.Pool:
+		conn.Close()
+	case *pgx.Conn:
+		return conn.Close(ctx)
+	}
+	return nil
 }
 
-func (c *Connection) Db() *pgx.Conn {
+// Db returns the underlying connection or pool
+func (c *Connection) Db() Querier {
 	return c.conn
 }
 
@@ -61,26 +169,58 @@ func (c *Connection) BatchSize() int {
 	return c.batchSize
 }
 
-func (c *Connection) Setup() (err error) {
-	if _, err = c.conn.Exec(context.TODO(), sql.CreateTableAddresses(c.AddressesTableName())); err != nil {
+func (c *Connection) Setup(ctx context.Context) (err error) {
+	if _, err = c.conn.Exec(ctx, sql.CreateTableAddresses(c.AddressesTableName())); err != nil {
 		return fmt.Errorf("creating address table (%s): %w", c.Chain, err)
 	}
-	if _, err = c.conn.Exec(context.TODO(), sql.CreateTableAppearances(c.AppearancesTableName(), c.AddressesTableName())); err != nil {
-		return fmt.Errorf("creating appearances table (%s): %w", c.Chain, err)
+	if c.AppearancesPartitionSize > 0 {
+		if _, err = c.conn.Exec(ctx, sql.CreateTablePartitionedAppearances(c.AppearancesTableName(), c.AddressesTableName())); err != nil {
+			return fmt.Errorf("creating partitioned appearances table (%s): %w", c.Chain, err)
+		}
+		if err = EnsureAppearancesPartitions(ctx, c, 0); err != nil {
+			return fmt.Errorf("creating appearances partitions (%s): %w", c.Chain, err)
+		}
+	} else {
+		if _, err = c.conn.Exec(ctx, sql.CreateTableAppearances(c.AppearancesTableName(), c.AddressesTableName())); err != nil {
+			return fmt.Errorf("creating appearances table (%s): %w", c.Chain, err)
+		}
 	}
-	if _, err = c.conn.Exec(context.TODO(), sql.CreateAppearancesOrderIndex(c.AppearancesTableName())); err != nil {
+	if _, err = c.conn.Exec(ctx, sql.CreateAppearancesOrderIndex(c.AppearancesTableName())); err != nil {
 		return fmt.Errorf("creating appearances order index (%s): %w", c.Chain, err)
 	}
+	if c.AppearancesAddressIndex {
+		if _, err = c.conn.Exec(ctx, sql.CreateAppearancesAddressIndex(c.AppearancesTableName(), false)); err != nil {
+			return fmt.Errorf("creating appearances address index (%s): %w", c.Chain, err)
+		}
+	}
 
-	if _, err = c.conn.Exec(context.TODO(), sql.CreateTableChunks(c.ChunksTableName())); err != nil {
+	if _, err = c.conn.Exec(ctx, sql.CreateTableChunks(c.ChunksTableName())); err != nil {
 		return fmt.Errorf("creating chunks table (%s): %w", c.Chain, err)
 	}
+	if _, err = c.conn.Exec(ctx, sql.CreateTableStatus(c.StatusTableName())); err != nil {
+		return fmt.Errorf("creating status table (%s): %w", c.Chain, err)
+	}
+	if _, err = c.conn.Exec(ctx, sql.CreateDataVersionSequence(c.DataVersionSequenceName())); err != nil {
+		return fmt.Errorf("creating data version sequence (%s): %w", c.Chain, err)
+	}
+	if _, err = c.conn.Exec(ctx, sql.CreateTableBlocks(c.BlocksTableName())); err != nil {
+		return fmt.Errorf("creating blocks table (%s): %w", c.Chain, err)
+	}
+	if _, err = c.conn.Exec(ctx, sql.CreateBlocksTimestampIndex(c.BlocksTableName())); err != nil {
+		return fmt.Errorf("creating blocks timestamp index (%s): %w", c.Chain, err)
+	}
+	if _, err = c.conn.Exec(ctx, sql.CreateTableTransactions(c.TransactionsTableName())); err != nil {
+		return fmt.Errorf("creating transactions table (%s): %w", c.Chain, err)
+	}
+	if err = c.detectAddressFormat(ctx); err != nil {
+		return fmt.Errorf("detecting address format (%s): %w", c.Chain, err)
+	}
 	return nil
 }
 
-func (c *Connection) CountAppearances() (count int, err error) {
+func (c *Connection) CountAppearances(ctx context.Context) (count int, err error) {
 	rows, err := c.conn.Query(
-		context.TODO(),
+		ctx,
 		fmt.Sprintf(
 			"select count(*) from %s",
 			pgx.Identifier.Sanitize(pgx.Identifier{c.AppearancesTableName()}),
@@ -93,8 +233,44 @@ func (c *Connection) CountAppearances() (count int, err error) {
 	return
 }
 
+// IsTimeout returns true if err was caused by a query running out of time, either because
+// ctx deadline passed or because of statement_timeout
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	// 57014 is query_canceled
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+// dsn builds keyword/value connection string. Empty values are left out, because
+// "password= dbname=x" would be parsed as password "dbname=x" (password is empty
+// when we use PasswordFunc).
 func (c *Connection) dsn() string {
-	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d", c.Host, c.User, c.Password, c.Database, c.Port)
+	params := []struct {
+		key   string
+		value string
+	}{
+		{"host", c.Host},
+		{"user", c.User},
+		{"password", c.Password},
+		{"dbname", c.Database},
+		{"port", strconv.Itoa(c.Port)},
+	}
+	parts := make([]string, 0, len(params))
+	for _, param := range params {
+		if param.value == "" {
+			continue
+		}
+		parts = append(parts, param.key+"="+dsnValue(param.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+// dsnValue quotes connection string value, escaping backslashes and single quotes
+func dsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
 
 func (c *Connection) validate() error {
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsTimeout(t *testing.T) {
+	if !IsTimeout(fmt.Errorf("query: %w", context.DeadlineExceeded)) {
+		t.Fatal("expected deadline to be a timeout")
+	}
+	if !IsTimeout(fmt.Errorf("query: %w", &pgconn.PgError{Code: "57014"})) {
+		t.Fatal("expected query_canceled to be a timeout")
+	}
+	if IsTimeout(&pgconn.PgError{Code: "23505"}) {
+		t.Fatal("unique violation is not a timeout")
+	}
+	if IsTimeout(errors.New("connection refused")) {
+		t.Fatal("unexpected timeout")
+	}
+}
+
+func TestConnection_dsn(t *testing.T) {
+	special := &Connection{
+		Host:     "localhost",
+		Port:     5432,
+		User:     "key",
+		Password: `p a's\s`,
+		Database: "index",
+	}
+	config, err := pgx.ParseConfig(special.dsn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Password != `p a's\s` || config.Database != "index" {
+		t.Fatal("wrong config:", config.Password, config.Database)
+	}
+}
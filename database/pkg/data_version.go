@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+	"github.com/jackc/pgx/v5"
+)
+
+// FetchDataVersion returns a number that changes whenever appearances or blocks are written,
+// including past blocks (backfills, imports, enrichment). Responses read at the same data
+// version are the same, so it can be used in cache keys. Like status, it's read from the primary.
+func FetchDataVersion(ctx context.Context, c *Connection) (result uint64, err error) {
+	err = c.conn.QueryRow(ctx, sql.SelectDataVersion(c.DataVersionSequenceName())).Scan(&result)
+	return
+}
+
+// bumpDataVersion has to be called after the write is committed. Otherwise a response read
+// before the commit could be cached under the new version.
+func bumpDataVersion(ctx context.Context, c *Connection) (err error) {
+	_, err = c.conn.Exec(ctx, sql.BumpDataVersion(c.DataVersionSequenceName()))
+	return
+}
+
+// QueueBumpDataVersion adds data version bump to batch, e.g. to one sent after writing
+// appearances with a separate connection
+func QueueBumpDataVersion(batch *pgx.Batch, c *Connection) {
+	batch.Queue(sql.BumpDataVersion(c.DataVersionSequenceName()))
+}
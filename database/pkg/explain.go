@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+	"github.com/jackc/pgx/v5"
+)
+
+// QueryPlan is a summary of EXPLAIN (ANALYZE) output
+type QueryPlan struct {
+	Name string
+	// SeqScans lists relations read by sequential scans
+	SeqScans      []string
+	ExecutionTime float64
+	SharedHit     int64
+	SharedRead    int64
+}
+
+type planNode struct {
+	NodeType     string     `json:"Node Type"`
+	RelationName string     `json:"Relation Name"`
+	SharedHit    int64      `json:"Shared Hit Blocks"`
+	SharedRead   int64      `json:"Shared Read Blocks"`
+	Plans        []planNode `json:"Plans"`
+}
+
+type explainOutput struct {
+	Plan          planNode `json:"Plan"`
+	ExecutionTime float64  `json:"Execution Time"`
+}
+
+// parsePlan reads EXPLAIN (FORMAT JSON) output
+func parsePlan(name string, raw []byte) (plan QueryPlan, err error) {
+	var outputs []explainOutput
+	if err = json.Unmarshal(raw, &outputs); err != nil {
+		return
+	}
+	if len(outputs) != 1 {
+		err = fmt.Errorf("expected single plan, got %d", len(outputs))
+		return
+	}
+
+	plan.Name = name
+	plan.ExecutionTime = outputs[0].ExecutionTime
+	// buffers of the top node include its children
+	plan.SharedHit = outputs[0].Plan.SharedHit
+	plan.SharedRead = outputs[0].Plan.SharedRead
+
+	var walk func(node *planNode)
+	walk = func(node *planNode) {
+		if node.NodeType == "Seq Scan" {
+			plan.SeqScans = append(plan.SeqScans, node.RelationName)
+		}
+		for i := range node.Plans {
+			walk(&node.Plans[i])
+		}
+	}
+	walk(&outputs[0].Plan)
+	return
+}
+
+// ExplainQuery runs EXPLAIN (ANALYZE, BUFFERS) for query. The query is executed, so it
+// should only be used with Select builders.
+func ExplainQuery(ctx context.Context, c *Connection, name string, query string, args pgx.NamedArgs) (plan QueryPlan, err error) {
+	var raw []byte
+	if err = c.conn.QueryRow(ctx, sql.Explain(query), args).Scan(&raw); err != nil {
+		return
+	}
+	return parsePlan(name, raw)
+}
+
+func FetchSampleAddresses(ctx context.Context, c *Connection, limit uint) (results []string, err error) {
+	rows, err := c.conn.Query(
+		ctx,
+		sql.SelectSampleAddresses(c.AddressesTableName()),
+		pgx.NamedArgs{
+			"limit": limit,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	addresses, err := pgx.CollectRows[dbAddress](rows, pgx.RowTo[dbAddress])
+	if err != nil {
+		return
+	}
+	return addressStrings(addresses), nil
+}
+
+// ExplainAddressQueries explains all Select builders used to serve requests for address,
+// with arguments similar to the ones sent by the API
+func ExplainAddressQueries(ctx context.Context, c *Connection, address string, lastBlock uint, pageSize uint) (plans []QueryPlan, err error) {
+	addressArg, err := c.addressArg(address)
+	if err != nil {
+		return
+	}
+	emptyAddressArg, _ := c.addressArg("")
+	appearancesTable := c.AppearancesTableName()
+	addressesTable := c.AddressesTableName()
+
+	base := pgx.NamedArgs{
+		"address":    addressArg,
+		"firstBlock": 0,
+		"lastBlock":  lastBlock,
+		"pageSize":   pageSize,
+		"watermark":  watermarkArg(0),
+	}
+
+	// use the first page to get realistic page cursor, block and transaction
+	firstPage, _, err := FetchAppearancesFirstPage(ctx, c, false, address, 0, lastBlock, pageSize)
+	if err != nil {
+		return
+	}
+	var last Appearance
+	if len(firstPage) > 0 {
+		last = firstPage[len(firstPage)-1]
+	}
+	withCursor := withArgs(base, pgx.NamedArgs{
+		"appBlockNumber":      last.BlockNumber,
+		"appTransactionIndex": last.TransactionIndex,
+	})
+	inBlock := pgx.NamedArgs{
+		"blockNumber":           last.BlockNumber,
+		"transactionIndex":      last.TransactionIndex,
+		"afterTransactionIndex": 0,
+		"afterAddress":          emptyAddressArg,
+		"pageSize":              pageSize,
+	}
+	blockNumbers := pgx.NamedArgs{
+		"blockNumbers": []uint32{last.BlockNumber},
+		"txIds":        []uint32{last.TransactionIndex},
+	}
+
+	return explainChecks(ctx, c, []explainCheck{
+		{"SelectAppearancesFirstPage", sql.SelectAppearancesFirstPage(appearancesTable, addressesTable), base},
+		{"SelectAppearancesEarliestPage", sql.SelectAppearancesEarliestPage(appearancesTable, addressesTable), base},
+		{"SelectAppearancesNextPage", sql.SelectAppearancesNextPage(appearancesTable, addressesTable), withCursor},
+		{"SelectAppearancesPreviousPage", sql.SelectAppearancesPreviousPage(appearancesTable, addressesTable), withCursor},
+		{"SelectAppearancesDatasetBounds", sql.SelectAppearancesDatasetBounds(appearancesTable, addressesTable), base},
+		{"SelectAddressesInTx", sql.SelectAddressesInTx(appearancesTable, addressesTable), inBlock},
+		{"SelectAddressesInBlock", sql.SelectAddressesInBlock(appearancesTable, addressesTable), inBlock},
+		{"SelectBlockTimestamps", sql.SelectBlockTimestamps(c.BlocksTableName()), blockNumbers},
+		{"SelectTransactionHashes", sql.SelectTransactionHashes(c.TransactionsTableName()), blockNumbers},
+	})
+}
+
+// ExplainServiceQueries explains Select builders that don't depend on address: resolving
+// timestamps, watermark, status and the last chunk
+func ExplainServiceQueries(ctx context.Context, c *Connection, timestamp uint) (plans []QueryPlan, err error) {
+	atTimestamp := pgx.NamedArgs{
+		"timestamp": timestamp,
+	}
+
+	return explainChecks(ctx, c, []explainCheck{
+		{"SelectBlockAtOrBeforeTimestamp", sql.SelectBlockAtOrBeforeTimestamp(c.BlocksTableName()), atTimestamp},
+		{"SelectBlockAtOrAfterTimestamp", sql.SelectBlockAtOrAfterTimestamp(c.BlocksTableName()), atTimestamp},
+		{"SelectBlocksBounds", sql.SelectBlocksBounds(c.BlocksTableName()), nil},
+		{"SelectWatermark", sql.SelectWatermark(), nil},
+		{"SelectStatus", sql.SelectStatus(c.StatusTableName()), nil},
+		{"SelectLastChunk", sql.SelectLastChunk(c.ChunksTableName()), nil},
+	})
+}
+
+type explainCheck struct {
+	name  string
+	query string
+	args  pgx.NamedArgs
+}
+
+func explainChecks(ctx context.Context, c *Connection, checks []explainCheck) (plans []QueryPlan, err error) {
+	for _, check := range checks {
+		var plan QueryPlan
+		plan, err = ExplainQuery(ctx, c, check.name, check.query, check.args)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", check.name, err)
+			return
+		}
+		plans = append(plans, plan)
+	}
+	return
+}
+
+func withArgs(base pgx.NamedArgs, extra pgx.NamedArgs) pgx.NamedArgs {
+	result := make(pgx.NamedArgs, len(base)+len(extra))
+	maps.Copy(result, base)
+	maps.Copy(result, extra)
+	return result
+}
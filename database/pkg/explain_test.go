@@ -0,0 +1,43 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parsePlan(t *testing.T) {
+	raw := []byte(`[
+  {
+    "Plan": {
+      "Node Type": "Limit",
+      "Shared Hit Blocks": 12,
+      "Shared Read Blocks": 3,
+      "Plans": [
+        {
+          "Node Type": "Nested Loop",
+          "Plans": [
+            {"Node Type": "Index Scan", "Relation Name": "mainnet_addresses"},
+            {"Node Type": "Seq Scan", "Relation Name": "mainnet_appearances"}
+          ]
+        }
+      ]
+    },
+    "Planning Time": 0.1,
+    "Execution Time": 1.5
+  }
+]`)
+
+	plan, err := parsePlan("test", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan.SeqScans, []string{"mainnet_appearances"}) {
+		t.Fatal("wrong seq scans:", plan.SeqScans)
+	}
+	if plan.ExecutionTime != 1.5 {
+		t.Fatal("wrong execution time:", plan.ExecutionTime)
+	}
+	if plan.SharedHit != 12 || plan.SharedRead != 3 {
+		t.Fatal("wrong buffers:", plan.SharedHit, plan.SharedRead)
+	}
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrInvalidAddressPrefix = errors.New("address prefix has to be lowercase hex without 0x")
+
+var addressPrefixPattern = regexp.MustCompile(`^[0-9a-f]{0,40}$`)
+
+// ExportFilter limits exported rows
+type ExportFilter struct {
+	// FirstBlock and LastBlock (inclusive) limit appearances, nil means no limit
+	FirstBlock *uint
+	LastBlock  *uint
+	// AddressPrefix selects addresses starting with the given hex digits (without 0x)
+	AddressPrefix string
+}
+
+// CopyAppearancesTo writes appearances as CSV with header (address, block_number, tx_id)
+// to w. The data is streamed, so it can be used with tables of any size.
+func CopyAppearancesTo(ctx context.Context, c *Connection, w io.Writer, filter ExportFilter) (rows int64, err error) {
+	conditions, err := filter.conditions(c, "addrs.address", "apps.block_number")
+	if err != nil {
+		return
+	}
+	return c.copyTo(ctx, w, sql.CopyAppearancesTo(c.AppearancesTableName(), c.AddressesTableName(), c.binaryAddresses, conditions))
+}
+
+// CopyAddressesTo writes addresses as CSV with header (id, address) to w. Block filters
+// are ignored.
+func CopyAddressesTo(ctx context.Context, c *Connection, w io.Writer, filter ExportFilter) (rows int64, err error) {
+	filter.FirstBlock = nil
+	filter.LastBlock = nil
+	conditions, err := filter.conditions(c, "addrs.address", "")
+	if err != nil {
+		return
+	}
+	return c.copyTo(ctx, w, sql.CopyAddressesTo(c.AddressesTableName(), c.binaryAddresses, conditions))
+}
+
+// conditions returns SQL conditions for the filter. The values are validated numbers
+// and hex strings, so it's safe to format them into the query.
+func (f *ExportFilter) conditions(c *Connection, addressColumn string, blockColumn string) (conditions []string, err error) {
+	if f.FirstBlock != nil {
+		conditions = append(conditions, fmt.Sprintf("%s >= %d", blockColumn, *f.FirstBlock))
+	}
+	if f.LastBlock != nil {
+		conditions = append(conditions, fmt.Sprintf("%s <= %d", blockColumn, *f.LastBlock))
+	}
+
+	if f.AddressPrefix == "" {
+		return
+	}
+	if !addressPrefixPattern.MatchString(f.AddressPrefix) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAddressPrefix, f.AddressPrefix)
+	}
+	// Range condition lets the database use address index
+	lower, upper := prefixBounds(f.AddressPrefix)
+	literal := func(hexDigits string) string {
+		if c.binaryAddresses {
+			// odd number of digits is padded, the bounds are compared as bytes anyway
+			if len(hexDigits)%2 == 1 {
+				hexDigits += "0"
+			}
+			return fmt.Sprintf(`'\x%s'::bytea`, hexDigits)
+		}
+		return fmt.Sprintf(`'0x%s'`, hexDigits)
+	}
+	conditions = append(conditions, fmt.Sprintf("%s >= %s", addressColumn, literal(lower)))
+	if upper != "" {
+		conditions = append(conditions, fmt.Sprintf("%s < %s", addressColumn, literal(upper)))
+	}
+	return
+}
+
+// prefixBounds returns the smallest hex string with the prefix and the smallest one
+// greater than all strings with the prefix (empty if there is none, e.g. for "ff")
+func prefixBounds(prefix string) (lower string, upper string) {
+	lower = prefix
+	value, _ := new(big.Int).SetString(prefix, 16)
+	value.Add(value, big.NewInt(1))
+	upper = fmt.Sprintf("%0*x", len(prefix), value)
+	if len(upper) > len(prefix) {
+		upper = ""
+	}
+	return
+}
+
+func (c *Connection) copyTo(ctx context.Context, w io.Writer, query string) (int64, error) {
+	var pgConn *pgconn.PgConn
+	switch conn := c.conn.(type) {
+	case *pgx.Conn:
+		pgConn = conn.PgConn()
+	case *pgxpool.Pool:
+		pooled, err := conn.Acquire(ctx)
+		if err != nil {
+			return 0, err
+		}
+		defer pooled.Release()
+		pgConn = pooled.Conn().PgConn()
+	default:
+		return 0, errors.New("copy: unsupported connection type")
+	}
+
+	tag, err := pgConn.CopyTo(ctx, w, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_prefixBounds(t *testing.T) {
+	tests := []struct {
+		prefix string
+		lower  string
+		upper  string
+	}{
+		{"00", "00", "01"},
+		{"a", "a", "b"},
+		{"0f", "0f", "10"},
+		{"ff", "ff", ""},
+	}
+	for _, tt := range tests {
+		lower, upper := prefixBounds(tt.prefix)
+		if lower != tt.lower || upper != tt.upper {
+			t.Fatal("wrong bounds for", tt.prefix, lower, upper)
+		}
+	}
+}
+
+func TestExportFilter_conditions(t *testing.T) {
+	first := uint(10)
+	last := uint(20)
+	filter := ExportFilter{FirstBlock: &first, LastBlock: &last, AddressPrefix: "0f"}
+
+	conditions, err := filter.conditions(&Connection{}, "address", "block_number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(conditions, " AND "); s != "block_number >= 10 AND block_number <= 20 AND address >= '0x0f' AND address < '0x10'" {
+		t.Fatal("wrong text conditions:", s)
+	}
+
+	filter = ExportFilter{AddressPrefix: "f"}
+	conditions, err = filter.conditions(&Connection{binaryAddresses: true}, "address", "block_number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(conditions, " AND "); s != `address >= '\xf0'::bytea` {
+		t.Fatal("wrong binary conditions:", s)
+	}
+
+	filter = ExportFilter{AddressPrefix: "0x'; DROP"}
+	if _, err := filter.conditions(&Connection{}, "address", "block_number"); !errors.Is(err, ErrInvalidAddressPrefix) {
+		t.Fatal("expected ErrInvalidAddressPrefix, got", err)
+	}
+}
@@ -0,0 +1,285 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrImportRowCount = errors.New("imported row count mismatch")
+var ErrImportNoAddressMap = errors.New("appearances with address ids require addresses to be imported first")
+
+// importChunkRows is the number of rows imported in a single transaction
+const importChunkRows = 100_000
+
+// StagingSuffix is appended to table names when importing into staging tables
+const StagingSuffix = "_staging"
+
+// ImportTarget names tables that Importer writes to
+type ImportTarget struct {
+	AddressesTable   string
+	AppearancesTable string
+}
+
+// ImportTarget returns the live tables or, if staging is true, their staging counterparts
+func (c *Connection) ImportTarget(staging bool) ImportTarget {
+	target := ImportTarget{
+		AddressesTable:   c.AddressesTableName(),
+		AppearancesTable: c.AppearancesTableName(),
+	}
+	if staging {
+		target.AddressesTable += StagingSuffix
+		target.AppearancesTable += StagingSuffix
+	}
+	return target
+}
+
+type ImportResult struct {
+	// Rows is the number of rows read from the source
+	Rows int64
+	// Inserted is the number of rows that were not in the target table before
+	Inserted int64
+}
+
+// Importer loads exported files with COPY FROM, committing every importChunkRows rows.
+// Address ids of the exported database are remapped: addresses get new ids in the target
+// table and appearances are linked to them. All imports run on a single connection,
+// because the id map is kept in a temporary table.
+type Importer struct {
+	c       *Connection
+	conn    *pgx.Conn
+	release func()
+	target  ImportTarget
+	// binaryAddresses is the address format of the target addresses table
+	binaryAddresses bool
+	hasAddressMap   bool
+	chunkRows       int64
+}
+
+// NewImporter creates target tables if they don't exist (only staging tables are
+// expected to be missing)
+func NewImporter(ctx context.Context, c *Connection, target ImportTarget) (i *Importer, err error) {
+	i = &Importer{c: c, target: target, release: func() {}, chunkRows: importChunkRows}
+	switch conn := c.conn.(type) {
+	case *pgx.Conn:
+		i.conn = conn
+	case *pgxpool.Pool:
+		pooled, err := conn.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		i.conn = pooled.Conn()
+		i.release = pooled.Release
+	default:
+		return nil, errors.New("import: unsupported connection type")
+	}
+	defer func() {
+		if err != nil {
+			i.Close()
+		}
+	}()
+
+	var exists bool
+	if err = i.conn.QueryRow(ctx, sql.SelectRelationExists(target.AddressesTable)).Scan(&exists); err != nil {
+		return
+	}
+	if !exists {
+		if _, err = i.conn.Exec(ctx, sql.CreateTableAddresses(target.AddressesTable)); err != nil {
+			return nil, fmt.Errorf("import: creating %s: %w", target.AddressesTable, err)
+		}
+	}
+	if err = i.conn.QueryRow(ctx, sql.SelectRelationExists(target.AppearancesTable)).Scan(&exists); err != nil {
+		return
+	}
+	if !exists {
+		if _, err = i.conn.Exec(ctx, sql.CreateTableAppearances(target.AppearancesTable, target.AddressesTable)); err != nil {
+			return nil, fmt.Errorf("import: creating %s: %w", target.AppearancesTable, err)
+		}
+	}
+
+	if err = i.conn.QueryRow(ctx, sql.SelectAddressesBinary(target.AddressesTable)).Scan(&i.binaryAddresses); err != nil {
+		return
+	}
+	if _, err = i.conn.Exec(ctx, sql.CreateImportAddressMap()); err != nil {
+		return
+	}
+	return
+}
+
+func (i *Importer) Close() {
+	i.release()
+}
+
+// ImportAddresses loads rows of (id, address). Ids are only used to remap appearances
+// imported later by ImportAppearanceIds.
+func (i *Importer) ImportAddresses(ctx context.Context, source pgx.CopyFromSource) (result ImportResult, err error) {
+	result, err = i.inChunks(source, func(chunk pgx.CopyFromSource) (result ImportResult, err error) {
+		if result.Rows, err = i.load(ctx, sql.ImportAddressesTableName, []string{"id", "address"}, chunk); err != nil {
+			return
+		}
+		err = pgx.BeginFunc(ctx, i.conn, func(tx pgx.Tx) (err error) {
+			tag, err := tx.Exec(ctx, sql.InsertImportedAddresses(sql.ImportAddressesTableName, i.target.AddressesTable, i.binaryAddresses))
+			if err != nil {
+				return
+			}
+			result.Inserted = tag.RowsAffected()
+			if _, err = tx.Exec(ctx, sql.InsertImportAddressMap(i.target.AddressesTable, i.binaryAddresses)); err != nil {
+				return
+			}
+			var mapped int64
+			if err = tx.QueryRow(ctx, sql.CountImportAddressMap()).Scan(&mapped); err != nil {
+				return
+			}
+			if mapped != result.Rows {
+				return fmt.Errorf("%w: %d addresses read, %d mapped", ErrImportRowCount, result.Rows, mapped)
+			}
+			return
+		})
+		return
+	})
+	if err == nil {
+		i.hasAddressMap = true
+	}
+	return
+}
+
+// ImportAppearances loads rows of (address, block_number, tx_id). Missing addresses
+// are inserted.
+func (i *Importer) ImportAppearances(ctx context.Context, source pgx.CopyFromSource) (ImportResult, error) {
+	return i.importAppearances(ctx, source, false)
+}
+
+// ImportAppearanceIds loads rows of (address_id, block_number, tx_id), where address_id
+// refers to addresses loaded by ImportAddresses
+func (i *Importer) ImportAppearanceIds(ctx context.Context, source pgx.CopyFromSource) (ImportResult, error) {
+	if !i.hasAddressMap {
+		return ImportResult{}, ErrImportNoAddressMap
+	}
+	return i.importAppearances(ctx, source, true)
+}
+
+// importAppearances commits every chunk separately. When importing into the live table,
+// status' last indexed block and the data version are updated after each chunk, so that
+// readers see imported appearances right away.
+func (i *Importer) importAppearances(ctx context.Context, source pgx.CopyFromSource, byId bool) (ImportResult, error) {
+	firstColumn := "address"
+	if byId {
+		firstColumn = "address_id"
+	}
+	live := i.target.AppearancesTable == i.c.AppearancesTableName()
+	return i.inChunks(source, func(chunk pgx.CopyFromSource) (result ImportResult, err error) {
+		if result.Rows, err = i.load(ctx, sql.ImportAppearancesTableName, []string{firstColumn, "block_number", "tx_id"}, chunk); err != nil {
+			return
+		}
+		var maxBlock uint32
+		if err = i.conn.QueryRow(ctx, sql.SelectImportedMaxBlock()).Scan(&maxBlock); err != nil {
+			return
+		}
+		// partitions are created outside of the transaction, so that they are remembered
+		// only once they exist (see EnsureAppearancesPartitions)
+		if live {
+			if err = EnsureAppearancesPartitions(ctx, i.c, maxBlock); err != nil {
+				return
+			}
+		}
+
+		err = pgx.BeginFunc(ctx, i.conn, func(tx pgx.Tx) (err error) {
+			if !byId {
+				if _, err = tx.Exec(ctx, sql.InsertImportedAddresses(sql.ImportAppearancesTableName, i.target.AddressesTable, i.binaryAddresses)); err != nil {
+					return
+				}
+			}
+			tag, err := tx.Exec(ctx, sql.InsertImportedAppearances(i.target.AppearancesTable, i.target.AddressesTable, i.binaryAddresses, byId))
+			if err != nil {
+				return
+			}
+			result.Inserted = tag.RowsAffected()
+
+			var present int64
+			if err = tx.QueryRow(ctx, sql.CountImportedAppearances(i.target.AppearancesTable, i.target.AddressesTable, i.binaryAddresses, byId)).Scan(&present); err != nil {
+				return
+			}
+			if present != result.Rows {
+				return fmt.Errorf("%w: %d appearances read, %d present in %s", ErrImportRowCount, result.Rows, present, i.target.AppearancesTable)
+			}
+			return
+		})
+		if err != nil || !live || result.Rows == 0 {
+			return
+		}
+		if err = updateStatusLastIndexedBlock(ctx, i.c, maxBlock); err != nil {
+			return
+		}
+		err = bumpDataVersion(ctx, i.c)
+		return
+	})
+}
+
+// inChunks calls importChunk with consecutive chunks of source, up to chunkRows rows each.
+// Every chunk is committed separately: a long transaction would hold back the watermark
+// (see FetchWatermark), hiding all appearances inserted in the meantime from readers.
+func (i *Importer) inChunks(source pgx.CopyFromSource, importChunk func(pgx.CopyFromSource) (ImportResult, error)) (result ImportResult, err error) {
+	for {
+		chunk := &chunkSource{CopyFromSource: source, limit: i.chunkRows}
+		chunkResult, err := importChunk(chunk)
+		result.Rows += chunkResult.Rows
+		result.Inserted += chunkResult.Inserted
+		if err != nil || chunk.done {
+			return result, err
+		}
+	}
+}
+
+// load copies source into temporary table and checks that all rows were copied. Temporary
+// tables are only visible to our session, so it doesn't need a transaction.
+func (i *Importer) load(ctx context.Context, tableName string, columns []string, source pgx.CopyFromSource) (rows int64, err error) {
+	if _, err = i.conn.Exec(ctx, sql.CreateImportTable(tableName, columns)); err != nil {
+		return
+	}
+	counted := &countingSource{CopyFromSource: source}
+	if rows, err = i.conn.CopyFrom(ctx, pgx.Identifier{tableName}, columns, counted); err != nil {
+		return
+	}
+	if rows != counted.rows {
+		err = fmt.Errorf("%w: %d rows read, %d copied", ErrImportRowCount, counted.rows, rows)
+	}
+	return
+}
+
+type countingSource struct {
+	pgx.CopyFromSource
+	rows int64
+}
+
+func (s *countingSource) Next() bool {
+	if !s.CopyFromSource.Next() {
+		return false
+	}
+	s.rows++
+	return true
+}
+
+// chunkSource reads up to limit rows of CopyFromSource
+type chunkSource struct {
+	pgx.CopyFromSource
+	limit int64
+	rows  int64
+	// done is true when CopyFromSource has no more rows
+	done bool
+}
+
+func (s *chunkSource) Next() bool {
+	if s.rows >= s.limit {
+		return false
+	}
+	if !s.CopyFromSource.Next() {
+		s.done = true
+		return false
+	}
+	s.rows++
+	return true
+}
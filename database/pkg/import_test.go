@@ -0,0 +1,50 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestConnection_ImportTarget(t *testing.T) {
+	c := &Connection{Chain: "mainnet"}
+	if target := c.ImportTarget(false); target.AddressesTable != "mainnet_addresses" || target.AppearancesTable != "mainnet_appearances" {
+		t.Fatal("wrong target:", target)
+	}
+	if target := c.ImportTarget(true); target.AddressesTable != "mainnet_addresses_staging" || target.AppearancesTable != "mainnet_appearances_staging" {
+		t.Fatal("wrong staging target:", target)
+	}
+}
+
+func Test_countingSource(t *testing.T) {
+	source := &countingSource{CopyFromSource: pgx.CopyFromRows([][]any{{"a"}, {"b"}, {"c"}})}
+	for source.Next() {
+	}
+	if source.rows != 3 {
+		t.Fatal("wrong rows:", source.rows)
+	}
+}
+
+func TestImporter_inChunks(t *testing.T) {
+	i := &Importer{chunkRows: 2}
+	var chunks []int64
+	result, err := i.inChunks(
+		pgx.CopyFromRows([][]any{{"a"}, {"b"}, {"c"}, {"d"}, {"e"}}),
+		func(chunk pgx.CopyFromSource) (result ImportResult, err error) {
+			for chunk.Next() {
+				result.Rows++
+			}
+			chunks = append(chunks, result.Rows)
+			return
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 || chunks[0] != 2 || chunks[1] != 2 || chunks[2] != 1 {
+		t.Fatal("wrong chunks:", chunks)
+	}
+	if result.Rows != 5 {
+		t.Fatal("wrong rows:", result.Rows)
+	}
+}
@@ -0,0 +1,364 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
+)
+
+// MemoryStore is AppearanceStore keeping everything in memory. It needs no external
+// services, so it's useful in unit tests and for running Key locally on a small dataset.
+type MemoryStore struct {
+	mutex sync.RWMutex
+	// appearances of every address, ordered by block number and transaction index
+	byAddress map[string][]memoryAppearance
+	// addresses appearing in every block, ordered by transaction index and address
+	byBlock map[uint32][]AddressInBlock
+	// seq works like seq column in Postgres: appearances inserted later get higher values (see FetchWatermark)
+	seq uint64
+	// dataVersion is incremented by every write of appearances or blocks (see FetchDataVersion)
+	dataVersion uint64
+	status      Status
+	// blocks are enriched blocks, their transaction hashes are in txHashes
+	blocks   map[uint32]uint64
+	txHashes map[Appearance]string
+	chunks   map[string]Chunk
+}
+
+type memoryAppearance struct {
+	Appearance
+	seq uint64
+}
+
+var _ AppearanceStore = (*MemoryStore)(nil)
+var _ BlockTimestampIndex = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		byAddress: make(map[string][]memoryAppearance),
+		byBlock:   make(map[uint32][]AddressInBlock),
+		blocks:    make(map[uint32]uint64),
+		txHashes:  make(map[Appearance]string),
+		chunks:    make(map[string]Chunk),
+	}
+}
+
+func (m *MemoryStore) InsertAppearanceBatch(ctx context.Context, apps []queueItem.Appearance) error {
+	if len(apps) == 0 {
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, app := range apps {
+		address := strings.ToLower(app.Address)
+		appearance := Appearance{
+			BlockNumber:      app.BlockNumber,
+			TransactionIndex: app.TransactionIndex,
+		}
+
+		appearances := m.byAddress[address]
+		i, found := slices.BinarySearchFunc(appearances, appearance, func(item memoryAppearance, target Appearance) int {
+			return compareAppearances(item.Appearance, target)
+		})
+		if found {
+			continue
+		}
+		m.seq++
+		m.byAddress[address] = slices.Insert(appearances, i, memoryAppearance{Appearance: appearance, seq: m.seq})
+
+		inBlock := AddressInBlock{TransactionIndex: app.TransactionIndex, Address: address}
+		addresses := m.byBlock[app.BlockNumber]
+		j, _ := slices.BinarySearchFunc(addresses, inBlock, compareAddressesInBlock)
+		m.byBlock[app.BlockNumber] = slices.Insert(addresses, j, inBlock)
+
+		m.status.LastIndexedBlock = max(m.status.LastIndexedBlock, uint(app.BlockNumber))
+	}
+	m.status.UpdatedAt = time.Now()
+	m.dataVersion++
+
+	return nil
+}
+
+func (m *MemoryStore) InsertBlockBatch(ctx context.Context, blocks []Block) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, block := range blocks {
+		if _, ok := m.blocks[block.Number]; ok {
+			continue
+		}
+		m.blocks[block.Number] = block.Timestamp
+		for txId, hash := range block.TransactionHashes {
+			m.txHashes[Appearance{BlockNumber: block.Number, TransactionIndex: uint32(txId)}] = hash
+		}
+	}
+	m.dataVersion++
+	return nil
+}
+
+func (m *MemoryStore) InsertChunkBatch(ctx context.Context, chunks []queueItem.Chunk) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, chunk := range chunks {
+		if _, ok := m.chunks[chunk.Cid]; ok {
+			continue
+		}
+		m.chunks[chunk.Cid] = Chunk{Cid: chunk.Cid, Range: chunk.Range, Author: chunk.Author}
+		// ranges are zero-padded, so string comparison works
+		if chunk.Range > m.status.LastChunkRange {
+			m.status.LastChunkRange = chunk.Range
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) FetchStatus(ctx context.Context) (Status, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	status := m.status
+	status.ReadAt = time.Now()
+	return status, nil
+}
+
+func (m *MemoryStore) FetchDataVersion(ctx context.Context) (uint64, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.dataVersion, nil
+}
+
+func (m *MemoryStore) FetchWatermark(ctx context.Context) (uint64, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.seq, nil
+}
+
+func (m *MemoryStore) FetchAppearancesFirstPage(ctx context.Context, earliest bool, address string, firstBlock uint, lastBlock uint, limit uint) ([]Appearance, uint64, error) {
+	watermark, _ := m.FetchWatermark(ctx)
+	appearances := m.visible(address, firstBlock, lastBlock, 0)
+	if earliest {
+		return descending(firstN(appearances, limit)), watermark, nil
+	}
+	return firstN(descending(appearances), limit), watermark, nil
+}
+
+func (m *MemoryStore) FetchAppearancesPage(ctx context.Context, nextPage bool, address string, firstBlock uint, lastBlock uint, limit uint, appBlockNumber uint, appTransactionIndex uint, watermark uint64) ([]Appearance, error) {
+	cursor := Appearance{BlockNumber: uint32(appBlockNumber), TransactionIndex: uint32(appTransactionIndex)}
+	appearances := m.visible(address, firstBlock, lastBlock, watermark)
+
+	if nextPage {
+		before := slices.DeleteFunc(appearances, func(a Appearance) bool {
+			return compareAppearances(a, cursor) >= 0
+		})
+		return firstN(descending(before), limit), nil
+	}
+
+	after := slices.DeleteFunc(appearances, func(a Appearance) bool {
+		return compareAppearances(a, cursor) <= 0
+	})
+	return descending(firstN(after, limit)), nil
+}
+
+func (m *MemoryStore) FetchAppearancesDatasetBounds(ctx context.Context, address string, firstBlock uint, lastBlock uint) (bounds AppearancesDatasetBounds, err error) {
+	appearances := m.visible(address, firstBlock, lastBlock, 0)
+	if len(appearances) == 0 {
+		err = fmt.Errorf("expected bounds result length == 2, but got %d", 0)
+		return
+	}
+
+	bounds.Latest = appearances[len(appearances)-1]
+	bounds.Earliest = appearances[0]
+	return
+}
+
+func (m *MemoryStore) FetchAddressesInTx(ctx context.Context, blockNumber int, transactionIndex int, afterAddress string, limit uint) (results []string, err error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	afterAddress = strings.ToLower(afterAddress)
+	results = make([]string, 0)
+	for _, item := range m.byBlock[uint32(blockNumber)] {
+		if uint(len(results)) == limit {
+			break
+		}
+		if item.TransactionIndex == uint32(transactionIndex) && item.Address > afterAddress {
+			results = append(results, item.Address)
+		}
+	}
+	return
+}
+
+func (m *MemoryStore) FetchAddressesInBlock(ctx context.Context, blockNumber int, afterTransactionIndex uint32, afterAddress string, limit uint) (results []AddressInBlock, err error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	after := AddressInBlock{TransactionIndex: afterTransactionIndex, Address: strings.ToLower(afterAddress)}
+	results = make([]AddressInBlock, 0)
+	for _, item := range m.byBlock[uint32(blockNumber)] {
+		if uint(len(results)) == limit {
+			break
+		}
+		if compareAddressesInBlock(item, after) > 0 {
+			results = append(results, item)
+		}
+	}
+	return
+}
+
+func (m *MemoryStore) FetchLastChunk(ctx context.Context) (result Chunk, found bool, err error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, chunk := range m.chunks {
+		if !found || chunk.Range > result.Range {
+			result = chunk
+			found = true
+		}
+	}
+	return
+}
+
+func (m *MemoryStore) FetchBlockTimestamps(ctx context.Context, blockNumbers []uint32) (map[uint32]uint64, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	results := make(map[uint32]uint64, len(blockNumbers))
+	for _, blockNumber := range blockNumbers {
+		if timestamp, ok := m.blocks[blockNumber]; ok {
+			results[blockNumber] = timestamp
+		}
+	}
+	return results, nil
+}
+
+func (m *MemoryStore) FetchTransactionHashes(ctx context.Context, apps []Appearance) (map[Appearance]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	results := make(map[Appearance]string, len(apps))
+	for _, app := range apps {
+		if hash, ok := m.txHashes[app]; ok {
+			results[app] = hash
+		}
+	}
+	return results, nil
+}
+
+func (m *MemoryStore) FetchBlockByTimestamp(ctx context.Context, timestamp uint64, after bool, lastIndexedBlock uint) (*PublicBlock, bool, error) {
+	return ResolveBlockByTimestamp(ctx, m, timestamp, after, lastIndexedBlock)
+}
+
+func (m *MemoryStore) FetchBlockRangeByTimestamps(ctx context.Context, fromTimestamp *uint64, toTimestamp *uint64, firstBlock uint, lastBlock uint) (uint, uint, bool, error) {
+	return ResolveBlockRangeByTimestamps(ctx, m, fromTimestamp, toTimestamp, firstBlock, lastBlock)
+}
+
+// BlockAtTimestamp implements BlockTimestampIndex. Ties are resolved like in Postgres
+// (see sql.SelectBlockAtOrBeforeTimestamp).
+func (m *MemoryStore) BlockAtTimestamp(ctx context.Context, timestamp uint64, after bool) (block Block, neighbourStored bool, found bool, err error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for number, blockTimestamp := range m.blocks {
+		candidate := Block{Number: number, Timestamp: blockTimestamp}
+		if after {
+			if blockTimestamp < timestamp || (found && compareBlocks(candidate, block) >= 0) {
+				continue
+			}
+		} else {
+			if blockTimestamp > timestamp || (found && compareBlocks(candidate, block) <= 0) {
+				continue
+			}
+		}
+		block = candidate
+		found = true
+	}
+	if !found {
+		return
+	}
+
+	if after {
+		_, neighbourStored = m.blocks[block.Number-1]
+		neighbourStored = neighbourStored || block.Number == 0
+	} else {
+		_, neighbourStored = m.blocks[block.Number+1]
+	}
+	return
+}
+
+// StoredBlocksBounds implements BlockTimestampIndex
+func (m *MemoryStore) StoredBlocksBounds(ctx context.Context) (first uint32, last uint32, found bool, err error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for number := range m.blocks {
+		if !found || number < first {
+			first = number
+		}
+		if !found || number > last {
+			last = number
+		}
+		found = true
+	}
+	return
+}
+
+// visible returns copy of address' appearances between firstBlock and lastBlock
+// (inclusive) inserted up to watermark, in ascending order
+func (m *MemoryStore) visible(address string, firstBlock uint, lastBlock uint, watermark uint64) []Appearance {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	watermark = watermarkArg(watermark)
+	results := make([]Appearance, 0)
+	for _, item := range m.byAddress[strings.ToLower(address)] {
+		if uint(item.BlockNumber) < firstBlock || uint(item.BlockNumber) > lastBlock || item.seq > watermark {
+			continue
+		}
+		results = append(results, item.Appearance)
+	}
+	return results
+}
+
+func compareAppearances(a Appearance, b Appearance) int {
+	if c := cmp.Compare(a.BlockNumber, b.BlockNumber); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.TransactionIndex, b.TransactionIndex)
+}
+
+func compareBlocks(a Block, b Block) int {
+	if c := cmp.Compare(a.Timestamp, b.Timestamp); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Number, b.Number)
+}
+
+func compareAddressesInBlock(a AddressInBlock, b AddressInBlock) int {
+	if c := cmp.Compare(a.TransactionIndex, b.TransactionIndex); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Address, b.Address)
+}
+
+func descending(appearances []Appearance) []Appearance {
+	slices.Reverse(appearances)
+	return appearances
+}
+
+func firstN(appearances []Appearance, n uint) []Appearance {
+	if uint(len(appearances)) > n {
+		return appearances[:n]
+	}
+	return appearances
+}
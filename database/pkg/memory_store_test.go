@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
+)
+
+const memoryTestAddress = "0xf503017d7baf7fbc0fff7492b751025c6a78179b"
+
+func newTestMemoryStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	store := NewMemoryStore()
+	apps := []queueItem.Appearance{
+		{Address: "0xF503017D7Baf7fbc0fff7492b751025c6a78179b", BlockNumber: 3, TransactionIndex: 0},
+		{Address: memoryTestAddress, BlockNumber: 1, TransactionIndex: 5},
+		{Address: memoryTestAddress, BlockNumber: 2, TransactionIndex: 1},
+		{Address: memoryTestAddress, BlockNumber: 1, TransactionIndex: 2},
+		{Address: memoryTestAddress, BlockNumber: 4, TransactionIndex: 0},
+		// duplicate
+		{Address: memoryTestAddress, BlockNumber: 4, TransactionIndex: 0},
+		{Address: "0x0000000000000000000000000000000000000002", BlockNumber: 1, TransactionIndex: 5},
+		{Address: "0x0000000000000000000000000000000000000001", BlockNumber: 1, TransactionIndex: 5},
+	}
+	if err := store.InsertAppearanceBatch(context.Background(), apps); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestMemoryStore_FetchAppearances(t *testing.T) {
+	ctx := context.Background()
+	store := newTestMemoryStore(t)
+
+	status, _ := store.FetchStatus(ctx)
+	if status.LastIndexedBlock != 4 {
+		t.Fatal("wrong last indexed block:", status.LastIndexedBlock)
+	}
+
+	latest, watermark, err := store.FetchAppearancesFirstPage(ctx, false, memoryTestAddress, 0, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(latest); s != "[{4 0} {3 0}]" {
+		t.Fatal("wrong latest page:", s)
+	}
+	if watermark != 7 {
+		t.Fatal("wrong watermark:", watermark)
+	}
+
+	earliest, _, _ := store.FetchAppearancesFirstPage(ctx, true, memoryTestAddress, 0, 4, 2)
+	if s := fmt.Sprint(earliest); s != "[{1 5} {1 2}]" {
+		t.Fatal("wrong earliest page:", s)
+	}
+
+	next, _ := store.FetchAppearancesPage(ctx, true, memoryTestAddress, 0, 4, 2, 3, 0, watermark)
+	if s := fmt.Sprint(next); s != "[{2 1} {1 5}]" {
+		t.Fatal("wrong next page:", s)
+	}
+
+	previous, _ := store.FetchAppearancesPage(ctx, false, memoryTestAddress, 0, 4, 2, 1, 5, watermark)
+	if s := fmt.Sprint(previous); s != "[{3 0} {2 1}]" {
+		t.Fatal("wrong previous page:", s)
+	}
+
+	// block range
+	ranged, _, _ := store.FetchAppearancesFirstPage(ctx, false, memoryTestAddress, 2, 3, 10)
+	if s := fmt.Sprint(ranged); s != "[{3 0} {2 1}]" {
+		t.Fatal("wrong ranged page:", s)
+	}
+
+	bounds, err := store.FetchAppearancesDatasetBounds(ctx, memoryTestAddress, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(bounds); s != "{{3 0} {1 2}}" {
+		t.Fatal("wrong bounds:", s)
+	}
+	if _, err := store.FetchAppearancesDatasetBounds(ctx, "0x00", 0, 3); err == nil {
+		t.Fatal("expected error for empty bounds")
+	}
+}
+
+func TestMemoryStore_Watermark(t *testing.T) {
+	ctx := context.Background()
+	store := newTestMemoryStore(t)
+	_, watermark, _ := store.FetchAppearancesFirstPage(ctx, false, memoryTestAddress, 0, 4, 1)
+
+	// backfill
+	err := store.InsertAppearanceBatch(ctx, []queueItem.Appearance{
+		{Address: memoryTestAddress, BlockNumber: 2, TransactionIndex: 7},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// first pages are not filtered
+	all, next, _ := store.FetchAppearancesFirstPage(ctx, false, memoryTestAddress, 0, 4, 10)
+	if l := len(all); l != 6 {
+		t.Fatal("wrong first page length:", l)
+	}
+	if next <= watermark {
+		t.Fatal("watermark didn't move:", next, watermark)
+	}
+	pinned, _ := store.FetchAppearancesPage(ctx, true, memoryTestAddress, 0, 4, 10, 4, 0, watermark)
+	if l := len(pinned); l != 4 {
+		t.Fatal("wrong pinned length:", l)
+	}
+	// zero watermark means no limit
+	unpinned, _ := store.FetchAppearancesPage(ctx, true, memoryTestAddress, 0, 4, 10, 4, 0, 0)
+	if l := len(unpinned); l != 5 {
+		t.Fatal("wrong length:", l)
+	}
+}
+
+func TestMemoryStore_FetchAddressesIn(t *testing.T) {
+	ctx := context.Background()
+	store := newTestMemoryStore(t)
+
+	inTx, _ := store.FetchAddressesInTx(ctx, 1, 5, "", 2)
+	if s := fmt.Sprint(inTx); s != "[0x0000000000000000000000000000000000000001 0x0000000000000000000000000000000000000002]" {
+		t.Fatal("wrong addresses in tx:", s)
+	}
+	inTx, _ = store.FetchAddressesInTx(ctx, 1, 5, "0x0000000000000000000000000000000000000002", 2)
+	if s := fmt.Sprint(inTx); s != "["+memoryTestAddress+"]" {
+		t.Fatal("wrong addresses in tx after cursor:", s)
+	}
+
+	inBlock, _ := store.FetchAddressesInBlock(ctx, 1, 0, "", 2)
+	if s := fmt.Sprint(inBlock); s != "[{2 "+memoryTestAddress+"} {5 0x0000000000000000000000000000000000000001}]" {
+		t.Fatal("wrong addresses in block:", s)
+	}
+	inBlock, _ = store.FetchAddressesInBlock(ctx, 1, 5, "0x0000000000000000000000000000000000000001", 10)
+	if l := len(inBlock); l != 2 {
+		t.Fatal("wrong addresses in block length:", l)
+	}
+}
+
+func TestMemoryStore_Blocks(t *testing.T) {
+	ctx := context.Background()
+	store := newTestMemoryStore(t)
+	// blocks 2 and 3 are enriched
+	err := store.InsertBlockBatch(ctx, []Block{
+		{Number: 2, Timestamp: 200, TransactionHashes: []string{"0xa0", "0xa1"}},
+		{Number: 3, Timestamp: 300},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamps, _ := store.FetchBlockTimestamps(ctx, []uint32{1, 2, 3})
+	if s := fmt.Sprint(timestamps); s != "map[2:200 3:300]" {
+		t.Fatal("wrong timestamps:", s)
+	}
+	hashes, _ := store.FetchTransactionHashes(ctx, []Appearance{{2, 1}, {3, 0}})
+	if s := fmt.Sprint(hashes); s != "map[{2 1}:0xa1]" {
+		t.Fatal("wrong hashes:", s)
+	}
+
+	block, found, err := store.FetchBlockByTimestamp(ctx, 250, false, 4)
+	if err != nil || !found || block.BlockNumber != "2" {
+		t.Fatal("wrong block before:", block, found, err)
+	}
+	block, found, err = store.FetchBlockByTimestamp(ctx, 250, true, 4)
+	if err != nil || !found || block.BlockNumber != "3" {
+		t.Fatal("wrong block after:", block, found, err)
+	}
+	// block 4 is not enriched, so it could be mined before the timestamp
+	if _, _, err := store.FetchBlockByTimestamp(ctx, 350, false, 4); !errors.Is(err, ErrTimestampNotCovered) {
+		t.Fatal("expected ErrTimestampNotCovered, got", err)
+	}
+
+	err = store.InsertChunkBatch(ctx, []queueItem.Chunk{
+		{Cid: "cid2", Range: "000000003-000000004", Author: "test"},
+		{Cid: "cid1", Range: "000000000-000000002", Author: "test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk, found, _ := store.FetchLastChunk(ctx)
+	if !found || chunk.Cid != "cid2" {
+		t.Fatal("wrong last chunk:", chunk, found)
+	}
+	if status, _ := store.FetchStatus(ctx); status.LastChunkRange != "000000003-000000004" {
+		t.Fatal("wrong last chunk range:", status.LastChunkRange)
+	}
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrPartitionNoSeq = errors.New("appearances table has no seq column, run `dbadmin migrate seq` first")
+var ErrPartitionNoSize = errors.New("appearances partition size is not set")
+var ErrAlreadyPartitioned = errors.New("appearances table is already partitioned")
+
+type AppearancesPartition struct {
+	Name          string `json:"name"`
+	Bounds        string `json:"bounds"`
+	EstimatedRows int64  `json:"estimatedRows"`
+}
+
+func (c *Connection) partitionIndex(blockNumber uint32) uint32 {
+	return blockNumber / c.AppearancesPartitionSize
+}
+
+// appearancesPartitions remembers which partitions exist, so that inserts don't run DDL.
+// Provider shares it between connections.
+type appearancesPartitions struct {
+	mutex sync.Mutex
+	// upperBlock is the first block not covered by partitions, 0 if not read yet
+	upperBlock uint64
+}
+
+// EnsureAppearancesPartitions creates partitions up to the one holding lastBlock, plus the
+// next one, so that ingestion never waits for a new partition. Partitions are created in
+// order, so only the ones above the highest existing partition are created. Once they
+// exist, it doesn't query the database.
+func EnsureAppearancesPartitions(ctx context.Context, c *Connection, lastBlock uint32) (err error) {
+	if c.AppearancesPartitionSize == 0 {
+		return
+	}
+
+	p := c.partitions
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	upperIndex := c.partitionIndex(lastBlock) + 2
+	upperBlock := uint64(upperIndex) * uint64(c.AppearancesPartitionSize)
+	if p.upperBlock >= upperBlock {
+		return
+	}
+	if p.upperBlock == 0 {
+		if err = c.conn.QueryRow(ctx, sql.SelectAppearancesPartitionsUpperBlock(c.AppearancesTableName())).Scan(&p.upperBlock); err != nil {
+			return
+		}
+		if p.upperBlock >= upperBlock {
+			return
+		}
+	}
+
+	batch := &pgx.Batch{}
+	for index := uint32(p.upperBlock / uint64(c.AppearancesPartitionSize)); index < upperIndex; index++ {
+		batch.Queue(sql.CreateAppearancesPartition(c.AppearancesTableName(), c.AppearancesPartitionSize, index))
+	}
+	if err = c.conn.SendBatch(ctx, batch).Close(); err != nil {
+		return
+	}
+	p.upperBlock = upperBlock
+	return
+}
+
+func FetchAppearancesPartitions(ctx context.Context, c *Connection) (results []AppearancesPartition, err error) {
+	rows, err := c.conn.Query(
+		ctx,
+		sql.SelectAppearancesPartitions(c.AppearancesTableName()),
+	)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[AppearancesPartition])
+}
+
+// CreateAppearancesAddressIndex creates address index (see sql.CreateAppearancesAddressIndex)
+// without blocking inserts. Partitioned tables cannot be indexed concurrently, so their
+// partitions are indexed one by one and attached to the partitioned table's index.
+func CreateAppearancesAddressIndex(ctx context.Context, c *Connection) (err error) {
+	tableName := c.AppearancesTableName()
+	var partitioned bool
+	if err = c.conn.QueryRow(ctx, sql.SelectIsPartitioned(tableName)).Scan(&partitioned); err != nil {
+		return
+	}
+	if !partitioned {
+		_, err = c.conn.Exec(ctx, sql.CreateAppearancesAddressIndex(tableName, true))
+		return
+	}
+
+	if _, err = c.conn.Exec(ctx, sql.CreateAppearancesAddressIndexOnly(tableName)); err != nil {
+		return
+	}
+	partitions, err := FetchAppearancesPartitions(ctx, c)
+	if err != nil {
+		return
+	}
+	for _, partition := range partitions {
+		log.Println("indexing partition", partition.Name)
+		if _, err = c.conn.Exec(ctx, sql.CreateAppearancesAddressIndex(partition.Name, true)); err != nil {
+			return fmt.Errorf("indexing partition %s: %w", partition.Name, err)
+		}
+		if _, err = c.conn.Exec(ctx, sql.AttachAppearancesAddressIndex(tableName, partition.Name)); err != nil {
+			return fmt.Errorf("attaching index of partition %s: %w", partition.Name, err)
+		}
+	}
+	return
+}
+
+// PartitionAppearances converts existing appearances table into a partitioned one. Current
+// table becomes a single (legacy) partition, so no data is copied. It validates a range check
+// first, which takes time on large tables, but doesn't block inserts.
+//
+// The table has to have seq column (the partitioned table is created with it and ATTACH
+// requires matching columns), which is checked before anything is changed.
+func PartitionAppearances(ctx context.Context, c *Connection) (upperBlock uint64, err error) {
+	if c.AppearancesPartitionSize == 0 {
+		err = ErrPartitionNoSize
+		return
+	}
+	var partitioned bool
+	if err = c.conn.QueryRow(ctx, sql.SelectIsPartitioned(c.AppearancesTableName())).Scan(&partitioned); err != nil {
+		return
+	}
+	if partitioned {
+		err = ErrAlreadyPartitioned
+		return
+	}
+	hasSeq, err := hasSeqColumn(ctx, c)
+	if err != nil {
+		return
+	}
+	if !hasSeq {
+		err = ErrPartitionNoSeq
+		return
+	}
+
+	rows, err := c.conn.Query(
+		ctx,
+		sql.SelectAppearancesMaxBlock(c.AppearancesTableName()),
+	)
+	if err != nil {
+		return
+	}
+	maxBlock, err := pgx.CollectOneRow(rows, pgx.RowTo[uint32])
+	if err != nil {
+		return
+	}
+
+	// leave room for one more partition to keep accepting inserts while we validate
+	upperBlock = uint64(c.partitionIndex(maxBlock)+2) * uint64(c.AppearancesPartitionSize)
+
+	if _, err = c.conn.Exec(ctx, sql.AddAppearancesRangeCheck(c.AppearancesTableName(), upperBlock)); err != nil {
+		return
+	}
+	if _, err = c.conn.Exec(ctx, sql.PartitionAppearances(c.AppearancesTableName(), c.AddressesTableName(), upperBlock)); err != nil {
+		return
+	}
+	// partitions below upperBlock are covered by the legacy one
+	err = EnsureAppearancesPartitions(ctx, c, uint32(upperBlock))
+	return
+}
+
+func hasSeqColumn(ctx context.Context, c *Connection) (hasSeq bool, err error) {
+	err = c.conn.QueryRow(ctx, sql.SelectHasSeqColumn(c.AppearancesTableName())).Scan(&hasSeq)
+	return
+}
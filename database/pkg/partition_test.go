@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPartitionAppearances_Checks(t *testing.T) {
+	tests := []struct {
+		name        string
+		partitioned bool
+		hasSeq      bool
+		expected    error
+	}{
+		{"missing seq", false, false, ErrPartitionNoSeq},
+		{"already partitioned", true, true, ErrAlreadyPartitioned},
+	}
+	for _, tt := range tests {
+		q := &fakeQuerier{row: func(sql string) []any {
+			switch {
+			case strings.Contains(sql, "relkind"):
+				return []any{tt.partitioned}
+			case strings.Contains(sql, "attname = 'seq'"):
+				return []any{tt.hasSeq}
+			}
+			return nil
+		}}
+		c := &Connection{Chain: "mainnet", AppearancesPartitionSize: 1000, conn: q}
+
+		_, err := PartitionAppearances(context.Background(), c)
+		if !errors.Is(err, tt.expected) {
+			t.Fatal(tt.name, "- wrong error:", err)
+		}
+		if len(q.execs) != 0 {
+			t.Fatal(tt.name, "- expected no changes, got:", q.execs)
+		}
+	}
+
+	if _, err := PartitionAppearances(context.Background(), &Connection{Chain: "mainnet"}); !errors.Is(err, ErrPartitionNoSize) {
+		t.Fatal("wrong error without partition size:", err)
+	}
+}
+
+func TestEnsureAppearancesPartitions(t *testing.T) {
+	var catalogQueries int
+	q := &fakeQuerier{row: func(sql string) []any {
+		catalogQueries++
+		// partitions 0 and 1 exist
+		return []any{uint64(2000)}
+	}}
+	c := &Connection{Chain: "mainnet", AppearancesPartitionSize: 1000, conn: q, partitions: &appearancesPartitions{}}
+	ctx := context.Background()
+
+	if err := EnsureAppearancesPartitions(ctx, c, 500); err != nil {
+		t.Fatal(err)
+	}
+	if len(q.execs) != 0 {
+		t.Fatal("expected no DDL for existing partitions, got:", q.execs)
+	}
+
+	// partitions 2 and 3
+	if err := EnsureAppearancesPartitions(ctx, c, 2500); err != nil {
+		t.Fatal(err)
+	}
+	if len(q.execs) != 1 || q.execs[0] != "batch of 2" {
+		t.Fatal("wrong DDL:", q.execs)
+	}
+
+	for _, lastBlock := range []uint32{10, 2500, 2999} {
+		if err := EnsureAppearancesPartitions(ctx, c, lastBlock); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(q.execs) != 1 || catalogQueries != 1 {
+		t.Fatal("ensured partitions should be cached:", q.execs, catalogQueries)
+	}
+}
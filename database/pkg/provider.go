@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	awshelper "github.com/TrueBlocks/trueblocks-key/awshelper/pkg"
+	keyConfig "github.com/TrueBlocks/trueblocks-key/config/pkg"
+)
+
+// Provider gives binaries connections configured in a database config group. It reads
+// config and secrets once, so it should live as long as the process (e.g. Lambda
+// execution environment).
+//
+// When MaxConnections is set, Provider keeps a single pooled Connection open between
+// calls. Otherwise, every call to Connection opens a new connection, which should be
+// released as soon as possible (required by RDS Proxy). Replica connections and state
+// are kept by Provider in both cases.
+type Provider struct {
+	// template is protected by templateMutex, because we save the detected address format in it
+	template      Connection
+	templateMutex sync.Mutex
+
+	mutex  sync.Mutex
+	pooled *Connection
+}
+
+// NewProvider creates Provider for database config group dbConfigKey (usually "default")
+func NewProvider(cnf *keyConfig.ConfigFile, dbConfigKey string, chain string) (p *Provider, err error) {
+	dbConfig, ok := cnf.Database[dbConfigKey]
+	if !ok {
+		return nil, fmt.Errorf("database.NewProvider: missing database config %s", dbConfigKey)
+	}
+
+	p = &Provider{
+		template: Connection{
+			Chain:    chain,
+			Host:     dbConfig.Host,
+			Port:     dbConfig.Port,
+			Database: dbConfig.Database,
+			User:     dbConfig.User,
+			Password: dbConfig.Password,
+
+			AppearancesPartitionSize: dbConfig.AppearancesPartitionSize,
+			MaxConnections:           dbConfig.MaxConnections,
+			StatementTimeout:         time.Duration(dbConfig.StatementTimeout) * time.Millisecond,
+			Replicas:                 dbConfig.Replicas,
+
+			partitions: &appearancesPartitions{},
+		},
+	}
+
+	if p.template.replicas, err = newReplicaSet(dbConfig.Replicas, dbConfig.Port); err != nil {
+		return nil, fmt.Errorf("database.NewProvider: %w", err)
+	}
+
+	switch {
+	case dbConfig.IamAuth:
+		log.Println("using IAM auth token as DB password")
+		region := dbConfig.AwsRegion
+		p.template.PasswordFunc = func(ctx context.Context) (string, error) {
+			return awshelper.BuildRdsAuthToken(dbConfig.Host, dbConfig.Port, region, dbConfig.User)
+		}
+	case dbConfig.AwsSecret != "":
+		log.Println("using Secrets Manager secret as DB password")
+		secretValue, err := awshelper.FetchUsernamePasswordSecret(dbConfig.AwsSecret)
+		if err != nil {
+			return nil, fmt.Errorf("database.NewProvider: %w", err)
+		}
+		p.template.User = secretValue.Username
+		p.template.Password = secretValue.Password
+	default:
+		log.Println("using configuration DB password")
+	}
+
+	return
+}
+
+// Connection returns an open connection. Pass it to Release when done.
+func (p *Provider) Connection(ctx context.Context) (c *Connection, err error) {
+	if p.template.MaxConnections == 0 {
+		c = p.newConnection()
+		if err = c.Connect(ctx); err != nil {
+			return nil, err
+		}
+		p.saveAddressFormat(c)
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.pooled == nil {
+		c = p.newConnection()
+		if err = c.Connect(ctx); err != nil {
+			return nil, err
+		}
+		p.pooled = c
+	}
+	return p.pooled, nil
+}
+
+// Release closes c, unless it is pooled
+func (p *Provider) Release(ctx context.Context, c *Connection) error {
+	if c == nil || c == p.pooled {
+		return nil
+	}
+	return c.Close(ctx)
+}
+
+// Close closes the pool and replica connections, if any
+func (p *Provider) Close(ctx context.Context) (err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.template.replicas != nil {
+		p.template.replicas.close(ctx)
+	}
+	if p.pooled != nil {
+		err = p.pooled.Close(ctx)
+		p.pooled = nil
+	}
+	return
+}
+
+func (p *Provider) newConnection() *Connection {
+	p.templateMutex.Lock()
+	c := p.template
+	p.templateMutex.Unlock()
+	log.Println(c.String())
+	return &c
+}
+
+// saveAddressFormat makes connections opened later skip address format detection
+func (p *Provider) saveAddressFormat(c *Connection) {
+	p.templateMutex.Lock()
+	defer p.templateMutex.Unlock()
+	p.template.binaryAddresses = c.binaryAddresses
+	p.template.addressFormatKnown = c.addressFormatKnown
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	keyConfig "github.com/TrueBlocks/trueblocks-key/config/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestNewProvider(t *testing.T) {
+	t.Setenv("KY_DATABASE_DEFAULT_HOST", "localhost")
+	t.Setenv("KY_DATABASE_DEFAULT_PORT", "5432")
+	t.Setenv("KY_DATABASE_DEFAULT_USER", "user")
+	t.Setenv("KY_DATABASE_DEFAULT_PASSWORD", "secret")
+	t.Setenv("KY_DATABASE_DEFAULT_DATABASE", "index")
+	t.Setenv("KY_DATABASE_DEFAULT_MAXCONNECTIONS", "4")
+	t.Setenv("KY_DATABASE_DEFAULT_STATEMENTTIMEOUT", "1500")
+	t.Setenv("KY_DATABASE_DEFAULT_REPLICAS", "replica1,replica2:5433")
+
+	cnf, err := keyConfig.Get("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewProvider(cnf, "missing", "mainnet"); err == nil {
+		t.Fatal("expected error for missing config")
+	}
+
+	p, err := NewProvider(cnf, "default", "mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := p.newConnection()
+	if c.Host != "localhost" || c.Port != 5432 || c.Database != "index" || c.Chain != "mainnet" {
+		t.Fatal("wrong connection:", c.String())
+	}
+	if c.User != "user" || c.Password != "secret" {
+		t.Fatal("wrong credentials:", c.User, c.Password)
+	}
+	if c.MaxConnections != 4 {
+		t.Fatal("wrong max connections:", c.MaxConnections)
+	}
+	if c.StatementTimeout != 1500*time.Millisecond {
+		t.Fatal("wrong statement timeout:", c.StatementTimeout)
+	}
+	if len(c.Replicas) != 2 || c.Replicas[0] != "replica1" || c.Replicas[1] != "replica2:5433" {
+		t.Fatal("wrong replicas:", c.Replicas)
+	}
+	if c.replicas == nil || c.replicas != p.newConnection().replicas || c.ownsReplicas {
+		t.Fatal("replica state should be shared by connections of a provider")
+	}
+	if err := p.Release(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// with IAM auth, password is empty and PasswordFunc provides the token
+	iamGroup := cnf.Database["default"]
+	iamGroup.IamAuth = true
+	iamGroup.Password = ""
+	iamCnf := *cnf
+	iamCnf.Database = maps.Clone(cnf.Database)
+	iamCnf.Database["default"] = iamGroup
+	p, err = NewProvider(&iamCnf, "default", "mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = p.newConnection()
+	if c.Password != "" || c.PasswordFunc == nil {
+		t.Fatal("expected IAM auth")
+	}
+	config, err := pgx.ParseConfig(c.dsn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Database != "index" || config.Password != "" {
+		t.Fatal("wrong database or password:", config.Database, config.Password)
+	}
+	if config.Host != "localhost" || config.Port != 5432 || config.User != "user" {
+		t.Fatal("wrong config:", config.Host, config.Port, config.User)
+	}
+}
+
+func TestProvider_saveAddressFormat(t *testing.T) {
+	p := &Provider{template: Connection{Host: "localhost", Port: 5432}}
+	if c := p.newConnection(); c.addressFormatKnown {
+		t.Fatal("address format should be detected by the first connection")
+	}
+
+	detected := p.newConnection()
+	detected.binaryAddresses = true
+	detected.addressFormatKnown = true
+	p.saveAddressFormat(detected)
+
+	c := p.newConnection()
+	if !c.addressFormatKnown || !c.BinaryAddresses() {
+		t.Fatal("wrong address format:", c.addressFormatKnown, c.BinaryAddresses())
+	}
+}
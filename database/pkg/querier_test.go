@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeQuerier answers QueryRow with values returned by row (nil means no rows) and records
+// executed statements
+type fakeQuerier struct {
+	row   func(sql string) []any
+	execs []string
+}
+
+func (f *fakeQuerier) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	f.execs = append(f.execs, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (f *fakeQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, errors.New("fakeQuerier: Query is not supported")
+}
+
+func (f *fakeQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return fakeRow(f.row(sql))
+}
+
+func (f *fakeQuerier) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	// queued statements are not accessible, so we only record the batch
+	f.execs = append(f.execs, fmt.Sprintf("batch of %d", b.Len()))
+	return &fakeBatchResults{}
+}
+
+func (f *fakeQuerier) Begin(ctx context.Context) (pgx.Tx, error) {
+	return nil, errors.New("fakeQuerier: Begin is not supported")
+}
+
+// executed returns true if any of the recorded statements contains s
+func (f *fakeQuerier) executed(s string) bool {
+	for _, exec := range f.execs {
+		if strings.Contains(exec, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type fakeRow []any
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r == nil {
+		return pgx.ErrNoRows
+	}
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r[i]))
+	}
+	return nil
+}
+
+type fakeBatchResults struct{}
+
+func (*fakeBatchResults) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, nil }
+func (*fakeBatchResults) Query() (pgx.Rows, error) {
+	return nil, errors.New("fakeBatchResults: Query is not supported")
+}
+func (*fakeBatchResults) QueryRow() pgx.Row { return fakeRow(nil) }
+func (*fakeBatchResults) Close() error      { return nil }
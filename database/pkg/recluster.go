@@ -0,0 +1,252 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrReclusterNoSeq = errors.New("appearances table has no seq column, run `dbadmin migrate seq` first")
+var ErrReclusterPartitioned = errors.New("partitioned appearances table cannot be reclustered online")
+var ErrReclusterLeftover = errors.New("table left from a previous recluster exists, drop it first")
+
+// maxCatchUpRounds limits catching up before the final swap. Each round should be shorter than
+// the previous one, as it only copies rows inserted during the previous round.
+const maxCatchUpRounds = 5
+
+type ReclusterPhase string
+
+const (
+	ReclusterPhasePrepare  ReclusterPhase = "prepare"
+	ReclusterPhaseCopy     ReclusterPhase = "copy"
+	ReclusterPhaseIndex    ReclusterPhase = "index"
+	ReclusterPhaseCatchUp  ReclusterPhase = "catch-up"
+	ReclusterPhaseSwap     ReclusterPhase = "swap"
+	ReclusterPhaseValidate ReclusterPhase = "validate"
+)
+
+type ReclusterProgress struct {
+	Phase ReclusterPhase
+	// CopiedRows is the number of rows copied so far
+	CopiedRows int64
+	// Done is the fraction of the copy phase that is done (0 - 1)
+	Done    float64
+	Elapsed time.Duration
+}
+
+// Remaining estimates time left in the copy phase
+func (p *ReclusterProgress) Remaining() time.Duration {
+	if p.Done <= 0 {
+		return 0
+	}
+	return time.Duration(float64(p.Elapsed) * (1 - p.Done) / p.Done)
+}
+
+type ReclusterEstimate struct {
+	Rows       int64
+	TableBytes int64
+	IndexBytes int64
+	// RequiredBytes is additional disk space needed for the copy and its indexes
+	RequiredBytes int64
+	SampleRows    int64
+	// Duration is extrapolated from copying a sample. It doesn't include index creation.
+	Duration time.Duration
+}
+
+// ReclusterOptions configure Recluster. BatchAddresses is the number of address IDs copied
+// in a single statement.
+type ReclusterOptions struct {
+	BatchAddresses uint64
+	Progress       func(ReclusterProgress)
+}
+
+// EstimateRecluster copies a single batch into a temporary table (rolled back afterwards)
+// and extrapolates the time needed to copy the whole table
+func EstimateRecluster(ctx context.Context, c *Connection, batchAddresses uint64) (estimate ReclusterEstimate, err error) {
+	if err = checkReclusterable(ctx, c); err != nil {
+		return
+	}
+
+	if err = c.conn.QueryRow(ctx, sql.SelectTableSize(c.AppearancesTableName())).Scan(&estimate.Rows, &estimate.TableBytes, &estimate.IndexBytes); err != nil {
+		return
+	}
+	// the copy is denser than the original, so this is the upper bound
+	estimate.RequiredBytes = estimate.TableBytes + estimate.IndexBytes
+
+	maxId, err := fetchMaxAddressId(ctx, c)
+	if err != nil || maxId == 0 {
+		return
+	}
+
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, sql.CreateReclusterTable(c.AppearancesTableName())); err != nil {
+		return
+	}
+	started := time.Now()
+	tag, err := tx.Exec(ctx, sql.CopyReclusterBatch(c.AppearancesTableName()), pgx.NamedArgs{
+		"fromId":    0,
+		"toId":      min(batchAddresses, maxId+1),
+		"watermark": watermarkArg(0),
+	})
+	if err != nil {
+		return
+	}
+	estimate.SampleRows = tag.RowsAffected()
+	if estimate.SampleRows > 0 {
+		estimate.Duration = time.Duration(float64(time.Since(started)) * float64(estimate.Rows) / float64(estimate.SampleRows))
+	}
+	return
+}
+
+// Recluster builds a copy of appearances table ordered by address_id, block_number and tx_id,
+// and swaps it with the live table. Inserts only wait during the final swap, reads are not
+// blocked. The original table is kept as sql.PreReclusterTableName.
+//
+// Rows committed at or below the initial watermark are copied in batches, rows above it
+// by the catch-up rounds and the final swap (see sql.SwapRecluster). The watermark has to
+// be read from the primary, replicas may be behind.
+func Recluster(ctx context.Context, c *Connection, options ReclusterOptions) (err error) {
+	if err = checkReclusterable(ctx, c); err != nil {
+		return
+	}
+	progress := options.Progress
+	if progress == nil {
+		progress = func(ReclusterProgress) {}
+	}
+	tableName := c.AppearancesTableName()
+	started := time.Now()
+
+	maxId, err := fetchMaxAddressId(ctx, c)
+	if err != nil {
+		return
+	}
+	watermark, err := FetchWatermark(ctx, c)
+	if err != nil {
+		return
+	}
+
+	initialWatermark := watermark
+
+	progress(ReclusterProgress{Phase: ReclusterPhasePrepare, Elapsed: time.Since(started)})
+	if _, err = c.conn.Exec(ctx, sql.CreateReclusterSeqIndex(tableName)); err != nil {
+		return fmt.Errorf("creating seq index: %w", err)
+	}
+	if _, err = c.conn.Exec(ctx, sql.CreateReclusterTable(tableName)); err != nil {
+		return fmt.Errorf("creating copy: %w", err)
+	}
+
+	var copied int64
+	for fromId := uint64(0); fromId <= maxId; fromId += options.BatchAddresses {
+		toId := fromId + options.BatchAddresses
+		tag, err := c.conn.Exec(ctx, sql.CopyReclusterBatch(tableName), pgx.NamedArgs{
+			"fromId":    fromId,
+			"toId":      toId,
+			"watermark": watermarkArg(watermark),
+		})
+		if err != nil {
+			return fmt.Errorf("copying address IDs %d-%d: %w", fromId, toId, err)
+		}
+		copied += tag.RowsAffected()
+		progress(ReclusterProgress{
+			Phase:      ReclusterPhaseCopy,
+			CopiedRows: copied,
+			Done:       float64(min(toId, maxId+1)) / float64(maxId+1),
+			Elapsed:    time.Since(started),
+		})
+	}
+
+	progress(ReclusterProgress{Phase: ReclusterPhaseIndex, CopiedRows: copied, Done: 1, Elapsed: time.Since(started)})
+	if _, err = c.conn.Exec(ctx, sql.CreateReclusterIndexes(tableName)); err != nil {
+		return fmt.Errorf("creating indexes: %w", err)
+	}
+	var hasAddressIndex bool
+	if err = c.conn.QueryRow(ctx, sql.SelectRelationExists(sql.AppearancesAddressIndexName(tableName))).Scan(&hasAddressIndex); err != nil {
+		return
+	}
+	if hasAddressIndex {
+		if _, err = c.conn.Exec(ctx, sql.CreateAppearancesAddressIndex(sql.ReclusterTableName(tableName), false)); err != nil {
+			return fmt.Errorf("creating address index: %w", err)
+		}
+	}
+
+	// Addresses inserted after we read maxId can only have appearances with seq above
+	// the watermark, so catching up copies them too. The rounds make the final swap (which
+	// blocks inserts) short.
+	for round := 0; round < maxCatchUpRounds; round++ {
+		var next uint64
+		next, err = FetchWatermark(ctx, c)
+		if err != nil {
+			return
+		}
+		if next == watermark {
+			break
+		}
+		tag, err := c.conn.Exec(ctx, sql.CopyReclusterCatchUp(tableName), pgx.NamedArgs{
+			"watermark": watermark,
+		})
+		if err != nil {
+			return fmt.Errorf("catching up: %w", err)
+		}
+		copied += tag.RowsAffected()
+		progress(ReclusterProgress{Phase: ReclusterPhaseCatchUp, CopiedRows: copied, Done: 1, Elapsed: time.Since(started)})
+		watermark = next
+	}
+
+	progress(ReclusterProgress{Phase: ReclusterPhaseSwap, CopiedRows: copied, Done: 1, Elapsed: time.Since(started)})
+	if _, err = c.conn.Exec(ctx, sql.SwapRecluster(tableName, c.AddressesTableName(), initialWatermark)); err != nil {
+		return fmt.Errorf("swapping tables: %w", err)
+	}
+
+	progress(ReclusterProgress{Phase: ReclusterPhaseValidate, CopiedRows: copied, Done: 1, Elapsed: time.Since(started)})
+	if _, err = c.conn.Exec(ctx, sql.ValidateReclusterForeignKey(tableName)); err != nil {
+		return fmt.Errorf("validating foreign key: %w", err)
+	}
+	return
+}
+
+func checkReclusterable(ctx context.Context, c *Connection) (err error) {
+	var partitioned bool
+	if err = c.conn.QueryRow(ctx, sql.SelectIsPartitioned(c.AppearancesTableName())).Scan(&partitioned); err != nil {
+		return
+	}
+	if partitioned {
+		return ErrReclusterPartitioned
+	}
+
+	hasSeq, err := hasSeqColumn(ctx, c)
+	if err != nil {
+		return
+	}
+	if !hasSeq {
+		return ErrReclusterNoSeq
+	}
+
+	tableName := c.AppearancesTableName()
+	for _, leftover := range []string{sql.ReclusterTableName(tableName), sql.PreReclusterTableName(tableName)} {
+		var exists bool
+		if err = c.conn.QueryRow(ctx, sql.SelectRelationExists(leftover)).Scan(&exists); err != nil {
+			return
+		}
+		if exists {
+			return fmt.Errorf("%w: %s", ErrReclusterLeftover, leftover)
+		}
+	}
+	return
+}
+
+func fetchMaxAddressId(ctx context.Context, c *Connection) (maxId uint64, err error) {
+	rows, err := c.conn.Query(ctx, sql.SelectMaxAddressId(c.AddressesTableName()))
+	if err != nil {
+		return
+	}
+	return pgx.CollectOneRow(rows, pgx.RowTo[uint64])
+}
@@ -0,0 +1,18 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReclusterProgress_Remaining(t *testing.T) {
+	p := &ReclusterProgress{Done: 0.25, Elapsed: time.Minute}
+	if r := p.Remaining(); r != 3*time.Minute {
+		t.Fatal("wrong remaining:", r)
+	}
+
+	p = &ReclusterProgress{}
+	if r := p.Remaining(); r != 0 {
+		t.Fatal("expected 0 when nothing is done, got", r)
+	}
+}
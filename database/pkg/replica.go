@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replicaBackoffMin and replicaBackoffMax limit how long we skip a replica after it fails.
+// The delay doubles with every consecutive failure.
+const replicaBackoffMin = time.Second
+const replicaBackoffMax = time.Minute
+
+// replica is a read-only copy of the primary database. We connect to it when it's needed
+// for the first time.
+type replica struct {
+	host string
+	port int
+
+	mutex sync.Mutex
+	conn  *Connection
+	// lastIndexedBlock is replica's status.last_indexed_block seen the last time we checked
+	lastIndexedBlock uint
+	// watermark is replica's watermark (see FetchWatermark) seen the last time we checked
+	watermark uint64
+	// failures is the number of consecutive failures, we skip the replica until retryAt
+	failures int
+	retryAt  time.Time
+}
+
+// replicaSet holds replicas and their state. Provider shares it between connections, so
+// that replica connections, health and progress survive between invocations.
+type replicaSet struct {
+	replicas []*replica
+	next     uint32
+}
+
+// parseReplicas reads replica addresses in host or host:port format. Replicas listen on
+// the primary's port by default.
+func parseReplicas(addresses []string, defaultPort int) (replicas []*replica, err error) {
+	replicas = make([]*replica, 0, len(addresses))
+	for _, address := range addresses {
+		r := &replica{host: address, port: defaultPort}
+		if host, port, splitErr := net.SplitHostPort(address); splitErr == nil {
+			r.host = host
+			if r.port, err = strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("invalid replica port %s: %w", address, err)
+			}
+		}
+		if r.host == "" {
+			return nil, fmt.Errorf("replica host missing: %s", address)
+		}
+		replicas = append(replicas, r)
+	}
+	return
+}
+
+// newReplicaSet returns nil if there are no replicas
+func newReplicaSet(addresses []string, defaultPort int) (*replicaSet, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+	replicas, err := parseReplicas(addresses, defaultPort)
+	if err != nil {
+		return nil, err
+	}
+	return &replicaSet{replicas: replicas}, nil
+}
+
+// querier returns connection to the replica if it has indexed at least minBlock and
+// replayed all transactions up to minWatermark, nil otherwise. It also returns nil while
+// the replica is backing off after a failure.
+func (r *replica) querier(ctx context.Context, primary *Connection, minBlock uint, minWatermark uint64) (q Querier, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if time.Now().Before(r.retryAt) {
+		return nil, nil
+	}
+	defer func() {
+		if err != nil {
+			r.fail(ctx)
+		} else {
+			r.failures = 0
+		}
+	}()
+
+	if r.conn == nil {
+		conn := *primary
+		conn.Host = r.host
+		conn.Port = r.port
+		conn.Replicas = nil
+		conn.replicas = nil
+		conn.ownsReplicas = false
+		// Replica connection is shared by all connections of a Provider, so it has to be
+		// safe for concurrent use
+		if conn.MaxConnections == 0 {
+			conn.MaxConnections = 1
+		}
+		if err := conn.Connect(ctx); err != nil {
+			return nil, err
+		}
+		r.conn = &conn
+	}
+
+	// Blocks are only added, so we can trust the last value as long as it's recent enough
+	if minBlock > 0 && r.lastIndexedBlock < minBlock {
+		status, err := FetchStatus(ctx, r.conn)
+		if err != nil {
+			return nil, err
+		}
+		r.lastIndexedBlock = status.LastIndexedBlock
+		if r.lastIndexedBlock < minBlock {
+			return nil, nil
+		}
+	}
+
+	// Watermark is commit-ordered, so replica having the same or higher watermark has
+	// all appearances visible at minWatermark on the primary
+	if minWatermark > 0 && r.watermark < minWatermark {
+		watermark, err := FetchWatermark(ctx, r.conn)
+		if err != nil {
+			return nil, err
+		}
+		r.watermark = watermark
+		if r.watermark < minWatermark {
+			return nil, nil
+		}
+	}
+	return r.conn.conn, nil
+}
+
+// fail schedules the next attempt and drops the connection, which may be broken.
+// Must be called with mutex locked.
+func (r *replica) fail(ctx context.Context) {
+	r.failures++
+	delay := min(replicaBackoffMin<<min(r.failures-1, 6), replicaBackoffMax)
+	r.retryAt = time.Now().Add(delay)
+	if r.conn != nil && r.conn.conn != nil {
+		if err := r.conn.Close(ctx); err != nil {
+			log.Println("closing replica connection:", err)
+		}
+	}
+	r.conn = nil
+}
+
+func (r *replica) close(ctx context.Context) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.conn != nil {
+		err = r.conn.Close(ctx)
+		r.conn = nil
+	}
+	return
+}
+
+// reader returns connection to a replica that has indexed at least minBlock and has
+// appearances up to minWatermark (0 means any replica). If there is no such replica,
+// or no replicas at all, the primary is returned. Pages of a single walk share the
+// watermark, so they return the same appearances no matter which node serves them.
+//
+// Functions that read data before writing it, watermark and status (which tell readers
+// what to expect from replicas) should use the primary directly.
+func (c *Connection) reader(ctx context.Context, minBlock uint, minWatermark uint64) Querier {
+	if c.replicas == nil {
+		return c.conn
+	}
+	return c.replicas.reader(ctx, c, minBlock, minWatermark)
+}
+
+func (s *replicaSet) reader(ctx context.Context, primary *Connection, minBlock uint, minWatermark uint64) Querier {
+	count := len(s.replicas)
+	start := int(atomic.AddUint32(&s.next, 1))
+	for i := 0; i < count; i++ {
+		r := s.replicas[(start+i)%count]
+		conn, err := r.querier(ctx, primary, minBlock, minWatermark)
+		if err != nil {
+			log.Println("replica", r.host, "unavailable:", err)
+			continue
+		}
+		if conn != nil {
+			return conn
+		}
+	}
+	return primary.conn
+}
+
+func (s *replicaSet) close(ctx context.Context) {
+	for _, r := range s.replicas {
+		if err := r.close(ctx); err != nil {
+			log.Println("closing replica connection:", err)
+		}
+	}
+}
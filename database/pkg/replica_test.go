@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_parseReplicas(t *testing.T) {
+	replicas, err := parseReplicas([]string{"replica1", "replica2:5433", "10.0.0.1"}, 5432)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(replicas); l != 3 {
+		t.Fatal("wrong length:", l)
+	}
+	if r := replicas[0]; r.host != "replica1" || r.port != 5432 {
+		t.Fatal("wrong replica 0:", r.host, r.port)
+	}
+	if r := replicas[1]; r.host != "replica2" || r.port != 5433 {
+		t.Fatal("wrong replica 1:", r.host, r.port)
+	}
+	if r := replicas[2]; r.host != "10.0.0.1" || r.port != 5432 {
+		t.Fatal("wrong replica 2:", r.host, r.port)
+	}
+
+	if _, err := parseReplicas([]string{"replica:port"}, 5432); err == nil {
+		t.Fatal("expected error for invalid port")
+	}
+	if _, err := parseReplicas([]string{":5432"}, 5432); err == nil {
+		t.Fatal("expected error for missing host")
+	}
+}
+
+func TestConnection_readerWithoutReplicas(t *testing.T) {
+	c := &Connection{}
+	if q := c.reader(nil, 100, 100); q != c.conn {
+		t.Fatal("expected primary")
+	}
+}
+
+func TestConnection_readerWatermark(t *testing.T) {
+	var watermarkQueries int
+	replicaQuerier := &fakeQuerier{row: func(sql string) []any {
+		watermarkQueries++
+		return []any{uint64(10)}
+	}}
+	primary := &fakeQuerier{}
+	c := &Connection{
+		conn: primary,
+		replicas: &replicaSet{replicas: []*replica{
+			{host: "replica", conn: &Connection{conn: replicaQuerier}},
+		}},
+	}
+
+	if q := c.reader(context.Background(), 0, 10); q != replicaQuerier {
+		t.Fatal("expected replica covering the watermark")
+	}
+	if q := c.reader(context.Background(), 0, 5); q != replicaQuerier {
+		t.Fatal("expected replica covering lower watermark")
+	}
+	if watermarkQueries != 1 {
+		t.Fatal("replica watermark should be cached, queries:", watermarkQueries)
+	}
+	if q := c.reader(context.Background(), 0, 11); q != primary {
+		t.Fatal("expected primary when replica is behind the watermark")
+	}
+	if watermarkQueries != 2 {
+		t.Fatal("replica watermark should be checked again, queries:", watermarkQueries)
+	}
+}
+
+func TestConnection_readerBackoff(t *testing.T) {
+	var watermarkQueries int
+	// nil row makes the query fail
+	replicaQuerier := &fakeQuerier{row: func(sql string) []any {
+		watermarkQueries++
+		return nil
+	}}
+	primary := &fakeQuerier{}
+	r := &replica{host: "replica", conn: &Connection{conn: replicaQuerier}}
+	c := &Connection{conn: primary, replicas: &replicaSet{replicas: []*replica{r}}}
+
+	if q := c.reader(context.Background(), 0, 10); q != primary {
+		t.Fatal("expected primary when replica fails")
+	}
+	if r.conn != nil {
+		t.Fatal("failed replica connection should be dropped")
+	}
+	if q := c.reader(context.Background(), 0, 10); q != primary {
+		t.Fatal("expected primary during backoff")
+	}
+	if watermarkQueries != 1 {
+		t.Fatal("replica should be skipped during backoff, queries:", watermarkQueries)
+	}
+	if d := time.Until(r.retryAt); d <= 0 || d > replicaBackoffMin {
+		t.Fatal("wrong backoff:", d)
+	}
+}
@@ -6,35 +6,34 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
-// SelectAddressesIn functions don't set the order, because it was too heave on CPU
+// SelectAddressesIn functions return pages ordered by address (and by transaction index,
+// in case of a block). Ordering a single block is cheap, so we can afford it here.
 
+// SelectAddressesInTx returns up to @pageSize addresses greater than @afterAddress
 func SelectAddressesInTx(appearancesTableName string, addressesTableName string) string {
 	return fmt.Sprintf(`
-WITH apps AS (
-    SELECT address_id
-    FROM %[1]s
-    WHERE block_number = @blockNumber AND tx_id = @transactionIndex
-    ORDER BY block_number DESC, tx_id ASC
-)
-SELECT DISTINCT addrs.address
-FROM apps
-JOIN %[2]s addrs ON addrs.id = apps.address_id;
+SELECT addrs.address
+FROM %[1]s apps
+JOIN %[2]s addrs ON addrs.id = apps.address_id
+WHERE apps.block_number = @blockNumber AND apps.tx_id = @transactionIndex AND addrs.address > @afterAddress
+ORDER BY addrs.address
+LIMIT @pageSize;
 `,
 		pgx.Identifier.Sanitize(pgx.Identifier{appearancesTableName}),
 		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
 	)
 }
 
+// SelectAddressesInBlock returns up to @pageSize (tx_id, address) pairs greater than
+// (@afterTransactionIndex, @afterAddress)
 func SelectAddressesInBlock(appearancesTableName string, addressesTableName string) string {
 	return fmt.Sprintf(`
-WITH apps AS (
-    SELECT DISTINCT address_id
-    FROM %[1]s
-    WHERE block_number = @blockNumber
-)
-SELECT addrs.address
-FROM apps
-JOIN %[2]s addrs ON addrs.id = apps.address_id;
+SELECT apps.tx_id, addrs.address
+FROM %[1]s apps
+JOIN %[2]s addrs ON addrs.id = apps.address_id
+WHERE apps.block_number = @blockNumber AND (apps.tx_id, addrs.address) > (@afterTransactionIndex, @afterAddress)
+ORDER BY apps.tx_id, addrs.address
+LIMIT @pageSize;
 `,
 		pgx.Identifier.Sanitize(pgx.Identifier{appearancesTableName}),
 		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
@@ -10,7 +10,7 @@ func InsertAppearance(appearancesTableName string, addressesTableName string) st
 	return fmt.Sprintf(`
 WITH ids AS (
     INSERT INTO %[1]s (address)
-    VALUES ($1::varchar(42))
+    VALUES ($1)
     ON CONFLICT DO NOTHING
     RETURNING id AS address_id
 ),
@@ -28,6 +28,35 @@ ON CONFLICT DO NOTHING;
 	)
 }
 
+// InsertHexAppearance is InsertAppearance taking $1 as hex string, which is converted to the
+// format of addresses table (used by bulk conversion, which doesn't know the schema)
+func InsertHexAppearance(appearancesTableName string, addressesTableName string, binaryAddresses bool) string {
+	address := HexAddress("$1::text", binaryAddresses)
+	return fmt.Sprintf(`
+WITH ids AS (
+    INSERT INTO %[1]s (address)
+    VALUES (%[3]s)
+    ON CONFLICT DO NOTHING
+    RETURNING id AS address_id
+),
+present_ids AS (
+    SELECT address_id FROM ids
+    UNION ALL
+    SELECT id AS address_id FROM %[1]s WHERE address = %[3]s LIMIT 1
+)
+INSERT INTO %[2]s (address_id, block_number, tx_id)
+SELECT present_ids.address_id, $2, $3 FROM present_ids
+ON CONFLICT DO NOTHING;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{appearancesTableName}),
+		address,
+	)
+}
+
+// Select functions return appearances between @firstBlock and @lastBlock (inclusive).
+// Pages following the first one only return appearances ingested up to @watermark (see
+// SelectWatermark), so that appearances inserted during a walk don't shift its pages.
 func SelectAppearancesFirstPage(appearancesTableName string, addressesTableName string) string {
 	return fmt.Sprintf(`
 WITH addrs AS (
@@ -37,7 +66,7 @@ WITH addrs AS (
 )
 SELECT block_number, tx_id
 FROM %[2]s
-WHERE block_number <= @lastBlock AND address_id = (SELECT id FROM addrs)
+WHERE block_number BETWEEN @firstBlock AND @lastBlock AND address_id = (SELECT id FROM addrs)
 ORDER BY block_number DESC, tx_id DESC
 LIMIT @pageSize;
 `,
@@ -56,7 +85,7 @@ SELECT * FROM (
 	)
 	SELECT block_number, tx_id
 	FROM %[2]s
-	WHERE block_number <= @lastBlock AND address_id = (SELECT id FROM addrs)
+	WHERE block_number BETWEEN @firstBlock AND @lastBlock AND address_id = (SELECT id FROM addrs)
 	ORDER BY block_number ASC, tx_id ASC
 	LIMIT @pageSize
 ) AS x ORDER BY block_number DESC, tx_id DESC;
@@ -76,7 +105,7 @@ WITH addrs AS (
 )
 SELECT block_number, tx_id
 FROM %[2]s
-WHERE block_number <= @lastBlock AND address_id = (SELECT id FROM addrs) AND (block_number, tx_id) < (@appBlockNumber, @appTransactionIndex)
+WHERE block_number BETWEEN @firstBlock AND @lastBlock AND address_id = (SELECT id FROM addrs) AND COALESCE(seq, 0) <= @watermark AND (block_number, tx_id) < (@appBlockNumber, @appTransactionIndex)
 ORDER BY block_number DESC, tx_id DESC
 LIMIT @pageSize;
 `,
@@ -97,7 +126,7 @@ SELECT * FROM (
 	)
 	SELECT block_number, tx_id
 	FROM %[2]s
-	WHERE block_number <= @lastBlock AND address_id = (SELECT id FROM addrs) AND (block_number, tx_id) > (@appBlockNumber, @appTransactionIndex)
+	WHERE block_number BETWEEN @firstBlock AND @lastBlock AND address_id = (SELECT id FROM addrs) AND COALESCE(seq, 0) <= @watermark AND (block_number, tx_id) > (@appBlockNumber, @appTransactionIndex)
 	ORDER BY block_number ASC, tx_id ASC
 	LIMIT @pageSize
 ) AS x ORDER BY block_number DESC, tx_id DESC;
@@ -116,12 +145,12 @@ WITH addrs AS (
 ), apps_desc AS (
     SELECT block_number, tx_id
     FROM %[2]s
-    WHERE block_number <= @lastBlock AND address_id = (SELECT id FROM addrs)
+    WHERE block_number BETWEEN @firstBlock AND @lastBlock AND address_id = (SELECT id FROM addrs)
     ORDER BY block_number DESC, tx_id DESC
 ), apps_asc AS (
     SELECT block_number, tx_id
     FROM %[2]s
-    WHERE block_number <= @lastBlock AND address_id = (SELECT id FROM addrs)
+    WHERE block_number BETWEEN @firstBlock AND @lastBlock AND address_id = (SELECT id FROM addrs)
     ORDER BY block_number ASC, tx_id ASC
 )
 (
@@ -141,6 +170,16 @@ UNION ALL
 	)
 }
 
+// SelectWatermark returns the highest transaction ID below the current snapshot's xmin.
+// Every transaction up to this ID has either committed or rolled back, so no appearance
+// with seq lower or equal this value (see AppearancesSeqDefault) can show up later.
+// It works on replicas too, where it only covers transactions already replayed.
+func SelectWatermark() string {
+	return `
+SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint - 1 AS watermark;
+`
+}
+
 func SelectAppearancesCount(appearancesTableName string) string {
 	return fmt.Sprintf(`
 SELECT reltuples::bigint AS estimate
@@ -150,3 +189,18 @@ WHERE  oid = 'public.%[1]s'::regclass;
 		pgx.Identifier.Sanitize(pgx.Identifier{appearancesTableName}),
 	)
 }
+
+// SelectAppearancesInRange returns all appearances between @firstBlock and @lastBlock
+// (inclusive) in the order of Unchained Index chunks: by address, then block and tx_id
+func SelectAppearancesInRange(appearancesTableName string, addressesTableName string) string {
+	return fmt.Sprintf(`
+SELECT addrs.address, apps.block_number, apps.tx_id
+FROM %[1]s apps
+JOIN %[2]s addrs ON addrs.id = apps.address_id
+WHERE apps.block_number BETWEEN @firstBlock AND @lastBlock
+ORDER BY addrs.address, apps.block_number, apps.tx_id;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{appearancesTableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
+	)
+}
@@ -0,0 +1,114 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func InsertBlock(blocksTableName string) string {
+	return fmt.Sprintf(`
+INSERT INTO %[1]s (block_number, timestamp)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{blocksTableName}),
+	)
+}
+
+func InsertTransaction(transactionsTableName string) string {
+	return fmt.Sprintf(`
+INSERT INTO %[1]s (block_number, tx_id, hash)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{transactionsTableName}),
+	)
+}
+
+// SelectMissingBlocks returns these of @blockNumbers that have no timestamp yet
+func SelectMissingBlocks(blocksTableName string) string {
+	return fmt.Sprintf(`
+SELECT n
+FROM unnest(@blockNumbers::integer[]) AS n
+WHERE NOT EXISTS (SELECT 1 FROM %[1]s b WHERE b.block_number = n)
+ORDER BY n;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{blocksTableName}),
+	)
+}
+
+// SelectMissingBlocksInRange works like SelectMissingBlocks, but checks every block
+// between @firstBlock and @lastBlock (inclusive)
+func SelectMissingBlocksInRange(blocksTableName string) string {
+	return fmt.Sprintf(`
+SELECT n::integer
+FROM generate_series(@firstBlock::integer, @lastBlock::integer) AS n
+WHERE NOT EXISTS (SELECT 1 FROM %[1]s b WHERE b.block_number = n)
+ORDER BY n;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{blocksTableName}),
+	)
+}
+
+func SelectBlockTimestamps(blocksTableName string) string {
+	return fmt.Sprintf(`
+SELECT block_number, timestamp
+FROM %[1]s
+WHERE block_number = ANY(@blockNumbers::integer[]);
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{blocksTableName}),
+	)
+}
+
+// SelectTransactionHashes expects two arrays of the same length: @blockNumbers and @txIds,
+// which together identify transactions
+func SelectTransactionHashes(transactionsTableName string) string {
+	return fmt.Sprintf(`
+SELECT t.block_number, t.tx_id, t.hash
+FROM unnest(@blockNumbers::integer[], @txIds::integer[]) AS ids(block_number, tx_id)
+JOIN %[1]s t ON t.block_number = ids.block_number AND t.tx_id = ids.tx_id;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{transactionsTableName}),
+	)
+}
+
+// SelectBlockAtOrBeforeTimestamp returns the latest block mined at or before @timestamp.
+// Only enriched blocks are stored, so covered tells if the next block is stored too (which
+// proves that there is no later block at or before @timestamp).
+func SelectBlockAtOrBeforeTimestamp(blocksTableName string) string {
+	return fmt.Sprintf(`
+SELECT b.block_number, b.timestamp, EXISTS (SELECT 1 FROM %[1]s n WHERE n.block_number = b.block_number + 1) AS covered
+FROM %[1]s b
+WHERE b.timestamp <= @timestamp
+ORDER BY b.timestamp DESC, b.block_number DESC
+LIMIT 1;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{blocksTableName}),
+	)
+}
+
+// SelectBlockAtOrAfterTimestamp returns the earliest block mined at or after @timestamp.
+// covered works like in SelectBlockAtOrBeforeTimestamp, but checks the previous block.
+func SelectBlockAtOrAfterTimestamp(blocksTableName string) string {
+	return fmt.Sprintf(`
+SELECT b.block_number, b.timestamp, (b.block_number = 0 OR EXISTS (SELECT 1 FROM %[1]s p WHERE p.block_number = b.block_number - 1)) AS covered
+FROM %[1]s b
+WHERE b.timestamp >= @timestamp
+ORDER BY b.timestamp ASC, b.block_number ASC
+LIMIT 1;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{blocksTableName}),
+	)
+}
+
+// SelectBlocksBounds returns the lowest and the highest stored block number (or NULLs
+// if there are no blocks)
+func SelectBlocksBounds(blocksTableName string) string {
+	return fmt.Sprintf(`
+SELECT MIN(block_number), MAX(block_number)
+FROM %[1]s;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{blocksTableName}),
+	)
+}
@@ -33,3 +33,15 @@ SELECT count(*) FROM %[1]s;
 		pgx.Identifier.Sanitize(pgx.Identifier{chunksTableName}),
 	)
 }
+
+// SelectLastChunk returns the chunk with the highest range. Ranges are zero-padded,
+// so ordering them as strings gives the right result.
+func SelectLastChunk(chunksTableName string) string {
+	return fmt.Sprintf(`
+SELECT cid, range, author FROM %[1]s
+ORDER BY range DESC
+LIMIT 1;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{chunksTableName}),
+	)
+}
@@ -0,0 +1,34 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateDataVersionSequence creates the sequence that counts writes of appearances and
+// blocks. Sequences are not transactional and don't lock rows, so concurrent writers
+// don't wait for each other when they bump it.
+func CreateDataVersionSequence(sequenceName string) string {
+	return fmt.Sprintf(`
+CREATE SEQUENCE IF NOT EXISTS %s;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{sequenceName}),
+	)
+}
+
+func BumpDataVersion(sequenceName string) string {
+	return fmt.Sprintf(`
+SELECT nextval('%s');
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{sequenceName}),
+	)
+}
+
+func SelectDataVersion(sequenceName string) string {
+	return fmt.Sprintf(`
+SELECT last_value AS data_version FROM %s;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{sequenceName}),
+	)
+}
@@ -0,0 +1,25 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Explain wraps a query, so that it returns JSON plan with execution statistics.
+// Note that the query is executed.
+func Explain(query string) string {
+	return "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) " + strings.TrimSpace(query)
+}
+
+// SelectSampleAddresses returns up to @limit addresses spread across the table
+func SelectSampleAddresses(addressesTableName string) string {
+	return fmt.Sprintf(`
+SELECT address
+FROM %[1]s TABLESAMPLE SYSTEM (1)
+LIMIT @limit;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
+	)
+}
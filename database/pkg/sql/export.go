@@ -0,0 +1,59 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// COPY doesn't accept parameters, so filters are formatted into the queries. Callers
+// have to validate them (see database.ExportFilter).
+
+// CopyAppearancesTo streams appearances with address strings instead of address ids
+// as CSV (address, block_number, tx_id)
+func CopyAppearancesTo(appearancesTableName string, addressesTableName string, binaryAddresses bool, conditions []string) string {
+	return fmt.Sprintf(`
+COPY (
+    SELECT %[3]s AS address, apps.block_number, apps.tx_id
+    FROM %[1]s apps
+    JOIN %[2]s addrs ON addrs.id = apps.address_id
+    %[4]s
+) TO STDOUT (FORMAT csv, HEADER);
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{appearancesTableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
+		AddressText("addrs.address", binaryAddresses),
+		where(conditions),
+	)
+}
+
+// CopyAddressesTo streams addresses as CSV (id, address)
+func CopyAddressesTo(addressesTableName string, binaryAddresses bool, conditions []string) string {
+	return fmt.Sprintf(`
+COPY (
+    SELECT addrs.id, %[2]s AS address
+    FROM %[1]s addrs
+    %[3]s
+) TO STDOUT (FORMAT csv, HEADER);
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
+		AddressText("addrs.address", binaryAddresses),
+		where(conditions),
+	)
+}
+
+// AddressText returns expression converting address column to 0x-prefixed hex string
+func AddressText(column string, binaryAddresses bool) string {
+	if binaryAddresses {
+		return fmt.Sprintf(`'0x' || encode(%s, 'hex')`, column)
+	}
+	return column
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,145 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Imported rows are loaded by COPY FROM into temporary tables with TEXT columns first and
+// cast when moved into target tables, so that any text export can be loaded.
+
+const ImportAddressesTableName = "import_addresses"
+const ImportAppearancesTableName = "import_appearances"
+
+// ImportAddressMapTableName maps address ids found in imported files to ids in the target
+// addresses table
+const ImportAddressMapTableName = "import_address_map"
+
+// CreateImportTable creates (or empties) temporary table with TEXT columns
+func CreateImportTable(tableName string, columns []string) string {
+	definitions := make([]string, 0, len(columns))
+	for _, column := range columns {
+		definitions = append(definitions, pgx.Identifier.Sanitize(pgx.Identifier{column})+" TEXT")
+	}
+	return fmt.Sprintf(`
+CREATE TEMPORARY TABLE IF NOT EXISTS %[1]s (%[2]s);
+TRUNCATE %[1]s;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+		strings.Join(definitions, ", "),
+	)
+}
+
+func CreateImportAddressMap() string {
+	return fmt.Sprintf(`
+CREATE TEMPORARY TABLE IF NOT EXISTS %s (
+    old_id BIGINT PRIMARY KEY,
+    address_id BIGINT NOT NULL
+);
+`, pgx.Identifier.Sanitize(pgx.Identifier{ImportAddressMapTableName}))
+}
+
+// InsertImportedAddresses inserts addresses that are not in the target table yet
+func InsertImportedAddresses(importTableName string, addressesTableName string, binaryAddresses bool) string {
+	return fmt.Sprintf(`
+INSERT INTO %[2]s (address)
+SELECT DISTINCT %[3]s FROM %[1]s i
+ON CONFLICT (address) DO NOTHING;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{importTableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
+		HexAddress("i.address", binaryAddresses),
+	)
+}
+
+// InsertImportAddressMap records target ids of imported addresses (i.id are ids from
+// the exported database)
+func InsertImportAddressMap(addressesTableName string, binaryAddresses bool) string {
+	return fmt.Sprintf(`
+INSERT INTO %[3]s (old_id, address_id)
+SELECT i.id::bigint, addrs.id
+FROM %[1]s i
+JOIN %[2]s addrs ON addrs.address = %[4]s
+ON CONFLICT (old_id) DO UPDATE SET address_id = EXCLUDED.address_id;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{ImportAddressesTableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{ImportAddressMapTableName}),
+		HexAddress("i.address", binaryAddresses),
+	)
+}
+
+// CountImportAddressMap counts imported addresses that are mapped to target ids
+func CountImportAddressMap() string {
+	return fmt.Sprintf(`
+SELECT count(*)
+FROM %[1]s i
+JOIN %[2]s m ON m.old_id = i.id::bigint;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{ImportAddressesTableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{ImportAddressMapTableName}),
+	)
+}
+
+// SelectImportedMaxBlock is used to create partitions before inserting appearances
+func SelectImportedMaxBlock() string {
+	return fmt.Sprintf(
+		`SELECT COALESCE(max(block_number::integer), 0) FROM %s;`,
+		pgx.Identifier.Sanitize(pgx.Identifier{ImportAppearancesTableName}),
+	)
+}
+
+// importedAppearancesJoin joins imported appearances with target address ids. byId means
+// that the file has address ids of the exported database instead of addresses.
+func importedAppearancesJoin(addressesTableName string, binaryAddresses bool, byId bool) string {
+	if byId {
+		return fmt.Sprintf(
+			`FROM %s i JOIN %s m ON m.old_id = i.address_id::bigint`,
+			pgx.Identifier.Sanitize(pgx.Identifier{ImportAppearancesTableName}),
+			pgx.Identifier.Sanitize(pgx.Identifier{ImportAddressMapTableName}),
+		)
+	}
+	return fmt.Sprintf(
+		`FROM %s i JOIN %s m ON m.address = %s`,
+		pgx.Identifier.Sanitize(pgx.Identifier{ImportAppearancesTableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
+		HexAddress("i.address", binaryAddresses),
+	)
+}
+
+func importedAddressId(byId bool) string {
+	if byId {
+		return "m.address_id"
+	}
+	return "m.id"
+}
+
+// InsertImportedAppearances inserts appearances that are not in the target table yet
+func InsertImportedAppearances(appearancesTableName string, addressesTableName string, binaryAddresses bool, byId bool) string {
+	return fmt.Sprintf(`
+INSERT INTO %[1]s (address_id, block_number, tx_id)
+SELECT %[2]s, i.block_number::integer, i.tx_id::integer
+%[3]s
+ORDER BY i.block_number::integer, i.tx_id::integer
+ON CONFLICT DO NOTHING;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{appearancesTableName}),
+		importedAddressId(byId),
+		importedAppearancesJoin(addressesTableName, binaryAddresses, byId),
+	)
+}
+
+// CountImportedAppearances counts imported rows that are present in the target table
+func CountImportedAppearances(appearancesTableName string, addressesTableName string, binaryAddresses bool, byId bool) string {
+	return fmt.Sprintf(`
+SELECT count(*)
+%[3]s
+JOIN %[1]s apps ON apps.address_id = %[2]s AND apps.block_number = i.block_number::integer AND apps.tx_id = i.tx_id::integer;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{appearancesTableName}),
+		importedAddressId(byId),
+		importedAppearancesJoin(addressesTableName, binaryAddresses, byId),
+	)
+}
@@ -0,0 +1,171 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Online reclustering builds a physically ordered copy of appearances table next to the
+// live one. Appearances are never updated or deleted, so rows inserted during the copy
+// can be found by their seq (see SelectWatermark) and copied before the swap.
+
+// The watermark follows commit order: every transaction with ID up to the watermark has
+// finished before we read it. So batches copying rows with seq up to the initial watermark
+// see all of them, no matter when a batch runs. Everything else (seq above the initial
+// watermark) is copied by the final swap under the table lock, which waits for transactions
+// that are still inserting (using index on seq, see CreateReclusterSeqIndex).
+
+// ReclusterTableName is the name of the copy that is being built
+func ReclusterTableName(tableName string) string {
+	return tableName + "_recluster"
+}
+
+// PreReclusterTableName is the name of the original table after the swap
+func PreReclusterTableName(tableName string) string {
+	return tableName + "_pre_recluster"
+}
+
+// CreateReclusterTable creates an empty copy without indexes, so that the copy is fast
+func CreateReclusterTable(tableName string) string {
+	return fmt.Sprintf(`
+CREATE TABLE %[2]s (LIKE %[1]s INCLUDING DEFAULTS);
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{ReclusterTableName(tableName)}),
+	)
+}
+
+func ReclusterSeqIndexName(tableName string) string {
+	return tableName + "_seq_idx"
+}
+
+// CreateReclusterSeqIndex creates index used to find rows inserted during the copy. It must not
+// be run in a transaction.
+func CreateReclusterSeqIndex(tableName string) string {
+	return fmt.Sprintf(`
+CREATE INDEX CONCURRENTLY IF NOT EXISTS %[2]s ON %[1]s (seq) WHERE seq IS NOT NULL;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{ReclusterSeqIndexName(tableName)}),
+	)
+}
+
+// CopyReclusterBatch copies appearances of addresses with IDs in [@fromId, @toId) that are
+// visible at @watermark, in the order in which we want them on disk
+func CopyReclusterBatch(tableName string) string {
+	return fmt.Sprintf(`
+INSERT INTO %[2]s (address_id, block_number, tx_id, seq)
+SELECT address_id, block_number, tx_id, seq
+FROM %[1]s
+WHERE address_id >= @fromId AND address_id < @toId AND COALESCE(seq, 0) <= @watermark
+ORDER BY address_id, block_number, tx_id;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{ReclusterTableName(tableName)}),
+	)
+}
+
+// CopyReclusterCatchUp copies appearances inserted after @watermark. It needs the unique
+// constraint on the copy (see CreateReclusterIndexes).
+func CopyReclusterCatchUp(tableName string) string {
+	return fmt.Sprintf(`
+INSERT INTO %[2]s (address_id, block_number, tx_id, seq)
+SELECT address_id, block_number, tx_id, seq
+FROM %[1]s
+WHERE seq > @watermark
+ORDER BY address_id, block_number, tx_id
+ON CONFLICT DO NOTHING;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{ReclusterTableName(tableName)}),
+	)
+}
+
+// CreateReclusterIndexes creates the same constraint and indexes as the live table has
+// (including seq index, see CreateReclusterSeqIndex), under temporary names
+func CreateReclusterIndexes(tableName string) string {
+	newTable := ReclusterTableName(tableName)
+	return fmt.Sprintf(`
+ALTER TABLE %[1]s ADD CONSTRAINT %[1]s_appearances_unique UNIQUE(address_id, block_number, tx_id);
+%[2]s
+CREATE INDEX %[3]s ON %[4]s (seq) WHERE seq IS NOT NULL;
+ANALYZE %[1]s;
+`,
+		newTable,
+		CreateAppearancesOrderIndex(newTable),
+		pgx.Identifier.Sanitize(pgx.Identifier{ReclusterSeqIndexName(newTable)}),
+		pgx.Identifier.Sanitize(pgx.Identifier{newTable}),
+	)
+}
+
+// SwapRecluster copies the remaining appearances (inserted after initialWatermark) and swaps
+// the tables in a single transaction. Inserts wait for the lock, reads continue until the rename.
+func SwapRecluster(tableName string, addressesTableName string, initialWatermark uint64) string {
+	newTable := ReclusterTableName(tableName)
+	oldTable := PreReclusterTableName(tableName)
+	return fmt.Sprintf(`
+BEGIN;
+LOCK TABLE %[1]s IN SHARE ROW EXCLUSIVE MODE;
+INSERT INTO %[2]s (address_id, block_number, tx_id, seq)
+SELECT address_id, block_number, tx_id, seq
+FROM %[1]s
+WHERE seq > %[5]d
+ON CONFLICT DO NOTHING;
+ALTER TABLE %[1]s RENAME TO %[3]s;
+ALTER TABLE %[3]s RENAME CONSTRAINT %[1]s_appearances_unique TO %[3]s_appearances_unique;
+ALTER INDEX IF EXISTS %[1]s_appearances_order RENAME TO %[3]s_appearances_order;
+ALTER INDEX IF EXISTS %[1]s_address RENAME TO %[3]s_address;
+ALTER INDEX IF EXISTS %[1]s_seq_idx RENAME TO %[3]s_seq_idx;
+ALTER TABLE %[2]s RENAME TO %[1]s;
+ALTER TABLE %[1]s RENAME CONSTRAINT %[2]s_appearances_unique TO %[1]s_appearances_unique;
+ALTER INDEX %[2]s_appearances_order RENAME TO %[1]s_appearances_order;
+ALTER INDEX IF EXISTS %[2]s_address RENAME TO %[1]s_address;
+ALTER INDEX %[2]s_seq_idx RENAME TO %[1]s_seq_idx;
+ALTER TABLE %[1]s ADD CONSTRAINT %[6]s FOREIGN KEY (address_id) REFERENCES %[4]s(id) ON DELETE RESTRICT NOT VALID;
+COMMIT;
+`, tableName, newTable, oldTable, addressesTableName, initialWatermark, ReclusterForeignKeyName(tableName))
+}
+
+func ReclusterForeignKeyName(tableName string) string {
+	return tableName + "_address_id_fkey"
+}
+
+// ValidateReclusterForeignKey checks rows of the swapped table against addresses. Unlike
+// adding a valid constraint, it doesn't block inserts, so it runs after the swap.
+func ValidateReclusterForeignKey(tableName string) string {
+	return fmt.Sprintf(`
+ALTER TABLE %[1]s VALIDATE CONSTRAINT %[2]s;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{ReclusterForeignKeyName(tableName)}),
+	)
+}
+
+func SelectMaxAddressId(addressesTableName string) string {
+	return fmt.Sprintf(`
+SELECT COALESCE(MAX(id), 0) FROM %[1]s;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
+	)
+}
+
+// SelectTableSize returns estimated row count, table size and indexes size in bytes
+func SelectTableSize(tableName string) string {
+	return fmt.Sprintf(`
+SELECT reltuples::bigint, pg_table_size(oid), pg_indexes_size(oid)
+FROM pg_class
+WHERE oid = '%[1]s'::regclass;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+	)
+}
+
+// SelectRelationExists returns true if a table, index or sequence with the name exists
+func SelectRelationExists(name string) string {
+	return fmt.Sprintf(`
+SELECT to_regclass('%[1]s') IS NOT NULL;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{name}),
+	)
+}
@@ -6,11 +6,87 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
-func SelectStatus(appearancesTableName string, addressesTableName string) string {
+func SelectStatus(statusTableName string) string {
 	return fmt.Sprintf(`
-SELECT max(block_number) as lastIndexedBlock
+SELECT last_indexed_block, last_chunk_range, last_notification_at, updated_at, now() AS read_at
 FROM %[1]s;
 `,
+		pgx.Identifier.Sanitize(pgx.Identifier{statusTableName}),
+	)
+}
+
+// UpdateStatusLastIndexedBlock never moves the last indexed block backwards, so it is
+// safe to call it with appearances that arrive out of order. The row is only locked when
+// the block moves forward (ON CONFLICT DO UPDATE would lock it every time), so consumers
+// that are behind don't wait for each other.
+func UpdateStatusLastIndexedBlock(statusTableName string) string {
+	return fmt.Sprintf(`
+WITH updated AS (
+    UPDATE %[1]s
+    SET last_indexed_block = @lastIndexedBlock,
+        updated_at = now()
+    WHERE last_indexed_block < @lastIndexedBlock
+    RETURNING id
+)
+INSERT INTO %[1]s (last_indexed_block)
+SELECT @lastIndexedBlock
+WHERE NOT EXISTS (SELECT 1 FROM %[1]s)
+ON CONFLICT (id) DO NOTHING;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{statusTableName}),
+	)
+}
+
+// UpdateStatusLastChunk works like UpdateStatusLastIndexedBlock. Chunk ranges are zero-padded,
+// so we can compare them as strings.
+func UpdateStatusLastChunk(statusTableName string) string {
+	return fmt.Sprintf(`
+INSERT INTO %[1]s (last_chunk_range)
+VALUES (@lastChunkRange)
+ON CONFLICT (id) DO UPDATE
+SET last_chunk_range = GREATEST(%[1]s.last_chunk_range, EXCLUDED.last_chunk_range),
+    updated_at = now();
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{statusTableName}),
+	)
+}
+
+// UpdateStatusLastNotification records when the scraper sent the latest notification
+// that we have ingested. Like UpdateStatusLastIndexedBlock, it only locks the row when
+// the time moves forward.
+func UpdateStatusLastNotification(statusTableName string) string {
+	return fmt.Sprintf(`
+WITH updated AS (
+    UPDATE %[1]s
+    SET last_notification_at = @lastNotificationAt,
+        updated_at = now()
+    WHERE last_notification_at IS NULL OR last_notification_at < @lastNotificationAt
+    RETURNING id
+)
+INSERT INTO %[1]s (last_notification_at)
+SELECT @lastNotificationAt
+WHERE NOT EXISTS (SELECT 1 FROM %[1]s)
+ON CONFLICT (id) DO NOTHING;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{statusTableName}),
+	)
+}
+
+// InitStatus creates the status row for tables that were populated before
+// the status table existed. It computes the values the slow way, so it should be called once.
+func InitStatus(statusTableName string, appearancesTableName string, chunksTableName string) string {
+	return fmt.Sprintf(`
+INSERT INTO %[1]s (last_indexed_block, last_chunk_range)
+SELECT
+    COALESCE((SELECT max(block_number) FROM %[2]s), 0),
+    COALESCE((SELECT max(range) FROM %[3]s), '')
+ON CONFLICT (id) DO UPDATE
+SET last_indexed_block = GREATEST(%[1]s.last_indexed_block, EXCLUDED.last_indexed_block),
+    last_chunk_range = GREATEST(%[1]s.last_chunk_range, EXCLUDED.last_chunk_range),
+    updated_at = now();
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{statusTableName}),
 		pgx.Identifier.Sanitize(pgx.Identifier{appearancesTableName}),
+		pgx.Identifier.Sanitize(pgx.Identifier{chunksTableName}),
 	)
 }
@@ -1,12 +1,52 @@
 package sql
 
-import "fmt"
+import (
+	"fmt"
 
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateTableAddresses creates addresses table storing 20-byte addresses as BYTEA.
+// Tables created before used lowercase hex VARCHAR(42), see MigrateAddressesToBinary.
 func CreateTableAddresses(tableName string) string {
 	return fmt.Sprintf(`
 CREATE TABLE %s (
     id BIGSERIAL UNIQUE,
-    address VARCHAR(42) UNIQUE
+    address BYTEA UNIQUE CHECK (octet_length(address) = 20)
 );
 `, tableName)
 }
+
+// SelectAddressesBinary returns true if address column is BYTEA, false if it is
+// VARCHAR (or the table doesn't exist yet)
+func SelectAddressesBinary(addressesTableName string) string {
+	return fmt.Sprintf(`
+SELECT COALESCE((
+    SELECT atttypid = 'bytea'::regtype
+    FROM pg_attribute
+    WHERE attrelid = to_regclass('%[1]s') AND attname = 'address' AND NOT attisdropped
+), false);
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{addressesTableName}),
+	)
+}
+
+// HexAddress converts address text in column (0x or \x prefixed hex, as written by COPY
+// for VARCHAR and BYTEA columns) to the format of addresses table
+func HexAddress(column string, binaryAddresses bool) string {
+	hex := fmt.Sprintf(`lower(CASE WHEN left(%[1]s, 2) IN ('0x', '\x') THEN substr(%[1]s, 3) ELSE %[1]s END)`, column)
+	if binaryAddresses {
+		return fmt.Sprintf(`decode(%s, 'hex')`, hex)
+	}
+	return `'0x' || ` + hex
+}
+
+// MigrateAddressesToBinary converts VARCHAR(42) addresses to BYTEA. It rewrites the table
+// and its indexes, locking it for the whole time.
+func MigrateAddressesToBinary(tableName string) string {
+	return fmt.Sprintf(`
+ALTER TABLE %[1]s
+    ALTER COLUMN address TYPE BYTEA USING decode(substr(address, 3), 'hex'),
+    ADD CHECK (octet_length(address) = 20);
+`, pgx.Identifier.Sanitize(pgx.Identifier{tableName}))
+}
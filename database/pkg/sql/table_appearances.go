@@ -1,17 +1,22 @@
 package sql
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
 
 func CreateTableAppearances(tableName string, addressesTableName string) string {
 	constraintName := tableName + "_appearances_unique"
 	return fmt.Sprintf(`
-CREATE TABLE %s(
-    address_id BIGINT REFERENCES %s(id) ON DELETE RESTRICT,
+CREATE TABLE %[1]s(
+    address_id BIGINT REFERENCES %[2]s(id) ON DELETE RESTRICT,
     block_number INTEGER,
     tx_id INTEGER,
-    CONSTRAINT %s UNIQUE(address_id, block_number, tx_id)
+    seq BIGINT DEFAULT %[4]s,
+    CONSTRAINT %[3]s UNIQUE(address_id, block_number, tx_id)
 );
-`, tableName, addressesTableName, constraintName)
+`, tableName, addressesTableName, constraintName, AppearancesSeqDefault)
 }
 
 func CreateAppearancesOrderIndex(tableName string) string {
@@ -20,3 +25,71 @@ func CreateAppearancesOrderIndex(tableName string) string {
 CREATE INDEX %s ON %s (block_number DESC NULLS LAST, tx_id ASC NULLS LAST);
 `, indexName, tableName)
 }
+
+// CreateAppearancesAddressIndex creates index matching appearance queries: they filter by
+// address_id and order by block_number and tx_id. seq is included, so that filtering by
+// watermark doesn't need to read the table. Use concurrently for tables that are in use
+// (it cannot run in a transaction and is not supported on partitioned tables, see
+// CreateAppearancesAddressIndexOnly).
+func CreateAppearancesAddressIndex(tableName string, concurrently bool) string {
+	indexName := AppearancesAddressIndexName(tableName)
+	var modifier string
+	if concurrently {
+		modifier = "CONCURRENTLY "
+	}
+	return fmt.Sprintf(`
+CREATE INDEX %sIF NOT EXISTS %s ON %s (address_id, block_number DESC, tx_id DESC) INCLUDE (seq);
+`, modifier, indexName, tableName)
+}
+
+// CreateAppearancesAddressIndexOnly creates address index of a partitioned table without
+// indexing its partitions. The index is invalid until indexes of all partitions are attached
+// (see AttachAppearancesAddressIndex), partitions created later get it automatically.
+func CreateAppearancesAddressIndexOnly(tableName string) string {
+	return fmt.Sprintf(`
+CREATE INDEX IF NOT EXISTS %s ON ONLY %s (address_id, block_number DESC, tx_id DESC) INCLUDE (seq);
+`, AppearancesAddressIndexName(tableName), tableName)
+}
+
+// AttachAppearancesAddressIndex attaches partition's address index to the partitioned
+// table's one. Attaching an index that is already attached does nothing.
+func AttachAppearancesAddressIndex(tableName string, partitionName string) string {
+	return fmt.Sprintf(`
+ALTER INDEX %s ATTACH PARTITION %s;
+`, AppearancesAddressIndexName(tableName), AppearancesAddressIndexName(partitionName))
+}
+
+func AppearancesAddressIndexName(tableName string) string {
+	return tableName + "_address"
+}
+
+// AppearancesSeqDefault is the default value of appearances' seq column: 64-bit ID of
+// the inserting transaction. Unlike sequence values, transaction IDs can be compared with
+// snapshot's xmin, which tells us that all transactions below it have finished (so
+// a watermark built from it follows commit order, see SelectWatermark).
+const AppearancesSeqDefault = "(pg_current_xact_id()::text::bigint)"
+
+// AddAppearancesSeqColumn migrates an existing appearances table. Rows that were
+// already present get NULL seq and are treated as part of every snapshot. The default
+// is set in a separate statement, so that Postgres does not rewrite the whole table.
+// Tables that used appearances sequence for seq are switched to transaction IDs and
+// the sequence is dropped.
+func AddAppearancesSeqColumn(tableName string) string {
+	return fmt.Sprintf(`
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS seq BIGINT;
+ALTER TABLE %[1]s ALTER COLUMN seq SET DEFAULT %[2]s;
+DROP SEQUENCE IF EXISTS %[1]s_seq;
+`, tableName, AppearancesSeqDefault)
+}
+
+// SelectHasSeqColumn returns true if the table has seq column (see AddAppearancesSeqColumn)
+func SelectHasSeqColumn(tableName string) string {
+	return fmt.Sprintf(`
+SELECT EXISTS (
+    SELECT 1 FROM pg_attribute
+    WHERE attrelid = '%[1]s'::regclass AND attname = 'seq' AND NOT attisdropped
+);
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+	)
+}
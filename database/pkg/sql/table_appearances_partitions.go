@@ -0,0 +1,145 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Appearances table can be range-partitioned by block_number. Postgres only reads
+// partitions matching block_number conditions, so every Select builder that limits
+// blocks (e.g. `block_number BETWEEN @firstBlock AND @lastBlock`) gets partition pruning.
+
+// CreateTablePartitionedAppearances works like CreateTableAppearances, but creates a table
+// partitioned by block_number. Partitions have to be created with CreateAppearancesPartition.
+func CreateTablePartitionedAppearances(tableName string, addressesTableName string) string {
+	constraintName := tableName + "_appearances_unique"
+	return fmt.Sprintf(`
+CREATE TABLE %[1]s(
+    address_id BIGINT REFERENCES %[2]s(id) ON DELETE RESTRICT,
+    block_number INTEGER NOT NULL,
+    tx_id INTEGER,
+    seq BIGINT DEFAULT %[4]s,
+    CONSTRAINT %[3]s UNIQUE(address_id, block_number, tx_id)
+) PARTITION BY RANGE (block_number);
+`, tableName, addressesTableName, constraintName, AppearancesSeqDefault)
+}
+
+// AppearancesPartitionName returns the name of index-th partition
+func AppearancesPartitionName(tableName string, index uint32) string {
+	return fmt.Sprintf("%s_p%04d", tableName, index)
+}
+
+// AppearancesLegacyPartitionName is the name of the partition holding appearances
+// of a table that was created before partitioning (see PartitionAppearances)
+func AppearancesLegacyPartitionName(tableName string) string {
+	return tableName + "_legacy"
+}
+
+// CreateAppearancesPartition creates index-th partition, holding blocks from
+// index * partitionSize (inclusive) to (index + 1) * partitionSize (exclusive).
+// It does nothing (and doesn't lock the parent table) if the partition exists. Overlapping
+// an existing partition (e.g. the legacy one) is not an error, as the blocks are covered.
+func CreateAppearancesPartition(tableName string, partitionSize uint32, index uint32) string {
+	from := uint64(index) * uint64(partitionSize)
+	return fmt.Sprintf(`
+DO $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS %[1]s PARTITION OF %[2]s FOR VALUES FROM (%[3]d) TO (%[4]d);
+EXCEPTION WHEN invalid_object_definition THEN
+    NULL;
+END $$;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{AppearancesPartitionName(tableName, index)}),
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+		from,
+		from+uint64(partitionSize),
+	)
+}
+
+func SelectIsPartitioned(tableName string) string {
+	return fmt.Sprintf(`
+SELECT relkind = 'p' FROM pg_class WHERE oid = '%[1]s'::regclass;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+	)
+}
+
+// SelectAppearancesPartitions lists partitions with their bounds and estimated row count
+func SelectAppearancesPartitions(tableName string) string {
+	return fmt.Sprintf(`
+SELECT child.relname::text, pg_get_expr(child.relpartbound, child.oid), child.reltuples::bigint
+FROM pg_inherits
+JOIN pg_class parent ON parent.oid = pg_inherits.inhparent
+JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+WHERE parent.oid = '%[1]s'::regclass
+ORDER BY child.relname;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+	)
+}
+
+// SelectAppearancesPartitionsUpperBlock returns the highest upper bound of partitions
+// (the first block they don't cover), 0 if there are no partitions
+func SelectAppearancesPartitionsUpperBlock(tableName string) string {
+	return fmt.Sprintf(`
+SELECT COALESCE(MAX(substring(pg_get_expr(child.relpartbound, child.oid) FROM 'TO \((\d+)\)')::bigint), 0)
+FROM pg_inherits
+JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+WHERE pg_inherits.inhparent = '%[1]s'::regclass;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+	)
+}
+
+func SelectAppearancesMaxBlock(tableName string) string {
+	return fmt.Sprintf(`
+SELECT COALESCE(MAX(block_number), 0) FROM %[1]s;
+`,
+		pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
+	)
+}
+
+// AddAppearancesRangeCheck adds a constraint proving that all appearances are below
+// @upperBlock, so that the table can be attached as a partition without a long lock.
+// Validation scans the whole table, but it doesn't block inserts.
+func AddAppearancesRangeCheck(tableName string, upperBlock uint64) string {
+	constraintName := tableName + "_range_check"
+	return fmt.Sprintf(`
+ALTER TABLE %[1]s ADD CONSTRAINT %[2]s CHECK (block_number IS NOT NULL AND block_number >= 0 AND block_number < %[3]d) NOT VALID;
+ALTER TABLE %[1]s VALIDATE CONSTRAINT %[2]s;
+`, tableName, constraintName, upperBlock)
+}
+
+// PartitionAppearances turns an existing appearances table into a legacy partition of
+// a new partitioned table, without copying any data. AddAppearancesRangeCheck has to be
+// run with the same upperBlock first. Next partitions start at upperBlock, so it should
+// be a multiple of the partition size.
+func PartitionAppearances(tableName string, addressesTableName string, upperBlock uint64) string {
+	legacyName := AppearancesLegacyPartitionName(tableName)
+	return fmt.Sprintf(`
+BEGIN;
+LOCK TABLE %[1]s IN ACCESS EXCLUSIVE MODE;
+ALTER TABLE %[1]s RENAME TO %[2]s;
+ALTER TABLE %[2]s RENAME CONSTRAINT %[1]s_appearances_unique TO %[2]s_appearances_unique;
+ALTER INDEX IF EXISTS %[1]s_appearances_order RENAME TO %[2]s_appearances_order;
+ALTER TABLE %[2]s ALTER COLUMN block_number SET NOT NULL;
+CREATE TABLE %[1]s(
+    address_id BIGINT REFERENCES %[3]s(id) ON DELETE RESTRICT,
+    block_number INTEGER NOT NULL,
+    tx_id INTEGER,
+    seq BIGINT DEFAULT %[4]s,
+    CONSTRAINT %[1]s_appearances_unique UNIQUE(address_id, block_number, tx_id)
+) PARTITION BY RANGE (block_number);
+ALTER TABLE %[1]s ATTACH PARTITION %[2]s FOR VALUES FROM (0) TO (%[5]d);
+%[6]s
+COMMIT;
+`,
+		tableName,
+		legacyName,
+		addressesTableName,
+		AppearancesSeqDefault,
+		upperBlock,
+		CreateAppearancesOrderIndex(tableName),
+	)
+}
@@ -0,0 +1,33 @@
+package sql
+
+import "fmt"
+
+// CreateTableBlocks creates table storing block timestamps, used to enrich appearances
+func CreateTableBlocks(tableName string) string {
+	return fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+    block_number INTEGER PRIMARY KEY,
+    timestamp BIGINT NOT NULL
+);
+`, tableName)
+}
+
+// CreateTableTransactions creates table storing transaction hashes, used to enrich appearances
+func CreateTableTransactions(tableName string) string {
+	return fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+    block_number INTEGER,
+    tx_id INTEGER,
+    hash VARCHAR(66) NOT NULL,
+    PRIMARY KEY(block_number, tx_id)
+);
+`, tableName)
+}
+
+// CreateBlocksTimestampIndex creates index used to find blocks by timestamp
+func CreateBlocksTimestampIndex(tableName string) string {
+	indexName := tableName + "_timestamp"
+	return fmt.Sprintf(`
+CREATE INDEX IF NOT EXISTS %s ON %s (timestamp);
+`, indexName, tableName)
+}
@@ -0,0 +1,17 @@
+package sql
+
+import "fmt"
+
+// CreateTableStatus creates single-row table that stores the state of the index,
+// so that we don't have to compute it from appearances on every request
+func CreateTableStatus(tableName string) string {
+	return fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_indexed_block INTEGER NOT NULL DEFAULT 0,
+    last_chunk_range VARCHAR(47) NOT NULL DEFAULT '',
+    last_notification_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+`, tableName)
+}
@@ -0,0 +1,177 @@
+package sqliteStore
+
+// Queries mirror the Postgres ones in database/pkg/sql. Addresses are stored as lowercase
+// hex text and appearance rowid works as seq.
+
+const schema = `
+CREATE TABLE IF NOT EXISTS appearances (
+    seq INTEGER PRIMARY KEY,
+    address TEXT NOT NULL,
+    block_number INTEGER NOT NULL,
+    tx_id INTEGER NOT NULL,
+    UNIQUE (address, block_number, tx_id)
+);
+CREATE INDEX IF NOT EXISTS appearances_block ON appearances (block_number, tx_id, address);
+
+CREATE TABLE IF NOT EXISTS blocks (
+    block_number INTEGER PRIMARY KEY,
+    timestamp INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS blocks_timestamp ON blocks (timestamp);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    block_number INTEGER NOT NULL,
+    tx_id INTEGER NOT NULL,
+    hash TEXT NOT NULL,
+    PRIMARY KEY (block_number, tx_id)
+);
+
+CREATE TABLE IF NOT EXISTS chunks (
+    cid TEXT PRIMARY KEY,
+    range TEXT NOT NULL,
+    author TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS status (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_indexed_block INTEGER NOT NULL DEFAULT 0,
+    last_chunk_range TEXT NOT NULL DEFAULT '',
+    updated_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS data_version (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    version INTEGER NOT NULL
+);
+`
+
+const insertAppearance = `
+INSERT INTO appearances (address, block_number, tx_id) VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING;
+`
+
+const insertBlock = `
+INSERT INTO blocks (block_number, timestamp) VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+`
+
+const insertTransaction = `
+INSERT INTO transactions (block_number, tx_id, hash) VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING;
+`
+
+const insertChunk = `
+INSERT INTO chunks (cid, range, author) VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING;
+`
+
+// status only moves forward
+const updateStatusLastIndexedBlock = `
+INSERT INTO status (id, last_indexed_block, updated_at) VALUES (1, ?1, ?2)
+ON CONFLICT (id) DO UPDATE SET
+    last_indexed_block = max(last_indexed_block, excluded.last_indexed_block),
+    updated_at = excluded.updated_at;
+`
+
+const updateStatusLastChunk = `
+INSERT INTO status (id, last_chunk_range, updated_at) VALUES (1, ?1, ?2)
+ON CONFLICT (id) DO UPDATE SET
+    last_chunk_range = max(last_chunk_range, excluded.last_chunk_range),
+    updated_at = excluded.updated_at;
+`
+
+const selectStatus = `
+SELECT last_indexed_block, last_chunk_range, updated_at FROM status WHERE id = 1;
+`
+
+const bumpDataVersion = `
+INSERT INTO data_version (id, version) VALUES (1, 1)
+ON CONFLICT (id) DO UPDATE SET version = version + 1;
+`
+
+const selectDataVersion = `
+SELECT COALESCE((SELECT version FROM data_version WHERE id = 1), 0);
+`
+
+const selectWatermark = `
+SELECT COALESCE(MAX(seq), 0) FROM appearances;
+`
+
+// Appearance pages take address, firstBlock, lastBlock and (except first pages) watermark,
+// then the cursor (block number and transaction index, if any) and page size
+
+const selectAppearancesLatestPage = `
+SELECT block_number, tx_id FROM appearances
+WHERE address = ? AND block_number BETWEEN ? AND ?
+ORDER BY block_number DESC, tx_id DESC
+LIMIT ?;
+`
+
+const selectAppearancesEarliestPage = `
+SELECT block_number, tx_id FROM appearances
+WHERE address = ? AND block_number BETWEEN ? AND ?
+ORDER BY block_number ASC, tx_id ASC
+LIMIT ?;
+`
+
+const selectAppearancesNextPage = `
+SELECT block_number, tx_id FROM appearances
+WHERE address = ? AND block_number BETWEEN ? AND ? AND seq <= ? AND (block_number, tx_id) < (?, ?)
+ORDER BY block_number DESC, tx_id DESC
+LIMIT ?;
+`
+
+const selectAppearancesPreviousPage = `
+SELECT block_number, tx_id FROM appearances
+WHERE address = ? AND block_number BETWEEN ? AND ? AND seq <= ? AND (block_number, tx_id) > (?, ?)
+ORDER BY block_number ASC, tx_id ASC
+LIMIT ?;
+`
+
+const selectAddressesInTx = `
+SELECT address FROM appearances
+WHERE block_number = ? AND tx_id = ? AND address > ?
+ORDER BY address
+LIMIT ?;
+`
+
+const selectAddressesInBlock = `
+SELECT tx_id, address FROM appearances
+WHERE block_number = ?1 AND (tx_id, address) > (?2, ?3)
+ORDER BY tx_id, address
+LIMIT ?4;
+`
+
+const selectLastChunk = `
+SELECT cid, range, author FROM chunks
+ORDER BY range DESC
+LIMIT 1;
+`
+
+const selectBlockTimestamp = `
+SELECT timestamp FROM blocks WHERE block_number = ?;
+`
+
+const selectTransactionHash = `
+SELECT hash FROM transactions WHERE block_number = ? AND tx_id = ?;
+`
+
+const selectBlockAtOrBeforeTimestamp = `
+SELECT b.block_number, b.timestamp, EXISTS (SELECT 1 FROM blocks n WHERE n.block_number = b.block_number + 1)
+FROM blocks b
+WHERE b.timestamp <= ?
+ORDER BY b.timestamp DESC, b.block_number DESC
+LIMIT 1;
+`
+
+const selectBlockAtOrAfterTimestamp = `
+SELECT b.block_number, b.timestamp, (b.block_number = 0 OR EXISTS (SELECT 1 FROM blocks p WHERE p.block_number = b.block_number - 1))
+FROM blocks b
+WHERE b.timestamp >= ?
+ORDER BY b.timestamp ASC, b.block_number ASC
+LIMIT 1;
+`
+
+const selectBlocksBounds = `
+SELECT MIN(block_number), MAX(block_number) FROM blocks;
+`
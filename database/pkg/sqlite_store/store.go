@@ -0,0 +1,329 @@
+package sqliteStore
+
+import (
+	"context"
+	dbsql "database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SqliteStore is AppearanceStore keeping everything in a single SQLite file. It's meant
+// for small deployments, e.g. running Key on a laptop. It needs cgo.
+//
+// SQLite has a single writer, so rowids of appearances grow in commit order and we can use
+// them as seq (see database.FetchWatermark).
+type SqliteStore struct {
+	db *dbsql.DB
+}
+
+var _ database.AppearanceStore = (*SqliteStore)(nil)
+var _ database.BlockTimestampIndex = (*SqliteStore)(nil)
+
+// Open opens (creating if needed) the store kept in file at path
+func Open(ctx context.Context, path string) (*SqliteStore, error) {
+	db, err := dbsql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		return nil, fmt.Errorf("sqliteStore.Open: %w", err)
+	}
+	// a single connection serializes reads and writes, which is enough for small deployments
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqliteStore.Open: creating schema: %w", err)
+	}
+	return &SqliteStore{db: db}, nil
+}
+
+func (s *SqliteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SqliteStore) InsertAppearanceBatch(ctx context.Context, apps []queueItem.Appearance) error {
+	if len(apps) == 0 {
+		return nil
+	}
+
+	return s.inTx(ctx, func(tx *dbsql.Tx) error {
+		var lastBlock uint32
+		for _, app := range apps {
+			if _, err := tx.ExecContext(ctx, insertAppearance, strings.ToLower(app.Address), app.BlockNumber, app.TransactionIndex); err != nil {
+				return err
+			}
+			lastBlock = max(lastBlock, app.BlockNumber)
+		}
+		if _, err := tx.ExecContext(ctx, updateStatusLastIndexedBlock, lastBlock, time.Now().UnixMilli()); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, bumpDataVersion)
+		return err
+	})
+}
+
+func (s *SqliteStore) InsertBlockBatch(ctx context.Context, blocks []database.Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	return s.inTx(ctx, func(tx *dbsql.Tx) error {
+		for _, block := range blocks {
+			if _, err := tx.ExecContext(ctx, insertBlock, block.Number, block.Timestamp); err != nil {
+				return err
+			}
+			for txId, hash := range block.TransactionHashes {
+				if _, err := tx.ExecContext(ctx, insertTransaction, block.Number, txId, hash); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := tx.ExecContext(ctx, bumpDataVersion)
+		return err
+	})
+}
+
+func (s *SqliteStore) InsertChunkBatch(ctx context.Context, chunks []queueItem.Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	return s.inTx(ctx, func(tx *dbsql.Tx) error {
+		var lastRange string
+		for _, chunk := range chunks {
+			if _, err := tx.ExecContext(ctx, insertChunk, chunk.Cid, chunk.Range, chunk.Author); err != nil {
+				return err
+			}
+			// ranges are zero-padded, so string comparison works
+			if chunk.Range > lastRange {
+				lastRange = chunk.Range
+			}
+		}
+		_, err := tx.ExecContext(ctx, updateStatusLastChunk, lastRange, time.Now().UnixMilli())
+		return err
+	})
+}
+
+func (s *SqliteStore) FetchStatus(ctx context.Context) (status database.Status, err error) {
+	var updatedAt int64
+	err = s.db.QueryRowContext(ctx, selectStatus).Scan(&status.LastIndexedBlock, &status.LastChunkRange, &updatedAt)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		err = nil
+	}
+	if updatedAt > 0 {
+		status.UpdatedAt = time.UnixMilli(updatedAt)
+	}
+	status.ReadAt = time.Now()
+	return
+}
+
+func (s *SqliteStore) FetchDataVersion(ctx context.Context) (version uint64, err error) {
+	err = s.db.QueryRowContext(ctx, selectDataVersion).Scan(&version)
+	return
+}
+
+func (s *SqliteStore) FetchWatermark(ctx context.Context) (watermark uint64, err error) {
+	err = s.db.QueryRowContext(ctx, selectWatermark).Scan(&watermark)
+	return
+}
+
+func (s *SqliteStore) FetchAppearancesFirstPage(ctx context.Context, earliest bool, address string, firstBlock uint, lastBlock uint, limit uint) (results []database.Appearance, watermark uint64, err error) {
+	if watermark, err = s.FetchWatermark(ctx); err != nil {
+		return
+	}
+	if earliest {
+		results, err = s.queryAppearances(ctx, selectAppearancesEarliestPage, strings.ToLower(address), firstBlock, lastBlock, limit)
+		slices.Reverse(results)
+		return
+	}
+	results, err = s.queryAppearances(ctx, selectAppearancesLatestPage, strings.ToLower(address), firstBlock, lastBlock, limit)
+	return
+}
+
+func (s *SqliteStore) FetchAppearancesPage(ctx context.Context, nextPage bool, address string, firstBlock uint, lastBlock uint, limit uint, appBlockNumber uint, appTransactionIndex uint, watermark uint64) ([]database.Appearance, error) {
+	if nextPage {
+		return s.queryAppearances(ctx, selectAppearancesNextPage, strings.ToLower(address), firstBlock, lastBlock, watermarkArg(watermark), appBlockNumber, appTransactionIndex, limit)
+	}
+	results, err := s.queryAppearances(ctx, selectAppearancesPreviousPage, strings.ToLower(address), firstBlock, lastBlock, watermarkArg(watermark), appBlockNumber, appTransactionIndex, limit)
+	slices.Reverse(results)
+	return results, err
+}
+
+func (s *SqliteStore) FetchAppearancesDatasetBounds(ctx context.Context, address string, firstBlock uint, lastBlock uint) (bounds database.AppearancesDatasetBounds, err error) {
+	latest, _, err := s.FetchAppearancesFirstPage(ctx, false, address, firstBlock, lastBlock, 1)
+	if err != nil {
+		return
+	}
+	earliest, _, err := s.FetchAppearancesFirstPage(ctx, true, address, firstBlock, lastBlock, 1)
+	if err != nil {
+		return
+	}
+	if len(latest) == 0 || len(earliest) == 0 {
+		err = fmt.Errorf("expected bounds result length == 2, but got %d", 0)
+		return
+	}
+
+	bounds.Latest = latest[0]
+	bounds.Earliest = earliest[0]
+	return
+}
+
+func (s *SqliteStore) FetchAddressesInTx(ctx context.Context, blockNumber int, transactionIndex int, afterAddress string, limit uint) (results []string, err error) {
+	rows, err := s.db.QueryContext(ctx, selectAddressesInTx, blockNumber, transactionIndex, strings.ToLower(afterAddress), limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	results = make([]string, 0)
+	for rows.Next() {
+		var address string
+		if err = rows.Scan(&address); err != nil {
+			return
+		}
+		results = append(results, address)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *SqliteStore) FetchAddressesInBlock(ctx context.Context, blockNumber int, afterTransactionIndex uint32, afterAddress string, limit uint) (results []database.AddressInBlock, err error) {
+	rows, err := s.db.QueryContext(ctx, selectAddressesInBlock, blockNumber, afterTransactionIndex, strings.ToLower(afterAddress), limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	results = make([]database.AddressInBlock, 0)
+	for rows.Next() {
+		var item database.AddressInBlock
+		if err = rows.Scan(&item.TransactionIndex, &item.Address); err != nil {
+			return
+		}
+		results = append(results, item)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *SqliteStore) FetchLastChunk(ctx context.Context) (result database.Chunk, found bool, err error) {
+	err = s.db.QueryRowContext(ctx, selectLastChunk).Scan(&result.Cid, &result.Range, &result.Author)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		err = nil
+		return
+	}
+	found = err == nil
+	return
+}
+
+func (s *SqliteStore) FetchBlockTimestamps(ctx context.Context, blockNumbers []uint32) (map[uint32]uint64, error) {
+	results := make(map[uint32]uint64, len(blockNumbers))
+	for _, blockNumber := range blockNumbers {
+		var timestamp uint64
+		err := s.db.QueryRowContext(ctx, selectBlockTimestamp, blockNumber).Scan(&timestamp)
+		if errors.Is(err, dbsql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[blockNumber] = timestamp
+	}
+	return results, nil
+}
+
+func (s *SqliteStore) FetchTransactionHashes(ctx context.Context, apps []database.Appearance) (map[database.Appearance]string, error) {
+	results := make(map[database.Appearance]string, len(apps))
+	for _, app := range apps {
+		var hash string
+		err := s.db.QueryRowContext(ctx, selectTransactionHash, app.BlockNumber, app.TransactionIndex).Scan(&hash)
+		if errors.Is(err, dbsql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[app] = hash
+	}
+	return results, nil
+}
+
+func (s *SqliteStore) FetchBlockByTimestamp(ctx context.Context, timestamp uint64, after bool, lastIndexedBlock uint) (*database.PublicBlock, bool, error) {
+	return database.ResolveBlockByTimestamp(ctx, s, timestamp, after, lastIndexedBlock)
+}
+
+func (s *SqliteStore) FetchBlockRangeByTimestamps(ctx context.Context, fromTimestamp *uint64, toTimestamp *uint64, firstBlock uint, lastBlock uint) (uint, uint, bool, error) {
+	return database.ResolveBlockRangeByTimestamps(ctx, s, fromTimestamp, toTimestamp, firstBlock, lastBlock)
+}
+
+// BlockAtTimestamp implements database.BlockTimestampIndex
+func (s *SqliteStore) BlockAtTimestamp(ctx context.Context, timestamp uint64, after bool) (block database.Block, neighbourStored bool, found bool, err error) {
+	query := selectBlockAtOrBeforeTimestamp
+	if after {
+		query = selectBlockAtOrAfterTimestamp
+	}
+	err = s.db.QueryRowContext(ctx, query, timestamp).Scan(&block.Number, &block.Timestamp, &neighbourStored)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		err = nil
+		return
+	}
+	found = err == nil
+	return
+}
+
+// StoredBlocksBounds implements database.BlockTimestampIndex
+func (s *SqliteStore) StoredBlocksBounds(ctx context.Context) (first uint32, last uint32, found bool, err error) {
+	var minStored, maxStored dbsql.NullInt64
+	if err = s.db.QueryRowContext(ctx, selectBlocksBounds).Scan(&minStored, &maxStored); err != nil {
+		return
+	}
+	if !minStored.Valid {
+		return
+	}
+	return uint32(minStored.Int64), uint32(maxStored.Int64), true, nil
+}
+
+func (s *SqliteStore) queryAppearances(ctx context.Context, query string, args ...any) (results []database.Appearance, err error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	results = make([]database.Appearance, 0)
+	for rows.Next() {
+		var app database.Appearance
+		if err = rows.Scan(&app.BlockNumber, &app.TransactionIndex); err != nil {
+			return
+		}
+		results = append(results, app)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *SqliteStore) inTx(ctx context.Context, f func(tx *dbsql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// watermarkArg translates zero watermark to "no limit", like Postgres store does
+func watermarkArg(watermark uint64) int64 {
+	if watermark == 0 {
+		return 1<<63 - 1
+	}
+	return int64(watermark)
+}
@@ -0,0 +1,188 @@
+package sqliteStore
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
+)
+
+const testAddress = "0xf503017d7baf7fbc0fff7492b751025c6a78179b"
+
+var testAppearances = []queueItem.Appearance{
+	{Address: "0xF503017D7Baf7fbc0fff7492b751025c6a78179b", BlockNumber: 3, TransactionIndex: 0},
+	{Address: testAddress, BlockNumber: 1, TransactionIndex: 5},
+	{Address: testAddress, BlockNumber: 2, TransactionIndex: 1},
+	{Address: testAddress, BlockNumber: 1, TransactionIndex: 2},
+	{Address: testAddress, BlockNumber: 4, TransactionIndex: 0},
+	// duplicate
+	{Address: testAddress, BlockNumber: 4, TransactionIndex: 0},
+	{Address: "0x0000000000000000000000000000000000000002", BlockNumber: 1, TransactionIndex: 5},
+	{Address: "0x0000000000000000000000000000000000000001", BlockNumber: 1, TransactionIndex: 5},
+}
+
+// blocks 2 and 3 are enriched, blocks 1 and 4 are not
+var testBlocks = []database.Block{
+	{Number: 2, Timestamp: 200, TransactionHashes: []string{"0xa0", "0xa1"}},
+	{Number: 3, Timestamp: 300, TransactionHashes: []string{"0xb0"}},
+}
+
+var testChunks = []queueItem.Chunk{
+	{Cid: "cid1", Range: "000000000-000000002", Author: "test"},
+	{Cid: "cid2", Range: "000000003-000000004", Author: "test"},
+}
+
+func openTestStore(t *testing.T, path string) *SqliteStore {
+	t.Helper()
+	store, err := Open(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func fill(t *testing.T, store database.AppearanceStore) {
+	t.Helper()
+	ctx := context.Background()
+	if err := store.InsertAppearanceBatch(ctx, testAppearances); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.InsertBlockBatch(ctx, testBlocks); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.InsertChunkBatch(ctx, testChunks); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// firstPage makes FetchAppearancesFirstPage result comparable
+func firstPage(items []database.Appearance, watermark uint64, err error) (any, error) {
+	return fmt.Sprint(items, watermark), err
+}
+
+// TestSqliteStore_SameAsMemory checks that both embedded stores answer every query the same way
+func TestSqliteStore_SameAsMemory(t *testing.T) {
+	ctx := context.Background()
+	sqlite := openTestStore(t, filepath.Join(t.TempDir(), "key.db"))
+	memory := database.NewMemoryStore()
+	fill(t, sqlite)
+	fill(t, memory)
+
+	ts := func(v uint64) *uint64 { return &v }
+	queries := map[string]func(store database.AppearanceStore) (any, error){
+		"data version": func(store database.AppearanceStore) (any, error) {
+			return store.FetchDataVersion(ctx)
+		},
+		"watermark": func(store database.AppearanceStore) (any, error) {
+			return store.FetchWatermark(ctx)
+		},
+		"last indexed block": func(store database.AppearanceStore) (any, error) {
+			status, err := store.FetchStatus(ctx)
+			return fmt.Sprint(status.LastIndexedBlock, status.LastChunkRange), err
+		},
+		"latest page": func(store database.AppearanceStore) (any, error) {
+			return firstPage(store.FetchAppearancesFirstPage(ctx, false, testAddress, 0, 4, 2))
+		},
+		"earliest page": func(store database.AppearanceStore) (any, error) {
+			return firstPage(store.FetchAppearancesFirstPage(ctx, true, testAddress, 0, 4, 2))
+		},
+		"ranged page": func(store database.AppearanceStore) (any, error) {
+			return firstPage(store.FetchAppearancesFirstPage(ctx, false, testAddress, 2, 3, 10))
+		},
+		"pinned page": func(store database.AppearanceStore) (any, error) {
+			return store.FetchAppearancesPage(ctx, true, testAddress, 0, 4, 10, 4, 0, 3)
+		},
+		"next page": func(store database.AppearanceStore) (any, error) {
+			return store.FetchAppearancesPage(ctx, true, testAddress, 0, 4, 2, 3, 0, 0)
+		},
+		"previous page": func(store database.AppearanceStore) (any, error) {
+			return store.FetchAppearancesPage(ctx, false, testAddress, 0, 4, 2, 1, 5, 0)
+		},
+		"bounds": func(store database.AppearanceStore) (any, error) {
+			return store.FetchAppearancesDatasetBounds(ctx, testAddress, 0, 3)
+		},
+		"addresses in tx": func(store database.AppearanceStore) (any, error) {
+			return store.FetchAddressesInTx(ctx, 1, 5, "0x0000000000000000000000000000000000000001", 10)
+		},
+		"addresses in block": func(store database.AppearanceStore) (any, error) {
+			return store.FetchAddressesInBlock(ctx, 1, 2, testAddress, 10)
+		},
+		"last chunk": func(store database.AppearanceStore) (any, error) {
+			chunk, found, err := store.FetchLastChunk(ctx)
+			return fmt.Sprint(chunk, found), err
+		},
+		"timestamps": func(store database.AppearanceStore) (any, error) {
+			return store.FetchBlockTimestamps(ctx, []uint32{1, 2, 3})
+		},
+		"tx hashes": func(store database.AppearanceStore) (any, error) {
+			return store.FetchTransactionHashes(ctx, []database.Appearance{{BlockNumber: 2, TransactionIndex: 1}, {BlockNumber: 4, TransactionIndex: 0}})
+		},
+		"block before": func(store database.AppearanceStore) (any, error) {
+			block, found, err := store.FetchBlockByTimestamp(ctx, 250, false, 4)
+			return fmt.Sprint(block, found), err
+		},
+		"block not covered": func(store database.AppearanceStore) (any, error) {
+			block, found, err := store.FetchBlockByTimestamp(ctx, 350, false, 4)
+			return fmt.Sprint(block, found), err
+		},
+		"block range": func(store database.AppearanceStore) (any, error) {
+			from, to, empty, err := store.FetchBlockRangeByTimestamps(ctx, ts(200), ts(300), 2, 3)
+			return fmt.Sprint(from, to, empty), err
+		},
+	}
+
+	for name, query := range queries {
+		expected, expectedErr := query(memory)
+		result, err := query(sqlite)
+		if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+			t.Fatal(name, "- wrong error:", err, "expected", expectedErr)
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Fatal(name, "- wrong result:", result, "expected", expected)
+		}
+	}
+}
+
+func TestSqliteStore_Persistent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "key.db")
+
+	store, err := Open(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, store)
+	watermark, err := store.FetchWatermark(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := openTestStore(t, path)
+	if w, _ := reopened.FetchWatermark(ctx); w != watermark {
+		t.Fatal("wrong watermark after reopening:", w, watermark)
+	}
+	status, err := reopened.FetchStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.LastIndexedBlock != 4 || status.LastChunkRange != "000000003-000000004" {
+		t.Fatal("wrong status after reopening:", status)
+	}
+
+	// appearances inserted after the watermark are hidden
+	if err := reopened.InsertAppearanceBatch(ctx, []queueItem.Appearance{{Address: testAddress, BlockNumber: 2, TransactionIndex: 7}}); err != nil {
+		t.Fatal(err)
+	}
+	pinned, _ := reopened.FetchAppearancesPage(ctx, true, testAddress, 0, 4, 10, 4, 0, watermark)
+	if l := len(pinned); l != 4 {
+		t.Fatal("wrong pinned length:", l)
+	}
+}
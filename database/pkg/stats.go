@@ -8,7 +8,7 @@ import (
 )
 
 func FetchAppearancesCount(ctx context.Context, c *Connection) (result int, err error) {
-	rows, err := c.conn.Query(
+	rows, err := c.reader(ctx, 0, 0).Query(
 		ctx,
 		sql.SelectAppearancesCount(c.AppearancesTableName()),
 	)
@@ -2,29 +2,113 @@ package database
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
 	"github.com/jackc/pgx/v5"
 )
 
 type Status struct {
-	LastIndexedBlock uint `json:"lastIndexedBlock"`
+	LastIndexedBlock uint   `json:"lastIndexedBlock" db:"last_indexed_block"`
+	LastChunkRange   string `json:"lastChunkRange" db:"last_chunk_range"`
+	// LastNotificationAt is the time when the scraper sent the latest ingested notification
+	LastNotificationAt *time.Time `json:"lastNotificationAt" db:"last_notification_at"`
+	UpdatedAt          time.Time  `json:"updatedAt" db:"updated_at"`
+	// ReadAt is the database time when the status was read
+	ReadAt time.Time `json:"readAt" db:"read_at"`
+}
+
+// IngestionLag is the time between the scraper sending the latest ingested notification
+// and reading the status. It grows when ingestion stops, so it shows how stale the index is.
+func (s *Status) IngestionLag() time.Duration {
+	if s.LastNotificationAt == nil {
+		return 0
+	}
+	readAt := s.ReadAt
+	if readAt.IsZero() {
+		readAt = time.Now()
+	}
+	if lag := readAt.Sub(*s.LastNotificationAt); lag > 0 {
+		return lag
+	}
+	return 0
+}
+
+// IngestionDelay is the time between the scraper sending the latest notification
+// and us writing it to the database
+func (s *Status) IngestionDelay() time.Duration {
+	if s.LastNotificationAt == nil {
+		return 0
+	}
+	if delay := s.UpdatedAt.Sub(*s.LastNotificationAt); delay > 0 {
+		return delay
+	}
+	return 0
 }
 
 func (s *Status) HasLastIndexedBlock() bool {
 	return s.LastIndexedBlock > 0
 }
 
+// FetchStatus reads the status row. If the row is missing (nothing has been
+// inserted yet), zero Status is returned. Status is read from the primary, so that
+// last indexed block reported to users doesn't depend on replica lag.
 func FetchStatus(ctx context.Context, c *Connection) (result Status, err error) {
 	rows, err := c.conn.Query(
 		ctx,
-		sql.SelectStatus(c.AppearancesTableName(), c.AddressesTableName()),
+		sql.SelectStatus(c.StatusTableName()),
 	)
 	if err != nil {
 		return
 	}
 
 	result, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[Status])
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = nil
+	}
+
+	return
+}
+
+// InitStatus fills the status row using appearances and chunks tables
+func InitStatus(ctx context.Context, c *Connection) (err error) {
+	_, err = c.conn.Exec(
+		ctx,
+		sql.InitStatus(c.StatusTableName(), c.AppearancesTableName(), c.ChunksTableName()),
+	)
+	return
+}
+
+func UpdateStatusLastNotification(ctx context.Context, c *Connection, notifiedAt time.Time) (err error) {
+	_, err = c.conn.Exec(
+		ctx,
+		sql.UpdateStatusLastNotification(c.StatusTableName()),
+		pgx.NamedArgs{
+			"lastNotificationAt": notifiedAt,
+		},
+	)
+	return
+}
 
+// updateStatusLastIndexedBlock has to be called after appearances are committed and outside
+// of their transaction. Otherwise concurrent inserts would serialize on the status row lock.
+func updateStatusLastIndexedBlock(ctx context.Context, c *Connection, blockNumber uint32) (err error) {
+	_, err = c.conn.Exec(
+		ctx,
+		sql.UpdateStatusLastIndexedBlock(c.StatusTableName()),
+		pgx.NamedArgs{
+			"lastIndexedBlock": blockNumber,
+		},
+	)
 	return
 }
+
+func queueStatusLastChunk(batch *pgx.Batch, c *Connection, chunkRange string) {
+	batch.Queue(
+		sql.UpdateStatusLastChunk(c.StatusTableName()),
+		pgx.NamedArgs{
+			"lastChunkRange": chunkRange,
+		},
+	)
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+
+	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
+)
+
+// AppearanceStore keeps appearances, with blocks and chunks used to enrich and describe
+// them, and answers queries about them. PostgresStore is used in production, MemoryStore
+// is meant for tests and sqliteStore.SqliteStore for small local deployments.
+//
+// Addresses are passed and returned as lowercase hex strings. All Fetch methods work like
+// their Postgres counterparts (e.g. FetchAppearancesFirstPage), including watermark semantics.
+type AppearanceStore interface {
+	InsertAppearanceBatch(ctx context.Context, apps []queueItem.Appearance) error
+	InsertBlockBatch(ctx context.Context, blocks []Block) error
+	InsertChunkBatch(ctx context.Context, chunks []queueItem.Chunk) error
+
+	FetchStatus(ctx context.Context) (Status, error)
+	FetchDataVersion(ctx context.Context) (uint64, error)
+	FetchWatermark(ctx context.Context) (uint64, error)
+	FetchAppearancesFirstPage(ctx context.Context, earliest bool, address string, firstBlock uint, lastBlock uint, limit uint) ([]Appearance, uint64, error)
+	FetchAppearancesPage(ctx context.Context, nextPage bool, address string, firstBlock uint, lastBlock uint, limit uint, appBlockNumber uint, appTransactionIndex uint, watermark uint64) ([]Appearance, error)
+	FetchAppearancesDatasetBounds(ctx context.Context, address string, firstBlock uint, lastBlock uint) (AppearancesDatasetBounds, error)
+	FetchAddressesInTx(ctx context.Context, blockNumber int, transactionIndex int, afterAddress string, limit uint) ([]string, error)
+	FetchAddressesInBlock(ctx context.Context, blockNumber int, afterTransactionIndex uint32, afterAddress string, limit uint) ([]AddressInBlock, error)
+
+	FetchLastChunk(ctx context.Context) (Chunk, bool, error)
+	FetchBlockTimestamps(ctx context.Context, blockNumbers []uint32) (map[uint32]uint64, error)
+	FetchTransactionHashes(ctx context.Context, apps []Appearance) (map[Appearance]string, error)
+	FetchBlockByTimestamp(ctx context.Context, timestamp uint64, after bool, lastIndexedBlock uint) (*PublicBlock, bool, error)
+	FetchBlockRangeByTimestamps(ctx context.Context, fromTimestamp *uint64, toTimestamp *uint64, firstBlock uint, lastBlock uint) (uint, uint, bool, error)
+}
+
+// PostgresStore is AppearanceStore backed by Connection
+type PostgresStore struct {
+	Connection *Connection
+}
+
+var _ AppearanceStore = (*PostgresStore)(nil)
+
+func NewPostgresStore(c *Connection) *PostgresStore {
+	return &PostgresStore{Connection: c}
+}
+
+func (p *PostgresStore) InsertAppearanceBatch(ctx context.Context, apps []queueItem.Appearance) error {
+	return InsertAppearanceBatch(ctx, p.Connection, apps)
+}
+
+func (p *PostgresStore) InsertBlockBatch(ctx context.Context, blocks []Block) error {
+	return InsertBlockBatch(ctx, p.Connection, blocks)
+}
+
+func (p *PostgresStore) InsertChunkBatch(ctx context.Context, chunks []queueItem.Chunk) error {
+	return InsertChunkBatch(ctx, p.Connection, chunks)
+}
+
+func (p *PostgresStore) FetchStatus(ctx context.Context) (Status, error) {
+	return FetchStatus(ctx, p.Connection)
+}
+
+func (p *PostgresStore) FetchDataVersion(ctx context.Context) (uint64, error) {
+	return FetchDataVersion(ctx, p.Connection)
+}
+
+func (p *PostgresStore) FetchWatermark(ctx context.Context) (uint64, error) {
+	return FetchWatermark(ctx, p.Connection)
+}
+
+func (p *PostgresStore) FetchAppearancesFirstPage(ctx context.Context, earliest bool, address string, firstBlock uint, lastBlock uint, limit uint) ([]Appearance, uint64, error) {
+	return FetchAppearancesFirstPage(ctx, p.Connection, earliest, address, firstBlock, lastBlock, limit)
+}
+
+func (p *PostgresStore) FetchAppearancesPage(ctx context.Context, nextPage bool, address string, firstBlock uint, lastBlock uint, limit uint, appBlockNumber uint, appTransactionIndex uint, watermark uint64) ([]Appearance, error) {
+	return FetchAppearancesPage(ctx, p.Connection, nextPage, address, firstBlock, lastBlock, limit, appBlockNumber, appTransactionIndex, watermark)
+}
+
+func (p *PostgresStore) FetchAppearancesDatasetBounds(ctx context.Context, address string, firstBlock uint, lastBlock uint) (AppearancesDatasetBounds, error) {
+	return FetchAppearancesDatasetBounds(ctx, p.Connection, address, firstBlock, lastBlock)
+}
+
+func (p *PostgresStore) FetchAddressesInTx(ctx context.Context, blockNumber int, transactionIndex int, afterAddress string, limit uint) ([]string, error) {
+	return FetchAddressesInTx(ctx, p.Connection, blockNumber, transactionIndex, afterAddress, limit)
+}
+
+func (p *PostgresStore) FetchAddressesInBlock(ctx context.Context, blockNumber int, afterTransactionIndex uint32, afterAddress string, limit uint) ([]AddressInBlock, error) {
+	return FetchAddressesInBlock(ctx, p.Connection, blockNumber, afterTransactionIndex, afterAddress, limit)
+}
+
+func (p *PostgresStore) FetchLastChunk(ctx context.Context) (Chunk, bool, error) {
+	return FetchLastChunk(ctx, p.Connection)
+}
+
+func (p *PostgresStore) FetchBlockTimestamps(ctx context.Context, blockNumbers []uint32) (map[uint32]uint64, error) {
+	return FetchBlockTimestamps(ctx, p.Connection, blockNumbers)
+}
+
+func (p *PostgresStore) FetchTransactionHashes(ctx context.Context, apps []Appearance) (map[Appearance]string, error) {
+	return FetchTransactionHashes(ctx, p.Connection, apps)
+}
+
+func (p *PostgresStore) FetchBlockByTimestamp(ctx context.Context, timestamp uint64, after bool, lastIndexedBlock uint) (*PublicBlock, bool, error) {
+	return FetchBlockByTimestamp(ctx, p.Connection, timestamp, after, lastIndexedBlock)
+}
+
+func (p *PostgresStore) FetchBlockRangeByTimestamps(ctx context.Context, fromTimestamp *uint64, toTimestamp *uint64, firstBlock uint, lastBlock uint) (uint, uint, bool, error) {
+	return FetchBlockRangeByTimestamps(ctx, p.Connection, fromTimestamp, toTimestamp, firstBlock, lastBlock)
+}
@@ -11,3 +11,19 @@ func (c *Connection) AppearancesTableName() string {
 func (c *Connection) ChunksTableName() string {
 	return c.Chain + "_chunks"
 }
+
+func (c *Connection) StatusTableName() string {
+	return c.Chain + "_status"
+}
+
+func (c *Connection) BlocksTableName() string {
+	return c.Chain + "_blocks"
+}
+
+func (c *Connection) TransactionsTableName() string {
+	return c.Chain + "_transactions"
+}
+
+func (c *Connection) DataVersionSequenceName() string {
+	return c.Chain + "_data_version"
+}
@@ -4,7 +4,6 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"log"
 
@@ -15,7 +14,7 @@ import (
 // clusterCmd represents the cluster command
 var clusterCmd = &cobra.Command{
 	Use:   "cluster",
-	Short: "Cluster appearances table using appearances order index",
+	Short: "Cluster appearances table using appearances order index (see recluster for an online alternative)",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if a := YesNoPrompt(fmt.Sprintf("Cluster appearances for chain %s? WARN: it means database DOWNTIME\n", dbConn.Chain)); !a {
 			log.Println("exit")
@@ -29,7 +28,7 @@ var clusterCmd = &cobra.Command{
 			pgx.Identifier.Sanitize(pgx.Identifier{dbConn.AppearancesTableName() + "_appearances_order"}),
 		)
 		log.Println(stmt)
-		if _, err := dbConn.Db().Exec(context.TODO(), stmt); err != nil {
+		if _, err := dbConn.Db().Exec(cmd.Context(), stmt); err != nil {
 			return err
 		}
 
@@ -10,6 +10,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var createAddressIndex bool
+
 // createCmd represents the create command
 var createCmd = &cobra.Command{
 	Use:   "create",
@@ -21,7 +23,8 @@ var createCmd = &cobra.Command{
 		}
 
 		log.Println("creating tables...")
-		if err := dbConn.Setup(); err != nil {
+		dbConn.AppearancesAddressIndex = createAddressIndex
+		if err := dbConn.Setup(cmd.Context()); err != nil {
 			return err
 		}
 
@@ -31,5 +34,6 @@ var createCmd = &cobra.Command{
 }
 
 func init() {
+	createCmd.Flags().BoolVar(&createAddressIndex, "address-index", false, "create covering index by address on appearances")
 	rootCmd.AddCommand(createCmd)
 }
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+
+	enrich "github.com/TrueBlocks/trueblocks-key/enrich/pkg"
+	"github.com/spf13/cobra"
+)
+
+var enrichRpcUrl string
+var enrichTxHashes bool
+var enrichFirstBlock uint32
+var enrichLastBlock uint32
+var enrichStep uint32
+
+// enrichCmd backfills block timestamps (and optionally transaction hashes) for blocks
+// indexed before the consumer started enriching new appearances
+var enrichCmd = &cobra.Command{
+	Use:   "enrich",
+	Short: "Read block timestamps and transaction hashes from RPC provider for a range of blocks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if enrichRpcUrl == "" {
+			return errors.New("rpc URL required")
+		}
+		if enrichLastBlock < enrichFirstBlock {
+			return errors.New("last block must not be lower than first block")
+		}
+		if enrichStep == 0 {
+			return errors.New("step must be greater than 0")
+		}
+
+		enricher := enrich.NewEnricher(enrichRpcUrl, enrichTxHashes)
+		for first := uint64(enrichFirstBlock); first <= uint64(enrichLastBlock); first += uint64(enrichStep) {
+			last := min(first+uint64(enrichStep)-1, uint64(enrichLastBlock))
+			enriched, err := enricher.Range(cmd.Context(), dbConn, uint32(first), uint32(last))
+			if err != nil {
+				return err
+			}
+			log.Println("blocks", first, "-", last, "enriched:", enriched)
+		}
+
+		log.Println("done")
+		return nil
+	},
+}
+
+func init() {
+	enrichCmd.Flags().StringVar(&enrichRpcUrl, "rpc", "", "Ethereum JSON-RPC provider URL")
+	enrichCmd.Flags().BoolVar(&enrichTxHashes, "tx-hashes", false, "store transaction hashes too")
+	enrichCmd.Flags().Uint32Var(&enrichFirstBlock, "first", 0, "first block")
+	enrichCmd.Flags().Uint32Var(&enrichLastBlock, "last", 0, "last block")
+	enrichCmd.Flags().Uint32Var(&enrichStep, "step", 1000, "number of blocks processed at once")
+	rootCmd.AddCommand(enrichCmd)
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/spf13/cobra"
+)
+
+var explainAddresses []string
+var explainSamples uint
+var explainPageSize uint
+var explainTimestamp uint
+
+// explainCmd reports query plans of Select builders, so that we can catch plan
+// regressions (e.g. after schema changes) before they hit customers
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Run EXPLAIN (ANALYZE, BUFFERS) for API queries and flag sequential scans of large tables",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addresses := explainAddresses
+		if len(addresses) == 0 {
+			sample, err := database.FetchSampleAddresses(cmd.Context(), dbConn, explainSamples)
+			if err != nil {
+				return err
+			}
+			addresses = sample
+		}
+		if len(addresses) == 0 {
+			return fmt.Errorf("no addresses to explain queries with")
+		}
+
+		largeTables := []string{
+			dbConn.AppearancesTableName(),
+			dbConn.AddressesTableName(),
+			dbConn.BlocksTableName(),
+			dbConn.TransactionsTableName(),
+		}
+
+		// partitions are named after their table (e.g. mainnet_appearances_p0001)
+		isLarge := func(relation string) bool {
+			return slices.ContainsFunc(largeTables, func(table string) bool {
+				return relation == table || strings.HasPrefix(relation, table+"_")
+			})
+		}
+
+		var flagged int
+		printPlans := func(plans []database.QueryPlan) {
+			for _, plan := range plans {
+				status := "ok"
+				for _, relation := range plan.SeqScans {
+					if isLarge(relation) {
+						status = "SEQ SCAN on " + relation
+						flagged++
+						break
+					}
+				}
+				fmt.Printf("  %-32s %9.2f ms  hit=%-8d read=%-8d %s\n", plan.Name, plan.ExecutionTime, plan.SharedHit, plan.SharedRead, status)
+			}
+		}
+
+		timestamp := explainTimestamp
+		if timestamp == 0 {
+			timestamp = uint(time.Now().Unix())
+		}
+		fmt.Println("timestamp", timestamp)
+		plans, err := database.ExplainServiceQueries(cmd.Context(), dbConn, timestamp)
+		if err != nil {
+			return err
+		}
+		printPlans(plans)
+
+		for _, address := range addresses {
+			fmt.Println(address)
+			plans, err := database.ExplainAddressQueries(cmd.Context(), dbConn, address, math.MaxInt32, explainPageSize)
+			if err != nil {
+				return err
+			}
+			printPlans(plans)
+		}
+
+		if flagged > 0 {
+			return fmt.Errorf("%d queries use sequential scans", flagged)
+		}
+		log.Println("no sequential scans found")
+		return nil
+	},
+}
+
+func init() {
+	explainCmd.Flags().StringSliceVar(&explainAddresses, "address", nil, "address to use (can be repeated), random sample by default")
+	explainCmd.Flags().UintVar(&explainSamples, "samples", 5, "number of sample addresses to use when --address is not set")
+	explainCmd.Flags().UintVar(&explainPageSize, "per-page", 100, "page size")
+	explainCmd.Flags().UintVar(&explainTimestamp, "timestamp", 0, "timestamp to resolve to block, current time by default")
+	rootCmd.AddCommand(explainCmd)
+}
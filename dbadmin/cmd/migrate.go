@@ -0,0 +1,161 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd groups migrations of tables created by older versions of `dbadmin create`
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate existing tables to the current schema",
+}
+
+// migrateSeqCmd adds seq column (inserting transaction ID) used by pagination snapshots
+var migrateSeqCmd = &cobra.Command{
+	Use:   "seq",
+	Short: "Add seq column (snapshot watermark) to appearances table",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if a := YesNoPrompt(fmt.Sprintf("Add seq column to appearances for chain %s?\n", dbConn.Chain)); !a {
+			log.Println("exit")
+			return nil
+		}
+
+		stmt := sql.AddAppearancesSeqColumn(dbConn.AppearancesTableName())
+		log.Println(stmt)
+		if _, err := dbConn.Db().Exec(cmd.Context(), stmt); err != nil {
+			return err
+		}
+
+		log.Println("done")
+		return nil
+	},
+}
+
+// migrateDataVersionCmd creates the sequence used in response cache keys
+var migrateDataVersionCmd = &cobra.Command{
+	Use:   "data-version",
+	Short: "Create data version sequence (changes whenever appearances or blocks are written)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stmt := sql.CreateDataVersionSequence(dbConn.DataVersionSequenceName())
+		log.Println(stmt)
+		if _, err := dbConn.Db().Exec(cmd.Context(), stmt); err != nil {
+			return err
+		}
+
+		log.Println("done")
+		return nil
+	},
+}
+
+// migrateStatusCmd creates status table and fills it using existing data
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Create status table and initialize it from appearances and chunks (slow on large tables)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if a := YesNoPrompt(fmt.Sprintf("Create and initialize status table for chain %s?\n", dbConn.Chain)); !a {
+			log.Println("exit")
+			return nil
+		}
+
+		stmt := sql.CreateTableStatus(dbConn.StatusTableName())
+		log.Println(stmt)
+		if _, err := dbConn.Db().Exec(cmd.Context(), stmt); err != nil {
+			return err
+		}
+
+		log.Println("initializing status (it takes time)")
+		if err := database.InitStatus(cmd.Context(), dbConn); err != nil {
+			return err
+		}
+
+		log.Println("done")
+		return nil
+	},
+}
+
+// migrateBlocksCmd creates tables used to enrich appearances
+var migrateBlocksCmd = &cobra.Command{
+	Use:   "blocks",
+	Short: "Create blocks and transactions tables (run `dbadmin enrich` to fill them)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if a := YesNoPrompt(fmt.Sprintf("Create blocks and transactions tables for chain %s?\n", dbConn.Chain)); !a {
+			log.Println("exit")
+			return nil
+		}
+
+		for _, stmt := range []string{
+			sql.CreateTableBlocks(dbConn.BlocksTableName()),
+			sql.CreateBlocksTimestampIndex(dbConn.BlocksTableName()),
+			sql.CreateTableTransactions(dbConn.TransactionsTableName()),
+		} {
+			log.Println(stmt)
+			if _, err := dbConn.Db().Exec(cmd.Context(), stmt); err != nil {
+				return err
+			}
+		}
+
+		log.Println("done")
+		return nil
+	},
+}
+
+// migrateAddressIndexCmd creates index by address on a live table
+var migrateAddressIndexCmd = &cobra.Command{
+	Use:   "address-index",
+	Short: "Create covering index (address_id, block_number DESC, tx_id DESC) on appearances without locking the table",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if a := YesNoPrompt(fmt.Sprintf("Create address index on appearances for chain %s?\n", dbConn.Chain)); !a {
+			log.Println("exit")
+			return nil
+		}
+
+		log.Println(sql.CreateAppearancesAddressIndex(dbConn.AppearancesTableName(), true))
+		log.Println("creating index (it takes time)")
+		if err := database.CreateAppearancesAddressIndex(cmd.Context(), dbConn); err != nil {
+			return err
+		}
+
+		log.Println("done")
+		return nil
+	},
+}
+
+// migrateBinaryAddressesCmd converts addresses from hex strings to 20-byte BYTEA
+var migrateBinaryAddressesCmd = &cobra.Command{
+	Use:   "binary-addresses",
+	Short: "Store addresses as BYTEA instead of hex VARCHAR (rewrites and locks addresses table, stop ingestion and API first)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if a := YesNoPrompt(fmt.Sprintf("Convert addresses to BYTEA for chain %s? The table will be locked until done.\n", dbConn.Chain)); !a {
+			log.Println("exit")
+			return nil
+		}
+
+		stmt := sql.MigrateAddressesToBinary(dbConn.AddressesTableName())
+		log.Println(stmt)
+		log.Println("converting addresses (it takes time)")
+		if _, err := dbConn.Db().Exec(cmd.Context(), stmt); err != nil {
+			return err
+		}
+
+		log.Println("done")
+		return nil
+	},
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateSeqCmd)
+	migrateCmd.AddCommand(migrateDataVersionCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+	migrateCmd.AddCommand(migrateBlocksCmd)
+	migrateCmd.AddCommand(migrateAddressIndexCmd)
+	migrateCmd.AddCommand(migrateBinaryAddressesCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/spf13/cobra"
+)
+
+var errPartitionSizeRequired = errors.New("--partition-size required")
+
+var partitionLastBlock uint32
+
+// partitionCmd groups commands managing appearances table partitioned by block number.
+// To create a new partitioned table, use `dbadmin create --partition-size`.
+var partitionCmd = &cobra.Command{
+	Use:   "partition",
+	Short: "Manage appearances partitions",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if dbConn.AppearancesPartitionSize == 0 {
+			return errPartitionSizeRequired
+		}
+		return rootCmd.PersistentPreRunE(cmd, args)
+	},
+}
+
+var partitionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List appearances partitions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		partitions, err := database.FetchAppearancesPartitions(cmd.Context(), dbConn)
+		if err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			fmt.Printf("%s\t%s\t~%d rows\n", partition.Name, partition.Bounds, partition.EstimatedRows)
+		}
+		return nil
+	},
+}
+
+// partitionEnsureCmd should be run before bulk loads (e.g. `extract convert_new`), which don't
+// create partitions by themselves
+var partitionEnsureCmd = &cobra.Command{
+	Use:   "ensure",
+	Short: "Create missing partitions up to --last block (and the next one)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := database.EnsureAppearancesPartitions(cmd.Context(), dbConn, partitionLastBlock); err != nil {
+			return err
+		}
+		log.Println("done")
+		return nil
+	},
+}
+
+var partitionMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Turn existing appearances table into a partitioned one (existing data becomes a single legacy partition)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if a := YesNoPrompt(fmt.Sprintf("Partition appearances for chain %s? Validating existing rows takes time, the final swap locks the table briefly\n", dbConn.Chain)); !a {
+			log.Println("exit")
+			return nil
+		}
+
+		log.Println("validating and partitioning appearances (it takes time)")
+		upperBlock, err := database.PartitionAppearances(cmd.Context(), dbConn)
+		if err != nil {
+			return err
+		}
+
+		log.Println("done, legacy partition holds blocks below", upperBlock)
+		log.Println("set Database.<name>.AppearancesPartitionSize to", dbConn.AppearancesPartitionSize, "in consumer's configuration")
+		return nil
+	},
+}
+
+func init() {
+	partitionEnsureCmd.Flags().Uint32Var(&partitionLastBlock, "last", 0, "last block to create partition for")
+
+	partitionCmd.AddCommand(partitionListCmd)
+	partitionCmd.AddCommand(partitionEnsureCmd)
+	partitionCmd.AddCommand(partitionMigrateCmd)
+	rootCmd.AddCommand(partitionCmd)
+}
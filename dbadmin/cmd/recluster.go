@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+)
+
+var reclusterDryRun bool
+var reclusterDropOld bool
+var reclusterBatchAddresses uint64
+
+// reclusterCmd is an online alternative to clusterCmd
+var reclusterCmd = &cobra.Command{
+	Use:   "recluster",
+	Short: "Rebuild appearances table ordered by address without downtime (online alternative to cluster)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if reclusterBatchAddresses == 0 {
+			return fmt.Errorf("--batch-addresses must be greater than 0")
+		}
+
+		if reclusterDryRun {
+			log.Println("estimating (copying a sample, it will be rolled back)")
+			estimate, err := database.EstimateRecluster(cmd.Context(), dbConn, reclusterBatchAddresses)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("rows (estimated):\t%d\n", estimate.Rows)
+			fmt.Printf("table size:\t\t%s\n", formatBytes(estimate.TableBytes))
+			fmt.Printf("indexes size:\t\t%s\n", formatBytes(estimate.IndexBytes))
+			fmt.Printf("disk required:\t\t%s (at most)\n", formatBytes(estimate.RequiredBytes))
+			fmt.Printf("sample rows copied:\t%d\n", estimate.SampleRows)
+			fmt.Printf("copy time:\t\t%s (plus index creation)\n", estimate.Duration.Round(time.Second))
+			return nil
+		}
+
+		if a := YesNoPrompt(fmt.Sprintf("Recluster appearances for chain %s? It needs as much free disk as the table and its indexes\n", dbConn.Chain)); !a {
+			log.Println("exit")
+			return nil
+		}
+
+		err := database.Recluster(cmd.Context(), dbConn, database.ReclusterOptions{
+			BatchAddresses: reclusterBatchAddresses,
+			Progress: func(p database.ReclusterProgress) {
+				if p.Phase == database.ReclusterPhaseCopy {
+					log.Printf("%s: %.2f%% done, %d rows copied, elapsed %s, remaining ~%s\n", p.Phase, p.Done*100, p.CopiedRows, p.Elapsed.Round(time.Second), p.Remaining().Round(time.Second))
+					return
+				}
+				log.Printf("%s: %d rows copied, elapsed %s\n", p.Phase, p.CopiedRows, p.Elapsed.Round(time.Second))
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		oldTable := sql.PreReclusterTableName(dbConn.AppearancesTableName())
+		if reclusterDropOld {
+			log.Println("dropping", oldTable)
+			stmt := fmt.Sprintf("DROP TABLE %s", pgx.Identifier.Sanitize(pgx.Identifier{oldTable}))
+			if _, err := dbConn.Db().Exec(cmd.Context(), stmt); err != nil {
+				return err
+			}
+		} else {
+			log.Println("original table kept as", oldTable, "- drop it when you don't need it")
+		}
+
+		log.Println("done")
+		return nil
+	},
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	reclusterCmd.Flags().BoolVar(&reclusterDryRun, "dry-run", false, "only estimate time and disk space")
+	reclusterCmd.Flags().BoolVar(&reclusterDropOld, "drop-old", false, "drop the original table after the swap")
+	reclusterCmd.Flags().Uint64Var(&reclusterBatchAddresses, "batch-addresses", 100000, "number of address IDs copied at once")
+	rootCmd.AddCommand(reclusterCmd)
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
 
 	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
@@ -26,7 +27,7 @@ var rootCmd = &cobra.Command{
 		}
 
 		log.Println(dbConn.String())
-		if err := dbConn.Connect(context.TODO()); err != nil {
+		if err := dbConn.Connect(cmd.Context()); err != nil {
 			return err
 		}
 
@@ -37,7 +38,11 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Ctrl+C cancels running queries
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
@@ -53,6 +58,7 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&dbConn.Password, "password", "w", "", "PostgreSQL password")
 	rootCmd.PersistentFlags().StringVarP(&dbConn.Database, "database", "d", "index", "PostgreSQL database name")
 	rootCmd.PersistentFlags().StringVarP(&dbConn.Chain, "chain", "c", "", "chain")
+	rootCmd.PersistentFlags().Uint32Var(&dbConn.AppearancesPartitionSize, "partition-size", 0, "number of blocks per appearances partition (0 if not partitioned)")
 }
 
 func YesNoPrompt(question string) bool {
@@ -8,6 +8,7 @@ import (
 	keyConfig "github.com/TrueBlocks/trueblocks-key/config/pkg"
 	"github.com/TrueBlocks/trueblocks-key/direct_customers/endpoint"
 	keyDynamodb "github.com/TrueBlocks/trueblocks-key/quicknode/keyDynamodb"
+	usage "github.com/TrueBlocks/trueblocks-key/usage/pkg"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -62,11 +63,15 @@ func HandleRequest(ctx context.Context, event events.APIGatewayCustomAuthorizerR
 	// PrincipalID is something that uniquely identifies the account
 	result.PrincipalID = endpoint.Email
 	result.UsageIdentifierKey = endpoint.ApiKey.Value
+	effect := "allow"
+	if usage.Authorize(ctx, cnf, dynamoClient, endpoint.Email, usage.DirectCustomersPlan, &result) == usage.StatusHardLimit {
+		effect = "deny"
+	}
 	result.PolicyDocument = events.APIGatewayCustomAuthorizerPolicy{
 		Version: "2012-10-17",
 		Statement: []events.IAMPolicyStatement{
 			{
-				Effect: "allow",
+				Effect: effect,
 				Action: []string{
 					"execute-api:Invoke",
 				},
@@ -0,0 +1,3 @@
+module github.com/TrueBlocks/trueblocks-key/enrich
+
+go 1.22
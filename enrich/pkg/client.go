@@ -0,0 +1,174 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRpcBatchLength limits the number of calls sent in a single JSON-RPC batch.
+// Most providers reject larger batches.
+const maxRpcBatchLength = 100
+
+var ErrBlockNotFound = errors.New("block not found")
+
+// Block is the part of eth_getBlockByNumber result that we store
+type Block struct {
+	Number    uint32
+	Timestamp uint64
+	// Transactions are hashes ordered by transaction index
+	Transactions []string
+}
+
+// Client is a minimal Ethereum JSON-RPC client
+type Client struct {
+	Url        string
+	HttpClient *http.Client
+}
+
+func NewClient(url string) *Client {
+	return &Client{
+		Url: url,
+		HttpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+type rpcRequest struct {
+	JsonRpc string `json:"jsonrpc"`
+	Id      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (r *rpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", r.Code, r.Message)
+}
+
+type rpcResponse struct {
+	Id     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcBlock struct {
+	Number       string   `json:"number"`
+	Timestamp    string   `json:"timestamp"`
+	Transactions []string `json:"transactions"`
+}
+
+// BlocksByNumber calls eth_getBlockByNumber for each block number (without full
+// transaction objects) and returns blocks in the same order
+func (c *Client) BlocksByNumber(ctx context.Context, blockNumbers []uint32) (blocks []Block, err error) {
+	blocks = make([]Block, 0, len(blockNumbers))
+	for start := 0; start < len(blockNumbers); start += maxRpcBatchLength {
+		end := min(start+maxRpcBatchLength, len(blockNumbers))
+		var batch []Block
+		batch, err = c.blocksBatch(ctx, blockNumbers[start:end])
+		if err != nil {
+			return
+		}
+		blocks = append(blocks, batch...)
+	}
+	return
+}
+
+func (c *Client) blocksBatch(ctx context.Context, blockNumbers []uint32) (blocks []Block, err error) {
+	requests := make([]rpcRequest, 0, len(blockNumbers))
+	for i, blockNumber := range blockNumbers {
+		requests = append(requests, rpcRequest{
+			JsonRpc: "2.0",
+			Id:      i,
+			Method:  "eth_getBlockByNumber",
+			Params:  []any{"0x" + strconv.FormatUint(uint64(blockNumber), 16), false},
+		})
+	}
+
+	var responses []rpcResponse
+	if err = c.call(ctx, requests, &responses); err != nil {
+		return
+	}
+	if len(responses) != len(requests) {
+		err = fmt.Errorf("expected %d responses, got %d", len(requests), len(responses))
+		return
+	}
+
+	// batch responses can come in any order
+	blocks = make([]Block, len(blockNumbers))
+	for _, response := range responses {
+		if response.Id < 0 || response.Id >= len(blockNumbers) {
+			err = fmt.Errorf("unexpected response id %d", response.Id)
+			return
+		}
+		if response.Error != nil {
+			err = fmt.Errorf("block %d: %w", blockNumbers[response.Id], response.Error)
+			return
+		}
+		var block *Block
+		block, err = parseBlock(response.Result)
+		if err != nil {
+			err = fmt.Errorf("block %d: %w", blockNumbers[response.Id], err)
+			return
+		}
+		blocks[response.Id] = *block
+	}
+	return
+}
+
+func (c *Client) call(ctx context.Context, request any, response any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	httpResponse, err := c.HttpClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("rpc provider returned status %d", httpResponse.StatusCode)
+	}
+	return json.NewDecoder(httpResponse.Body).Decode(response)
+}
+
+func parseBlock(raw json.RawMessage) (*Block, error) {
+	var result *rpcBlock
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, ErrBlockNotFound
+	}
+
+	number, err := strconv.ParseUint(result.Number, 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("parsing number: %w", err)
+	}
+	timestamp, err := strconv.ParseUint(result.Timestamp, 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing timestamp: %w", err)
+	}
+	return &Block{
+		Number:       uint32(number),
+		Timestamp:    timestamp,
+		Transactions: result.Transactions,
+	}, nil
+}
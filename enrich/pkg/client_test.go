@@ -0,0 +1,105 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// newRpcStandIn returns a server answering eth_getBlockByNumber batches. Block timestamp
+// is block number * 10 and each block has two transactions. Blocks above lastBlock don't exist.
+func newRpcStandIn(t *testing.T, lastBlock uint64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		responses := make([]map[string]any, 0, len(requests))
+		// reverse order, as batch responses can come in any order
+		for i := len(requests) - 1; i >= 0; i-- {
+			request := requests[i]
+			if request.Method != "eth_getBlockByNumber" || request.Params[1] != false {
+				t.Error("unexpected request:", request)
+			}
+			number, _ := strconv.ParseUint(request.Params[0].(string), 0, 64)
+			var result any
+			if number <= lastBlock {
+				result = map[string]any{
+					"number":    "0x" + strconv.FormatUint(number, 16),
+					"timestamp": "0x" + strconv.FormatUint(number*10, 16),
+					"transactions": []string{
+						"0x" + strconv.FormatUint(number, 16) + "00",
+						"0x" + strconv.FormatUint(number, 16) + "01",
+					},
+				}
+			}
+			responses = append(responses, map[string]any{
+				"jsonrpc": "2.0",
+				"id":      request.Id,
+				"result":  result,
+			})
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_BlocksByNumber(t *testing.T) {
+	server := newRpcStandIn(t, 1000)
+	client := NewClient(server.URL)
+
+	blockNumbers := make([]uint32, 0, maxRpcBatchLength+5)
+	for i := 0; i < cap(blockNumbers); i++ {
+		blockNumbers = append(blockNumbers, uint32(i+1))
+	}
+
+	blocks, err := client.BlocksByNumber(context.Background(), blockNumbers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(blocks); l != len(blockNumbers) {
+		t.Fatal("wrong length:", l)
+	}
+	for i, block := range blocks {
+		if block.Number != blockNumbers[i] {
+			t.Fatal("wrong order at", i, ":", block.Number)
+		}
+		if block.Timestamp != uint64(block.Number)*10 {
+			t.Fatal("wrong timestamp:", block.Timestamp)
+		}
+	}
+	if h := blocks[0].Transactions[1]; h != "0x101" {
+		t.Fatal("wrong tx hash:", h)
+	}
+}
+
+func TestClient_BlocksByNumberNotFound(t *testing.T) {
+	server := newRpcStandIn(t, 10)
+	client := NewClient(server.URL)
+
+	_, err := client.BlocksByNumber(context.Background(), []uint32{9, 11})
+	if !errors.Is(err, ErrBlockNotFound) {
+		t.Fatal("expected ErrBlockNotFound, got", err)
+	}
+}
+
+func TestClient_RpcError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"jsonrpc":"2.0","id":0,"error":{"code":-32005,"message":"limit exceeded"}}]`))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL).BlocksByNumber(context.Background(), []uint32{1})
+	var rpcErr *rpcError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32005 {
+		t.Fatal("expected rpc error, got", err)
+	}
+}
@@ -0,0 +1,77 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+)
+
+// Enricher reads block data from RPC provider and stores it in the database,
+// so that we can return timestamps and transaction hashes with appearances
+type Enricher struct {
+	Client *Client
+	// TxHashes enables storing transaction hashes. They take much more space than timestamps.
+	TxHashes bool
+}
+
+func NewEnricher(rpcUrl string, txHashes bool) *Enricher {
+	return &Enricher{
+		Client:   NewClient(rpcUrl),
+		TxHashes: txHashes,
+	}
+}
+
+// Blocks stores data of these blockNumbers that are not in the database yet
+func (e *Enricher) Blocks(ctx context.Context, c *database.Connection, blockNumbers []uint32) (enriched int, err error) {
+	unique := slices.Clone(blockNumbers)
+	slices.Sort(unique)
+	unique = slices.Compact(unique)
+
+	missing, err := database.FetchMissingBlocks(ctx, c, unique)
+	if err != nil {
+		return 0, fmt.Errorf("fetching missing blocks: %w", err)
+	}
+	return e.store(ctx, c, missing)
+}
+
+// Range works like Blocks, but for every block between firstBlock and lastBlock (inclusive)
+func (e *Enricher) Range(ctx context.Context, c *database.Connection, firstBlock uint32, lastBlock uint32) (enriched int, err error) {
+	missing, err := database.FetchMissingBlocksInRange(ctx, c, firstBlock, lastBlock)
+	if err != nil {
+		return 0, fmt.Errorf("fetching missing blocks: %w", err)
+	}
+	return e.store(ctx, c, missing)
+}
+
+func (e *Enricher) store(ctx context.Context, c *database.Connection, blockNumbers []uint32) (int, error) {
+	if len(blockNumbers) == 0 {
+		return 0, nil
+	}
+
+	blocks, err := e.Client.BlocksByNumber(ctx, blockNumbers)
+	if err != nil {
+		return 0, fmt.Errorf("reading blocks from rpc: %w", err)
+	}
+
+	if err := database.InsertBlockBatch(ctx, c, e.toDatabase(blocks)); err != nil {
+		return 0, fmt.Errorf("inserting blocks: %w", err)
+	}
+	return len(blocks), nil
+}
+
+func (e *Enricher) toDatabase(blocks []Block) []database.Block {
+	result := make([]database.Block, 0, len(blocks))
+	for _, block := range blocks {
+		dbBlock := database.Block{
+			Number:    block.Number,
+			Timestamp: block.Timestamp,
+		}
+		if e.TxHashes {
+			dbBlock.TransactionHashes = block.Transactions
+		}
+		result = append(result, dbBlock)
+	}
+	return result
+}
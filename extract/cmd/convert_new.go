@@ -2,20 +2,26 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
 
 	config "github.com/TrueBlocks/trueblocks-key/config/pkg"
 	convertNew "github.com/TrueBlocks/trueblocks-key/extract/internal/convert_new"
+	"github.com/TrueBlocks/trueblocks-key/extract/internal/db"
 	"github.com/spf13/cobra"
 )
 
 var convertNewCmd = &cobra.Command{
 	Use:   "convert_new path/to/index",
 	Short: "New fast tool to convert Unchained Index chunks to SQL",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runConvertNew,
+	Long: `New fast tool to convert Unchained Index chunks to SQL.
+With --manifest, chunks missing from path/to/index/finalized are downloaded first
+(see download command) and that directory is converted.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runConvertNew,
 }
 
 func init() {
+	convertNewCmd.Flags().String("manifest", "", "path to manifest.json, chunks are downloaded before converting")
 	rootCmd.AddCommand(convertNewCmd)
 }
 
@@ -38,6 +44,18 @@ func runConvertNew(cmd *cobra.Command, args []string) (err error) {
 		return err
 	}
 
+	dirPath := args[0]
+	manifestPath, err := cmd.Flags().GetString("manifest")
+	if err != nil {
+		return err
+	}
+	if manifestPath != "" {
+		if err = download(cmd, manifestPath, dirPath, false, false); err != nil {
+			return err
+		}
+		dirPath = filepath.Join(dirPath, "finalized")
+	}
+
 	host := cnf.Database[dbConfigKey].Host
 	port := cnf.Database[dbConfigKey].Port
 	database := cnf.Database[dbConfigKey].Database
@@ -45,6 +63,13 @@ func runConvertNew(cmd *cobra.Command, args []string) (err error) {
 	password := cnf.Database[dbConfigKey].Password
 	dsn := fmt.Sprintf("postgres://%s:%s@%s:%d/%s", user, password, host, port, database)
 
-	convertNew.ConvertDir(args[0], dsn)
-	return nil
+	conn, err := db.Connection(cmd.Context(), configPath, dbConfigKey)
+	if err != nil {
+		return err
+	}
+
+	return convertNew.ConvertDir(cmd.Context(), conn, dirPath, dsn, convertNew.Options{
+		Workers:   cnf.Convert.MaxConnections,
+		BatchSize: cnf.Convert.BatchSize,
+	})
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"log"
+
+	config "github.com/TrueBlocks/trueblocks-key/config/pkg"
+	manifest "github.com/TrueBlocks/trueblocks-key/manifest/pkg"
+	"github.com/spf13/cobra"
+)
+
+var downloadCmd = &cobra.Command{
+	Use:   "download path/to/manifest.json path/to/index",
+	Short: "Download index chunks listed in the manifest from IPFS gateway",
+	Long: `Download index chunks listed in the manifest from IPFS gateway (Manifest.Gateway).
+Files already present are downloaded again if their sizes don't match the manifest
+(or their CIDs, with --verify).`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDownload,
+}
+
+func init() {
+	downloadCmd.Flags().Bool("blooms", false, "download bloom filters too")
+	downloadCmd.Flags().Bool("verify", false, "check files already present against their CIDs, not only sizes")
+	rootCmd.AddCommand(downloadCmd)
+}
+
+func runDownload(cmd *cobra.Command, args []string) (err error) {
+	blooms, err := cmd.Flags().GetBool("blooms")
+	if err != nil {
+		return err
+	}
+	verify, err := cmd.Flags().GetBool("verify")
+	if err != nil {
+		return err
+	}
+	return download(cmd, args[0], args[1], blooms, verify)
+}
+
+// download saves chunks listed in manifestPath to indexPath
+func download(cmd *cobra.Command, manifestPath string, indexPath string, blooms bool, verify bool) (err error) {
+	configPath, err := cmd.Flags().GetString("config_path")
+	if err != nil {
+		return err
+	}
+	cnf, err := config.Get(configPath)
+	if err != nil {
+		return err
+	}
+
+	m, err := manifest.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	downloader := &manifest.Downloader{
+		Gateway:       cnf.Manifest.Gateway,
+		Workers:       cnf.Manifest.Workers,
+		Blooms:        blooms,
+		Indexes:       true,
+		VerifyPresent: verify,
+	}
+	result, err := downloader.Download(cmd.Context(), m, indexPath)
+	if err != nil {
+		return err
+	}
+	log.Println("downloaded", len(result.Downloaded), "files,", len(result.Present), "already present")
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"log"
 
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
 	"github.com/TrueBlocks/trueblocks-key/extract/internal/db"
 	"github.com/TrueBlocks/trueblocks-key/extract/internal/export"
 	"github.com/spf13/cobra"
@@ -16,16 +17,30 @@ import (
 var exportCmd = &cobra.Command{
 	Use:   "export destination/path",
 	Short: "Exports local database table to CSV files that can be uploaded to servers",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runExport,
+	Long: `Exports local database table to CSV files that can be uploaded to servers.
+
+Without --format, the table is exported by the database server into files split
+into 1GB parts (requires superuser). With --format, the data is streamed to this
+machine and written into destination/path directory as csv, csv.gz or parquet.
+Appearances are then exported with address strings instead of address ids.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
 }
 
 var exportAddresses bool
 var exportAppearances bool
+var exportFormat string
+var exportFirstBlock uint
+var exportLastBlock uint
+var exportPartitionByAddress bool
 
 func init() {
 	exportCmd.Flags().BoolVar(&exportAddresses, "addresses", false, "export addresses table")
 	exportCmd.Flags().BoolVar(&exportAppearances, "appearances", false, "export appearances table")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "stream to this machine as csv, csv.gz or parquet")
+	exportCmd.Flags().UintVar(&exportFirstBlock, "first-block", 0, "export appearances starting at this block (requires --format)")
+	exportCmd.Flags().UintVar(&exportLastBlock, "last-block", 0, "export appearances up to this block, inclusive (requires --format)")
+	exportCmd.Flags().BoolVar(&exportPartitionByAddress, "partition-by-address", false, "write a file per first address byte into address_prefix=XX directories (requires --format)")
 
 	rootCmd.AddCommand(exportCmd)
 }
@@ -44,19 +59,54 @@ func runExport(cmd *cobra.Command, args []string) error {
 		return errors.New("cannot export two tables at the same time")
 	}
 
-	conn, err := db.Connection(configPath, dbConfigKey)
+	conn, err := db.Connection(cmd.Context(), configPath, dbConfigKey)
 	if err != nil {
 		return err
 	}
 
 	log.Println(conn)
 
+	if exportFormat != "" {
+		return runStreamExport(cmd, conn, args[0])
+	}
+	if cmd.Flags().Changed("first-block") || cmd.Flags().Changed("last-block") || exportPartitionByAddress {
+		return errors.New("filters and partitioning require --format")
+	}
+
+	if exportAddresses {
+		return export.ExportAddresses(cmd.Context(), conn, args[0])
+	}
+
+	if exportAppearances {
+		return export.ExportAppearances(cmd.Context(), conn, args[0])
+	}
+
+	return errors.New("specify which table to export")
+}
+
+func runStreamExport(cmd *cobra.Command, conn *database.Connection, destDir string) error {
+	format, err := export.ParseFormat(exportFormat)
+	if err != nil {
+		return err
+	}
+
+	options := export.Options{
+		Format:             format,
+		PartitionByAddress: exportPartitionByAddress,
+	}
+	if cmd.Flags().Changed("first-block") {
+		options.Filter.FirstBlock = &exportFirstBlock
+	}
+	if cmd.Flags().Changed("last-block") {
+		options.Filter.LastBlock = &exportLastBlock
+	}
+
 	if exportAddresses {
-		return export.ExportAddresses(conn, args[0])
+		return export.Stream(cmd.Context(), conn, export.TableAddresses, destDir, options)
 	}
 
 	if exportAppearances {
-		return export.ExportAppearances(conn, args[0])
+		return export.Stream(cmd.Context(), conn, export.TableAppearances, destDir, options)
 	}
 
 	return errors.New("specify which table to export")
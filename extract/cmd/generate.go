@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/TrueBlocks/trueblocks-key/extract/internal/db"
+	"github.com/TrueBlocks/trueblocks-key/extract/internal/generate"
+	manifest "github.com/TrueBlocks/trueblocks-key/manifest/pkg"
+	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/blkrange"
+	"github.com/spf13/cobra"
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate path/to/index [ranges...]",
+	Short: "Generates index chunks and bloom filters from the database",
+	Long: `Generates Unchained Index chunks (finalized/*.bin) and bloom filters (blooms/*.bloom)
+for the given block ranges (e.g. 000000000-000000100) from appearances in the database.
+
+With --manifest, ranges listed in the manifest are generated (limited by --first-block
+and --last-block) and the files are compared with the manifest's CIDs and sizes, so
+the database can be cross-checked against the upstream index.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runGenerate,
+}
+
+func init() {
+	generateCmd.Flags().String("manifest", "", "generate and verify ranges listed in this manifest.json")
+	generateCmd.Flags().Uint64("first-block", 0, "skip manifest ranges ending before this block")
+	generateCmd.Flags().Uint64("last-block", 0, "skip manifest ranges starting after this block")
+	rootCmd.AddCommand(generateCmd)
+}
+
+func runGenerate(cmd *cobra.Command, args []string) error {
+	configPath, err := cmd.Flags().GetString("config_path")
+	if err != nil {
+		return err
+	}
+	dbConfigKey, err := cmd.Flags().GetString("database")
+	if err != nil {
+		return err
+	}
+	manifestPath, err := cmd.Flags().GetString("manifest")
+	if err != nil {
+		return err
+	}
+	firstBlock, err := cmd.Flags().GetUint64("first-block")
+	if err != nil {
+		return err
+	}
+	lastBlock, err := cmd.Flags().GetUint64("last-block")
+	if err != nil {
+		return err
+	}
+
+	indexPath := args[0]
+	var chunks []manifest.Chunk
+	var verify bool
+	if manifestPath != "" {
+		if len(args) > 1 {
+			return errors.New("specify either ranges or --manifest")
+		}
+		m, err := manifest.ReadFile(manifestPath)
+		if err != nil {
+			return err
+		}
+		for _, chunk := range m.Chunks {
+			blockRange, err := blkrange.FromFilename(chunk.Range)
+			if err != nil {
+				return err
+			}
+			if blockRange[1] < firstBlock || (cmd.Flags().Changed("last-block") && blockRange[0] > lastBlock) {
+				continue
+			}
+			chunks = append(chunks, chunk)
+		}
+		verify = true
+	} else {
+		if len(args) < 2 {
+			return errors.New("specify ranges or --manifest")
+		}
+		for _, fileRange := range args[1:] {
+			chunks = append(chunks, manifest.Chunk{Range: fileRange})
+		}
+	}
+
+	conn, err := db.Connection(cmd.Context(), configPath, dbConfigKey)
+	if err != nil {
+		return err
+	}
+
+	var mismatched int
+	for _, chunk := range chunks {
+		var expected *manifest.Chunk
+		if verify {
+			expected = &chunk
+		}
+		result, err := generate.Generate(cmd.Context(), conn, indexPath, chunk.Range, expected)
+		if err != nil {
+			return fmt.Errorf("generating %s: %w", chunk.Range, err)
+		}
+		log.Println("generated", result.Chunk.Range, "index", result.Chunk.IndexHash, "bloom", result.Chunk.BloomHash)
+		for _, mismatch := range result.Mismatches {
+			log.Println(result.Chunk.Range, "differs:", mismatch)
+		}
+		if len(result.Mismatches) > 0 {
+			mismatched++
+		}
+	}
+
+	if mismatched > 0 {
+		return fmt.Errorf("%d of %d chunks differ from the manifest", mismatched, len(chunks))
+	}
+	return nil
+}
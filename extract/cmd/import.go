@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/TrueBlocks/trueblocks-key/extract/internal/db"
+	"github.com/TrueBlocks/trueblocks-key/extract/internal/export"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import --addresses files... --appearances files...",
+	Short: "Imports files written by export into the database",
+	Long: `Imports files written by export into the database using COPY FROM STDIN.
+
+Files can be csv, csv.gz or parquet (export --format) or parts written by the
+server-side export (no --format). Flags accept globs and directories, e.g. an
+address-partitioned export. Addresses get new ids in the target database. Parts
+of the server-side export have address ids instead of addresses, so they have to
+be imported together with the exported addresses. Files are imported in chunks,
+each in its own transaction, and rows already present in the target tables are
+skipped, so an interrupted import can be run again. Importing appearances into
+the live table moves status' last indexed block.`,
+	Args: cobra.NoArgs,
+	RunE: runImport,
+}
+
+func init() {
+	importCmd.Flags().StringSlice("addresses", nil, "addresses files, imported first")
+	importCmd.Flags().StringSlice("appearances", nil, "appearances files")
+	importCmd.Flags().Bool("staging", false, "import into staging tables (table names with "+database.StagingSuffix+" suffix), created if missing")
+	rootCmd.AddCommand(importCmd)
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	configPath, err := cmd.Flags().GetString("config_path")
+	if err != nil {
+		return err
+	}
+	dbConfigKey, err := cmd.Flags().GetString("database")
+	if err != nil {
+		return err
+	}
+	addressPatterns, err := cmd.Flags().GetStringSlice("addresses")
+	if err != nil {
+		return err
+	}
+	appearancePatterns, err := cmd.Flags().GetStringSlice("appearances")
+	if err != nil {
+		return err
+	}
+	staging, err := cmd.Flags().GetBool("staging")
+	if err != nil {
+		return err
+	}
+	if len(addressPatterns) == 0 && len(appearancePatterns) == 0 {
+		return errors.New("specify files to import")
+	}
+
+	var addressFiles, appearanceFiles []string
+	if len(addressPatterns) > 0 {
+		if addressFiles, err = export.FindFiles(addressPatterns); err != nil {
+			return err
+		}
+	}
+	if len(appearancePatterns) > 0 {
+		if appearanceFiles, err = export.FindFiles(appearancePatterns); err != nil {
+			return err
+		}
+	}
+
+	conn, err := db.Connection(cmd.Context(), configPath, dbConfigKey)
+	if err != nil {
+		return err
+	}
+	target := conn.ImportTarget(staging)
+	log.Println(conn, "importing into", target.AddressesTable, "and", target.AppearancesTable)
+
+	importer, err := database.NewImporter(cmd.Context(), conn, target)
+	if err != nil {
+		return err
+	}
+	defer importer.Close()
+
+	var total database.ImportResult
+	for _, path := range addressFiles {
+		result, err := importFile(path, 2, func(rows *export.Rows) (database.ImportResult, error) {
+			return importer.ImportAddresses(cmd.Context(), rows)
+		})
+		if err != nil {
+			return err
+		}
+		total.Rows += result.Rows
+		total.Inserted += result.Inserted
+	}
+	if len(addressFiles) > 0 {
+		log.Println("addresses:", total.Rows, "read,", total.Inserted, "inserted")
+	}
+
+	total = database.ImportResult{}
+	for _, path := range appearanceFiles {
+		result, err := importFile(path, 3, func(rows *export.Rows) (database.ImportResult, error) {
+			// files without column names are server-side export parts with address ids
+			if rows.Columns == nil || rows.Columns[0] == "address_id" {
+				return importer.ImportAppearanceIds(cmd.Context(), rows)
+			}
+			return importer.ImportAppearances(cmd.Context(), rows)
+		})
+		if err != nil {
+			return err
+		}
+		total.Rows += result.Rows
+		total.Inserted += result.Inserted
+	}
+	if len(appearanceFiles) > 0 {
+		log.Println("appearances:", total.Rows, "read,", total.Inserted, "inserted")
+	}
+	return nil
+}
+
+func importFile(path string, width int, load func(*export.Rows) (database.ImportResult, error)) (result database.ImportResult, err error) {
+	rows, err := export.OpenRows(path, width)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	if result, err = load(rows); err != nil {
+		return result, fmt.Errorf("importing %s: %w", path, err)
+	}
+	log.Println(path, result.Rows, "rows read,", result.Inserted, "inserted")
+	return
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	config "github.com/TrueBlocks/trueblocks-key/config/pkg"
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	convertNew "github.com/TrueBlocks/trueblocks-key/extract/internal/convert_new"
+	"github.com/TrueBlocks/trueblocks-key/extract/internal/db"
+	"github.com/TrueBlocks/trueblocks-key/extract/internal/watch"
+	manifest "github.com/TrueBlocks/trueblocks-key/manifest/pkg"
+	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch path/to/index",
+	Short: "Convert new chunks as they are written to the index",
+	Long: `Convert new chunks as they are written to path/to/index/finalized and record them in
+chunks table. Chunks up to the last one in the table are skipped, so the command can be
+restarted at any time. It doesn't need notifications from the scraper.
+
+The index belongs to the scraper and is never modified. Invalid chunks are skipped and
+their copies are saved to --quarantine directory (with report.txt listing reasons). A
+skipped chunk is converted if it changes later.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWatch,
+}
+
+func init() {
+	watchCmd.Flags().Duration("poll", 0, "list the directory at this interval instead of using filesystem notifications")
+	watchCmd.Flags().Duration("settle", watch.DefaultSettle, "time file size has to stay the same before the chunk is converted")
+	watchCmd.Flags().String("author", "extract watch", "author recorded with chunks")
+	watchCmd.Flags().String("quarantine", "quarantine", "directory for copies of invalid chunks, outside of the index")
+	rootCmd.AddCommand(watchCmd)
+}
+
+func runWatch(cmd *cobra.Command, args []string) (err error) {
+	configPath, err := cmd.Flags().GetString("config_path")
+	if err != nil {
+		return err
+	}
+	dbConfigKey, err := cmd.Flags().GetString("database")
+	if err != nil {
+		return err
+	}
+	poll, err := cmd.Flags().GetDuration("poll")
+	if err != nil {
+		return err
+	}
+	settle, err := cmd.Flags().GetDuration("settle")
+	if err != nil {
+		return err
+	}
+	author, err := cmd.Flags().GetString("author")
+	if err != nil {
+		return err
+	}
+	quarantineDir, err := cmd.Flags().GetString("quarantine")
+	if err != nil {
+		return err
+	}
+	if err = checkOutside(quarantineDir, args[0]); err != nil {
+		return err
+	}
+
+	cnf, err := config.Get(configPath)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Connection(cmd.Context(), configPath, dbConfigKey)
+	if err != nil {
+		return err
+	}
+	lastChunk, _, err := database.FetchLastChunk(cmd.Context(), conn)
+	if err != nil {
+		return fmt.Errorf("reading last chunk: %w", err)
+	}
+	log.Println("last recorded chunk:", lastChunk.Range)
+
+	dbConfig := cnf.Database[dbConfigKey]
+	dsn := fmt.Sprintf("postgres://%s:%s@%s:%d/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+	dirPath := filepath.Join(args[0], "finalized")
+	converter, err := convertNew.NewConverter(cmd.Context(), conn, dsn, convertNew.Options{
+		Workers:     cnf.Convert.MaxConnections,
+		BatchSize:   cnf.Convert.BatchSize,
+		KeepInvalid: true,
+	}, quarantineDir)
+	if err != nil {
+		return err
+	}
+	defer converter.Close()
+
+	watcher := &watch.Watcher{
+		Dir:    dirPath,
+		After:  lastChunk.Range,
+		Poll:   poll,
+		Settle: settle,
+		Handle: func(ctx context.Context, filePath string) error {
+			converted, err := converter.ConvertFile(ctx, filePath)
+			if err != nil || !converted {
+				return err
+			}
+			return recordChunk(ctx, conn, converter, filePath, author)
+		},
+	}
+	return watcher.Run(cmd.Context())
+}
+
+// checkOutside returns an error if dirPath is inside indexPath, which we mustn't modify
+func checkOutside(dirPath string, indexPath string) error {
+	absDir, err := filepath.Abs(dirPath)
+	if err != nil {
+		return err
+	}
+	absIndex, err := filepath.Abs(indexPath)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absIndex, absDir)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("quarantine directory %s has to be outside of the index %s", dirPath, indexPath)
+	}
+	return nil
+}
+
+// recordChunk saves converted chunk in chunks table and moves status forward
+func recordChunk(ctx context.Context, conn *database.Connection, converter *convertNew.Converter, filePath string, author string) error {
+	cid, err := manifest.FileCid(filePath)
+	if err != nil {
+		return fmt.Errorf("computing CID: %w", err)
+	}
+	chunk := queueItem.Chunk{
+		Cid:    cid,
+		Range:  strings.TrimSuffix(filepath.Base(filePath), ".bin"),
+		Author: author,
+	}
+	if err = converter.SaveStatus(ctx); err != nil {
+		return fmt.Errorf("saving status: %w", err)
+	}
+	if err = database.InsertChunkBatch(ctx, conn, []queueItem.Chunk{chunk}); err != nil {
+		return fmt.Errorf("recording chunk: %w", err)
+	}
+	log.Println("recorded chunk", chunk.Range, chunk.Cid)
+	return nil
+}
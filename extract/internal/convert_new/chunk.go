@@ -5,89 +5,78 @@ import (
 	"fmt"
 	"io"
 	"strings"
-	"sync"
 )
 
 const recordSize = 28 // address table record size in bytes
 
-type convertResult struct {
-	Err  error
-	Args []any
+// appearanceRow is a single appearance ready to be inserted
+type appearanceRow struct {
+	Address          string
+	BlockNumber      uint32
+	TransactionIndex uint32
 }
 
-type addressResult []any
+// saveFunc saves a batch of rows. The slice is reused after saveFunc returns.
+type saveFunc func(ctx context.Context, rows []appearanceRow) error
 
-func ConvertChunk(ctx context.Context, out chan<- convertResult, chunk io.ReaderAt, chunkName string, fileSize int) {
-	h, err := NewHeader(chunk)
+// ConvertChunk validates chunk and passes its appearances to save in batches of at most
+// batchSize rows. Rows come in address table order (and in chunk order for every
+// address), so the output is the same for the same chunk. Nothing is saved if the chunk
+// is invalid (the error wraps ErrInvalidChunk then).
+func ConvertChunk(ctx context.Context, chunk io.ReaderAt, chunkName string, fileSize int, batchSize int, save saveFunc) error {
+	h, err := ValidateChunk(chunk, fileSize)
 	if err != nil {
-		reportError(out, fmt.Errorf("reading header: %w", err), chunkName)
-		return
+		return fmt.Errorf("%s: validating chunk: %w", chunkName, err)
 	}
-
-	workerCount := 100
-	if h.AddressCount < 100 {
-		workerCount = int(h.AddressCount)
+	if h.AddressCount == 0 {
+		return nil
 	}
-	addrPerWorker := int(h.AddressCount) / workerCount
-	lastWorkerExtra := int(h.AddressCount) % workerCount
-
-	// log.Println("Addr records:", h.AddressCount, "Worker cnt:", workerCount, "Addr/worker:", addrPerWorker, "Extra:", lastWorkerExtra)
-
-	var wg sync.WaitGroup
-
-	for i := 0; i < workerCount; i++ {
-		wg.Add(1)
-		go func(workerIndex int) {
-			defer wg.Done()
-
-			startByte := 44 + (workerIndex * recordSize * addrPerWorker) // header + ...
-			addrToRead := addrPerWorker
-			if workerIndex == workerCount-1 {
-				addrToRead += lastWorkerExtra
-			}
-
-			records := make([]addressRecord, addrToRead)
-			if err := readBytes(chunk, int64(startByte), (addrToRead * recordSize), &records); err != nil {
-				reportError(out, fmt.Errorf("reading records: %w", err), chunkName)
-				return
-			}
-
-			appTableStart := 44 + recordSize*h.AddressCount // header + address table
 
-			for i := 0; i < addrToRead; i++ {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					record := records[i]
-					addrStr := strings.ToLower(record.Address.Hex())
-
-					apps := make([]appearanceRecord, record.Count)
-					if err := readBytes(chunk, int64(appTableStart+8*record.Offset), 8*int(record.Count), &apps); err != nil {
-						reportError(out, fmt.Errorf("reading appearances: %w", err), chunkName)
-						return
-					}
-					for j := 0; j < len(apps); j++ {
-						app := apps[j]
-						// bnStr := strconv.FormatUint(uint64(app.BlockNumber), 10)
-						// txidStr := strconv.FormatUint(uint64(app.TransactionId), 10)
+	records := make([]addressRecord, h.AddressCount)
+	if err := readBytes(chunk, headerSize, int(h.AddressCount)*recordSize, &records); err != nil {
+		return fmt.Errorf("%s: reading records: %w", chunkName, err)
+	}
 
-						out <- convertResult{
-							Args: []any{
-								addrStr,
-								app.BlockNumber,
-								app.TransactionId,
-							},
-						}
-					}
+	appTableStart := int64(headerSize + recordSize*h.AddressCount) // header + address table
+	rows := make([]appearanceRow, 0, batchSize)
+	var apps []appearanceRecord
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if record.Count == 0 {
+			continue
+		}
+		addrStr := strings.ToLower(record.Address.Hex())
+		if cap(apps) < int(record.Count) {
+			apps = make([]appearanceRecord, record.Count)
+		}
+		apps = apps[:record.Count]
+		if err := readBytes(chunk, appTableStart+appearanceRecordSize*int64(record.Offset), appearanceRecordSize*int(record.Count), &apps); err != nil {
+			return fmt.Errorf("%s: reading appearances: %w", chunkName, err)
+		}
+
+		for _, app := range apps {
+			rows = append(rows, appearanceRow{
+				Address:          addrStr,
+				BlockNumber:      app.BlockNumber,
+				TransactionIndex: app.TransactionId,
+			})
+			if len(rows) >= batchSize {
+				if err := save(ctx, rows); err != nil {
+					return fmt.Errorf("%s: saving batch: %w", chunkName, err)
 				}
+				rows = rows[:0]
 			}
-		}(i)
+		}
 	}
 
-	wg.Wait()
-}
-
-func reportError(out chan<- convertResult, err error, fileName string) {
-	out <- convertResult{Err: fmt.Errorf("%s: %w", fileName, err)}
+	if len(rows) > 0 {
+		if err := save(ctx, rows); err != nil {
+			return fmt.Errorf("%s: saving batch: %w", chunkName, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,65 @@
+package convertNew
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestConvertChunk(t *testing.T) {
+	chunk := buildChunk(t, validHeader(2, 6), testAddresses, 3)
+
+	var batches [][]appearanceRow
+	save := func(ctx context.Context, rows []appearanceRow) error {
+		// rows are reused, so we have to copy them
+		batches = append(batches, slices.Clone(rows))
+		return nil
+	}
+	if err := ConvertChunk(context.Background(), bytes.NewReader(chunk), "000000000-000000001.bin", len(chunk), 4, save); err != nil {
+		t.Fatal(err)
+	}
+
+	first := "0x0000000000000000000000000000000000000001"
+	second := "0x0000000000000000000000000000000000000002"
+	expected := [][]appearanceRow{
+		{{first, 0, 0}, {first, 0, 1}, {first, 0, 2}, {second, 1, 0}},
+		{{second, 1, 1}, {second, 1, 2}},
+	}
+	if !reflect.DeepEqual(batches, expected) {
+		t.Fatal("wrong batches:", batches)
+	}
+}
+
+func TestConvertChunk_Errors(t *testing.T) {
+	chunk := buildChunk(t, validHeader(2, 6), testAddresses, 3)
+
+	var saved int
+	save := func(ctx context.Context, rows []appearanceRow) error {
+		saved += len(rows)
+		return nil
+	}
+
+	// nothing is saved from invalid chunks
+	truncated := chunk[:len(chunk)-1]
+	err := ConvertChunk(context.Background(), bytes.NewReader(truncated), "000000000-000000001.bin", len(truncated), 4, save)
+	if !errors.Is(err, ErrInvalidChunk) || saved != 0 {
+		t.Fatal("expected ErrInvalidChunk and no rows, got", err, saved)
+	}
+
+	errSave := errors.New("save failed")
+	err = ConvertChunk(context.Background(), bytes.NewReader(chunk), "000000000-000000001.bin", len(chunk), 4, func(ctx context.Context, rows []appearanceRow) error {
+		return errSave
+	})
+	if !errors.Is(err, errSave) {
+		t.Fatal("expected save error, got", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = ConvertChunk(ctx, bytes.NewReader(chunk), "000000000-000000001.bin", len(chunk), 4, save); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context error, got", err)
+	}
+}
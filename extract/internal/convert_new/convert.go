@@ -2,46 +2,110 @@ package convertNew
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/exp/mmap"
 )
 
-const batchSize = 5000 // 10000
-
-var insert = `
-WITH ids AS (
-    INSERT INTO mainnet_addresses (address)
-    VALUES ($1::varchar(42))
-    ON CONFLICT DO NOTHING
-    RETURNING id AS address_id
-),
-pids AS (
-    SELECT address_id FROM ids
-    UNION ALL
-    SELECT id AS address_id FROM mainnet_addresses WHERE ADDRESS = $1 LIMIT 1
-)
-INSERT INTO mainnet_appearances (address_id, block_number, tx_id)
-SELECT pids.address_id, $2, $3 FROM pids
-ON CONFLICT DO NOTHING;
-`
+const defaultWorkers = 20
+const defaultBatchSize = 5000
+
+// Options of ConvertDir, usually read from Convert config group
+type Options struct {
+	// Workers is the number of chunks converted at once. Every worker uses its own
+	// database connection.
+	Workers int
+	// BatchSize is the number of appearances inserted in a single batch
+	BatchSize int
+	// KeepInvalid makes invalid chunks copied to quarantine, not moved (see Quarantine.Copy)
+	KeepInvalid bool
+}
+
+func (o Options) workers() int {
+	if o.Workers <= 0 {
+		return defaultWorkers
+	}
+	return o.Workers
+}
+
+func (o Options) batchSize() int {
+	if o.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+	return o.BatchSize
+}
+
+// Converter saves chunks to the database. It's safe to use from many goroutines.
+type Converter struct {
+	dbpool     *pgxpool.Pool
+	batchSize  int
+	quarantine *Quarantine
+	doneApps   atomic.Int32
+	// insert matches the address format of conn's addresses table
+	insert string
+
+	mutex          sync.Mutex
+	lastBlock      uint32
+	lastChunkRange string
+	// conn is used for schema changes (partitions, see database.EnsureAppearancesPartitions)
+	conn *database.Connection
+}
 
-func ConvertDir(dirPath string, dsn string) {
-	dbpool, err := pgxpool.New(context.Background(), dsn)
+// NewConverter connects to dsn. conn is used to create appearances partitions before
+// they are needed. Invalid chunks are moved (or copied, see Options.KeepInvalid) to
+// quarantineDir.
+func NewConverter(ctx context.Context, conn *database.Connection, dsn string, options Options, quarantineDir string) (*Converter, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
-		log.Fatalln("unable to create connection pool:", err)
+		return nil, fmt.Errorf("parsing dsn: %w", err)
 	}
-	defer dbpool.Close()
+	poolConfig.MaxConns = int32(options.workers())
+	dbpool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create connection pool: %w", err)
+	}
+
+	return &Converter{
+		conn:       conn,
+		dbpool:     dbpool,
+		insert:     sql.InsertHexAppearance(conn.AppearancesTableName(), conn.AddressesTableName(), conn.BinaryAddresses()),
+		batchSize:  options.batchSize(),
+		quarantine: &Quarantine{Dir: quarantineDir, Copy: options.KeepInvalid},
+	}, nil
+}
+
+// Close closes database connections and logs quarantined chunks
+func (c *Converter) Close() {
+	c.quarantine.Report()
+	c.dbpool.Close()
+}
+
+// ConvertDir saves appearances from all chunks in dirPath. Chunks that fail validation
+// (see ValidateChunk) are skipped and moved to quarantine.
+//
+// At most options.Workers chunks are mapped into memory at once, each one is unmapped as
+// soon as it's converted.
+func ConvertDir(ctx context.Context, conn *database.Connection, dirPath string, dsn string, options Options) error {
+	c, err := NewConverter(ctx, conn, dsn, options, filepath.Join(dirPath, QuarantineDir))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
 
-	var doneApps atomic.Int32
 	var doneSecs atomic.Int32
-	progressContext, cancelProgress := context.WithCancel(context.Background())
+	progressContext, cancelProgress := context.WithCancel(ctx)
 	defer cancelProgress()
 
 	go func() {
@@ -50,7 +114,7 @@ func ConvertDir(dirPath string, dsn string) {
 		for {
 			select {
 			case <-ticker.C:
-				d := doneApps.Load()
+				d := c.doneApps.Load()
 				s := doneSecs.Add(1)
 				aps := d / s
 				fmt.Printf("\rApps done: %d (%d apps/sec)                        ", d, aps)
@@ -61,67 +125,129 @@ func ConvertDir(dirPath string, dsn string) {
 		}
 	}()
 
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
 	filePaths := make(chan string, 100)
 	go DirFiles(dirPath, filePaths)
 
-	for fileName := range filePaths {
-		chunkName := path.Base(fileName)
-		chunk, err := mmap.Open(fileName)
-		if err != nil {
-			log.Fatalln("mmap:", err)
-		}
-		defer chunk.Close()
-		fileSize := chunk.Len()
-
-		resuts := make(chan convertResult, 10000)
-
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < options.workers(); i++ {
+		wg.Add(1)
 		go func() {
-			defer close(resuts)
-			ConvertChunk(ctx, resuts, chunk, chunkName, fileSize)
+			defer wg.Done()
+			for fileName := range filePaths {
+				if ctx.Err() != nil {
+					// drain, so DirFiles can finish
+					continue
+				}
+				if _, err := c.ConvertFile(ctx, fileName); err != nil {
+					cancel(err)
+				}
+			}
 		}()
+	}
+	wg.Wait()
+	cancelProgress()
 
-		batch := &pgx.Batch{}
-		for item := range resuts {
-			if err := item.Err; err != nil {
-				cancel()
-				log.Fatalln("processing error:", err)
-			}
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
 
-			if args := item.Args; len(args) > 0 {
-				batch.Queue(insert, args...)
-				doneApps.Add(1)
-				if batch.Len() >= batchSize {
-					if err := saveApps(dbpool, batch); err != nil {
-						cancel()
-						log.Fatalln("batch insert:", err)
-					}
-					batch = &pgx.Batch{}
-				}
-			}
-		}
+	if err := c.SaveStatus(context.WithoutCancel(ctx)); err != nil {
+		// appearances are already saved, so we don't want to fail here
+		log.Println("updating status (run `dbadmin migrate status` if the table is missing):", err)
+	}
 
-		if err := saveApps(dbpool, batch); err != nil {
-			cancel()
-			log.Fatalln("batch insert remainder:", err)
+	log.Println("Done:", c.doneApps.Load())
+	return nil
+}
+
+// ConvertFile saves appearances from a single chunk file. Invalid chunks are quarantined
+// and converted is false then.
+func (c *Converter) ConvertFile(ctx context.Context, fileName string) (converted bool, err error) {
+	chunkName := path.Base(fileName)
+	chunk, err := mmap.Open(fileName)
+	if err != nil {
+		return false, fmt.Errorf("mmap: %w", err)
+	}
+
+	var lastBlock uint32
+	err = ConvertChunk(ctx, chunk, chunkName, chunk.Len(), c.batchSize, func(ctx context.Context, rows []appearanceRow) error {
+		for _, row := range rows {
+			lastBlock = max(lastBlock, row.BlockNumber)
+		}
+		if err := c.saveApps(ctx, rows); err != nil {
+			return err
 		}
+		c.doneApps.Add(int32(len(rows)))
+		return nil
+	})
+	// unmap right away, so we don't keep all chunks in memory
+	if closeErr := chunk.Close(); closeErr != nil {
+		log.Println("munmap:", closeErr)
 	}
 
-	log.Println("Done:", doneApps.Load())
+	if errors.Is(err, ErrInvalidChunk) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		return false, c.quarantine.Add(fileName, err)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastBlock = max(c.lastBlock, lastBlock)
+	if chunkRange := strings.TrimSuffix(chunkName, ".bin"); chunkRange > c.lastChunkRange {
+		c.lastChunkRange = chunkRange
+	}
+	return true, nil
 }
 
-func saveApps(dbpool *pgxpool.Pool, batch *pgx.Batch) error {
-	if batch.Len() == 0 {
-		return nil
+// SaveStatus moves status' last indexed block and last chunk to the values seen in
+// converted chunks (it never moves them backwards) and bumps the data version
+func (c *Converter) SaveStatus(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	batch := &pgx.Batch{}
+	batch.Queue(
+		sql.UpdateStatusLastIndexedBlock(c.conn.StatusTableName()),
+		pgx.NamedArgs{"lastIndexedBlock": c.lastBlock},
+	)
+	if c.lastChunkRange != "" {
+		batch.Queue(
+			sql.UpdateStatusLastChunk(c.conn.StatusTableName()),
+			pgx.NamedArgs{"lastChunkRange": c.lastChunkRange},
+		)
 	}
+	database.QueueBumpDataVersion(batch, c.conn)
+	return c.dbpool.SendBatch(ctx, batch).Close()
+}
 
-	// _, err := dbpool.CopyFrom(
-	// 	context.TODO(),
-	// 	pgx.Identifier{"appearances"},
-	// 	[]string{"id", "address", "block_number", "txid"},
-	// 	pgx.CopyFromRows(batch),
-	// )
+// ensurePartitions creates appearances partitions up to lastBlock (if the table is partitioned).
+// Partitions that already exist are remembered by conn, so it doesn't run DDL for every batch.
+func (c *Converter) ensurePartitions(ctx context.Context, lastBlock uint32) error {
+	if err := database.EnsureAppearancesPartitions(ctx, c.conn, lastBlock); err != nil {
+		return fmt.Errorf("creating partitions: %w", err)
+	}
+	return nil
+}
+
+func (c *Converter) saveApps(ctx context.Context, rows []appearanceRow) error {
+	var lastBlock uint32
+	for _, row := range rows {
+		lastBlock = max(lastBlock, row.BlockNumber)
+	}
+	if err := c.ensurePartitions(ctx, lastBlock); err != nil {
+		return err
+	}
 
-	return dbpool.SendBatch(context.TODO(), batch).Close()
+	batch := &pgx.Batch{}
+	for _, row := range rows {
+		batch.Queue(c.insert, row.Address, row.BlockNumber, row.TransactionIndex)
+	}
+	return c.dbpool.SendBatch(ctx, batch).Close()
 }
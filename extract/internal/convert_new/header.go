@@ -23,5 +23,5 @@ func NewHeader(r io.ReaderAt) (*indexHeader, error) {
 }
 
 func (i *indexHeader) Read(r io.ReaderAt) error {
-	return readBytes(r, 0, 44, i)
+	return readBytes(r, 0, headerSize, i)
 }
@@ -0,0 +1,89 @@
+package convertNew
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// QuarantineDir is created in the converted directory
+const QuarantineDir = "quarantine"
+
+// reportFileName lists quarantined chunks with reasons, one per line
+const reportFileName = "report.txt"
+
+// Quarantine moves invalid chunks away, so they are not converted again, and records
+// why they were rejected. A valid copy can be downloaded again (see extract download).
+type Quarantine struct {
+	Dir string
+	// Copy leaves invalid chunks where they are and stores their copies instead. It's
+	// for directories that we don't own, e.g. the scraper's index.
+	Copy    bool
+	entries []string
+}
+
+// Add moves (or copies) filePath to the quarantine directory and appends reason to the report
+func (q *Quarantine) Add(filePath string, reason error) (err error) {
+	if err = os.MkdirAll(q.Dir, 0755); err != nil {
+		return
+	}
+	quarantinedPath := filepath.Join(q.Dir, filepath.Base(filePath))
+	if q.Copy {
+		err = copyFile(filePath, quarantinedPath)
+	} else {
+		err = os.Rename(filePath, quarantinedPath)
+	}
+	if err != nil {
+		return
+	}
+
+	entry := fmt.Sprintf("%s %s: %s", time.Now().UTC().Format(time.RFC3339), filepath.Base(filePath), reason)
+	q.entries = append(q.entries, entry)
+	log.Println("quarantined", entry)
+
+	report, err := os.OpenFile(filepath.Join(q.Dir, reportFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	if _, err = fmt.Fprintln(report, entry); err != nil {
+		report.Close()
+		return
+	}
+	return report.Close()
+}
+
+// Report logs all chunks quarantined in this run
+func (q *Quarantine) Report() {
+	if len(q.entries) == 0 {
+		return
+	}
+	action := "moved"
+	if q.Copy {
+		action = "copied"
+	}
+	log.Println(len(q.entries), "invalid chunks", action, "to", q.Dir, "(see", reportFileName+"):")
+	for _, entry := range q.entries {
+		log.Println("  ", entry)
+	}
+}
+
+func copyFile(src string, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return
+	}
+	return out.Close()
+}
@@ -0,0 +1,86 @@
+package convertNew
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	headerSize           = 44 // index header size in bytes
+	appearanceRecordSize = 8  // appearance table record size in bytes
+
+	// MagicNumber starts every index chunk
+	MagicNumber = 0xdeadbeef
+	// SpecVersion is Unchained Index specification version that we support. Its Keccak
+	// hash is stored in chunk headers.
+	SpecVersion = "trueblocks-core@v2.0.0-release"
+)
+
+// ErrInvalidChunk is returned for chunks that are corrupted or have unsupported format
+var ErrInvalidChunk = errors.New("invalid chunk")
+
+var specVersionHash = crypto.Keccak256Hash([]byte(SpecVersion))
+
+// Validate checks magic number, specification hash and that tables described by the
+// header fill the file exactly (truncated files fail here)
+func (i *indexHeader) Validate(fileSize int) error {
+	if i.Magic != MagicNumber {
+		return fmt.Errorf("%w: wrong magic number %#x, expected %#x", ErrInvalidChunk, i.Magic, MagicNumber)
+	}
+	if i.Hash != specVersionHash {
+		return fmt.Errorf("%w: unsupported specification hash %s, expected %s (%s)", ErrInvalidChunk, i.Hash, specVersionHash, SpecVersion)
+	}
+	expectedSize := headerSize + int64(i.AddressCount)*recordSize + int64(i.AppearanceCount)*appearanceRecordSize
+	if int64(fileSize) != expectedSize {
+		return fmt.Errorf("%w: file size %d does not match header (%d addresses, %d appearances => %d bytes)", ErrInvalidChunk, fileSize, i.AddressCount, i.AppearanceCount, expectedSize)
+	}
+	return nil
+}
+
+// ValidateChunk reads chunk header and address table and checks that addresses are
+// sorted and unique, and that their appearances fill the appearance table without
+// gaps or overlaps. It doesn't read the appearance table itself.
+func ValidateChunk(chunk io.ReaderAt, fileSize int) (h *indexHeader, err error) {
+	if fileSize < headerSize {
+		return nil, fmt.Errorf("%w: file size %d is smaller than header", ErrInvalidChunk, fileSize)
+	}
+	if h, err = NewHeader(chunk); err != nil {
+		return
+	}
+	if err = h.Validate(fileSize); err != nil {
+		return nil, err
+	}
+
+	if h.AddressCount == 0 {
+		return
+	}
+	records := make([]addressRecord, h.AddressCount)
+	if err = readBytes(chunk, headerSize, int(h.AddressCount)*recordSize, &records); err != nil {
+		return nil, fmt.Errorf("reading address table: %w", err)
+	}
+
+	var previous common.Address
+	var nextOffset uint32
+	for index, record := range records {
+		if index > 0 && bytes.Compare(previous[:], record.Address[:]) >= 0 {
+			return nil, fmt.Errorf("%w: address %d (%s) is not greater than the previous one (%s)", ErrInvalidChunk, index, record.Address.Hex(), previous.Hex())
+		}
+		if record.Offset != nextOffset {
+			return nil, fmt.Errorf("%w: address %d (%s) appearances start at %d, expected %d", ErrInvalidChunk, index, record.Address.Hex(), record.Offset, nextOffset)
+		}
+		if uint64(record.Offset)+uint64(record.Count) > uint64(h.AppearanceCount) {
+			return nil, fmt.Errorf("%w: address %d (%s) appearances end past the appearance table", ErrInvalidChunk, index, record.Address.Hex())
+		}
+		previous = record.Address
+		nextOffset += record.Count
+	}
+	if nextOffset != h.AppearanceCount {
+		return nil, fmt.Errorf("%w: addresses have %d appearances, header says %d", ErrInvalidChunk, nextOffset, h.AppearanceCount)
+	}
+	return
+}
@@ -0,0 +1,154 @@
+package convertNew
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// buildChunk encodes chunk with addresses, each having count appearances
+func buildChunk(t *testing.T, header indexHeader, addresses []common.Address, count uint32) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	write := func(data any) {
+		if err := binary.Write(buf, binary.LittleEndian, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(header)
+	for i, address := range addresses {
+		write(addressRecord{Address: address, Offset: uint32(i) * count, Count: count})
+	}
+	for i := range addresses {
+		for j := uint32(0); j < count; j++ {
+			write(appearanceRecord{BlockNumber: uint32(i), TransactionId: j})
+		}
+	}
+	return buf.Bytes()
+}
+
+func validHeader(addressCount uint32, appearanceCount uint32) indexHeader {
+	return indexHeader{
+		Magic:           MagicNumber,
+		Hash:            specVersionHash,
+		AddressCount:    addressCount,
+		AppearanceCount: appearanceCount,
+	}
+}
+
+var testAddresses = []common.Address{
+	common.HexToAddress("0x0000000000000000000000000000000000000001"),
+	common.HexToAddress("0x0000000000000000000000000000000000000002"),
+}
+
+func TestSpecVersionHash(t *testing.T) {
+	// the value chifra writes to chunk headers
+	if specVersionHash.Hex() != "0x6fc0c6dd027719f456c1e50a329f6157767325aa937411fa6e7be9359d9e0046" {
+		t.Fatal("wrong spec version hash:", specVersionHash.Hex())
+	}
+}
+
+func TestValidateChunk(t *testing.T) {
+	chunk := buildChunk(t, validHeader(2, 6), testAddresses, 3)
+	h, err := ValidateChunk(bytes.NewReader(chunk), len(chunk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.AddressCount != 2 {
+		t.Fatal("wrong address count:", h.AddressCount)
+	}
+
+	// empty chunk is fine
+	empty := buildChunk(t, validHeader(0, 0), nil, 0)
+	if _, err = ValidateChunk(bytes.NewReader(empty), len(empty)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateChunk_Invalid(t *testing.T) {
+	wrongMagic := validHeader(2, 6)
+	wrongMagic.Magic = 0xbeef
+	wrongHash := validHeader(2, 6)
+	wrongHash.Hash = common.Hash{}
+
+	valid := buildChunk(t, validHeader(2, 6), testAddresses, 3)
+	tests := map[string]struct {
+		chunk    []byte
+		contains string
+	}{
+		"wrong magic":       {buildChunk(t, wrongMagic, testAddresses, 3), "magic number"},
+		"wrong hash":        {buildChunk(t, wrongHash, testAddresses, 3), "specification hash"},
+		"truncated":         {valid[:len(valid)-1], "file size"},
+		"too short":         {valid[:10], "smaller than header"},
+		"unsorted":          {buildChunk(t, validHeader(2, 6), []common.Address{testAddresses[1], testAddresses[0]}, 3), "not greater"},
+		"duplicated":        {buildChunk(t, validHeader(2, 6), []common.Address{testAddresses[0], testAddresses[0]}, 3), "not greater"},
+		"wrong app count":   {append(buildChunk(t, validHeader(2, 6), testAddresses, 2), make([]byte, 2*appearanceRecordSize)...), "addresses have 4 appearances"},
+		"address past apps": {buildChunk(t, validHeader(2, 4), testAddresses, 3)[:headerSize+2*recordSize+4*appearanceRecordSize], "past the appearance table"},
+	}
+
+	for name, test := range tests {
+		_, err := ValidateChunk(bytes.NewReader(test.chunk), len(test.chunk))
+		if !errors.Is(err, ErrInvalidChunk) || !strings.Contains(err.Error(), test.contains) {
+			t.Fatal(name, "wrong error:", err)
+		}
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "000000000-000000099.bin")
+	if err := os.WriteFile(filePath, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	q := &Quarantine{Dir: filepath.Join(dir, QuarantineDir)}
+	if err := q.Add(filePath, ErrInvalidChunk); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filePath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("file should be moved, got", err)
+	}
+	if _, err := os.Stat(filepath.Join(q.Dir, "000000000-000000099.bin")); err != nil {
+		t.Fatal(err)
+	}
+	report, err := os.ReadFile(filepath.Join(q.Dir, reportFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "000000000-000000099.bin: invalid chunk") {
+		t.Fatal("wrong report:", string(report))
+	}
+}
+
+func TestQuarantine_Copy(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "000000000-000000099.bin")
+	if err := os.WriteFile(filePath, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	q := &Quarantine{Dir: filepath.Join(t.TempDir(), QuarantineDir), Copy: true}
+	if err := q.Add(filePath, ErrInvalidChunk); err != nil {
+		t.Fatal(err)
+	}
+	// source directory is left untouched
+	if content, err := os.ReadFile(filePath); err != nil || string(content) != "garbage" {
+		t.Fatal("source file changed:", string(content), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatal("wrong source directory entries:", entries)
+	}
+	if content, err := os.ReadFile(filepath.Join(q.Dir, "000000000-000000099.bin")); err != nil || string(content) != "garbage" {
+		t.Fatal("wrong copy:", string(content), err)
+	}
+	if _, err := os.Stat(filepath.Join(q.Dir, reportFileName)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 var connection *database.Connection
 
-func Connection(configPath string, dbConfigKey string) (*database.Connection, error) {
+func Connection(ctx context.Context, configPath string, dbConfigKey string) (*database.Connection, error) {
 	if connection != nil {
 		return connection, nil
 	}
@@ -19,15 +19,12 @@ func Connection(configPath string, dbConfigKey string) (*database.Connection, er
 		return nil, err
 	}
 
-	dbConnection := &database.Connection{
-		Chain:    "mainnet",
-		Host:     config.Database[dbConfigKey].Host,
-		Port:     config.Database[dbConfigKey].Port,
-		User:     config.Database[dbConfigKey].User,
-		Password: config.Database[dbConfigKey].Password,
-		Database: config.Database[dbConfigKey].Database,
+	dbProvider, err := database.NewProvider(config, dbConfigKey, "mainnet")
+	if err != nil {
+		return nil, err
 	}
-	if err := dbConnection.Connect(context.TODO()); err != nil {
+	dbConnection, err := dbProvider.Connection(ctx)
+	if err != nil {
 		return nil, err
 	}
 
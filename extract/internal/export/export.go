@@ -10,15 +10,15 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
-func ExportAddresses(dbConn *database.Connection, destPath string) error {
-	return export(dbConn, destPath, dbConn.AddressesTableName())
+func ExportAddresses(ctx context.Context, dbConn *database.Connection, destPath string) error {
+	return export(ctx, dbConn, destPath, dbConn.AddressesTableName())
 }
 
-func ExportAppearances(dbConn *database.Connection, destPath string) error {
-	return export(dbConn, destPath, dbConn.AppearancesTableName())
+func ExportAppearances(ctx context.Context, dbConn *database.Connection, destPath string) error {
+	return export(ctx, dbConn, destPath, dbConn.AppearancesTableName())
 }
 
-func export(dbConn *database.Connection, destPath string, tableName string) error {
+func export(ctx context.Context, dbConn *database.Connection, destPath string, tableName string) error {
 	if destPath == "" {
 		return errors.New("export: destination path required")
 	}
@@ -26,7 +26,7 @@ func export(dbConn *database.Connection, destPath string, tableName string) erro
 	log.Println("Exporting", tableName, "table, destination:", destPath)
 
 	_, err := dbConn.Db().Exec(
-		context.TODO(),
+		ctx,
 		fmt.Sprintf(
 			"COPY (SELECT * FROM %s) TO PROGRAM %s (FORMAT 'csv')",
 			pgx.Identifier.Sanitize(pgx.Identifier{tableName}),
@@ -0,0 +1,184 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetRowGroupSize is the number of rows buffered before a row group is written
+const parquetRowGroupSize = 100_000
+
+var ErrUnsupportedParquet = errors.New("unsupported parquet file")
+
+type parquetColumn struct {
+	Name string
+	// Type is parquet.Type_INT64 or parquet.Type_BYTE_ARRAY (UTF-8 string)
+	Type parquet.Type
+}
+
+// metadata describes the column in the format of writer.NewCSVWriter
+func (c parquetColumn) metadata() string {
+	if c.Type == parquet.Type_BYTE_ARRAY {
+		return fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED", c.Name)
+	}
+	return fmt.Sprintf("name=%s, type=%s, repetitiontype=REQUIRED", c.Name, c.Type)
+}
+
+// parquetWriter writes flat tables of required INT64 and UTF-8 columns
+type parquetWriter struct {
+	pw      *writer.CSVWriter
+	columns []parquetColumn
+	rows    int64
+}
+
+func newParquetWriter(w io.Writer, columns []parquetColumn, compress bool) (*parquetWriter, error) {
+	metadata := make([]string, 0, len(columns))
+	for _, column := range columns {
+		metadata = append(metadata, column.metadata())
+	}
+	pw, err := writer.NewCSVWriterFromWriter(metadata, w, 1)
+	if err != nil {
+		return nil, fmt.Errorf("parquet: %w", err)
+	}
+	// readers ignore statistics of files without created_by (PARQUET-251)
+	createdBy := "trueblocks-key extract"
+	pw.Footer.CreatedBy = &createdBy
+	pw.CompressionType = parquet.CompressionCodec_UNCOMPRESSED
+	if compress {
+		pw.CompressionType = parquet.CompressionCodec_GZIP
+	}
+	return &parquetWriter{pw: pw, columns: columns}, nil
+}
+
+// WriteRow appends a row of values in text form (as returned by COPY ... csv)
+func (p *parquetWriter) WriteRow(row []string) error {
+	if len(row) != len(p.columns) {
+		return fmt.Errorf("parquet: expected %d values, got %d", len(p.columns), len(row))
+	}
+	// the writer buffers values until a page is full, so they can't be reused
+	values := make([]any, len(p.columns))
+	for i, column := range p.columns {
+		if column.Type != parquet.Type_INT64 {
+			values[i] = row[i]
+			continue
+		}
+		v, err := strconv.ParseInt(row[i], 10, 64)
+		if err != nil {
+			return fmt.Errorf("parquet: column %s: %w", column.Name, err)
+		}
+		values[i] = v
+	}
+	if err := p.pw.Write(values); err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	p.rows++
+	if p.rows%parquetRowGroupSize == 0 {
+		// true writes a row group
+		return p.pw.Flush(true)
+	}
+	return nil
+}
+
+// Close writes remaining rows and the footer. It doesn't close the underlying writer.
+func (p *parquetWriter) Close() error {
+	if err := p.pw.WriteStop(); err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	return nil
+}
+
+// parquetReader reads flat tables of INT64 and BYTE_ARRAY columns, e.g. written by
+// parquetWriter. Values are returned in text form, the same way COPY writes them.
+type parquetReader struct {
+	pr      *reader.ParquetReader
+	columns []parquetColumn
+	// left is the number of rows not read yet
+	left int64
+
+	values [][]any
+	row    int
+}
+
+// parquetReadBatch is the number of rows read from each column at once
+const parquetReadBatch = 10_000
+
+func newParquetReader(file source.ParquetFile) (*parquetReader, error) {
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedParquet, err)
+	}
+
+	p := &parquetReader{pr: pr, left: pr.GetNumRows()}
+	for i, element := range pr.Footer.Schema {
+		if i == 0 {
+			// root
+			continue
+		}
+		// the reader renames columns to Go names, ExName is the one stored in the file
+		name := pr.SchemaHandler.Infos[i].ExName
+		if element.GetNumChildren() > 0 || element.Type == nil ||
+			(*element.Type != parquet.Type_INT64 && *element.Type != parquet.Type_BYTE_ARRAY) {
+			p.Close()
+			return nil, fmt.Errorf("%w: column %s has to be INT64 or BYTE_ARRAY", ErrUnsupportedParquet, name)
+		}
+		p.columns = append(p.columns, parquetColumn{Name: name, Type: *element.Type})
+	}
+	return p, nil
+}
+
+// Next returns next row or io.EOF
+func (p *parquetReader) Next() ([]string, error) {
+	if p.values == nil || p.row >= len(p.values[0]) {
+		if p.left <= 0 {
+			return nil, io.EOF
+		}
+		if err := p.readBatch(); err != nil {
+			return nil, err
+		}
+	}
+
+	row := make([]string, len(p.columns))
+	for i, column := range p.columns {
+		switch value := p.values[i][p.row].(type) {
+		case int64:
+			row[i] = strconv.FormatInt(value, 10)
+		case string:
+			row[i] = value
+		default:
+			return nil, fmt.Errorf("%w: column %s: unexpected value %v", ErrUnsupportedParquet, column.Name, value)
+		}
+	}
+	p.row++
+	return row, nil
+}
+
+func (p *parquetReader) readBatch() error {
+	batch := min(p.left, parquetReadBatch)
+	p.values = make([][]any, len(p.columns))
+	for i, column := range p.columns {
+		values, _, _, err := p.pr.ReadColumnByIndex(int64(i), batch)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", column.Name, err)
+		}
+		if int64(len(values)) != batch {
+			return fmt.Errorf("%w: column %s: expected %d values, got %d", ErrUnsupportedParquet, column.Name, batch, len(values))
+		}
+		p.values[i] = values
+	}
+	p.left -= batch
+	p.row = 0
+	return nil
+}
+
+// Close closes all files opened by the reader, including the one passed to newParquetReader
+func (p *parquetReader) Close() {
+	p.pr.ReadStop()
+	p.pr.PFile.Close()
+}
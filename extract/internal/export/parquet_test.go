@@ -0,0 +1,219 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestWriteParquet(t *testing.T) {
+	csvData := "address,block_number,tx_id\n" +
+		"0xf503017d7baf7fbc0fff7492b751025c6a78179b,46147,1\n" +
+		"0x0000000000000000000000000000000000000001,1000000,0\n"
+
+	var out bytes.Buffer
+	rows, err := writeFormat(&out, FormatParquet, TableAppearances.columns, func(w io.Writer) (int64, error) {
+		_, err := io.WriteString(w, csvData)
+		return 2, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 {
+		t.Fatal("wrong rows:", rows)
+	}
+
+	file, err := buffer.NewBufferFile(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	footerReader, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := footerReader.Footer
+	if meta.NumRows != 2 {
+		t.Fatal("wrong num_rows:", meta.NumRows)
+	}
+	if len(meta.Schema) != 4 || meta.Schema[0].GetNumChildren() != 3 {
+		t.Fatal("wrong schema:", meta.Schema)
+	}
+	// names in the footer are renamed to Go names when it's read
+	if names := footerReader.SchemaHandler.Infos; names[1].ExName != "address" || names[2].ExName != "block_number" || names[3].ExName != "tx_id" {
+		t.Fatal("wrong column names:", names)
+	}
+	if address := meta.Schema[1]; address.GetType() != parquet.Type_BYTE_ARRAY ||
+		address.GetConvertedType() != parquet.ConvertedType_UTF8 || address.GetRepetitionType() != parquet.FieldRepetitionType_REQUIRED {
+		t.Fatal("wrong address column:", address)
+	}
+	if blockNumber := meta.Schema[2]; blockNumber.GetType() != parquet.Type_INT64 {
+		t.Fatal("wrong block_number column:", blockNumber)
+	}
+	if len(meta.RowGroups) != 1 {
+		t.Fatal("wrong row groups:", len(meta.RowGroups))
+	}
+	for _, column := range meta.RowGroups[0].Columns {
+		if codec := column.MetaData.Codec; codec != parquet.CompressionCodec_GZIP {
+			t.Fatal("wrong codec:", codec)
+		}
+	}
+
+	// rows read with parquet-go's row reader, which maps columns to struct fields
+	type appearance struct {
+		Address     string `parquet:"name=address, type=BYTE_ARRAY, convertedtype=UTF8"`
+		BlockNumber int64  `parquet:"name=block_number, type=INT64"`
+		TxId        int64  `parquet:"name=tx_id, type=INT64"`
+	}
+	rowReader, err := reader.NewParquetReader(file, new(appearance), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rowReader.ReadStop()
+	apps := make([]appearance, 2)
+	if err := rowReader.Read(&apps); err != nil {
+		t.Fatal(err)
+	}
+	if apps[0] != (appearance{"0xf503017d7baf7fbc0fff7492b751025c6a78179b", 46147, 1}) || apps[1] != (appearance{"0x0000000000000000000000000000000000000001", 1000000, 0}) {
+		t.Fatal("wrong appearances:", apps)
+	}
+
+}
+
+func TestWriteParquet_RowGroups(t *testing.T) {
+	var out bytes.Buffer
+	pw, err := newParquetWriter(&out, TableAddresses.columns, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := parquetRowGroupSize + 10
+	for i := 0; i < total; i++ {
+		if err := pw.WriteRow([]string{strconv.Itoa(i), "0x" + strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := buffer.NewBufferFile(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	footerReader, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer footerReader.ReadStop()
+	if numRows := footerReader.GetNumRows(); numRows != int64(total) {
+		t.Fatal("wrong num_rows:", numRows)
+	}
+	if rowGroups := len(footerReader.Footer.RowGroups); rowGroups != 2 {
+		t.Fatal("wrong row groups:", rowGroups)
+	}
+}
+
+func TestWriteParquet_InvalidValue(t *testing.T) {
+	_, err := writeFormat(io.Discard, FormatParquet, TableAppearances.columns, func(w io.Writer) (int64, error) {
+		_, err := io.WriteString(w, "address,block_number,tx_id\n0x01,notanumber,1\n")
+		return 1, err
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("csv.gz"); err != nil || f != FormatCsvGzip {
+		t.Fatal("wrong format:", f, err)
+	}
+	if _, err := ParseFormat("xlsx"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParquetReader(t *testing.T) {
+	csvData := "address,block_number,tx_id\n" +
+		"0xf503017d7baf7fbc0fff7492b751025c6a78179b,46147,1\n" +
+		"0x0000000000000000000000000000000000000001,1000000,0\n"
+
+	var out bytes.Buffer
+	if _, err := writeFormat(&out, FormatParquet, TableAppearances.columns, func(w io.Writer) (int64, error) {
+		_, err := io.WriteString(w, csvData)
+		return 2, err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := buffer.NewBufferFile(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := newParquetReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	if len(pr.columns) != 3 || pr.columns[0].Name != "address" || pr.columns[1].Name != "block_number" || pr.columns[2].Name != "tx_id" {
+		t.Fatal("wrong columns:", pr.columns)
+	}
+	var rowsRead []string
+	for {
+		row, err := pr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rowsRead = append(rowsRead, strings.Join(row, ","))
+	}
+	if strings.Join(rowsRead, "\n")+"\n" != strings.SplitN(csvData, "\n", 2)[1] {
+		t.Fatal("wrong rows:", rowsRead)
+	}
+}
+
+func TestParquetReader_RowGroups(t *testing.T) {
+	var out bytes.Buffer
+	pw, err := newParquetWriter(&out, TableAddresses.columns, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := parquetRowGroupSize + 10
+	for i := 0; i < total; i++ {
+		if err := pw.WriteRow([]string{strconv.Itoa(i), "0x" + strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := buffer.NewBufferFile(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := newParquetReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	for i := 0; i < total; i++ {
+		row, err := pr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row[0] != strconv.Itoa(i) || row[1] != "0x"+strconv.Itoa(i) {
+			t.Fatal("wrong row", i, row)
+		}
+	}
+	if _, err := pr.Next(); !errors.Is(err, io.EOF) {
+		t.Fatal("expected EOF, got", err)
+	}
+}
@@ -0,0 +1,169 @@
+package export
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xitongsys/parquet-go-source/local"
+)
+
+// Rows reads files written by Stream (csv, csv.gz and parquet, with column names) and
+// parts written by server-side export (CSV without header). It implements
+// pgx.CopyFromSource, so it can be passed to database.Importer.
+type Rows struct {
+	Path string
+	// Columns are column names, nil for server-side export parts
+	Columns []string
+
+	file *os.File
+	// parquet is set for parquet files, they are read by column, not from file
+	parquet *parquetReader
+	next    func() ([]string, error)
+	width   int
+	values  []any
+	err     error
+}
+
+// OpenRows opens path and reads its column names. Only the first width values of each
+// row are returned (server-side exports of appearances include the seq column).
+func OpenRows(path string, width int) (r *Rows, err error) {
+	r = &Rows{Path: path, width: width}
+	if r.file, err = os.Open(path); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			r.Close()
+		}
+	}()
+
+	switch {
+	case strings.HasSuffix(path, "."+string(FormatParquet)):
+		file, err := local.NewLocalFileReader(path)
+		if err != nil {
+			return nil, err
+		}
+		if r.parquet, err = newParquetReader(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, column := range r.parquet.columns {
+			r.Columns = append(r.Columns, column.Name)
+		}
+		r.next = r.parquet.Next
+	case strings.HasSuffix(path, "."+string(FormatCsvGzip)):
+		gz, err := gzip.NewReader(r.file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if err = r.readCsv(gz, true); err != nil {
+			return nil, err
+		}
+	case strings.HasSuffix(path, "."+string(FormatCsv)):
+		if err = r.readCsv(r.file, true); err != nil {
+			return nil, err
+		}
+	default:
+		if err = r.readCsv(r.file, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Columns != nil && len(r.Columns) < width {
+		return nil, fmt.Errorf("%s: expected %d columns, got %v", path, width, r.Columns)
+	}
+	return r, nil
+}
+
+func (r *Rows) readCsv(reader io.Reader, header bool) (err error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	if header {
+		columns, err := csvReader.Read()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", r.Path, err)
+		}
+		r.Columns = append([]string{}, columns...)
+	}
+	r.next = csvReader.Read
+	return nil
+}
+
+// Next implements pgx.CopyFromSource
+func (r *Rows) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	row, err := r.next()
+	if errors.Is(err, io.EOF) {
+		return false
+	}
+	if err == nil && len(row) < r.width {
+		err = fmt.Errorf("expected %d values, got %d", r.width, len(row))
+	}
+	if err != nil {
+		r.err = fmt.Errorf("%s: %w", r.Path, err)
+		return false
+	}
+	r.values = r.values[:0]
+	for _, value := range row[:r.width] {
+		r.values = append(r.values, value)
+	}
+	return true
+}
+
+// Values implements pgx.CopyFromSource
+func (r *Rows) Values() ([]any, error) {
+	return r.values, nil
+}
+
+// Err implements pgx.CopyFromSource
+func (r *Rows) Err() error {
+	return r.err
+}
+
+func (r *Rows) Close() error {
+	if r.parquet != nil {
+		r.parquet.Close()
+	}
+	return r.file.Close()
+}
+
+// FindFiles expands globs and directories (recursively, e.g. address-partitioned
+// exports) into a sorted list of files. Temporary files of interrupted exports are
+// skipped.
+func FindFiles(patterns []string) (files []string, err error) {
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", pattern)
+		}
+		for _, match := range matches {
+			err = filepath.WalkDir(match, func(path string, d os.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() || strings.HasSuffix(path, ".tmp") {
+					return nil
+				}
+				files = append(files, path)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(files)
+	return
+}
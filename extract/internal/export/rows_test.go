@@ -0,0 +1,104 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readAll(t *testing.T, path string, width int) (columns []string, rows [][]any) {
+	r, err := OpenRows(path, width)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for r.Next() {
+		values, _ := r.Values()
+		rows = append(rows, append([]any{}, values...))
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return r.Columns, rows
+}
+
+func TestOpenRows(t *testing.T) {
+	dir := t.TempDir()
+	csvData := "address,block_number,tx_id\n0xf503017d7baf7fbc0fff7492b751025c6a78179b,46147,1\n"
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(csvData))
+	gz.Close()
+
+	var parquet bytes.Buffer
+	pw, err := newParquetWriter(&parquet, TableAppearances.columns, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.WriteRow([]string{"0xf503017d7baf7fbc0fff7492b751025c6a78179b", "46147", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"appearances.csv":     []byte(csvData),
+		"appearances.csv.gz":  gzipped.Bytes(),
+		"appearances.parquet": parquet.Bytes(),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		columns, rows := readAll(t, path, 3)
+		if len(columns) != 3 || columns[0] != "address" {
+			t.Fatal(name, "wrong columns:", columns)
+		}
+		if len(rows) != 1 || rows[0][0] != "0xf503017d7baf7fbc0fff7492b751025c6a78179b" || rows[0][1] != "46147" || rows[0][2] != "1" {
+			t.Fatal(name, "wrong rows:", rows)
+		}
+	}
+
+	// server-side export part: no header, appearances include seq
+	part := filepath.Join(dir, "appearances00")
+	if err := os.WriteFile(part, []byte("1,46147,1,10\n2,46147,2,11\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	columns, rows := readAll(t, part, 3)
+	if columns != nil {
+		t.Fatal("expected no columns, got", columns)
+	}
+	if len(rows) != 2 || len(rows[1]) != 3 || rows[1][0] != "2" {
+		t.Fatal("wrong part rows:", rows)
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"address_prefix=01/appearances.parquet",
+		"address_prefix=00/appearances.parquet",
+		"address_prefix=02/appearances.parquet.tmp",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, nil, 0644)
+	}
+
+	files, err := FindFiles([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(filepath.Dir(files[0])) != "address_prefix=00" {
+		t.Fatal("wrong files:", files)
+	}
+
+	if _, err := FindFiles([]string{filepath.Join(dir, "missing*")}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,202 @@
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/xitongsys/parquet-go/parquet"
+)
+
+// Format of files written by Stream
+type Format string
+
+const (
+	FormatCsv     Format = "csv"
+	FormatCsvGzip Format = "csv.gz"
+	FormatParquet Format = "parquet"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+func ParseFormat(value string) (Format, error) {
+	switch f := Format(value); f {
+	case FormatCsv, FormatCsvGzip, FormatParquet:
+		return f, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownFormat, value)
+}
+
+// Table describes exported table: how to stream it and what columns it has
+type Table struct {
+	Name    string
+	copyTo  func(ctx context.Context, c *database.Connection, w io.Writer, filter database.ExportFilter) (int64, error)
+	columns []parquetColumn
+}
+
+var TableAddresses = Table{
+	Name:   "addresses",
+	copyTo: database.CopyAddressesTo,
+	columns: []parquetColumn{
+		{Name: "id", Type: parquet.Type_INT64},
+		{Name: "address", Type: parquet.Type_BYTE_ARRAY},
+	},
+}
+
+var TableAppearances = Table{
+	Name:   "appearances",
+	copyTo: database.CopyAppearancesTo,
+	columns: []parquetColumn{
+		{Name: "address", Type: parquet.Type_BYTE_ARRAY},
+		{Name: "block_number", Type: parquet.Type_INT64},
+		{Name: "tx_id", Type: parquet.Type_INT64},
+	},
+}
+
+// Options of Stream
+type Options struct {
+	Format Format
+	Filter database.ExportFilter
+	// PartitionByAddress writes a file per first byte of address into
+	// address_prefix=XX directories (Hive-style partitioning)
+	PartitionByAddress bool
+}
+
+// Stream exports table using COPY TO STDOUT, so unlike ExportAddresses and
+// ExportAppearances it doesn't need superuser privileges and files are written on
+// the client's machine. Files are written to destDir and named after the table.
+func Stream(ctx context.Context, dbConn *database.Connection, table Table, destDir string, options Options) error {
+	if destDir == "" {
+		return errors.New("export: destination path required")
+	}
+	if options.Format == "" {
+		options.Format = FormatCsv
+	}
+
+	if !options.PartitionByAddress {
+		return streamFile(ctx, dbConn, table, filepath.Join(destDir, table.Name+"."+string(options.Format)), options)
+	}
+
+	for prefix := 0; prefix < 256; prefix++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		partition := options
+		partition.Filter.AddressPrefix = fmt.Sprintf("%02x", prefix)
+		dir := filepath.Join(destDir, "address_prefix="+partition.Filter.AddressPrefix)
+		if err := streamFile(ctx, dbConn, table, filepath.Join(dir, table.Name+"."+string(options.Format)), partition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamFile writes to a temporary file first, so interrupted export doesn't leave
+// incomplete files behind
+func streamFile(ctx context.Context, dbConn *database.Connection, table Table, destPath string, options Options) (err error) {
+	if err = os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	tmpPath := destPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	rows, err := writeFormat(file, options.Format, table.columns, func(w io.Writer) (int64, error) {
+		return table.copyTo(ctx, dbConn, w, options.Filter)
+	})
+	if err != nil {
+		return fmt.Errorf("export: %s: %w", destPath, err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err = os.Rename(tmpPath, destPath); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	log.Println("Exported", rows, table.Name, "to", destPath)
+	return nil
+}
+
+// writeFormat converts CSV (with header) written by copyTo into format and writes
+// it to w
+func writeFormat(w io.Writer, format Format, columns []parquetColumn, copyTo func(io.Writer) (int64, error)) (int64, error) {
+	switch format {
+	case FormatCsv:
+		return copyTo(w)
+	case FormatCsvGzip:
+		gz := gzip.NewWriter(w)
+		rows, err := copyTo(gz)
+		if err != nil {
+			return rows, err
+		}
+		return rows, gz.Close()
+	case FormatParquet:
+		return writeParquet(w, columns, copyTo)
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+func writeParquet(w io.Writer, columns []parquetColumn, copyTo func(io.Writer) (int64, error)) (int64, error) {
+	pw, err := newParquetWriter(w, columns, true)
+	if err != nil {
+		return 0, err
+	}
+
+	reader, writer := io.Pipe()
+	parsed := make(chan error, 1)
+	go func() {
+		parsed <- parseCsv(reader, pw)
+		// unblock COPY if parsing stopped early
+		reader.CloseWithError(io.ErrClosedPipe)
+	}()
+
+	rows, err := copyTo(writer)
+	writer.CloseWithError(err)
+	if parseErr := <-parsed; err == nil {
+		err = parseErr
+	}
+	if err != nil {
+		return rows, err
+	}
+	return rows, pw.Close()
+}
+
+func parseCsv(r io.Reader, pw *parquetWriter) error {
+	csvReader := csv.NewReader(r)
+	csvReader.ReuseRecord = true
+	// header
+	if _, err := csvReader.Read(); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := pw.WriteRow(record); err != nil {
+			return err
+		}
+	}
+}
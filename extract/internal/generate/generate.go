@@ -0,0 +1,147 @@
+package generate
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	manifest "github.com/TrueBlocks/trueblocks-key/manifest/pkg"
+	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/blkrange"
+	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query/bloom"
+	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query/chunk"
+)
+
+// Result describes files written for a single range
+type Result struct {
+	Chunk manifest.Chunk
+	// Mismatches lists differences from the expected manifest entry (if any was given)
+	Mismatches []string
+}
+
+// Generate writes the chunk and bloom filter of fileRange (e.g. 000000000-000000100)
+// into indexPath, laid out like chifra does it. The files are built from appearances
+// stored in the database, so they can only match upstream chunks if the database has
+// exactly the same appearances. If expected is not nil, the CIDs and sizes of the written
+// files are compared with it.
+func Generate(ctx context.Context, dbConn *database.Connection, indexPath string, fileRange string, expected *manifest.Chunk) (result Result, err error) {
+	blockRange, err := blkrange.FromFilename(fileRange)
+	if err != nil {
+		return result, fmt.Errorf("invalid range %s: %w", fileRange, err)
+	}
+	if blockRange[0] > blockRange[1] || blockRange[1] > uint64(^uint32(0)) {
+		return result, fmt.Errorf("invalid range %s", fileRange)
+	}
+
+	appearances, err := database.FetchAppearancesInRange(ctx, dbConn, uint32(blockRange[0]), uint32(blockRange[1]))
+	if err != nil {
+		return
+	}
+	addresses, err := groupByAddress(appearances)
+	if err != nil {
+		return
+	}
+
+	result.Chunk = manifest.Chunk{Range: fileRange}
+	indexFilePath := result.Chunk.IndexPath(indexPath)
+	if result.Chunk.IndexSize, err = writeFile(indexFilePath, func(f *os.File) error {
+		return chunk.Write(f, addresses)
+	}); err != nil {
+		return
+	}
+	bloomFilePath := result.Chunk.BloomPath(indexPath)
+	if result.Chunk.BloomSize, err = writeFile(bloomFilePath, func(f *os.File) error {
+		b := &bloom.Bloom{}
+		for _, address := range addresses {
+			b.InsertAddress(address.Address)
+		}
+		return b.Write(f)
+	}); err != nil {
+		return
+	}
+
+	if result.Chunk.IndexHash, err = manifest.FileCid(indexFilePath); err != nil {
+		return
+	}
+	if result.Chunk.BloomHash, err = manifest.FileCid(bloomFilePath); err != nil {
+		return
+	}
+
+	if expected != nil {
+		result.Mismatches = compare(&result.Chunk, expected)
+	}
+	return
+}
+
+// groupByAddress expects appearances ordered by address (see database.FetchAppearancesInRange)
+func groupByAddress(appearances []database.AddressAppearance) (addresses []chunk.AddressAppearances, err error) {
+	for i, appearance := range appearances {
+		if i == 0 || appearance.Address != appearances[i-1].Address {
+			decoded, err := hex.DecodeString(strings.TrimPrefix(appearance.Address, "0x"))
+			if err != nil || len(decoded) != 20 {
+				return nil, fmt.Errorf("invalid address in the database: %s", appearance.Address)
+			}
+			addresses = append(addresses, chunk.AddressAppearances{Address: [20]byte(decoded)})
+		}
+		last := &addresses[len(addresses)-1]
+		last.Appearances = append(last.Appearances, chunk.AppearanceRecord{
+			BlockNumber:   appearance.BlockNumber,
+			TransactionId: appearance.TransactionIndex,
+		})
+	}
+	// The database sorts already, but collation of text addresses could differ from
+	// byte order
+	chunk.SortAddressAppearances(addresses)
+	return
+}
+
+func compare(actual *manifest.Chunk, expected *manifest.Chunk) (mismatches []string) {
+	if actual.IndexHash != expected.IndexHash {
+		mismatches = append(mismatches, fmt.Sprintf("index hash %s, expected %s", actual.IndexHash, expected.IndexHash))
+	}
+	if actual.IndexSize != expected.IndexSize {
+		mismatches = append(mismatches, fmt.Sprintf("index size %d, expected %d", actual.IndexSize, expected.IndexSize))
+	}
+	if actual.BloomHash != expected.BloomHash {
+		mismatches = append(mismatches, fmt.Sprintf("bloom hash %s, expected %s", actual.BloomHash, expected.BloomHash))
+	}
+	if actual.BloomSize != expected.BloomSize {
+		mismatches = append(mismatches, fmt.Sprintf("bloom size %d, expected %d", actual.BloomSize, expected.BloomSize))
+	}
+	return
+}
+
+// writeFile writes to a temporary file first, so that readers never see partial files
+func writeFile(filePath string, write func(f *os.File) error) (size int64, err error) {
+	if err = os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return
+	}
+	tmpPath := filePath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err = write(f); err != nil {
+		return
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+	size = info.Size()
+	if err = f.Close(); err != nil {
+		return
+	}
+	err = os.Rename(tmpPath, filePath)
+	return
+}
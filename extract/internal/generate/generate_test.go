@@ -0,0 +1,46 @@
+package generate
+
+import (
+	"testing"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	manifest "github.com/TrueBlocks/trueblocks-key/manifest/pkg"
+)
+
+func Test_groupByAddress(t *testing.T) {
+	appearances := []database.AddressAppearance{
+		{Address: "0xf503017d7baf7fbc0fff7492b751025c6a78179b", Appearance: database.Appearance{BlockNumber: 10, TransactionIndex: 3}},
+		{Address: "0xf503017d7baf7fbc0fff7492b751025c6a78179b", Appearance: database.Appearance{BlockNumber: 12, TransactionIndex: 1}},
+		{Address: "0x0000000000000000000000000000000000000001", Appearance: database.Appearance{BlockNumber: 11, TransactionIndex: 0}},
+	}
+	addresses, err := groupByAddress(appearances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 2 {
+		t.Fatal("wrong addresses:", addresses)
+	}
+	if addresses[0].Address[19] != 1 || len(addresses[0].Appearances) != 1 {
+		t.Fatal("wrong first address:", addresses[0])
+	}
+	if addresses[1].Address[0] != 0xf5 || len(addresses[1].Appearances) != 2 || addresses[1].Appearances[1].BlockNumber != 12 {
+		t.Fatal("wrong second address:", addresses[1])
+	}
+
+	if _, err := groupByAddress([]database.AddressAppearance{{Address: "0x1234"}}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func Test_compare(t *testing.T) {
+	expected := &manifest.Chunk{Range: "000000000-000000001", IndexHash: "QmA", IndexSize: 100, BloomHash: "QmB", BloomSize: 200}
+	actual := *expected
+	if mismatches := compare(&actual, expected); len(mismatches) != 0 {
+		t.Fatal("unexpected mismatches:", mismatches)
+	}
+	actual.IndexHash = "QmC"
+	actual.BloomSize = 10
+	if mismatches := compare(&actual, expected); len(mismatches) != 2 {
+		t.Fatal("wrong mismatches:", mismatches)
+	}
+}
@@ -0,0 +1,170 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const DefaultSettle = 5 * time.Second
+
+// chunkFileName matches names of finalized chunks, e.g. 000000000-000000099.bin
+var chunkFileName = regexp.MustCompile(`^\d{9}-\d{9}\.bin$`)
+
+// Watcher calls Handle for every new chunk written to Dir, in range order. It doesn't
+// depend on the scraper sending notifications, it only needs access to the index folder.
+type Watcher struct {
+	Dir string
+	// After skips chunks with ranges lower or equal to it (e.g. ones already converted)
+	After string
+	// Poll > 0 makes Watcher list Dir every Poll instead of using filesystem
+	// notifications (they don't work on some network filesystems)
+	Poll time.Duration
+	// Settle is how long the file size has to stay the same before the file is handled,
+	// so we don't read chunks that are still being written
+	Settle time.Duration
+	// Handle is called for every new chunk. Returning an error stops Run.
+	Handle func(ctx context.Context, filePath string) error
+
+	// pending are files that we've seen, but they can still be changing
+	pending map[string]pendingFile
+	// done maps handled files to their sizes. A file that changes after it's been handled
+	// (e.g. a chunk skipped as invalid, because the scraper hadn't finished writing it)
+	// is handled again.
+	done map[string]int64
+}
+
+type pendingFile struct {
+	size  int64
+	since time.Time
+}
+
+// Run handles chunks already in Dir and then new ones as they appear, until ctx is done
+func (w *Watcher) Run(ctx context.Context) (err error) {
+	w.pending = make(map[string]pendingFile)
+	w.done = make(map[string]int64)
+	settle := w.Settle
+	if settle <= 0 {
+		settle = DefaultSettle
+	}
+
+	var events chan fsnotify.Event
+	var errs chan error
+	interval := w.Poll
+	if w.Poll <= 0 {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("creating watcher: %w", err)
+		}
+		defer watcher.Close()
+		if err = watcher.Add(w.Dir); err != nil {
+			return fmt.Errorf("watching %s: %w", w.Dir, err)
+		}
+		events = watcher.Events
+		errs = watcher.Errors
+		// we still have to check if pending files have settled
+		interval = settle / 2
+	}
+
+	// chunks written before we started watching
+	if err = w.scan(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err = w.handleSettled(ctx, settle); err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-events:
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				w.add(event.Name)
+			}
+		case err = <-errs:
+			return fmt.Errorf("watching %s: %w", w.Dir, err)
+		case <-ticker.C:
+			if w.Poll > 0 {
+				if err = w.scan(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// scan adds all chunks in Dir to pending files
+func (w *Watcher) scan() error {
+	entries, err := os.ReadDir(w.Dir)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", w.Dir, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			w.add(filepath.Join(w.Dir, entry.Name()))
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) add(filePath string) {
+	name := filepath.Base(filePath)
+	if !chunkFileName.MatchString(name) || strings.TrimSuffix(name, ".bin") <= w.After {
+		return
+	}
+	if size, ok := w.done[filePath]; ok {
+		info, err := os.Stat(filePath)
+		if err != nil || info.Size() == size {
+			return
+		}
+		delete(w.done, filePath)
+	}
+	if _, ok := w.pending[filePath]; !ok {
+		w.pending[filePath] = pendingFile{size: -1, since: time.Now()}
+	}
+}
+
+// handleSettled calls Handle for pending files that haven't changed for settle
+func (w *Watcher) handleSettled(ctx context.Context, settle time.Duration) error {
+	settled := make([]string, 0)
+	now := time.Now()
+	for filePath, pending := range w.pending {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			// removed or renamed (we'll get the new name separately)
+			delete(w.pending, filePath)
+			continue
+		}
+		if info.Size() != pending.size {
+			w.pending[filePath] = pendingFile{size: info.Size(), since: now}
+			continue
+		}
+		if now.Sub(pending.since) >= settle {
+			settled = append(settled, filePath)
+		}
+	}
+
+	// ranges are zero-padded, so this sorts them by block numbers
+	sort.Strings(settled)
+	for _, filePath := range settled {
+		log.Println("new chunk:", filePath)
+		if err := w.Handle(ctx, filePath); err != nil {
+			return fmt.Errorf("handling %s: %w", filePath, err)
+		}
+		w.done[filePath] = w.pending[filePath].size
+		delete(w.pending, filePath)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func runWatcher(t *testing.T, w *Watcher) (handled func() []string, stop func()) {
+	t.Helper()
+
+	var mutex sync.Mutex
+	var names []string
+	w.Handle = func(ctx context.Context, filePath string) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		names = append(names, filepath.Base(filePath))
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	handled = func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string{}, names...)
+	}
+	stop = func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return
+}
+
+func waitFor(t *testing.T, handled func() []string, expected []string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if reflect.DeepEqual(handled(), expected) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("wrong handled files:", handled(), "expected", expected)
+}
+
+func writeFile(t *testing.T, dir string, name string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("chunk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testWatcher(t *testing.T, poll time.Duration) {
+	dir := t.TempDir()
+	writeFile(t, dir, "000000000-000000099.bin")
+	writeFile(t, dir, "000000200-000000299.bin")
+	writeFile(t, dir, "000000100-000000199.bin")
+	writeFile(t, dir, "000000300-000000399.bin.download")
+	writeFile(t, dir, "manifest.json")
+
+	w := &Watcher{Dir: dir, After: "000000000-000000099", Poll: poll, Settle: 50 * time.Millisecond}
+	handled, stop := runWatcher(t, w)
+	defer stop()
+
+	// existing chunks, in range order
+	waitFor(t, handled, []string{"000000100-000000199.bin", "000000200-000000299.bin"})
+
+	// new chunk, renamed into place like the downloads are
+	writeFile(t, dir, "000000300-000000399.bin.tmp")
+	if err := os.Rename(filepath.Join(dir, "000000300-000000399.bin.tmp"), filepath.Join(dir, "000000300-000000399.bin")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "000000400-000000499.bin")
+	waitFor(t, handled, []string{"000000100-000000199.bin", "000000200-000000299.bin", "000000300-000000399.bin", "000000400-000000499.bin"})
+}
+
+func TestWatcher_Notifications(t *testing.T) {
+	testWatcher(t, 0)
+}
+
+func TestWatcher_Polling(t *testing.T) {
+	testWatcher(t, 20*time.Millisecond)
+}
+
+func TestWatcher_Unsettled(t *testing.T) {
+	dir := t.TempDir()
+	w := &Watcher{Dir: dir, Poll: 10 * time.Millisecond, Settle: time.Hour}
+	handled, stop := runWatcher(t, w)
+	defer stop()
+
+	writeFile(t, dir, "000000000-000000099.bin")
+	time.Sleep(100 * time.Millisecond)
+	if h := handled(); len(h) != 0 {
+		t.Fatal("chunk handled before it settled:", h)
+	}
+}
+
+func TestWatcher_Changed(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "000000000-000000099.bin")
+
+	w := &Watcher{Dir: dir, Poll: 10 * time.Millisecond, Settle: 30 * time.Millisecond}
+	handled, stop := runWatcher(t, w)
+	defer stop()
+	waitFor(t, handled, []string{"000000000-000000099.bin"})
+
+	// e.g. the scraper finished writing a chunk that we've skipped as invalid
+	if err := os.WriteFile(filepath.Join(dir, "000000000-000000099.bin"), []byte("longer chunk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, handled, []string{"000000000-000000099.bin", "000000000-000000099.bin"})
+}
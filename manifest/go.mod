@@ -0,0 +1,3 @@
+module github.com/TrueBlocks/trueblocks-key/manifest
+
+go 1.22
@@ -0,0 +1,218 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// The values below are `ipfs add` defaults, which are used to pin the index.
+// We recreate the DAG locally, so we can check files without an IPFS node.
+const (
+	// chunkSize is the size of file data in a single leaf block
+	chunkSize = 256 * 1024
+	// maxLinks is the max number of children of a balanced DAG node
+	maxLinks = 174
+)
+
+var ErrCidMismatch = errors.New("file does not match CID")
+var ErrUnsupportedCid = errors.New("only CIDv0 (Qm...) is supported")
+
+// unixfsFile is UnixFS Data.Type for files
+const unixfsFile = 2
+
+// dagNode describes a DAG node already written (hashed)
+type dagNode struct {
+	multihash []byte
+	// tsize is the size of the node's block and all blocks below it
+	tsize uint64
+	// fileSize is the size of file data under the node
+	fileSize uint64
+}
+
+// dagBuilder builds a balanced DAG of UnixFS dag-pb nodes, like `ipfs add` with
+// default settings does
+type dagBuilder struct {
+	reader io.Reader
+	next   []byte
+	done   bool
+}
+
+// Cid returns CIDv0 of reader's content as if it was added to IPFS with default settings
+func Cid(reader io.Reader) (string, error) {
+	b := &dagBuilder{reader: reader}
+	if err := b.prepareNext(); err != nil {
+		return "", err
+	}
+
+	// empty file is a single leaf without data
+	root, err := b.leaf()
+	if err != nil {
+		return "", err
+	}
+	for depth := 1; !b.done; depth++ {
+		if root, err = b.fill(&root, depth); err != nil {
+			return "", err
+		}
+	}
+	return base58(root.multihash), nil
+}
+
+// FileCid returns CIDv0 of file (see Cid)
+func FileCid(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return Cid(f)
+}
+
+// Verify returns ErrCidMismatch if file's CID is not cid
+func Verify(filePath string, cid string) error {
+	if !strings.HasPrefix(cid, "Qm") {
+		return fmt.Errorf("%w: %s", ErrUnsupportedCid, cid)
+	}
+	computed, err := FileCid(filePath)
+	if err != nil {
+		return err
+	}
+	if computed != cid {
+		return fmt.Errorf("%w: %s is %s, expected %s", ErrCidMismatch, filePath, computed, cid)
+	}
+	return nil
+}
+
+// prepareNext reads the next chunk of data, so we know if there's anything left
+func (b *dagBuilder) prepareNext() error {
+	buf := make([]byte, chunkSize)
+	n, err := io.ReadFull(b.reader, buf)
+	switch {
+	case errors.Is(err, io.EOF):
+		b.next = nil
+		b.done = true
+		return nil
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		err = nil
+	case err != nil:
+		return err
+	}
+	b.next = buf[:n]
+	return nil
+}
+
+func (b *dagBuilder) leaf() (dagNode, error) {
+	data := b.next
+	if err := b.prepareNext(); err != nil {
+		return dagNode{}, err
+	}
+	node := writeNode(nil, unixfsData(data, uint64(len(data)), nil))
+	node.fileSize = uint64(len(data))
+	return node, nil
+}
+
+// fill returns a node with up to maxLinks children, depth levels above leaves
+func (b *dagBuilder) fill(first *dagNode, depth int) (node dagNode, err error) {
+	children := make([]dagNode, 0, maxLinks)
+	if first != nil {
+		children = append(children, *first)
+	}
+	for len(children) < maxLinks && !b.done {
+		var child dagNode
+		if depth == 1 {
+			child, err = b.leaf()
+		} else {
+			child, err = b.fill(nil, depth-1)
+		}
+		if err != nil {
+			return
+		}
+		children = append(children, child)
+	}
+
+	var fileSize uint64
+	blockSizes := make([]uint64, 0, len(children))
+	for _, child := range children {
+		fileSize += child.fileSize
+		blockSizes = append(blockSizes, child.fileSize)
+	}
+	node = writeNode(children, unixfsData(nil, fileSize, blockSizes))
+	node.fileSize = fileSize
+	return
+}
+
+// writeNode encodes dag-pb node (links first, then data) and hashes it. The caller
+// sets fileSize.
+func writeNode(links []dagNode, data []byte) dagNode {
+	var block []byte
+	node := dagNode{}
+	for _, link := range links {
+		var encoded []byte
+		encoded = appendBytes(encoded, 1, link.multihash)
+		encoded = appendBytes(encoded, 2, nil) // name is always empty
+		encoded = appendVarint(encoded, 3, link.tsize)
+		block = appendBytes(block, 2, encoded)
+
+		node.tsize += link.tsize
+	}
+	block = appendBytes(block, 1, data)
+
+	hash := sha256.Sum256(block)
+	node.multihash = append([]byte{0x12, 0x20}, hash[:]...)
+	node.tsize += uint64(len(block))
+	return node
+}
+
+// unixfsData encodes UnixFS Data message of a file node
+func unixfsData(data []byte, fileSize uint64, blockSizes []uint64) []byte {
+	var encoded []byte
+	encoded = appendVarint(encoded, 1, unixfsFile)
+	if len(data) > 0 {
+		encoded = appendBytes(encoded, 2, data)
+	}
+	encoded = appendVarint(encoded, 3, fileSize)
+	for _, size := range blockSizes {
+		encoded = appendVarint(encoded, 4, size)
+	}
+	return encoded
+}
+
+func appendVarint(b []byte, field uint64, value uint64) []byte {
+	b = binary.AppendUvarint(b, field<<3)
+	return binary.AppendUvarint(b, value)
+}
+
+func appendBytes(b []byte, field uint64, value []byte) []byte {
+	b = binary.AppendUvarint(b, field<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func base58(b []byte) string {
+	number := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for number.Sign() > 0 {
+		number.DivMod(number, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for _, v := range b {
+		if v != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
@@ -0,0 +1,74 @@
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCid(t *testing.T) {
+	// CIDs returned by `ipfs add`
+	cid, err := Cid(strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cid != "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH" {
+		t.Fatal("wrong CID of empty file:", cid)
+	}
+
+	cid, err = Cid(strings.NewReader("hello world\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cid != "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o" {
+		t.Fatal("wrong CID:", cid)
+	}
+}
+
+func TestCid_MultipleBlocks(t *testing.T) {
+	pattern := make([]byte, chunkSize*maxLinks+1)
+	for i := range pattern {
+		pattern[i] = byte(i % 251)
+	}
+
+	// CIDs returned by `ipfs add` (default chunker and balanced layout)
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"three leaves", bytes.Repeat([]byte{1}, chunkSize*2+10), "QmNUbFeoGygPdWYGT1C9ypDMsgcEBQdwvB5eM7PMBHdHBo"},
+		{"last leaf partial", pattern[:600000], "QmWKdZuiD9zqoZFnLYbpV2Q5YhRCJWpqiVeYA8ygYEjcEe"},
+		// one leaf more than a single node can link, so the root is two levels above leaves
+		{"two levels", pattern, "QmTedsTekQQkgACJXb1sPZSW8bLdS9LPMrT7L4YdjNRd4n"},
+	}
+	for _, tt := range tests {
+		cid, err := Cid(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cid != tt.expected {
+			t.Fatal("wrong CID of", tt.name, cid)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(filePath, []byte("hello world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify(filePath, "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(filePath, "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"); !errors.Is(err, ErrCidMismatch) {
+		t.Fatal("expected ErrCidMismatch, got", err)
+	}
+	if err := Verify(filePath, "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"); !errors.Is(err, ErrUnsupportedCid) {
+		t.Fatal("expected ErrUnsupportedCid, got", err)
+	}
+}
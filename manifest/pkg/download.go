@@ -0,0 +1,204 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const DefaultGateway = "https://ipfs.unchainedindex.io/ipfs/"
+
+// Downloader fetches chunks listed in a manifest from an IPFS gateway
+type Downloader struct {
+	// Gateway is URL prefix, CID is appended to it
+	Gateway string
+	Client  *http.Client
+	// Workers is the number of files downloaded at once
+	Workers int
+	// Blooms and Indexes select which files to download
+	Blooms  bool
+	Indexes bool
+	// VerifyPresent makes files already present checked against their CIDs. Otherwise
+	// only their sizes are compared with the manifest, which is much faster.
+	VerifyPresent bool
+}
+
+// DownloadResult lists files that have been downloaded and ones that were already present
+type DownloadResult struct {
+	Downloaded []string
+	Present    []string
+}
+
+type downloadJob struct {
+	filePath string
+	cid      string
+	// size is 0 if manifest doesn't have it
+	size int64
+}
+
+// Download saves files missing from indexPath (or not matching their sizes or CIDs) and
+// the manifest itself as indexPath/manifest.json. Every file is verified before it's
+// moved into place, so an interrupted run leaves no partial files behind.
+func (d *Downloader) Download(ctx context.Context, m *Manifest, indexPath string) (result DownloadResult, err error) {
+	jobs := make([]downloadJob, 0, len(m.Chunks)*2)
+	for _, chunk := range m.Chunks {
+		if d.Blooms {
+			jobs = append(jobs, downloadJob{filePath: chunk.BloomPath(indexPath), cid: chunk.BloomHash, size: chunk.BloomSize})
+		}
+		if d.Indexes {
+			jobs = append(jobs, downloadJob{filePath: chunk.IndexPath(indexPath), cid: chunk.IndexHash, size: chunk.IndexSize})
+		}
+	}
+
+	for _, dir := range []string{"blooms", "finalized"} {
+		if err = os.MkdirAll(filepath.Join(indexPath, dir), 0755); err != nil {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	workers := max(d.Workers, 1)
+	jobsCh := make(chan downloadJob)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobsCh {
+				downloaded, err := d.ensure(ctx, job)
+				if err != nil {
+					cancel(err)
+					continue
+				}
+				mutex.Lock()
+				if downloaded {
+					result.Downloaded = append(result.Downloaded, job.filePath)
+				} else {
+					result.Present = append(result.Present, job.filePath)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+send:
+	for _, job := range jobs {
+		select {
+		case jobsCh <- job:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(jobsCh)
+	wg.Wait()
+
+	if err = context.Cause(ctx); err != nil {
+		return
+	}
+
+	err = writeManifest(m, filepath.Join(indexPath, "manifest.json"))
+	return
+}
+
+// ensure downloads job's file, unless it's there already
+func (d *Downloader) ensure(ctx context.Context, job downloadJob) (downloaded bool, err error) {
+	present, err := d.present(job)
+	if err != nil || present {
+		return false, err
+	}
+
+	tmpPath := job.filePath + ".download"
+	defer os.Remove(tmpPath)
+
+	if err = d.fetch(ctx, job.cid, tmpPath); err != nil {
+		return false, fmt.Errorf("fetching %s: %w", job.cid, err)
+	}
+	if err = Verify(tmpPath, job.cid); err != nil {
+		return false, err
+	}
+	if err = os.Rename(tmpPath, job.filePath); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// present checks if job's file is there already. Files are hashed only if the manifest
+// has no size for them or VerifyPresent is set, so that we don't read the whole index
+// on every run.
+func (d *Downloader) present(job downloadJob) (bool, error) {
+	info, err := os.Stat(job.filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if job.size > 0 && info.Size() != job.size {
+		log.Println("replacing file:", job.filePath, "has size", info.Size(), "expected", job.size)
+		return false, nil
+	}
+	if job.size > 0 && !d.VerifyPresent {
+		return true, nil
+	}
+
+	err = Verify(job.filePath, job.cid)
+	if errors.Is(err, ErrCidMismatch) {
+		log.Println("replacing file:", err)
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (d *Downloader) fetch(ctx context.Context, cid string, filePath string) error {
+	gateway := d.Gateway
+	if gateway == "" {
+		gateway = DefaultGateway
+	}
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(gateway, "/")+"/"+cid, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("gateway responded with %s", response.Status)
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, response.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeManifest(m *Manifest, filePath string) error {
+	encoded, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, encoded, 0644)
+}
@@ -0,0 +1,200 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// gateway serves files by CID, like IPFS gateway does
+func gateway(t *testing.T, files map[string][]byte, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	byCid := make(map[string][]byte, len(files))
+	for _, content := range files {
+		cid, err := Cid(bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		byCid[cid] = content
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		content, ok := byCid[strings.TrimPrefix(r.URL.Path, "/ipfs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testManifest(t *testing.T, files map[string][]byte) *Manifest {
+	t.Helper()
+
+	cid := func(name string) string {
+		c, err := Cid(bytes.NewReader(files[name]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	size := func(name string) int64 {
+		return int64(len(files[name]))
+	}
+	return &Manifest{
+		Chain: "mainnet",
+		Chunks: []Chunk{
+			{Range: "000000000-000000099", BloomHash: cid("bloom1"), BloomSize: size("bloom1"), IndexHash: cid("index1"), IndexSize: size("index1")},
+			{Range: "000000100-000000199", BloomHash: cid("bloom2"), BloomSize: size("bloom2"), IndexHash: cid("index2"), IndexSize: size("index2")},
+		},
+	}
+}
+
+func TestDownloader_Download(t *testing.T) {
+	files := map[string][]byte{
+		"bloom1": []byte("bloom 1"),
+		"bloom2": []byte("bloom 2"),
+		"index1": bytes.Repeat([]byte("index 1"), chunkSize/3),
+		"index2": []byte("index 2"),
+	}
+	var requests atomic.Int32
+	server := gateway(t, files, &requests)
+	m := testManifest(t, files)
+	indexPath := t.TempDir()
+
+	downloader := &Downloader{Gateway: server.URL + "/ipfs/", Workers: 2, Blooms: true, Indexes: true}
+	result, err := downloader.Download(context.Background(), m, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Downloaded) != 4 || len(result.Present) != 0 {
+		t.Fatal("wrong result:", result)
+	}
+	content, err := os.ReadFile(filepath.Join(indexPath, "finalized", "000000000-000000099.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, files["index1"]) {
+		t.Fatal("wrong index file content")
+	}
+
+	saved, err := ReadFile(filepath.Join(indexPath, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := saved.Ranges(); len(r) != 2 || r[1] != "000000100-000000199" {
+		t.Fatal("wrong saved manifest ranges:", r)
+	}
+
+	// corrupted file is downloaded again, valid ones are kept
+	bloomPath := filepath.Join(indexPath, "blooms", "000000100-000000199.bloom")
+	if err := os.WriteFile(bloomPath, []byte("corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	requests.Store(0)
+	result, err = downloader.Download(context.Background(), m, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Downloaded) != 1 || result.Downloaded[0] != bloomPath || len(result.Present) != 3 {
+		t.Fatal("wrong result after corruption:", result)
+	}
+	if r := requests.Load(); r != 1 {
+		t.Fatal("wrong request count:", r)
+	}
+
+	// corruption that keeps the size is only found when verifying present files
+	if err := os.WriteFile(bloomPath, []byte("bloom 3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = downloader.Download(context.Background(), m, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Downloaded) != 0 {
+		t.Fatal("expected files compared by size only:", result)
+	}
+	downloader.VerifyPresent = true
+	result, err = downloader.Download(context.Background(), m, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Downloaded) != 1 || result.Downloaded[0] != bloomPath {
+		t.Fatal("wrong result when verifying:", result)
+	}
+
+	// without size in the manifest, files are always verified
+	downloader.VerifyPresent = false
+	if err := os.WriteFile(bloomPath, []byte("bloom 3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.Chunks[1].BloomSize = 0
+	result, err = downloader.Download(context.Background(), m, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Downloaded) != 1 || result.Downloaded[0] != bloomPath {
+		t.Fatal("wrong result without size:", result)
+	}
+}
+
+func TestDownloader_Download_Errors(t *testing.T) {
+	files := map[string][]byte{
+		"bloom1": []byte("bloom 1"),
+		"bloom2": []byte("bloom 2"),
+	}
+	var requests atomic.Int32
+	server := gateway(t, files, &requests)
+	m := testManifest(t, files)
+
+	// gateway doesn't have the file
+	m.Chunks[1].BloomHash = "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o"
+	indexPath := t.TempDir()
+	downloader := &Downloader{Gateway: server.URL + "/ipfs", Blooms: true}
+	if _, err := downloader.Download(context.Background(), m, indexPath); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatal("expected 404 error, got", err)
+	}
+
+	// gateway returns wrong content
+	m.Chunks[1].BloomHash = m.Chunks[0].BloomHash
+	m.Chunks[0].BloomHash = "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o"
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not what you asked for"))
+	})
+	indexPath = t.TempDir()
+	if _, err := downloader.Download(context.Background(), m, indexPath); !errors.Is(err, ErrCidMismatch) {
+		t.Fatal("expected ErrCidMismatch, got", err)
+	}
+	if _, err := os.Stat(m.Chunks[0].BloomPath(indexPath)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("invalid file should not be saved, got", err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	manifest := `{"version":"trueblocks-core@v2.0.0-release","chain":"mainnet","chunks":[{"range":"000000000-000000099","bloomHash":"Qm1","bloomSize":10,"indexHash":"Qm2","indexSize":20},{"range":"000000100-000000199"}]}`
+	m, err := Read(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Chunks[0].IndexHash != "Qm2" || m.Chunks[0].BloomSize != 10 {
+		t.Fatal("wrong chunk:", m.Chunks[0])
+	}
+	if r := m.Ranges(); len(r) != 2 || r[0] != "000000000-000000099" {
+		t.Fatal("wrong ranges:", r)
+	}
+
+	if _, err = Read(strings.NewReader(`{"chunks":[]}`)); !errors.Is(err, ErrEmptyManifest) {
+		t.Fatal("expected ErrEmptyManifest, got", err)
+	}
+}
@@ -0,0 +1,71 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var ErrEmptyManifest = errors.New("manifest has no chunks")
+
+// Manifest lists all chunks of Unchained Index (it's published as manifest.json)
+type Manifest struct {
+	Version       string  `json:"version"`
+	Chain         string  `json:"chain"`
+	Specification string  `json:"specification"`
+	Chunks        []Chunk `json:"chunks"`
+}
+
+// Chunk is a single manifest entry. Hashes are IPFS CIDs of the files.
+type Chunk struct {
+	Range     string `json:"range"`
+	BloomHash string `json:"bloomHash"`
+	BloomSize int64  `json:"bloomSize"`
+	IndexHash string `json:"indexHash"`
+	IndexSize int64  `json:"indexSize"`
+}
+
+// Read decodes manifest from reader
+func Read(reader io.Reader) (m *Manifest, err error) {
+	m = &Manifest{}
+	if err = json.NewDecoder(reader).Decode(m); err != nil {
+		return nil, fmt.Errorf("decoding manifest: %w", err)
+	}
+	if len(m.Chunks) == 0 {
+		return nil, ErrEmptyManifest
+	}
+	return
+}
+
+// ReadFile decodes manifest stored in filePath
+func ReadFile(filePath string) (*Manifest, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Ranges returns block ranges of all chunks, in manifest order
+func (m *Manifest) Ranges() []string {
+	ranges := make([]string, 0, len(m.Chunks))
+	for _, chunk := range m.Chunks {
+		ranges = append(ranges, chunk.Range)
+	}
+	return ranges
+}
+
+// BloomPath returns path of the chunk's bloom filter in index directory laid out like
+// chifra does it (blooms/ and finalized/ subdirectories)
+func (c *Chunk) BloomPath(indexPath string) string {
+	return filepath.Join(indexPath, "blooms", c.Range+".bloom")
+}
+
+// IndexPath returns path of the chunk's index file (see BloomPath)
+func (c *Chunk) IndexPath(indexPath string) string {
+	return filepath.Join(indexPath, "finalized", c.Range+".bin")
+}
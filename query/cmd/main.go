@@ -5,21 +5,25 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
 
 	keyConfig "github.com/TrueBlocks/trueblocks-key/config/pkg"
 	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
-	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
+	sqliteStore "github.com/TrueBlocks/trueblocks-key/database/pkg/sqlite_store"
 )
 
 var configFilePath string
 var dbConfigKey string
+var sqlitePath string
 var offset int
 var limit = 100
 
 func init() {
 	flag.StringVar(&configFilePath, "config", "", "configuration file path")
 	flag.StringVar(&dbConfigKey, "database", "default", "database to use")
+	flag.StringVar(&sqlitePath, "sqlite", "", "read from SQLite store in this file instead of the configured database")
 	flag.IntVar(&offset, "offset", 0, "offset")
 	flag.IntVar(&limit, "limit", 100, "limit")
 }
@@ -27,8 +31,8 @@ func init() {
 func main() {
 	flag.Parse()
 
-	if configFilePath == "" {
-		log.Fatalln("configuration file path required")
+	if configFilePath == "" && sqlitePath == "" {
+		log.Fatalln("configuration file path or SQLite store path required")
 	}
 
 	if len(flag.Args()) != 1 {
@@ -37,37 +41,46 @@ func main() {
 
 	address := strings.ToLower(flag.Arg(0))
 
-	config, err := keyConfig.Get(configFilePath)
-	if err != nil {
-		log.Fatalln(err)
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	dbConnection := &database.Connection{
-		Chain:    "mainnet",
-		Host:     config.Database[dbConfigKey].Host,
-		Port:     config.Database[dbConfigKey].Port,
-		User:     config.Database[dbConfigKey].User,
-		Password: config.Database[dbConfigKey].Password,
-		Database: config.Database[dbConfigKey].Database,
-	}
-	if err := dbConnection.Connect(context.TODO()); err != nil {
-		log.Fatalln(err)
-	}
-	defer dbConnection.Close(context.TODO())
+	var store database.AppearanceStore
+	if sqlitePath != "" {
+		sqlite, err := sqliteStore.Open(ctx, sqlitePath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer sqlite.Close()
+		store = sqlite
+	} else {
+		config, err := keyConfig.Get(configFilePath)
+		if err != nil {
+			log.Fatalln(err)
+		}
 
-	q := query.Query{
-		Limit:      limit,
-		Offset:     offset,
-		Address:    address,
-		Connection: dbConnection,
+		dbProvider, err := database.NewProvider(config, dbConfigKey, "mainnet")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		dbConnection, err := dbProvider.Connection(ctx)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer dbConnection.Close(context.WithoutCancel(ctx))
+		store = database.NewPostgresStore(dbConnection)
 	}
 
-	results, err := q.Do()
+	status, err := store.FetchStatus(ctx)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// the newest appearances come first, like on the first page of tb_getAppearances
+	results, _, err := store.FetchAppearancesFirstPage(ctx, false, address, 0, status.LastIndexedBlock, uint(offset+limit))
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	for _, appearance := range results {
+	for _, appearance := range results[min(offset, len(results)):] {
 		fmt.Println(appearance.BlockNumber, appearance.TransactionIndex)
 	}
 }
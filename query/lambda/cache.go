@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/cache"
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
+)
+
+const cacheTypeMemory = "memory"
+const cacheTypeMemcached = "memcached"
+
+// responseCache is kept between invocations, nil if caching is disabled
+var responseCache cache.Cache
+
+func setupCache() {
+	if responseCache != nil {
+		return
+	}
+
+	switch t := cnf.Query.Cache.Type; t {
+	case "":
+		return
+	case cacheTypeMemory:
+		log.Println("using in-memory response cache, size:", cnf.Query.Cache.Size)
+		responseCache = cache.NewLRU(cnf.Query.Cache.Size)
+	case cacheTypeMemcached:
+		log.Println("using memcached response cache:", cnf.Query.Cache.Address)
+		responseCache = cache.NewMemcached(
+			cnf.Query.Cache.Address,
+			time.Duration(cnf.Query.Cache.Expiration)*time.Second,
+		)
+	default:
+		log.Println("unknown cache type, caching disabled:", t)
+	}
+}
+
+// appearancesCacheKey identifies tb_getAppearances response. Because dataVersion (see
+// database.FetchDataVersion) is a part of the key, all entries are invalidated as soon as
+// appearances or blocks are written, including past ones.
+func appearancesCacheKey(param *query.RpcGetAppearancesParam, lastBlock uint, perPage uint, dataVersion uint64) string {
+	include := slices.Clone(param.Include)
+	slices.Sort(include)
+	return fmt.Sprintf(
+		"appearances:%s:%d:%d:%s:%s:%s:%s:%d",
+		strings.ToLower(param.Address),
+		lastBlock,
+		perPage,
+		param.PageId,
+		strings.Join(slices.Compact(include), ","),
+		optionalUint(param.FromTimestamp),
+		optionalUint(param.ToTimestamp),
+		dataVersion,
+	)
+}
+
+// dataVersionCacheKey calls key with the current data version. It returns empty key, which
+// disables caching of the response, if the cache is disabled or the version is unavailable.
+func dataVersionCacheKey(ctx context.Context, key func(dataVersion uint64) string) string {
+	if responseCache == nil {
+		return ""
+	}
+	dataVersion, err := store.FetchDataVersion(ctx)
+	if err != nil {
+		log.Println("database data version query, skipping cache:", err)
+		return ""
+	}
+	return key(dataVersion)
+}
+
+func optionalUint(value *uint64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatUint(*value, 10)
+}
+
+// getCachedResult returns nil if the cache is disabled or it doesn't have the key.
+// Cache errors are logged, but never returned, so that we can still reach the database.
+func getCachedResult[T query.RpcResponseResult](ctx context.Context, key string) *query.Result[T] {
+	if responseCache == nil || key == "" {
+		return nil
+	}
+
+	encoded, found, err := responseCache.Get(ctx, key)
+	if err != nil {
+		log.Println("cache get:", err)
+		return nil
+	}
+	if !found {
+		return nil
+	}
+
+	result := &query.Result[T]{}
+	if err := json.Unmarshal(encoded, result); err != nil {
+		log.Println("cache unmarshal:", err)
+		return nil
+	}
+	log.Println("cache hit:", key)
+	return result
+}
+
+func setCachedResult[T query.RpcResponseResult](ctx context.Context, key string, result *query.Result[T]) {
+	if responseCache == nil || key == "" {
+		return
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		log.Println("cache marshal:", err)
+		return
+	}
+	if err := responseCache.Set(ctx, key, encoded); err != nil {
+		log.Println("cache set:", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
+)
+
+// enrichAppearances sets fields listed in param.Include. publicApps has to be
+// created from items (same length and order).
+func enrichAppearances(ctx context.Context, param *query.RpcGetAppearancesParam, items []database.Appearance, publicApps []database.PublicAppearance) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	if param.Includes(query.IncludeTimestamp) {
+		blockNumbers := make([]uint32, 0, len(items))
+		for _, item := range items {
+			blockNumbers = append(blockNumbers, item.BlockNumber)
+		}
+		timestamps, err := store.FetchBlockTimestamps(ctx, blockNumbers)
+		if err != nil {
+			return err
+		}
+		for i, item := range items {
+			if timestamp, ok := timestamps[item.BlockNumber]; ok {
+				publicApps[i].Timestamp = strconv.FormatUint(timestamp, 10)
+			}
+		}
+	}
+
+	if param.Includes(query.IncludeTxHash) {
+		hashes, err := store.FetchTransactionHashes(ctx, items)
+		if err != nil {
+			return err
+		}
+		for i, item := range items {
+			publicApps[i].TxHash = hashes[item]
+		}
+	}
+
+	return nil
+}
@@ -1,16 +1,26 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
 	"strconv"
 
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
 	"github.com/aws/aws-lambda-go/events"
 )
 
+// queryTooExpensive is sent when the request runs out of its time limit (see methodTimeout)
+const queryTooExpensive = "query too expensive"
+
 type RpcError struct {
 	PublicError string
 	internal    error
 	statusCode  int
+	// code, if set, makes Report send JSON-RPC error object instead of a quoted string
+	code int
 }
 
 func NewRpcError(internal error, statusCode int, public string) *RpcError {
@@ -21,12 +31,55 @@ func NewRpcError(internal error, statusCode int, public string) *RpcError {
 	}
 }
 
+// NewJsonRpcError creates RpcError reported as JSON-RPC error object with the given code
+func NewJsonRpcError(internal error, code int, public string) *RpcError {
+	return &RpcError{
+		PublicError: public,
+		internal:    internal,
+		statusCode:  http.StatusOK,
+		code:        code,
+	}
+}
+
 func (r *RpcError) Error() string {
 	return r.internal.Error()
 }
 
-func (r *RpcError) Report(response *events.APIGatewayProxyResponse) {
+// Report writes the error to response. id is the ID of the request (nil if unknown).
+func (r *RpcError) Report(response *events.APIGatewayProxyResponse, id any) {
 	log.Println(r.internal)
 	response.StatusCode = r.statusCode
-	response.Body = strconv.Quote(r.PublicError)
+	if r.code == 0 {
+		response.Body = strconv.Quote(r.PublicError)
+		return
+	}
+
+	body, err := json.Marshal(&query.RpcErrorResponse{
+		JsonRpc: "2.0",
+		Id:      id,
+		Error: query.RpcErrorObject{
+			Code:    r.code,
+			Message: r.PublicError,
+		},
+	})
+	if err != nil {
+		log.Println("error marshal:", err)
+		response.StatusCode = http.StatusInternalServerError
+		response.Body = strconv.Quote(ErrInternal.Error())
+		return
+	}
+	response.Body = string(body)
+}
+
+// databaseError returns the error to report when a database call fails. Timeouts are
+// reported to the user, so they know to narrow the query down, and so are timestamps
+// we cannot translate to blocks. Everything else is internal.
+func databaseError(err error) error {
+	if database.IsTimeout(err) {
+		return NewJsonRpcError(err, query.ErrorCodeQueryTooExpensive, queryTooExpensive)
+	}
+	if errors.Is(err, database.ErrTimestampNotCovered) {
+		return NewRpcError(err, http.StatusNotFound, err.Error())
+	}
+	return ErrInternal
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestDatabaseError_Timeout(t *testing.T) {
+	err := databaseError(fmt.Errorf("query: %w", context.DeadlineExceeded))
+	rpcErr, ok := err.(*RpcError)
+	if !ok {
+		t.Fatal("expected RpcError, got", err)
+	}
+
+	var response events.APIGatewayProxyResponse
+	rpcErr.Report(&response, 7)
+	if response.StatusCode != http.StatusOK {
+		t.Fatal("wrong status code:", response.StatusCode)
+	}
+	var body query.RpcErrorResponse
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.JsonRpc != "2.0" || body.Id != float64(7) {
+		t.Fatal("wrong envelope:", response.Body)
+	}
+	if body.Error.Code != query.ErrorCodeQueryTooExpensive || body.Error.Message != queryTooExpensive {
+		t.Fatal("wrong error:", body.Error)
+	}
+}
+
+func TestRpcError_ReportPlain(t *testing.T) {
+	var response events.APIGatewayProxyResponse
+	NewRpcError(fmt.Errorf("bad"), http.StatusBadRequest, "invalid JSON").Report(&response, nil)
+	if response.StatusCode != http.StatusBadRequest || response.Body != `"invalid JSON"` {
+		t.Fatal("wrong response:", response.StatusCode, response.Body)
+	}
+}
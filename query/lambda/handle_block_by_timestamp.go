@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
+)
+
+var errBlockNotFound = errors.New("no block found for the timestamp")
+
+func handleBlockByTimestamp(ctx context.Context, rpcRequest *query.RpcRequest) (response *query.RpcResponse[*database.PublicBlock], err error) {
+	rpcParams, err := rpcRequest.BlockByTimestampParams()
+	if err != nil {
+		err = NewRpcError(err, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if err = rpcParams.Validate(); err != nil {
+		err = NewRpcError(err, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	param := rpcParams.Get()
+	if err = param.Validate(); err != nil {
+		err = NewRpcError(err, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	meta, err := getMeta(ctx, "")
+	if err != nil {
+		return
+	}
+
+	block, found, err := store.FetchBlockByTimestamp(ctx, param.Timestamp, param.After(), meta.LastIndexedBlockUint())
+	if err != nil {
+		log.Println("database query (block by timestamp):", err)
+		err = databaseError(err)
+		return
+	}
+	if !found {
+		err = NewRpcError(errBlockNotFound, http.StatusNotFound, errBlockNotFound.Error())
+		return
+	}
+
+	response = &query.RpcResponse[*database.PublicBlock]{
+		JsonRpc: "2.0",
+		Id:      rpcRequest.Id,
+		Result: query.Result[*database.PublicBlock]{
+			Data: block,
+			Meta: meta,
+		},
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
+)
+
+func TestHandleBlockByTimestamp(t *testing.T) {
+	setupEnrichedMemoryStore(t, "0xf503017d7baf7fbc0fff7492b751025c6a78179b")
+
+	tests := []struct {
+		param    query.RpcGetBlockByTimestampParam
+		expected string
+	}{
+		{query.RpcGetBlockByTimestampParam{Timestamp: 250}, "2"},
+		{query.RpcGetBlockByTimestampParam{Timestamp: 250, Closest: query.ClosestAfter}, "3"},
+		{query.RpcGetBlockByTimestampParam{Timestamp: 300}, "3"},
+	}
+	for _, tt := range tests {
+		request := &query.RpcRequest{Id: 1, Method: query.MethodGetBlockByTimestamp}
+		if err := query.SetParams(request, []query.RpcGetBlockByTimestampParam{tt.param}); err != nil {
+			t.Fatal(err)
+		}
+		response, err := handleBlockByTimestamp(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := response.Result.Data.BlockNumber; b != tt.expected {
+			t.Fatal("wrong block for", tt.param, b)
+		}
+	}
+
+	// there can be blocks after 4 that we haven't indexed yet
+	request := &query.RpcRequest{Id: 1, Method: query.MethodGetBlockByTimestamp}
+	if err := query.SetParams(request, []query.RpcGetBlockByTimestampParam{{Timestamp: 450, Closest: query.ClosestAfter}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handleBlockByTimestamp(context.Background(), request); err == nil {
+		t.Fatal("expected error for timestamp after the last indexed block")
+	}
+}
@@ -31,15 +31,15 @@ func handleBounds(ctx context.Context, rpcRequest *query.RpcRequest) (response *
 		return
 	}
 
-	bounds, err := database.FetchAppearancesDatasetBounds(
+	bounds, err := store.FetchAppearancesDatasetBounds(
 		ctx,
-		dbConn,
 		param.Address,
+		0,
 		meta.LastIndexedBlockUint(),
 	)
 	if err != nil {
 		log.Println("database query (count):", err)
-		err = ErrInternal
+		err = databaseError(err)
 		return
 	}
 
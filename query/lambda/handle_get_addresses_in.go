@@ -2,13 +2,23 @@ package main
 
 import (
 	"context"
+	"log"
 	"net/http"
 
 	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
 	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
 )
 
-func handleGetAddressesIn(ctx context.Context, rpcRequest *query.RpcRequest, inTx bool) (response *query.RpcResponse[[]string], err error) {
+// addressesInRequest holds parameters shared by tb_getAddressesIn* methods
+type addressesInRequest struct {
+	param       *query.RpcGetAddressesInParam
+	meta        *query.Meta
+	blockNumber uint32
+	limit       uint
+	cursor      query.AddressesCursor
+}
+
+func readAddressesInRequest(ctx context.Context, rpcRequest *query.RpcRequest) (request *addressesInRequest, err error) {
 	rpcParams, err := rpcRequest.AddressesInParam()
 	if err != nil {
 		err = NewRpcError(err, http.StatusBadRequest, "invalid JSON")
@@ -16,11 +26,13 @@ func handleGetAddressesIn(ctx context.Context, rpcRequest *query.RpcRequest, inT
 	}
 	if err = rpcParams.Validate(); err != nil {
 		// Validate() always returns public errors
+		err = NewRpcError(err, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	param := rpcParams.Get()
 	if err = param.Validate(); err != nil {
+		err = NewRpcError(err, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -35,30 +47,54 @@ func handleGetAddressesIn(ctx context.Context, rpcRequest *query.RpcRequest, inT
 		return
 	}
 
-	var transactionIndex uint32
+	// already validated
+	cursor, _ := param.CursorValue()
 
-	if inTx {
-		transactionIndex, err = param.TransactionIndexUint()
-		if err != nil {
-			err = NewRpcError(err, http.StatusBadRequest, "invalid transaction index")
-			return
-		}
+	request = &addressesInRequest{
+		param:       param,
+		meta:        meta,
+		blockNumber: blockNumber,
+		limit:       getValidLimits(param),
+		cursor:      cursor,
+	}
+	return
+}
+
+func handleGetAddressesInTx(ctx context.Context, rpcRequest *query.RpcRequest) (response *query.RpcResponse[[]string], err error) {
+	request, err := readAddressesInRequest(ctx, rpcRequest)
+	if err != nil {
+		return
 	}
 
-	var addrs []string
-	if inTx {
-		addrs, err = database.FetchAddressesInTx(
-			ctx,
-			dbConn,
-			int(blockNumber),
-			int(transactionIndex),
-		)
-	} else {
-		addrs, err = database.FetchAddressesInBlock(
-			ctx,
-			dbConn,
-			int(blockNumber),
-		)
+	transactionIndex, err := request.param.TransactionIndexUint()
+	if err != nil {
+		err = NewRpcError(err, http.StatusBadRequest, "invalid transaction index")
+		return
+	}
+
+	// we read one more item to know if there is the next page
+	addrs, err := store.FetchAddressesInTx(
+		ctx,
+		int(request.blockNumber),
+		int(transactionIndex),
+		request.cursor.Address,
+		request.limit+1,
+	)
+	if err != nil {
+		log.Println("database query (addresses in tx):", err)
+		err = databaseError(err)
+		return
+	}
+
+	if uint(len(addrs)) > request.limit {
+		addrs = addrs[:request.limit]
+		request.meta.NextCursor = &query.AddressesCursor{
+			TransactionIndex: transactionIndex,
+			Address:          addrs[len(addrs)-1],
+		}
+	}
+	if addrs == nil {
+		addrs = []string{}
 	}
 
 	response = &query.RpcResponse[[]string]{
@@ -66,7 +102,47 @@ func handleGetAddressesIn(ctx context.Context, rpcRequest *query.RpcRequest, inT
 		Id:      rpcRequest.Id,
 		Result: query.Result[[]string]{
 			Data: addrs,
-			Meta: meta,
+			Meta: request.meta,
+		},
+	}
+	return
+}
+
+func handleGetAddressesInBlock(ctx context.Context, rpcRequest *query.RpcRequest) (response *query.RpcResponse[[]database.PublicAddressesInTx], err error) {
+	request, err := readAddressesInRequest(ctx, rpcRequest)
+	if err != nil {
+		return
+	}
+
+	// we read one more item to know if there is the next page
+	items, err := store.FetchAddressesInBlock(
+		ctx,
+		int(request.blockNumber),
+		request.cursor.TransactionIndex,
+		request.cursor.Address,
+		request.limit+1,
+	)
+	if err != nil {
+		log.Println("database query (addresses in block):", err)
+		err = databaseError(err)
+		return
+	}
+
+	if uint(len(items)) > request.limit {
+		items = items[:request.limit]
+		last := items[len(items)-1]
+		request.meta.NextCursor = &query.AddressesCursor{
+			TransactionIndex: last.TransactionIndex,
+			Address:          last.Address,
+		}
+	}
+
+	response = &query.RpcResponse[[]database.PublicAddressesInTx]{
+		JsonRpc: "2.0",
+		Id:      rpcRequest.Id,
+		Result: query.Result[[]database.PublicAddressesInTx]{
+			Data: database.GroupAddressesByTx(items),
+			Meta: request.meta,
 		},
 	}
 	return
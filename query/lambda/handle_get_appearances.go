@@ -52,6 +52,7 @@ func handleGetAppearances(ctx context.Context, rpcRequest *query.RpcRequest) (re
 
 	var items []database.Appearance
 	var fetchBounds bool
+	var watermark uint64
 	specialPageId, pageId, err := param.PageIdValue()
 	if err != nil {
 		log.Println("reading page id value:", err)
@@ -59,14 +60,49 @@ func handleGetAppearances(ctx context.Context, rpcRequest *query.RpcRequest) (re
 		return
 	}
 
+	cacheKey := dataVersionCacheKey(ctx, func(dataVersion uint64) string {
+		return appearancesCacheKey(param, *lastBlock, limit, dataVersion)
+	})
+	if cached := getCachedResult[[]database.PublicAppearance](ctx, cacheKey); cached != nil {
+		response = &query.RpcResponse[[]database.PublicAppearance]{
+			JsonRpc: "2.0",
+			Id:      rpcRequest.Id,
+			Result:  *cached,
+		}
+		return
+	}
+
+	var firstBlock uint
 	switch specialPageId {
 	case query.PageIdLatest, query.PageIdEarliest:
+		var empty bool
+		firstBlock, *lastBlock, empty, err = store.FetchBlockRangeByTimestamps(ctx, param.FromTimestamp, param.ToTimestamp, 0, *lastBlock)
+		if err != nil {
+			log.Println("database block range query:", err)
+			err = databaseError(err)
+			return
+		}
+		if empty {
+			log.Println("no blocks between timestamps")
+			response = &query.RpcResponse[[]database.PublicAppearance]{
+				JsonRpc: "2.0",
+				Id:      rpcRequest.Id,
+				Result: query.Result[[]database.PublicAppearance]{
+					Data: []database.PublicAppearance{},
+					Meta: meta,
+				},
+			}
+			return
+		}
+
+		// the first page shows the latest data and returns the watermark that pins
+		// the following pages
 		log.Println("fetching first page")
-		items, err = database.FetchAppearancesFirstPage(
+		items, watermark, err = store.FetchAppearancesFirstPage(
 			ctx,
-			dbConn,
 			specialPageId == query.PageIdEarliest,
 			param.Address,
+			firstBlock,
 			*lastBlock,
 			uint(limit),
 		)
@@ -75,14 +111,18 @@ func handleGetAppearances(ctx context.Context, rpcRequest *query.RpcRequest) (re
 		// pageId.LastBlock takes precedence before query's lastBlock (it shouldn't be there if the user sends pageId)
 		bn := uint(pageId.LastBlock)
 		lastBlock = &bn
+		watermark = pageId.Watermark
+		// there is nothing before the earliest appearance in the set, so we can use it
+		// as the first block (this way we also keep fromTimestamp filter)
+		firstBlock = uint(pageId.EarliestInSet.BlockNumber)
 
-		log.Println("fetching page -- next?", pageId.DirectionNextPage, "last seen:", fmt.Sprint(pageId.LastSeen), "latest in set:", fmt.Sprint(pageId.LatestInSet), "earliest in set:", fmt.Sprint(pageId.EarliestInSet))
-		items, err = database.FetchAppearancesPage(ctx, dbConn, pageId.DirectionNextPage, param.Address, *lastBlock, uint(limit), uint(pageId.LastSeen.BlockNumber), uint(pageId.LastSeen.TransactionIndex))
+		log.Println("fetching page -- next?", pageId.DirectionNextPage, "last seen:", fmt.Sprint(pageId.LastSeen), "latest in set:", fmt.Sprint(pageId.LatestInSet), "earliest in set:", fmt.Sprint(pageId.EarliestInSet), "watermark:", watermark)
+		items, err = store.FetchAppearancesPage(ctx, pageId.DirectionNextPage, param.Address, firstBlock, *lastBlock, uint(limit), uint(pageId.LastSeen.BlockNumber), uint(pageId.LastSeen.TransactionIndex), watermark)
 	}
 
 	if err != nil {
 		log.Println("database query:", err)
-		err = ErrInternal
+		err = databaseError(err)
 		return
 	}
 
@@ -90,10 +130,10 @@ func handleGetAppearances(ctx context.Context, rpcRequest *query.RpcRequest) (re
 	var bounds database.AppearancesDatasetBounds
 	if fetchBounds {
 		if hasItems {
-			bounds, err = database.FetchAppearancesDatasetBounds(ctx, dbConn, param.Address, *lastBlock)
+			bounds, err = store.FetchAppearancesDatasetBounds(ctx, param.Address, firstBlock, *lastBlock)
 			if err != nil {
 				log.Println("error while getting bounds:", err)
-				err = ErrInternal
+				err = databaseError(err)
 				return
 			}
 		}
@@ -105,7 +145,7 @@ func handleGetAppearances(ctx context.Context, rpcRequest *query.RpcRequest) (re
 	}
 
 	if hasItems {
-		previousPageId, nextPageId := getPageIds(items, *lastBlock, &bounds)
+		previousPageId, nextPageId := query.NewPageIds(items, *lastBlock, watermark, &bounds)
 		meta.PreviousPageId = previousPageId
 		meta.NextPageId = nextPageId
 
@@ -115,6 +155,11 @@ func handleGetAppearances(ctx context.Context, rpcRequest *query.RpcRequest) (re
 	}
 
 	publicApps := database.AppearanceSliceToPublicSlice(items)
+	if err = enrichAppearances(ctx, param, items, publicApps); err != nil {
+		log.Println("enriching appearances:", err)
+		err = databaseError(err)
+		return
+	}
 
 	response = &query.RpcResponse[[]database.PublicAppearance]{
 		JsonRpc: "2.0",
@@ -124,34 +169,6 @@ func handleGetAppearances(ctx context.Context, rpcRequest *query.RpcRequest) (re
 			Meta: meta,
 		},
 	}
-	return
-}
-
-func getPageIds(items []database.Appearance, lastBlock uint, bounds *database.AppearancesDatasetBounds) (previousPageId *query.PageId, nextPageId *query.PageId) {
-	if len(items) == 0 {
-		return
-	}
-
-	if !bounds.IsLatest(&items[0]) {
-		nextPageId = &query.PageId{
-			DirectionNextPage: false,
-			LastBlock:         uint32(lastBlock),
-			LastSeen:          items[0],
-			LatestInSet:       bounds.Latest,
-			EarliestInSet:     bounds.Earliest,
-		}
-	}
-
-	lastCurrentAppearance := items[len(items)-1]
-
-	if !bounds.IsEarliest(&lastCurrentAppearance) {
-		previousPageId = &query.PageId{
-			DirectionNextPage: true,
-			LastBlock:         uint32(lastBlock),
-			LastSeen:          lastCurrentAppearance,
-			LatestInSet:       bounds.Latest,
-			EarliestInSet:     bounds.Earliest,
-		}
-	}
+	setCachedResult(ctx, cacheKey, &response.Result)
 	return
 }
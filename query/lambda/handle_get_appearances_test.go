@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	keyConfig "github.com/TrueBlocks/trueblocks-key/config/pkg"
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/cache"
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
+	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
+)
+
+func setupMemoryStore(t *testing.T, apps []queueItem.Appearance) *database.MemoryStore {
+	t.Helper()
+	cnf = &keyConfig.ConfigFile{}
+	memoryStore := database.NewMemoryStore()
+	if err := memoryStore.InsertAppearanceBatch(context.Background(), apps); err != nil {
+		t.Fatal(err)
+	}
+	store = memoryStore
+	return memoryStore
+}
+
+// setupEnrichedMemoryStore stores appearances of address in blocks 1 - 4, mined every
+// 100 seconds, with their timestamps and transaction hashes
+func setupEnrichedMemoryStore(t *testing.T, address string) {
+	t.Helper()
+	apps := make([]queueItem.Appearance, 0, 4)
+	blocks := make([]database.Block, 0, 4)
+	for i := uint32(1); i <= 4; i++ {
+		apps = append(apps, queueItem.Appearance{Address: address, BlockNumber: i, TransactionIndex: 0})
+		blocks = append(blocks, database.Block{Number: i, Timestamp: uint64(i) * 100, TransactionHashes: []string{fmt.Sprintf("0x%02d", i)}})
+	}
+	memoryStore := setupMemoryStore(t, apps)
+	if err := memoryStore.InsertBlockBatch(context.Background(), blocks); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandleGetAppearances_Paging(t *testing.T) {
+	address := "0xf503017d7baf7fbc0fff7492b751025c6a78179b"
+	apps := make([]queueItem.Appearance, 0, 12)
+	for i := uint32(1); i <= 12; i++ {
+		apps = append(apps, queueItem.Appearance{Address: address, BlockNumber: i, TransactionIndex: 0})
+	}
+	setupMemoryStore(t, apps)
+
+	param := query.RpcGetAppearancesParam{Address: address, PerPage: 5}
+	var pageSizes []int
+	var blocks []string
+	for {
+		request := &query.RpcRequest{Id: 1, Method: query.MethodGetAppearances}
+		if err := query.SetParams(request, []query.RpcGetAppearancesParam{param}); err != nil {
+			t.Fatal(err)
+		}
+		response, err := handleGetAppearances(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pageSizes = append(pageSizes, len(response.Result.Data))
+		for _, app := range response.Result.Data {
+			blocks = append(blocks, app.BlockNumber)
+		}
+		// previous page holds older appearances
+		if response.Result.Meta.PreviousPageId == nil {
+			break
+		}
+		if err := param.SetPageId("", response.Result.Meta.PreviousPageId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(pageSizes) != 3 || pageSizes[0] != 5 || pageSizes[1] != 5 || pageSizes[2] != 2 {
+		t.Fatal("wrong page sizes:", pageSizes)
+	}
+	if blocks[0] != "12" || blocks[len(blocks)-1] != "1" {
+		t.Fatal("wrong order:", blocks)
+	}
+}
+
+// TestHandleGetAppearances_Backfill checks that appearances inserted during a walk only
+// show up on first pages, not on the pages of the walk
+func TestHandleGetAppearances_Backfill(t *testing.T) {
+	address := "0xf503017d7baf7fbc0fff7492b751025c6a78179b"
+	apps := make([]queueItem.Appearance, 0, 8)
+	for i := uint32(1); i <= 8; i++ {
+		apps = append(apps, queueItem.Appearance{Address: address, BlockNumber: i, TransactionIndex: 0})
+	}
+	memoryStore := setupMemoryStore(t, apps)
+
+	fetch := func(param query.RpcGetAppearancesParam) *query.Result[[]database.PublicAppearance] {
+		t.Helper()
+		request := &query.RpcRequest{Id: 1, Method: query.MethodGetAppearances}
+		if err := query.SetParams(request, []query.RpcGetAppearancesParam{param}); err != nil {
+			t.Fatal(err)
+		}
+		response, err := handleGetAppearances(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &response.Result
+	}
+
+	param := query.RpcGetAppearancesParam{Address: address, PerPage: 5}
+	first := fetch(param)
+
+	backfill := []queueItem.Appearance{{Address: address, BlockNumber: 1, TransactionIndex: 1}}
+	if err := memoryStore.InsertAppearanceBatch(context.Background(), backfill); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := param.SetPageId("", first.Meta.PreviousPageId); err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(fetch(param).Data); s != "[{3 0  } {2 0  } {1 0  }]" {
+		t.Fatal("wrong second page:", s)
+	}
+
+	param = query.RpcGetAppearancesParam{Address: address, PerPage: 5}
+	if err := param.SetPageId(query.PageIdEarliest, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(fetch(param).Data); s != "[{4 0  } {3 0  } {2 0  } {1 1  } {1 0  }]" {
+		t.Fatal("wrong new first page:", s)
+	}
+}
+
+// TestHandleGetAppearances_Cache checks that cached responses are not served after
+// backfill, which does not move the last indexed block
+func TestHandleGetAppearances_Cache(t *testing.T) {
+	address := "0xf503017d7baf7fbc0fff7492b751025c6a78179b"
+	apps := make([]queueItem.Appearance, 0, 4)
+	for i := uint32(1); i <= 4; i++ {
+		apps = append(apps, queueItem.Appearance{Address: address, BlockNumber: i, TransactionIndex: 0})
+	}
+	memoryStore := setupMemoryStore(t, apps)
+	responseCache = cache.NewLRU(10)
+	defer func() { responseCache = nil }()
+
+	fetch := func() string {
+		t.Helper()
+		request := &query.RpcRequest{Id: 1, Method: query.MethodGetAppearances}
+		if err := query.SetParams(request, []query.RpcGetAppearancesParam{{Address: address, PerPage: 5}}); err != nil {
+			t.Fatal(err)
+		}
+		response, err := handleGetAppearances(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprint(response.Result.Data)
+	}
+
+	if s := fetch(); s != "[{4 0  } {3 0  } {2 0  } {1 0  }]" {
+		t.Fatal("wrong first response:", s)
+	}
+
+	backfill := []queueItem.Appearance{{Address: address, BlockNumber: 1, TransactionIndex: 1}}
+	if err := memoryStore.InsertAppearanceBatch(context.Background(), backfill); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := fetch(); s != "[{4 0  } {3 0  } {2 0  } {1 1  } {1 0  }]" {
+		t.Fatal("stale cached response:", s)
+	}
+}
+
+func TestHandleGetAppearances_NotFound(t *testing.T) {
+	setupMemoryStore(t, []queueItem.Appearance{
+		{Address: "0xf503017d7baf7fbc0fff7492b751025c6a78179b", BlockNumber: 1, TransactionIndex: 0},
+	})
+
+	request := &query.RpcRequest{Id: 1, Method: query.MethodGetAppearances}
+	err := query.SetParams(request, []query.RpcGetAppearancesParam{
+		{Address: "0x0000000000000000000000000000000000000001"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := handleGetAppearances(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(response.Result.Data); l != 0 {
+		t.Fatal("wrong result count:", l)
+	}
+	if m := response.Result.Meta; m.NextPageId != nil || m.PreviousPageId != nil {
+		t.Fatal("unexpected page ids:", m.NextPageId, m.PreviousPageId)
+	}
+}
+
+func TestHandleGetAppearances_TimestampsAndInclude(t *testing.T) {
+	address := "0xf503017d7baf7fbc0fff7492b751025c6a78179b"
+	setupEnrichedMemoryStore(t, address)
+
+	from, to := uint64(200), uint64(350)
+	request := &query.RpcRequest{Id: 1, Method: query.MethodGetAppearances}
+	err := query.SetParams(request, []query.RpcGetAppearancesParam{{
+		Address:       address,
+		FromTimestamp: &from,
+		ToTimestamp:   &to,
+		Include:       []string{query.IncludeTimestamp, query.IncludeTxHash},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := handleGetAppearances(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := fmt.Sprint(response.Result.Data); s != "[{3 0 300 0x03} {2 0 200 0x02}]" {
+		t.Fatal("wrong appearances:", s)
+	}
+}
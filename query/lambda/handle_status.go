@@ -2,22 +2,39 @@ package main
 
 import (
 	"context"
+	"log"
 
 	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
 	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
 )
 
-func handleLastIndexedBlock(ctx context.Context, rpcRequest *query.RpcRequest) (response *query.RpcResponse[*database.Status], err error) {
-	meta, err := getMeta(ctx, "")
+func handleLastIndexedBlock(ctx context.Context, rpcRequest *query.RpcRequest) (response *query.RpcResponse[*query.Status], err error) {
+	dbStatus, meta, err := getStatusAndMeta(ctx, "")
 	if err != nil {
 		return
 	}
 
-	response = &query.RpcResponse[*database.Status]{
+	lastChunk, found, err := store.FetchLastChunk(ctx)
+	if err != nil {
+		log.Println("database last chunk query:", err)
+		err = databaseError(err)
+		return
+	}
+
+	var chunk *database.Chunk
+	if found {
+		chunk = &lastChunk
+	}
+	status := query.NewStatus(&dbStatus, chunk)
+	status.Chains = cnf.Chains.Allowed
+	status.ApiVersion = cnf.Version
+	status.MaxPerPage = maxPerPage()
+
+	response = &query.RpcResponse[*query.Status]{
 		JsonRpc: "2.0",
 		Id:      rpcRequest.Id,
-		Result: query.Result[*database.Status]{
-			Data: nil,
+		Result: query.Result[*query.Status]{
+			Data: status,
 			Meta: meta,
 		},
 	}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
+	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
+)
+
+func TestHandleLastIndexedBlock(t *testing.T) {
+	memoryStore := setupMemoryStore(t, []queueItem.Appearance{
+		{Address: "0xf503017d7baf7fbc0fff7492b751025c6a78179b", BlockNumber: 12, TransactionIndex: 0},
+	})
+	err := memoryStore.InsertChunkBatch(context.Background(), []queueItem.Chunk{
+		{Cid: "cid", Range: "000000000-000000012", Author: "test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := handleLastIndexedBlock(context.Background(), &query.RpcRequest{Id: 1, Method: query.MethodLastIndexedBlock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := response.Result.Data
+	if status.LastIndexedBlock != "12" {
+		t.Fatal("wrong last indexed block:", status.LastIndexedBlock)
+	}
+	if status.LastChunk == nil || status.LastChunk.Cid != "cid" {
+		t.Fatal("wrong last chunk:", status.LastChunk)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"strings"
+	"time"
+
 	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
 )
 
@@ -25,3 +28,22 @@ func getValidLimits(p query.Limiter) (validLimit uint) {
 
 	return
 }
+
+// maxPerPage returns the largest perPage value that we will honor
+func maxPerPage() uint {
+	if confLimit := cnf.Query.MaxLimit; confLimit > 0 && confLimit < query.MaxSafePerPage {
+		return confLimit
+	}
+	return query.MaxSafePerPage
+}
+
+// methodTimeout returns the time limit for serving method, 0 if there is no limit
+func methodTimeout(method string) time.Duration {
+	name := strings.ToLower(strings.TrimPrefix(method, "tb_"))
+	for key, timeout := range cnf.Query.Timeouts {
+		if strings.ToLower(key) == name {
+			return time.Duration(timeout) * time.Millisecond
+		}
+	}
+	return time.Duration(cnf.Query.Timeout) * time.Millisecond
+}
@@ -8,10 +8,11 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
-	awshelper "github.com/TrueBlocks/trueblocks-key/awshelper/pkg"
 	keyConfig "github.com/TrueBlocks/trueblocks-key/config/pkg"
 	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	sqliteStore "github.com/TrueBlocks/trueblocks-key/database/pkg/sqlite_store"
 	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -22,14 +23,18 @@ const defaultLimit = 100
 var ErrInternal = errors.New(http.StatusText(http.StatusInternalServerError))
 
 var cnf *keyConfig.ConfigFile
+var dbProvider *database.Provider
 var dbConn *database.Connection
 
+// store serves all queries, it's backed by dbConn or SQLite store (see query.sqlite config)
+var store database.AppearanceStore
+
 func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (response events.APIGatewayProxyResponse, err error) {
 	rpcRequest := &query.RpcRequest{}
 	if err = json.Unmarshal([]byte(request.Body), rpcRequest); err != nil {
 		// response.StatusCode = http.StatusBadRequest
 		// response.Body = strconv.Quote("invalid JSON")
-		NewRpcError(err, http.StatusBadRequest, "invalid JSON").Report(&response)
+		NewRpcError(err, http.StatusBadRequest, "invalid JSON").Report(&response, nil)
 		err = nil
 		return
 	}
@@ -48,15 +53,30 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 		}
 	}
 
+	setupCache()
+	setupUsage(ctx)
+
 	// When working with RDS Proxy we don't "cache" the connection
-	// between lambda invocations, so we need to recreate it each time
-	if err = setupDbConnection(ctx); err != nil {
-		log.Println("database connection:", err)
-		err = ErrInternal
-		return
+	// between lambda invocations, so the provider recreates it each time
+	if dbProvider != nil {
+		if dbConn, err = dbProvider.Connection(ctx); err != nil {
+			log.Println("database connection:", err)
+			err = ErrInternal
+			return
+		}
+		store = database.NewPostgresStore(dbConn)
+	}
+
+	// Queries that take too long are cancelled, so we can tell the user instead
+	// of API Gateway timing out
+	if timeout := methodTimeout(rpcRequest.Method); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
 	var r any
+	started := time.Now()
 	switch rpcRequest.Method {
 	case query.MethodGetAppearances:
 		r, err = handleGetAppearances(ctx, rpcRequest)
@@ -64,23 +84,34 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 		r, err = handleBounds(ctx, rpcRequest)
 	case query.MethodLastIndexedBlock:
 		r, err = handleLastIndexedBlock(ctx, rpcRequest)
+	case query.MethodGetBlockByTimestamp:
+		r, err = handleBlockByTimestamp(ctx, rpcRequest)
 	case query.MethodGetAddressesInTx:
-		r, err = handleGetAddressesIn(ctx, rpcRequest, true)
+		r, err = handleGetAddressesInTx(ctx, rpcRequest)
 	case query.MethodGetAddressesInBlock:
-		r, err = handleGetAddressesIn(ctx, rpcRequest, false)
+		r, err = handleGetAddressesInBlock(ctx, rpcRequest)
 	default:
 		err = fmt.Errorf("unsupported method: %s", rpcRequest.Method)
 		err = NewRpcError(err, http.StatusBadRequest, err.Error())
 	}
+	duration := time.Since(started)
 	// When working with RDS Proxy we have to close db connection as soon
 	// as possible
-	if closeErr := dbConn.Close(ctx); closeErr != nil {
-		log.Println("error while closing db connection:", closeErr)
+	if dbProvider != nil {
+		if closeErr := dbProvider.Release(context.WithoutCancel(ctx), dbConn); closeErr != nil {
+			log.Println("error while closing db connection:", closeErr)
+		}
 	}
 
+	var rows int
+	if counter, ok := r.(interface{ Rows() int }); ok && err == nil {
+		rows = counter.Rows()
+	}
+	recordUsage(ctx, &request, rpcRequest.Method, rows, duration)
+
 	if err != nil {
 		if rpcErr, ok := err.(*RpcError); ok {
-			rpcErr.Report(&response)
+			rpcErr.Report(&response, rpcRequest.Id)
 			err = nil
 		} else {
 			log.Println(err)
@@ -101,6 +132,9 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 		Body:       string(body),
 		StatusCode: 200,
 	}
+	if warning := quotaWarning(&request); warning != "" {
+		response.Headers = map[string]string{"X-Quota-Warning": warning}
+	}
 	return
 }
 
@@ -122,40 +156,21 @@ func unmarshallAndValidateRequest[T query.Validator](encoded string, response ev
 }
 
 func loadConfig() (err error) {
-	cnf, err = keyConfig.Get("")
-	return
-}
-
-func setupDbConnection(ctx context.Context) (err error) {
-	var user string
-	var password string
-	secretId := cnf.Database["default"].AwsSecret
-	if secretId != "" {
-		log.Println("using Secrets Manager secret as DB password")
-		secretValue, err := awshelper.FetchUsernamePasswordSecret(secretId)
-		if err != nil {
-			return err
-		}
-		user = secretValue.Username
-		password = secretValue.Password
-	} else {
-		log.Println("using configuration DB password")
-		user = cnf.Database["default"].User
-		password = cnf.Database["default"].Password
+	loaded, err := keyConfig.Get("")
+	if err != nil {
+		return
 	}
-
-	dbConn = &database.Connection{
-		Chain:    "mainnet",
-		Host:     cnf.Database["default"].Host,
-		Port:     cnf.Database["default"].Port,
-		Database: cnf.Database["default"].Database,
-		User:     user,
-		Password: password,
+	// SQLite store is opened once and kept for the life of the process
+	if path := loaded.Query.Sqlite; path != "" {
+		log.Println("using SQLite store", path)
+		if store, err = sqliteStore.Open(context.Background(), path); err != nil {
+			return
+		}
+	} else if dbProvider, err = database.NewProvider(loaded, "default", "mainnet"); err != nil {
+		return
 	}
-
-	log.Println(dbConn.String())
-
-	return dbConn.Connect(ctx)
+	cnf = loaded
+	return
 }
 
 func main() {
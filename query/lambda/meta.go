@@ -9,10 +9,15 @@ import (
 )
 
 func getMeta(ctx context.Context, address string) (m *query.Meta, err error) {
-	status, err := database.FetchStatus(ctx, dbConn)
+	_, m, err = getStatusAndMeta(ctx, address)
+	return
+}
+
+func getStatusAndMeta(ctx context.Context, address string) (status database.Status, m *query.Meta, err error) {
+	status, err = store.FetchStatus(ctx)
 	if err != nil {
 		log.Println("database status query:", err)
-		err = ErrInternal
+		err = databaseError(err)
 		return
 	}
 
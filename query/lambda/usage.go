@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	keyDynamodb "github.com/TrueBlocks/trueblocks-key/quicknode/keyDynamodb"
+	usage "github.com/TrueBlocks/trueblocks-key/usage/pkg"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// recordUsageTimeout limits time spent on metering, so it doesn't slow down responses
+const recordUsageTimeout = time.Second
+
+// usageMeter is kept between invocations, nil if metering is disabled
+var usageMeter *usage.Meter
+
+func setupUsage(ctx context.Context) {
+	if usageMeter != nil || cnf.Usage.TableName == "" {
+		return
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Println("usage metering disabled, error reading AWS config:", err)
+		return
+	}
+	dynamoClient := dynamodb.NewFromConfig(awsConfig, func(o *dynamodb.Options) {
+		if keyDynamodb.ShouldUseLocal() {
+			// When running inside sam local (in tests), use local endpoint
+			o.BaseEndpoint = aws.String("http://dynamodb:8000")
+			o.Credentials = credentials.NewStaticCredentialsProvider("fake", "fake", "test")
+		}
+	})
+	usageMeter = &usage.Meter{Client: dynamoClient, TableName: cnf.Usage.TableName}
+}
+
+// recordUsage meters request of the account identified by the authorizer. Errors are only
+// logged: metering must not fail requests.
+func recordUsage(ctx context.Context, request *events.APIGatewayProxyRequest, method string, rows int, duration time.Duration) {
+	if usageMeter == nil {
+		return
+	}
+	account, _ := request.RequestContext.Authorizer[usage.ContextAccount].(string)
+	if account == "" {
+		return
+	}
+	plan, _ := request.RequestContext.Authorizer[usage.ContextPlan].(string)
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordUsageTimeout)
+	defer cancel()
+	err := usageMeter.Record(ctx, usage.Request{
+		Account:  account,
+		Plan:     plan,
+		Method:   method,
+		Rows:     rows,
+		Duration: duration,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// quotaWarning returns the soft limit exceeded by the account (set by the authorizer)
+func quotaWarning(request *events.APIGatewayProxyRequest) string {
+	warning, _ := request.RequestContext.Authorizer[usage.ContextQuotaWarning].(string)
+	return warning
+}
@@ -0,0 +1,12 @@
+package cache
+
+import (
+	"context"
+)
+
+// Cache stores encoded responses. Implementations have to be safe for concurrent use.
+// Cache is best-effort: callers should treat errors as cache misses.
+type Cache interface {
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+	Set(ctx context.Context, key string, value []byte) error
+}
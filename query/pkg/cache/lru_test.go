@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLRU_Evicts(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(2)
+
+	l.Set(ctx, "a", []byte("1"))
+	l.Set(ctx, "b", []byte("2"))
+	// "a" is now the most recently used, so "b" should be evicted
+	if _, found, _ := l.Get(ctx, "a"); !found {
+		t.Fatal("expected a to be found")
+	}
+	l.Set(ctx, "c", []byte("3"))
+
+	if _, found, _ := l.Get(ctx, "b"); found {
+		t.Fatal("expected b to be evicted")
+	}
+	if v, found, _ := l.Get(ctx, "a"); !found || string(v) != "1" {
+		t.Fatal("wrong value of a:", string(v), found)
+	}
+	if v, found, _ := l.Get(ctx, "c"); !found || string(v) != "3" {
+		t.Fatal("wrong value of c:", string(v), found)
+	}
+	if n := l.Len(); n != 2 {
+		t.Fatal("wrong length:", n)
+	}
+}
+
+func TestLRU_Overwrite(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(2)
+
+	l.Set(ctx, "a", []byte("1"))
+	l.Set(ctx, "a", []byte("2"))
+
+	if v, _, _ := l.Get(ctx, "a"); string(v) != "2" {
+		t.Fatal("wrong value:", string(v))
+	}
+	if n := l.Len(); n != 1 {
+		t.Fatal("wrong length:", n)
+	}
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memcached does not accept longer keys
+const maxMemcachedKeyLength = 250
+
+var ErrMemcachedResponse = errors.New("unexpected memcached response")
+
+// Memcached is a minimal client of memcached text protocol (get and set commands only).
+// It keeps a single connection open and reconnects after any error, which fits
+// Lambda's one-request-at-a-time model.
+type Memcached struct {
+	Address string
+	// Expiration is passed to memcached as item TTL. Zero means no expiration.
+	Expiration time.Duration
+	// Timeout bounds every round trip (unless ctx has an earlier deadline)
+	Timeout time.Duration
+
+	mutex sync.Mutex
+	conn  net.Conn
+	rw    *bufio.ReadWriter
+}
+
+func NewMemcached(address string, expiration time.Duration) *Memcached {
+	return &Memcached{
+		Address:    address,
+		Expiration: expiration,
+		Timeout:    100 * time.Millisecond,
+	}
+}
+
+func (m *Memcached) Get(ctx context.Context, key string) (value []byte, found bool, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key = memcachedKey(key)
+	err = m.roundTrip(ctx, func() (err error) {
+		if _, err = fmt.Fprintf(m.rw, "get %s\r\n", key); err != nil {
+			return
+		}
+		if err = m.rw.Flush(); err != nil {
+			return
+		}
+
+		line, err := m.readLine()
+		if err != nil {
+			return
+		}
+		if line == "END" {
+			return
+		}
+
+		// VALUE <key> <flags> <bytes>
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "VALUE" {
+			return fmt.Errorf("%w: %s", ErrMemcachedResponse, line)
+		}
+		size, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrMemcachedResponse, line)
+		}
+		// data block is followed by \r\n
+		data := make([]byte, size+2)
+		if _, err = io.ReadFull(m.rw, data); err != nil {
+			return
+		}
+		if !bytes.HasSuffix(data, []byte("\r\n")) {
+			return fmt.Errorf("%w: data block not terminated", ErrMemcachedResponse)
+		}
+		if line, err = m.readLine(); err != nil {
+			return
+		}
+		if line != "END" {
+			return fmt.Errorf("%w: %s", ErrMemcachedResponse, line)
+		}
+
+		value = data[:size]
+		found = true
+		return
+	})
+	return
+}
+
+func (m *Memcached) Set(ctx context.Context, key string, value []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key = memcachedKey(key)
+	return m.roundTrip(ctx, func() (err error) {
+		expiration := int(m.Expiration.Seconds())
+		if _, err = fmt.Fprintf(m.rw, "set %s 0 %d %d\r\n", key, expiration, len(value)); err != nil {
+			return
+		}
+		if _, err = m.rw.Write(value); err != nil {
+			return
+		}
+		if _, err = m.rw.WriteString("\r\n"); err != nil {
+			return
+		}
+		if err = m.rw.Flush(); err != nil {
+			return
+		}
+
+		line, err := m.readLine()
+		if err != nil {
+			return
+		}
+		if line != "STORED" {
+			return fmt.Errorf("%w: %s", ErrMemcachedResponse, line)
+		}
+		return
+	})
+}
+
+func (m *Memcached) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.disconnect()
+}
+
+// roundTrip connects if needed, sets the deadline and drops the connection
+// if anything goes wrong, so the next call starts with a clean state
+func (m *Memcached) roundTrip(ctx context.Context, fn func() error) (err error) {
+	if err = m.connect(ctx); err != nil {
+		return fmt.Errorf("memcached: connecting to %s: %w", m.Address, err)
+	}
+
+	deadline := time.Now().Add(m.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err = m.conn.SetDeadline(deadline); err != nil {
+		m.disconnect()
+		return fmt.Errorf("memcached: setting deadline: %w", err)
+	}
+
+	if err = fn(); err != nil {
+		m.disconnect()
+		return fmt.Errorf("memcached: %w", err)
+	}
+	return
+}
+
+func (m *Memcached) connect(ctx context.Context) (err error) {
+	if m.conn != nil {
+		return
+	}
+	dialer := &net.Dialer{Timeout: m.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", m.Address)
+	if err != nil {
+		return
+	}
+	m.conn = conn
+	m.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	return
+}
+
+func (m *Memcached) disconnect() (err error) {
+	if m.conn == nil {
+		return
+	}
+	err = m.conn.Close()
+	m.conn = nil
+	m.rw = nil
+	return
+}
+
+func (m *Memcached) readLine() (string, error) {
+	line, err := m.rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if strings.HasPrefix(line, "ERROR") ||
+		strings.HasPrefix(line, "CLIENT_ERROR") ||
+		strings.HasPrefix(line, "SERVER_ERROR") {
+		return "", fmt.Errorf("%w: %s", ErrMemcachedResponse, line)
+	}
+	return line, nil
+}
+
+// memcachedKey makes sure that the key is valid memcached key: it can't be longer than
+// 250 bytes and can't contain whitespace or control characters
+func memcachedKey(key string) string {
+	valid := len(key) <= maxMemcachedKeyLength && !strings.ContainsFunc(key, func(r rune) bool {
+		return r <= ' ' || r == 0x7f
+	})
+	if valid {
+		return key
+	}
+	sum := sha1.Sum([]byte(key))
+	return "sha1:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memcachedStandIn implements just enough of memcached text protocol to test the client
+type memcachedStandIn struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	items    map[string][]byte
+	exptimes map[string]int
+}
+
+func newMemcachedStandIn(t *testing.T) *memcachedStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &memcachedStandIn{
+		listener: listener,
+		items:    make(map[string][]byte),
+		exptimes: make(map[string]int),
+	}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *memcachedStandIn) Address() string {
+	return s.listener.Addr().String()
+}
+
+func (s *memcachedStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *memcachedStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+
+		s.mutex.Lock()
+		switch {
+		case fields[0] == "get" && len(fields) == 2:
+			if value, ok := s.items[fields[1]]; ok {
+				fmt.Fprintf(rw, "VALUE %s 0 %d\r\n%s\r\n", fields[1], len(value), value)
+			}
+			rw.WriteString("END\r\n")
+		case fields[0] == "set" && len(fields) == 5:
+			size, _ := strconv.Atoi(fields[4])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(rw, data); err != nil {
+				s.mutex.Unlock()
+				return
+			}
+			s.items[fields[1]] = data[:size]
+			s.exptimes[fields[1]], _ = strconv.Atoi(fields[3])
+			rw.WriteString("STORED\r\n")
+		default:
+			rw.WriteString("ERROR\r\n")
+		}
+		s.mutex.Unlock()
+		rw.Flush()
+	}
+}
+
+func TestMemcached_GetSet(t *testing.T) {
+	ctx := context.Background()
+	server := newMemcachedStandIn(t)
+	m := NewMemcached(server.Address(), time.Minute)
+	defer m.Close()
+
+	if _, found, err := m.Get(ctx, "missing"); err != nil || found {
+		t.Fatal("expected miss, got", found, err)
+	}
+
+	value := []byte("{\"data\":[]}\r\nwith line break")
+	if err := m.Set(ctx, "key", value); err != nil {
+		t.Fatal(err)
+	}
+
+	result, found, err := m.Get(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("expected hit")
+	}
+	if string(result) != string(value) {
+		t.Fatal("wrong value:", string(result))
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if e := server.exptimes["key"]; e != 60 {
+		t.Fatal("wrong expiration:", e)
+	}
+}
+
+func TestMemcached_Reconnects(t *testing.T) {
+	ctx := context.Background()
+	server := newMemcachedStandIn(t)
+	m := NewMemcached(server.Address(), 0)
+	defer m.Close()
+
+	if err := m.Set(ctx, "key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate dropped connection
+	m.conn.Close()
+	if _, _, err := m.Get(ctx, "key"); err == nil {
+		t.Fatal("expected error on closed connection")
+	}
+
+	if _, found, err := m.Get(ctx, "key"); err != nil || !found {
+		t.Fatal("expected hit after reconnecting, got", found, err)
+	}
+}
+
+func Test_memcachedKey(t *testing.T) {
+	if k := memcachedKey("appearances:0xabc"); k != "appearances:0xabc" {
+		t.Fatal("valid key changed:", k)
+	}
+	if k := memcachedKey("with space"); strings.Contains(k, " ") {
+		t.Fatal("key with space not hashed:", k)
+	}
+	if k := memcachedKey(strings.Repeat("a", 300)); len(k) > maxMemcachedKeyLength {
+		t.Fatal("long key not hashed:", k)
+	}
+}
@@ -0,0 +1,42 @@
+package query
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// AddressesCursor points to the last item of tb_getAddressesIn* page. It is sent to the
+// user as an opaque string.
+type AddressesCursor struct {
+	TransactionIndex uint32
+	Address          string
+}
+
+func (c *AddressesCursor) MarshalText() (text []byte, err error) {
+	raw := fmt.Sprintf("%d:%s", c.TransactionIndex, c.Address)
+	text = []byte(base64.RawURLEncoding.EncodeToString([]byte(raw)))
+	return
+}
+
+func (c *AddressesCursor) UnmarshalText(text []byte) error {
+	raw, err := base64.RawURLEncoding.DecodeString(string(text))
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	txId, address, ok := strings.Cut(string(raw), ":")
+	if !ok || validateAddress(address) != nil {
+		return ErrInvalidCursor
+	}
+	parsed, err := strconv.ParseUint(txId, 10, 32)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	c.TransactionIndex = uint32(parsed)
+	c.Address = address
+	return nil
+}
@@ -0,0 +1,38 @@
+package query
+
+import "testing"
+
+func TestAddressesCursor_MarshalText(t *testing.T) {
+	cursor := &AddressesCursor{
+		TransactionIndex: 12,
+		Address:          "0xf503017d7baf7fbc0fff7492b751025c6a78179b",
+	}
+	text, err := cursor.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := &AddressesCursor{}
+	if err := result.UnmarshalText(text); err != nil {
+		t.Fatal(err)
+	}
+	if *result != *cursor {
+		t.Fatal("wrong result:", result)
+	}
+}
+
+func TestAddressesCursor_UnmarshalTextErrors(t *testing.T) {
+	for _, text := range []string{
+		"not base64!",
+		// "12"
+		"MTI",
+		// "x:0xf503017d7baf7fbc0fff7492b751025c6a78179b"
+		"eDoweGY1MDMwMTdkN2JhZjdmYmMwZmZmNzQ5MmI3NTEwMjVjNmE3ODE3OWI",
+		// "12:0x"
+		"MTI6MHg",
+	} {
+		if err := (&AddressesCursor{}).UnmarshalText([]byte(text)); err != ErrInvalidCursor {
+			t.Fatal("expected ErrInvalidCursor for", text, "got", err)
+		}
+	}
+}
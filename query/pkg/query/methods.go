@@ -3,6 +3,7 @@ package query
 const MethodGetAppearances = "tb_getAppearances"
 const MethodGetBounds = "tb_getBounds"
 const MethodLastIndexedBlock = "tb_status"
+const MethodGetBlockByTimestamp = "tb_getBlockByTimestamp"
 
 const MethodGetAddressesInTx = "tb_getAddressesInTransaction"
 const MethodGetAddressesInBlock = "tb_getAddressesInBlock"
@@ -18,6 +18,19 @@ type PageId struct {
 	// TransactionIndex  uint32
 	LatestInSet   database.Appearance
 	EarliestInSet database.Appearance
+	// Watermark is returned with the first page and hides appearances
+	// inserted later from the following pages
+	Watermark uint64
+}
+
+// pageIdV1 is the layout of page ids issued before Watermark was added.
+// We still accept it, so the clients can finish walks that they've already started.
+type pageIdV1 struct {
+	DirectionNextPage bool
+	LastBlock         uint32
+	LastSeen          database.Appearance
+	LatestInSet       database.Appearance
+	EarliestInSet     database.Appearance
 }
 
 func (p *PageId) MarshalText() (text []byte, err error) {
@@ -37,6 +50,21 @@ func (p *PageId) UnmarshalText(text []byte) (err error) {
 		return
 	}
 
+	if len(b) == binary.Size(pageIdV1{}) {
+		var legacy pageIdV1
+		if err = binary.Read(bytes.NewReader(b), binary.LittleEndian, &legacy); err != nil {
+			return
+		}
+		*p = PageId{
+			DirectionNextPage: legacy.DirectionNextPage,
+			LastBlock:         legacy.LastBlock,
+			LastSeen:          legacy.LastSeen,
+			LatestInSet:       legacy.LatestInSet,
+			EarliestInSet:     legacy.EarliestInSet,
+		}
+		return
+	}
+
 	var result PageId
 	if err = binary.Read(bytes.NewReader(b), binary.LittleEndian, &result); err != nil {
 		return
@@ -84,3 +112,37 @@ func (p *PageIdSpecial) FromBytes(b []byte) bool {
 
 	return false
 }
+
+// NewPageIds returns ids of pages around items (ordered latest first). previousPageId
+// points to older appearances, nextPageId to newer ones. Ids are nil if there is nothing
+// more to read in the respective direction.
+func NewPageIds(items []database.Appearance, lastBlock uint, watermark uint64, bounds *database.AppearancesDatasetBounds) (previousPageId *PageId, nextPageId *PageId) {
+	if len(items) == 0 {
+		return
+	}
+
+	if !bounds.IsLatest(&items[0]) {
+		nextPageId = &PageId{
+			DirectionNextPage: false,
+			LastBlock:         uint32(lastBlock),
+			LastSeen:          items[0],
+			LatestInSet:       bounds.Latest,
+			EarliestInSet:     bounds.Earliest,
+			Watermark:         watermark,
+		}
+	}
+
+	lastCurrentAppearance := items[len(items)-1]
+
+	if !bounds.IsEarliest(&lastCurrentAppearance) {
+		previousPageId = &PageId{
+			DirectionNextPage: true,
+			LastBlock:         uint32(lastBlock),
+			LastSeen:          lastCurrentAppearance,
+			LatestInSet:       bounds.Latest,
+			EarliestInSet:     bounds.Earliest,
+			Watermark:         watermark,
+		}
+	}
+	return
+}
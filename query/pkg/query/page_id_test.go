@@ -18,6 +18,7 @@ func TestPageId_MarshalJSON(t *testing.T) {
 		LastSeen:          database.Appearance{BlockNumber: 19317517, TransactionIndex: 7},
 		LatestInSet:       database.Appearance{BlockNumber: 19317590, TransactionIndex: 10},
 		EarliestInSet:     database.Appearance{BlockNumber: 100000, TransactionIndex: 126},
+		Watermark:         42,
 	}
 
 	b, err = json.Marshal(p)
@@ -25,11 +26,12 @@ func TestPageId_MarshalJSON(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if s := string(b); s != `"QVZiREpnRU53eVlCQndBQUFGYkRKZ0VLQUFBQW9JWUJBSDRBQUFBPQ=="` {
+	if s := string(b); s != `"QVZiREpnRU53eVlCQndBQUFGYkRKZ0VLQUFBQW9JWUJBSDRBQUFBcUFBQUFBQUFBQUE9PQ=="` {
 		t.Fatal("wrong value:", s)
 	}
 }
 
+// TestPageId_UnmarshalJSON decodes page id issued before watermarks were introduced
 func TestPageId_UnmarshalJSON(t *testing.T) {
 	var s struct {
 		PageId *PageId `json:"pageId"`
@@ -57,6 +59,27 @@ func TestPageId_UnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestPageId_UnmarshalJSONWatermark(t *testing.T) {
+	var s struct {
+		PageId *PageId `json:"pageId"`
+	}
+	str := `{"pageId": "QVZiREpnRU53eVlCQndBQUFGYkRKZ0VLQUFBQW9JWUJBSDRBQUFBcUFBQUFBQUFBQUE9PQ=="}`
+
+	if err := json.Unmarshal([]byte(str), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := s.PageId.LastBlock; v != 19317590 {
+		t.Fatal("wrong last block:", v)
+	}
+	if v := s.PageId.EarliestInSet; !reflect.DeepEqual(v, database.Appearance{BlockNumber: 100000, TransactionIndex: 126}) {
+		t.Fatal("wrong EarliestInSet:", v)
+	}
+	if v := s.PageId.Watermark; v != 42 {
+		t.Fatal("wrong Watermark:", v)
+	}
+}
+
 func TestPageId_Errors(t *testing.T) {
 	var s struct {
 		PageId *PageId `json:"pageId"`
@@ -5,9 +5,24 @@ import "strconv"
 type RpcGetAddressesInParam struct {
 	BlockNumber      string `json:"blockNumber"`
 	TransactionIndex string `json:"transactionIndex"`
+	PerPage          uint   `json:"perPage"`
+	// Cursor is meta.nextCursor of the previous page
+	Cursor string `json:"cursor,omitempty"`
+}
+
+func (r *RpcGetAddressesInParam) Limit() uint {
+	return r.PerPage
 }
 
 func (r *RpcGetAddressesInParam) Validate() error {
+	if err := validateLimit(r); err != nil {
+		return err
+	}
+
+	if _, err := r.CursorValue(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -26,3 +41,12 @@ func (r *RpcGetAddressesInParam) TransactionIndexUint() (uint32, error) {
 	}
 	return uint32(parsed), err
 }
+
+// CursorValue returns zero cursor (the beginning) if no cursor has been sent
+func (r *RpcGetAddressesInParam) CursorValue() (cursor AddressesCursor, err error) {
+	if r.Cursor == "" {
+		return
+	}
+	err = cursor.UnmarshalText([]byte(r.Cursor))
+	return
+}
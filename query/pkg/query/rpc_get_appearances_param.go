@@ -12,8 +12,17 @@ type RpcGetAppearancesParam struct {
 	LastBlock *json.RawMessage `json:"lastBlock,omitempty"`
 	PageId    json.RawMessage  `json:"pageId,omitempty"`
 	PerPage   uint             `json:"perPage"`
+	// Include lists additional fields to return with each appearance
+	Include []string `json:"include,omitempty"`
+	// FromTimestamp and ToTimestamp (inclusive, Unix seconds) limit appearances to blocks
+	// mined in this period. They are ignored when PageId is set.
+	FromTimestamp *uint64 `json:"fromTimestamp,omitempty"`
+	ToTimestamp   *uint64 `json:"toTimestamp,omitempty"`
 }
 
+const IncludeTimestamp = "timestamp"
+const IncludeTxHash = "txHash"
+
 func (r *RpcGetAppearancesParam) Limit() uint {
 	return r.PerPage
 }
@@ -27,9 +36,29 @@ func (r *RpcGetAppearancesParam) Validate() error {
 		return err
 	}
 
+	if r.FromTimestamp != nil && r.ToTimestamp != nil && *r.FromTimestamp > *r.ToTimestamp {
+		return ErrInvalidTimestampRange
+	}
+
+	for _, field := range r.Include {
+		if field != IncludeTimestamp && field != IncludeTxHash {
+			return ErrInvalidInclude
+		}
+	}
+
 	return nil
 }
 
+// Includes returns true if the user asked for field
+func (r *RpcGetAppearancesParam) Includes(field string) bool {
+	for _, f := range r.Include {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
 // LastBlock returns nil for latest block, block number otherwise
 func (r *RpcGetAppearancesParam) LastBlockNumber() (*uint, error) {
 	if r.LastBlock == nil {
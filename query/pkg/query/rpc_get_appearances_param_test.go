@@ -0,0 +1,46 @@
+package query
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRpcGetAppearancesParam_Include(t *testing.T) {
+	param := &RpcGetAppearancesParam{}
+	if err := json.Unmarshal([]byte(`{"address":"0xf503017d7baf7fbc0fff7492b751025c6a78179b","perPage":100,"include":["txHash"]}`), param); err != nil {
+		t.Fatal(err)
+	}
+	if err := param.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !param.Includes(IncludeTxHash) {
+		t.Fatal("expected txHash to be included")
+	}
+	if param.Includes(IncludeTimestamp) {
+		t.Fatal("expected timestamp not to be included")
+	}
+
+	param.Include = []string{IncludeTimestamp, "gasUsed"}
+	if err := param.Validate(); err != ErrInvalidInclude {
+		t.Fatal("expected ErrInvalidInclude, got", err)
+	}
+}
+
+func TestRpcGetAppearancesParam_TimestampRange(t *testing.T) {
+	from := uint64(1704067200)
+	to := uint64(1735689599)
+	param := &RpcGetAppearancesParam{
+		Address:       "0xf503017d7baf7fbc0fff7492b751025c6a78179b",
+		PerPage:       100,
+		FromTimestamp: &from,
+		ToTimestamp:   &to,
+	}
+	if err := param.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	param.FromTimestamp, param.ToTimestamp = &to, &from
+	if err := param.Validate(); err != ErrInvalidTimestampRange {
+		t.Fatal("expected ErrInvalidTimestampRange, got", err)
+	}
+}
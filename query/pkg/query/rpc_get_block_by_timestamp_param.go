@@ -0,0 +1,23 @@
+package query
+
+const ClosestBefore = "before"
+const ClosestAfter = "after"
+
+type RpcGetBlockByTimestampParam struct {
+	// Timestamp in Unix seconds
+	Timestamp uint64 `json:"timestamp"`
+	// Closest is "before" (default) to get the latest block mined at or before Timestamp,
+	// or "after" to get the earliest block mined at or after it
+	Closest string `json:"closest,omitempty"`
+}
+
+func (r *RpcGetBlockByTimestampParam) Validate() error {
+	if r.Closest != "" && r.Closest != ClosestBefore && r.Closest != ClosestAfter {
+		return ErrInvalidClosest
+	}
+	return nil
+}
+
+func (r *RpcGetBlockByTimestampParam) After() bool {
+	return r.Closest == ClosestAfter
+}
@@ -18,6 +18,7 @@ type rpcRequestParams interface {
 	RpcGetAppearancesParam |
 		RpcGetAddressesInParam |
 		BoundsParam |
+		RpcGetBlockByTimestampParam |
 		NoParam
 }
 
@@ -29,6 +30,10 @@ func (r *RpcRequest) BoundsParams() (RpcParams[BoundsParam], error) {
 	return unmarshalParams[BoundsParam](r)
 }
 
+func (r *RpcRequest) BlockByTimestampParams() (RpcParams[RpcGetBlockByTimestampParam], error) {
+	return unmarshalParams[RpcGetBlockByTimestampParam](r)
+}
+
 func (r *RpcRequest) AddressesInParam() (RpcParams[RpcGetAddressesInParam], error) {
 	return unmarshalParams[RpcGetAddressesInParam](r)
 }
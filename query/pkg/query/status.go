@@ -0,0 +1,48 @@
+package query
+
+import (
+	"strconv"
+	"time"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+)
+
+// Status is returned by tb_status. It describes freshness of the index and the limits
+// of the API, so that clients don't have to call several endpoints.
+type Status struct {
+	LastIndexedBlock string       `json:"lastIndexedBlock"`
+	LastChunk        *StatusChunk `json:"lastChunk"`
+	// LastNotificationAt is the time when the scraper sent the latest ingested notification
+	LastNotificationAt *time.Time `json:"lastNotificationAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+	// IngestionLagSeconds is the time since LastNotificationAt. It grows when ingestion stops.
+	IngestionLagSeconds int64 `json:"ingestionLagSeconds"`
+	// IngestionDelaySeconds is the time between LastNotificationAt and UpdatedAt
+	IngestionDelaySeconds int64 `json:"ingestionDelaySeconds"`
+	// Chains maps chain names to supported networks
+	Chains     map[string][]string `json:"chains"`
+	ApiVersion string              `json:"apiVersion"`
+	MaxPerPage uint                `json:"maxPerPage"`
+}
+
+type StatusChunk struct {
+	Range string `json:"range"`
+	Cid   string `json:"cid"`
+}
+
+func NewStatus(status *database.Status, lastChunk *database.Chunk) *Status {
+	s := &Status{
+		LastIndexedBlock:      strconv.FormatUint(uint64(status.LastIndexedBlock), 10),
+		LastNotificationAt:    status.LastNotificationAt,
+		UpdatedAt:             status.UpdatedAt,
+		IngestionLagSeconds:   int64(status.IngestionLag().Seconds()),
+		IngestionDelaySeconds: int64(status.IngestionDelay().Seconds()),
+	}
+	if lastChunk != nil {
+		s.LastChunk = &StatusChunk{
+			Range: lastChunk.Range,
+			Cid:   lastChunk.Cid,
+		}
+	}
+	return s
+}
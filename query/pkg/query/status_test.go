@@ -0,0 +1,55 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+)
+
+func TestNewStatus(t *testing.T) {
+	updatedAt := time.Date(2024, 3, 1, 12, 0, 30, 0, time.UTC)
+	notifiedAt := updatedAt.Add(-30 * time.Second)
+
+	s := NewStatus(
+		&database.Status{
+			LastIndexedBlock:   19317590,
+			LastChunkRange:     "019316001-019317590",
+			LastNotificationAt: &notifiedAt,
+			UpdatedAt:          updatedAt,
+			// ingestion stopped 10 minutes ago
+			ReadAt: updatedAt.Add(10 * time.Minute),
+		},
+		&database.Chunk{
+			Cid:   "QmaozNR7DZHQK1ZcU9p7QdrshMvXqWK6gpu5rmrkPdT3L4",
+			Range: "019316001-019317590",
+		},
+	)
+
+	if v := s.LastIndexedBlock; v != "19317590" {
+		t.Fatal("wrong LastIndexedBlock:", v)
+	}
+	if v := s.IngestionLagSeconds; v != 630 {
+		t.Fatal("wrong IngestionLagSeconds:", v)
+	}
+	if v := s.IngestionDelaySeconds; v != 30 {
+		t.Fatal("wrong IngestionDelaySeconds:", v)
+	}
+	if s.LastChunk == nil {
+		t.Fatal("LastChunk is nil")
+	}
+	if v := s.LastChunk.Cid; v != "QmaozNR7DZHQK1ZcU9p7QdrshMvXqWK6gpu5rmrkPdT3L4" {
+		t.Fatal("wrong LastChunk.Cid:", v)
+	}
+}
+
+func TestNewStatus_Empty(t *testing.T) {
+	s := NewStatus(&database.Status{LastIndexedBlock: 1}, nil)
+
+	if s.LastChunk != nil {
+		t.Fatal("expected LastChunk to be nil")
+	}
+	if v := s.IngestionLagSeconds; v != 0 {
+		t.Fatal("wrong IngestionLagSeconds:", v)
+	}
+}
@@ -12,6 +12,9 @@ var ErrIncorrectPerPage = errors.New("incorrect perPage")
 var ErrWrongNumOfParameters = errors.New("exactly 1 parameter object required")
 var ErrInvalidLastBlockSpecial = errors.New("if lastBlock is a string, it has to be 'latest'")
 var ErrInvalidLastBlockInvalid = errors.New("lastBlock must be a number or string")
+var ErrInvalidTimestampRange = errors.New("fromTimestamp cannot be greater than toTimestamp")
+var ErrInvalidClosest = errors.New("closest has to be 'before' or 'after'")
+var ErrInvalidInclude = errors.New("include can only contain 'timestamp' and 'txHash'")
 
 // MaxSafePerPage is the largest sane value of PerPage that we would allow users to use
 const MaxSafePerPage = 1000
@@ -31,6 +34,36 @@ type RpcResponse[T RpcResponseResult] struct {
 	Result[T] `json:"result"`
 }
 
+// Rows returns the number of items returned, used by usage metering. Addresses in
+// transactions are counted individually.
+func (r *RpcResponse[T]) Rows() int {
+	switch data := any(r.Data).(type) {
+	case []database.PublicAppearance:
+		return len(data)
+	case []string:
+		return len(data)
+	case []database.PublicAddressesInTx:
+		var rows int
+		for _, tx := range data {
+			rows += len(tx.Addresses)
+		}
+		return rows
+	case *Status:
+		if data == nil {
+			return 0
+		}
+	case *database.PublicBlock:
+		if data == nil {
+			return 0
+		}
+	case *int:
+		if data == nil {
+			return 0
+		}
+	}
+	return 1
+}
+
 type Result[T RpcResponseResult] struct {
 	Data  T `json:"data"`
 	*Meta `json:"meta"`
@@ -39,8 +72,10 @@ type Result[T RpcResponseResult] struct {
 type RpcResponseResult interface {
 	[]database.PublicAppearance |
 		[]string |
+		[]database.PublicAddressesInTx |
 		database.PublicAppearancesDatasetBounds |
-		*database.Status |
+		*Status |
+		*database.PublicBlock |
 		*int
 }
 
@@ -49,6 +84,8 @@ type Meta struct {
 	Address          string  `json:"address,omitempty"`
 	PreviousPageId   *PageId `json:"previousPageId"`
 	NextPageId       *PageId `json:"nextPageId"`
+	// NextCursor is set by tb_getAddressesIn* methods if there are more results
+	NextCursor *AddressesCursor `json:"nextCursor,omitempty"`
 
 	lastIndexedBlock uint
 }
@@ -67,3 +104,18 @@ type RpcAddressesResponse struct {
 	Id      int      `json:"id"`
 	Result  []string `json:"result"`
 }
+
+// ErrorCodeQueryTooExpensive is sent when a query runs out of its time limit. JSON-RPC
+// reserves codes from -32000 to -32099 for server errors.
+const ErrorCodeQueryTooExpensive = -32001
+
+type RpcErrorResponse struct {
+	JsonRpc string         `json:"jsonrpc"`
+	Id      any            `json:"id"`
+	Error   RpcErrorObject `json:"error"`
+}
+
+type RpcErrorObject struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
@@ -67,3 +67,26 @@ func TestRpcRequest_SetPageId(t *testing.T) {
 		t.Fatal("wrong value:", special)
 	}
 }
+
+func TestRpcResponse_Rows(t *testing.T) {
+	appearances := &RpcResponse[[]database.PublicAppearance]{}
+	appearances.Data = []database.PublicAppearance{{}, {}}
+	if rows := appearances.Rows(); rows != 2 {
+		t.Fatal("wrong appearances rows:", rows)
+	}
+
+	addresses := &RpcResponse[[]database.PublicAddressesInTx]{}
+	addresses.Data = []database.PublicAddressesInTx{{Addresses: []string{"a", "b"}}, {Addresses: []string{"c"}}}
+	if rows := addresses.Rows(); rows != 3 {
+		t.Fatal("wrong addresses rows:", rows)
+	}
+
+	block := &RpcResponse[*database.PublicBlock]{}
+	if rows := block.Rows(); rows != 0 {
+		t.Fatal("wrong nil block rows:", rows)
+	}
+	block.Data = &database.PublicBlock{}
+	if rows := block.Rows(); rows != 1 {
+		t.Fatal("wrong block rows:", rows)
+	}
+}
@@ -6,23 +6,29 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 
-	awshelper "github.com/TrueBlocks/trueblocks-key/awshelper/pkg"
 	config "github.com/TrueBlocks/trueblocks-key/config/pkg"
 	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	enrich "github.com/TrueBlocks/trueblocks-key/enrich/pkg"
 	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
 var maxBatchSize = 500
+var dbProvider *database.Provider
 var dbConn *database.Connection
 
+// enricher is nil if enrichment is disabled
+var enricher *enrich.Enricher
+
 func HandleRequest(ctx context.Context, sqsEvent events.SQSEvent) (err error) {
 	if err = setupDbConnection(ctx); err != nil {
 		return
 	}
-	defer dbConn.Close(context.TODO())
+	defer dbProvider.Release(context.WithoutCancel(ctx), dbConn)
 
 	recordCount := len(sqsEvent.Records)
 	appearances := make([]queueItem.Appearance, 0, recordCount)
@@ -30,7 +36,13 @@ func HandleRequest(ctx context.Context, sqsEvent events.SQSEvent) (err error) {
 
 	log.Println("Inserting", recordCount, "items")
 
+	var lastNotificationAt time.Time
+
 	for _, record := range sqsEvent.Records {
+		if sentAt, ok := sentTimestamp(&record); ok && sentAt.After(lastNotificationAt) {
+			lastNotificationAt = sentAt
+		}
+
 		var recordType string
 		rawType := record.MessageAttributes["Type"].StringValue
 		if rawType == nil {
@@ -68,6 +80,7 @@ func HandleRequest(ctx context.Context, sqsEvent events.SQSEvent) (err error) {
 		err = database.InsertAppearanceBatch(ctx, dbConn, appearances)
 		if err == nil {
 			log.Println("Success:", recordCount, "items inserted")
+			enrichAppearances(ctx, appearances)
 		}
 	}
 
@@ -77,9 +90,49 @@ func HandleRequest(ctx context.Context, sqsEvent events.SQSEvent) (err error) {
 		err = database.InsertChunkBatch(ctx, dbConn, chunks)
 	}
 
+	if err == nil && !lastNotificationAt.IsZero() {
+		if statusErr := database.UpdateStatusLastNotification(ctx, dbConn, lastNotificationAt); statusErr != nil {
+			// items are already saved, so we only log it
+			log.Println("updating status last notification time:", statusErr)
+		}
+	}
+
 	return
 }
 
+// enrichAppearances stores timestamps of new blocks. Appearances are already saved,
+// so errors are only logged (missing blocks can be backfilled with `dbadmin enrich`)
+func enrichAppearances(ctx context.Context, appearances []queueItem.Appearance) {
+	if enricher == nil {
+		return
+	}
+
+	blockNumbers := make([]uint32, 0, len(appearances))
+	for _, app := range appearances {
+		blockNumbers = append(blockNumbers, app.BlockNumber)
+	}
+	enriched, err := enricher.Blocks(ctx, dbConn, blockNumbers)
+	if err != nil {
+		log.Println("enriching appearances:", err)
+		return
+	}
+	log.Println("enriched", enriched, "blocks")
+}
+
+// sentTimestamp returns the time when queue/insert forwarded scraper's notification to SQS
+func sentTimestamp(record *events.SQSMessage) (sentAt time.Time, ok bool) {
+	raw, ok := record.Attributes["SentTimestamp"]
+	if !ok {
+		return
+	}
+	millis, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		log.Println("parsing SentTimestamp:", err)
+		return sentAt, false
+	}
+	return time.UnixMilli(millis), true
+}
+
 func setupDbConnection(ctx context.Context) (err error) {
 	cnf, err := config.Get("")
 	if err != nil {
@@ -88,33 +141,16 @@ func setupDbConnection(ctx context.Context) (err error) {
 	if bs := cnf.Sqs.InsertBatchSize; bs > 0 {
 		maxBatchSize = int(bs)
 	}
-
-	var user string
-	var password string
-	secretId := cnf.Database["default"].AwsSecret
-	if secretId != "" {
-		log.Println("using Secrets Manager secret as DB password")
-		secretValue, err := awshelper.FetchUsernamePasswordSecret(secretId)
-		if err != nil {
-			return err
-		}
-		user = secretValue.Username
-		password = secretValue.Password
-	} else {
-		log.Println("using configuration DB password")
-		user = cnf.Database["default"].User
-		password = cnf.Database["default"].Password
+	if enricher == nil && cnf.Enrich.RpcUrl != "" {
+		enricher = enrich.NewEnricher(cnf.Enrich.RpcUrl, cnf.Enrich.TxHashes)
 	}
 
-	dbConn = &database.Connection{
-		Chain:    "mainnet",
-		Host:     cnf.Database["default"].Host,
-		Port:     cnf.Database["default"].Port,
-		Database: cnf.Database["default"].Database,
-		User:     user,
-		Password: password,
+	if dbProvider == nil {
+		if dbProvider, err = database.NewProvider(cnf, "default", "mainnet"); err != nil {
+			return
+		}
 	}
-	err = dbConn.Connect(ctx)
+	dbConn, err = dbProvider.Connection(ctx)
 	return
 }
 
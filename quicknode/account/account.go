@@ -5,10 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/apigateway"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
@@ -42,15 +42,55 @@ func NewAccount(dynamoClient *dynamodb.Client, tableName string) *Account {
 	}
 }
 
-func (a *Account) LoadApiKey(apiGatewayClient *apigateway.Client) error {
-	apiKey, err := FindByPlanSlug(apiGatewayClient, a.Plan)
+// CreateApiKey creates a dedicated API key for the account and links it to the plan's
+// usage plan, so the account is throttled separately from other accounts on the same plan.
+// Accounts which already have a dedicated key keep it.
+func (a *Account) CreateApiKey(ctx context.Context, apiGatewayClient ApiGatewayClient) error {
+	if a.hasOwnApiKey() {
+		return nil
+	}
+	apiKey, err := createAccountApiKey(ctx, apiGatewayClient, a.QuicknodeId, a.Plan)
 	if err != nil {
-		return fmt.Errorf("fetching API key for plan %s: %w", a.Plan, err)
+		return fmt.Errorf("creating API key for account %s, plan %s: %w", a.QuicknodeId, a.Plan, err)
 	}
 	a.ApiKey = *apiKey
 	return nil
 }
 
+// UpdateApiKey takes over the API key of previous and moves it to the new plan's usage plan
+// if the plan has changed. Accounts using the shared plan key get a dedicated one.
+func (a *Account) UpdateApiKey(ctx context.Context, apiGatewayClient ApiGatewayClient, previous *Account) error {
+	a.ApiKey = previous.ApiKey
+	if !a.hasOwnApiKey() {
+		return a.CreateApiKey(ctx, apiGatewayClient)
+	}
+	if a.Plan == previous.Plan {
+		return nil
+	}
+	if err := moveAccountApiKey(ctx, apiGatewayClient, a.ApiKey.Id, previous.Plan, a.Plan); err != nil {
+		return fmt.Errorf("moving API key of account %s from plan %s to %s: %w", a.QuicknodeId, previous.Plan, a.Plan, err)
+	}
+	return nil
+}
+
+// DeleteApiKey deletes the account's dedicated API key. Shared plan keys are left untouched.
+func (a *Account) DeleteApiKey(ctx context.Context, apiGatewayClient ApiGatewayClient) error {
+	if !a.hasOwnApiKey() {
+		return nil
+	}
+	if err := deleteApiKey(ctx, apiGatewayClient, a.ApiKey.Id); err != nil {
+		return fmt.Errorf("deleting API key of account %s: %w", a.QuicknodeId, err)
+	}
+	a.ApiKey = ApiKey{}
+	return nil
+}
+
+// hasOwnApiKey returns true if the account has a dedicated API key (older accounts
+// use the key shared by all accounts on the plan)
+func (a *Account) hasOwnApiKey() bool {
+	return a.ApiKey.Id != "" && strings.HasPrefix(a.ApiKey.Name, accountApiKeyPrefix)
+}
+
 func (a *Account) Find() (found bool, err error) {
 	if err = a.dynamoGet(false); err != nil {
 		err = fmt.Errorf("loading account: %w", err)
@@ -2,8 +2,10 @@ package qnaccount
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	keyDynamodb "github.com/TrueBlocks/trueblocks-key/quicknode/keyDynamodb"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -11,32 +13,50 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/apigateway/types"
 )
 
+// accountApiKeyPrefix is prepended to QuickNode ID to build the name of account's API key.
+// It keeps account keys apart from plan keys, which are named after plan slugs.
+const accountApiKeyPrefix = "qn-account-"
+
 type ApiKey struct {
+	Id    string `json:"id,omitempty"`
 	Name  string `json:"name"`
 	Value string `json:"value"`
 }
 
+// ApiGatewayClient is the part of API Gateway client that we use to manage API keys
+type ApiGatewayClient interface {
+	apigateway.GetApiKeysAPIClient
+	apigateway.GetUsagePlansAPIClient
+	CreateApiKey(ctx context.Context, params *apigateway.CreateApiKeyInput, optFns ...func(*apigateway.Options)) (*apigateway.CreateApiKeyOutput, error)
+	DeleteApiKey(ctx context.Context, params *apigateway.DeleteApiKeyInput, optFns ...func(*apigateway.Options)) (*apigateway.DeleteApiKeyOutput, error)
+	CreateUsagePlanKey(ctx context.Context, params *apigateway.CreateUsagePlanKeyInput, optFns ...func(*apigateway.Options)) (*apigateway.CreateUsagePlanKeyOutput, error)
+	DeleteUsagePlanKey(ctx context.Context, params *apigateway.DeleteUsagePlanKeyInput, optFns ...func(*apigateway.Options)) (*apigateway.DeleteUsagePlanKeyOutput, error)
+}
+
 // This is our QN plan slug to API key value cache
 var planSlugToApiKey = make(map[string]*types.ApiKey)
 
-// FindBySlug fetches API keys from API Gateway (if the cache is empty) and finds the key which name is
+// This is our QN plan slug to usage plan ID cache
+var planSlugToUsagePlanId = make(map[string]string)
+
+// FindBySlug fetches API keys from API Gateway (if the slug is not cached) and finds the key which name is
 // same as qnPlanSlug
-func FindByPlanSlug(apiGatewayClient *apigateway.Client, qnPlanSlug string) (apiKey *ApiKey, err error) {
+func FindByPlanSlug(ctx context.Context, apiGatewayClient ApiGatewayClient, qnPlanSlug string) (apiKey *ApiKey, err error) {
 	var keys []types.ApiKey
-	if len(planSlugToApiKey) == 0 {
-		keys, err = fetchApiKeys(apiGatewayClient)
+	if _, ok := planSlugToApiKey[qnPlanSlug]; !ok {
+		keys, err = fetchApiKeys(ctx, apiGatewayClient, qnPlanSlug)
 		if err != nil {
 			return
 		}
 		cacheApiKeys(keys)
 	} else {
-		log.Println("api keys are already fetched")
+		log.Println("api key is already fetched")
 	}
 	return findPlanApiKey(qnPlanSlug, planSlugToApiKey)
 }
 
-// fetchApiKeys fetches API keys from API Gateway
-func fetchApiKeys(apiGatewayClient *apigateway.Client) (keys []types.ApiKey, err error) {
+// fetchApiKeys fetches API keys which names start with nameQuery from API Gateway
+func fetchApiKeys(ctx context.Context, apiGatewayClient ApiGatewayClient, nameQuery string) (keys []types.ApiKey, err error) {
 	if keyDynamodb.ShouldUseLocal() {
 		// Testing: return test keys
 
@@ -47,14 +67,18 @@ func fetchApiKeys(apiGatewayClient *apigateway.Client) (keys []types.ApiKey, err
 
 	// Production: fetch real API key
 
-	keysOutput, err := apiGatewayClient.GetApiKeys(context.TODO(), &apigateway.GetApiKeysInput{
+	paginator := apigateway.NewGetApiKeysPaginator(apiGatewayClient, &apigateway.GetApiKeysInput{
 		IncludeValues: aws.Bool(true),
+		NameQuery:     aws.String(nameQuery),
 	})
-	if err != nil {
-		err = fmt.Errorf("cannot get api keys: %w", err)
-		return
+	for paginator.HasMorePages() {
+		keysOutput, pageErr := paginator.NextPage(ctx)
+		if pageErr != nil {
+			err = fmt.Errorf("cannot get api keys: %w", pageErr)
+			return
+		}
+		keys = append(keys, keysOutput.Items...)
 	}
-	keys = keysOutput.Items
 	return
 }
 
@@ -67,9 +91,11 @@ func cacheApiKeys(keys []types.ApiKey) {
 			log.Println("findPlanApiKey: ommiting disabled key:", *apiKey.Name, *apiKey.Id)
 			continue
 		}
+		if strings.HasPrefix(*apiKey.Name, accountApiKeyPrefix) {
+			continue
+		}
 		planSlugToApiKey[*apiKey.Name] = &apiKey
 	}
-	return
 }
 
 // findPlanApiKey performs key lookup, caching the keys if the cache is empty
@@ -80,20 +106,247 @@ func findPlanApiKey(qnPlanSlug string, planToKey map[string]*types.ApiKey) (apiK
 		return
 	}
 	apiKey = &ApiKey{
+		Id:    aws.ToString(key.Id),
 		Name:  *key.Name,
 		Value: *key.Value,
 	}
 	return
 }
 
+// FindUsagePlanId returns ID of the usage plan linked to plan's API key
+func FindUsagePlanId(ctx context.Context, apiGatewayClient ApiGatewayClient, qnPlanSlug string) (usagePlanId string, err error) {
+	if usagePlanId = planSlugToUsagePlanId[qnPlanSlug]; usagePlanId != "" {
+		return
+	}
+
+	planKey, err := FindByPlanSlug(ctx, apiGatewayClient, qnPlanSlug)
+	if err != nil {
+		return
+	}
+
+	if keyDynamodb.ShouldUseLocal() {
+		usagePlanId = "local-" + qnPlanSlug
+		planSlugToUsagePlanId[qnPlanSlug] = usagePlanId
+		return
+	}
+
+	plansOutput, err := apiGatewayClient.GetUsagePlans(ctx, &apigateway.GetUsagePlansInput{
+		KeyId: aws.String(planKey.Id),
+	})
+	if err != nil {
+		err = fmt.Errorf("cannot get usage plans: %w", err)
+		return
+	}
+	if len(plansOutput.Items) == 0 {
+		err = fmt.Errorf("cannot find usage plan for qn plan slug '%s'", qnPlanSlug)
+		return
+	}
+	if len(plansOutput.Items) > 1 {
+		log.Println("more than one usage plan for qn plan slug", qnPlanSlug, "using the first one")
+	}
+
+	usagePlanId = *plansOutput.Items[0].Id
+	planSlugToUsagePlanId[qnPlanSlug] = usagePlanId
+	return
+}
+
+// createAccountApiKey creates a new API key for the account and links it to plan's usage plan.
+// If the key already exists (e.g. we failed to save the account and QN retries), it is reused.
+func createAccountApiKey(ctx context.Context, apiGatewayClient ApiGatewayClient, quicknodeId string, qnPlanSlug string) (apiKey *ApiKey, err error) {
+	usagePlanId, err := FindUsagePlanId(ctx, apiGatewayClient, qnPlanSlug)
+	if err != nil {
+		return
+	}
+
+	name := accountApiKeyPrefix + quicknodeId
+
+	if keyDynamodb.ShouldUseLocal() {
+		log.Println("using TEST account API key")
+		apiKey = &ApiKey{
+			Id:    "local-" + quicknodeId,
+			Name:  name,
+			Value: "local-" + quicknodeId,
+		}
+		return
+	}
+
+	existing, err := findAccountApiKey(ctx, apiGatewayClient, name)
+	if err != nil {
+		return
+	}
+	if existing != nil {
+		log.Println("reusing api key", existing.Id, "of", quicknodeId)
+		if err = relinkApiKey(ctx, apiGatewayClient, existing.Id, usagePlanId); err != nil {
+			return
+		}
+		apiKey = existing
+		return
+	}
+
+	created, err := apiGatewayClient.CreateApiKey(ctx, &apigateway.CreateApiKeyInput{
+		Name:    aws.String(name),
+		Enabled: true,
+	})
+	if err != nil {
+		err = fmt.Errorf("cannot create api key: %w", err)
+		return
+	}
+	apiKey = &ApiKey{
+		Id:    *created.Id,
+		Name:  *created.Name,
+		Value: *created.Value,
+	}
+
+	if err = linkApiKey(ctx, apiGatewayClient, apiKey.Id, usagePlanId); err != nil {
+		// Don't leave the key behind, we will create a new one when QN retries
+		if deleteErr := deleteApiKey(ctx, apiGatewayClient, apiKey.Id); deleteErr != nil {
+			log.Println("removing unlinked api key", apiKey.Id, ":", deleteErr)
+		}
+		apiKey = nil
+	}
+	return
+}
+
+// findAccountApiKey returns the API key named name or nil if there is no such key
+func findAccountApiKey(ctx context.Context, apiGatewayClient ApiGatewayClient, name string) (apiKey *ApiKey, err error) {
+	keys, err := fetchApiKeys(ctx, apiGatewayClient, name)
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		// NameQuery matches prefixes, so qn-account-1 finds qn-account-10 too
+		if aws.ToString(key.Name) != name {
+			continue
+		}
+		apiKey = &ApiKey{
+			Id:    aws.ToString(key.Id),
+			Name:  aws.ToString(key.Name),
+			Value: aws.ToString(key.Value),
+		}
+		return
+	}
+	return
+}
+
+// relinkApiKey makes sure that the key is linked to usagePlanId only
+func relinkApiKey(ctx context.Context, apiGatewayClient ApiGatewayClient, keyId string, usagePlanId string) (err error) {
+	plansOutput, err := apiGatewayClient.GetUsagePlans(ctx, &apigateway.GetUsagePlansInput{
+		KeyId: aws.String(keyId),
+	})
+	if err != nil {
+		err = fmt.Errorf("cannot get usage plans of api key %s: %w", keyId, err)
+		return
+	}
+	linked := false
+	for _, plan := range plansOutput.Items {
+		if aws.ToString(plan.Id) == usagePlanId {
+			linked = true
+			continue
+		}
+		if err = unlinkApiKey(ctx, apiGatewayClient, keyId, aws.ToString(plan.Id)); err != nil {
+			return
+		}
+	}
+	if linked {
+		return
+	}
+	return linkApiKey(ctx, apiGatewayClient, keyId, usagePlanId)
+}
+
+// moveAccountApiKey unlinks API key from the old plan's usage plan and links it to the new one
+func moveAccountApiKey(ctx context.Context, apiGatewayClient ApiGatewayClient, keyId string, oldPlanSlug string, newPlanSlug string) (err error) {
+	oldUsagePlanId, err := FindUsagePlanId(ctx, apiGatewayClient, oldPlanSlug)
+	if err != nil {
+		return
+	}
+	newUsagePlanId, err := FindUsagePlanId(ctx, apiGatewayClient, newPlanSlug)
+	if err != nil {
+		return
+	}
+
+	if keyDynamodb.ShouldUseLocal() {
+		log.Println("moving TEST account API key from", oldUsagePlanId, "to", newUsagePlanId)
+		return
+	}
+
+	// API Gateway does not allow a key to be in two usage plans of the same stage,
+	// so we have to unlink first
+	if err = unlinkApiKey(ctx, apiGatewayClient, keyId, oldUsagePlanId); err != nil {
+		return
+	}
+	if err = linkApiKey(ctx, apiGatewayClient, keyId, newUsagePlanId); err != nil {
+		// Try to restore the old link, so the account can still make requests
+		if restoreErr := linkApiKey(ctx, apiGatewayClient, keyId, oldUsagePlanId); restoreErr != nil {
+			log.Println("restoring usage plan of api key", keyId, ":", restoreErr)
+		}
+	}
+	return
+}
+
+func linkApiKey(ctx context.Context, apiGatewayClient ApiGatewayClient, keyId string, usagePlanId string) (err error) {
+	_, err = apiGatewayClient.CreateUsagePlanKey(ctx, &apigateway.CreateUsagePlanKeyInput{
+		KeyId:       aws.String(keyId),
+		KeyType:     aws.String("API_KEY"),
+		UsagePlanId: aws.String(usagePlanId),
+	})
+	if err != nil {
+		var conflict *types.ConflictException
+		if errors.As(err, &conflict) {
+			// already linked
+			return nil
+		}
+		err = fmt.Errorf("cannot link api key %s to usage plan %s: %w", keyId, usagePlanId, err)
+	}
+	return
+}
+
+func unlinkApiKey(ctx context.Context, apiGatewayClient ApiGatewayClient, keyId string, usagePlanId string) (err error) {
+	_, err = apiGatewayClient.DeleteUsagePlanKey(ctx, &apigateway.DeleteUsagePlanKeyInput{
+		KeyId:       aws.String(keyId),
+		UsagePlanId: aws.String(usagePlanId),
+	})
+	if err != nil {
+		var notFound *types.NotFoundException
+		if errors.As(err, &notFound) {
+			// already unlinked
+			return nil
+		}
+		err = fmt.Errorf("cannot unlink api key %s from usage plan %s: %w", keyId, usagePlanId, err)
+	}
+	return
+}
+
+// deleteApiKey removes API key. Deleting a key also removes its usage plan links.
+func deleteApiKey(ctx context.Context, apiGatewayClient ApiGatewayClient, keyId string) (err error) {
+	if keyDynamodb.ShouldUseLocal() {
+		log.Println("deleting TEST account API key", keyId)
+		return
+	}
+
+	_, err = apiGatewayClient.DeleteApiKey(ctx, &apigateway.DeleteApiKeyInput{
+		ApiKey: aws.String(keyId),
+	})
+	if err != nil {
+		var notFound *types.NotFoundException
+		if errors.As(err, &notFound) {
+			// already deleted
+			return nil
+		}
+		err = fmt.Errorf("cannot delete api key %s: %w", keyId, err)
+	}
+	return
+}
+
 func loadTestApiKeys() []types.ApiKey {
 	return []types.ApiKey{
 		{
+			Id:      aws.String("IntegrationTestPlan"),
 			Name:    aws.String("IntegrationTestPlan"),
 			Value:   aws.String("int3gr4ti0n"),
 			Enabled: true,
 		},
 		{
+			Id:      aws.String("IntegrationTestPlan2"),
 			Name:    aws.String("IntegrationTestPlan2"),
 			Value:   aws.String("int3gr4ti0n2"),
 			Enabled: true,
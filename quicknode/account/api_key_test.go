@@ -1,10 +1,15 @@
 package qnaccount
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway"
 	"github.com/aws/aws-sdk-go-v2/service/apigateway/types"
 )
 
@@ -63,3 +68,250 @@ func Test_findPlanApiKey(t *testing.T) {
 		})
 	}
 }
+
+type fakeApiGateway struct {
+	keys       map[string]types.ApiKey
+	usagePlans map[string][]string
+	linkErr    error
+	nextId     int
+}
+
+func newFakeApiGateway() *fakeApiGateway {
+	planKeys := []string{"plan-a", "plan-b"}
+	f := &fakeApiGateway{
+		keys:       make(map[string]types.ApiKey),
+		usagePlans: make(map[string][]string),
+	}
+	for _, slug := range planKeys {
+		f.keys[slug] = types.ApiKey{
+			Id:      aws.String(slug),
+			Name:    aws.String(slug),
+			Value:   aws.String(slug + "-value"),
+			Enabled: true,
+		}
+		f.usagePlans["usage-"+slug] = []string{slug}
+	}
+	return f
+}
+
+func (f *fakeApiGateway) usagePlanOf(keyId string) (usagePlanIds []string) {
+	for usagePlanId, keyIds := range f.usagePlans {
+		for _, id := range keyIds {
+			if id == keyId {
+				usagePlanIds = append(usagePlanIds, usagePlanId)
+			}
+		}
+	}
+	return
+}
+
+func (f *fakeApiGateway) GetApiKeys(ctx context.Context, params *apigateway.GetApiKeysInput, optFns ...func(*apigateway.Options)) (*apigateway.GetApiKeysOutput, error) {
+	output := &apigateway.GetApiKeysOutput{}
+	for _, key := range f.keys {
+		if strings.HasPrefix(*key.Name, aws.ToString(params.NameQuery)) {
+			output.Items = append(output.Items, key)
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeApiGateway) GetUsagePlans(ctx context.Context, params *apigateway.GetUsagePlansInput, optFns ...func(*apigateway.Options)) (*apigateway.GetUsagePlansOutput, error) {
+	output := &apigateway.GetUsagePlansOutput{}
+	for _, id := range f.usagePlanOf(*params.KeyId) {
+		output.Items = append(output.Items, types.UsagePlan{Id: aws.String(id)})
+	}
+	return output, nil
+}
+
+func (f *fakeApiGateway) CreateApiKey(ctx context.Context, params *apigateway.CreateApiKeyInput, optFns ...func(*apigateway.Options)) (*apigateway.CreateApiKeyOutput, error) {
+	f.nextId++
+	id := fmt.Sprint("key-", f.nextId)
+	f.keys[id] = types.ApiKey{
+		Id:      aws.String(id),
+		Name:    params.Name,
+		Value:   aws.String(id + "-value"),
+		Enabled: params.Enabled,
+	}
+	return &apigateway.CreateApiKeyOutput{
+		Id:    aws.String(id),
+		Name:  params.Name,
+		Value: aws.String(id + "-value"),
+	}, nil
+}
+
+func (f *fakeApiGateway) DeleteApiKey(ctx context.Context, params *apigateway.DeleteApiKeyInput, optFns ...func(*apigateway.Options)) (*apigateway.DeleteApiKeyOutput, error) {
+	if _, ok := f.keys[*params.ApiKey]; !ok {
+		return nil, &types.NotFoundException{}
+	}
+	delete(f.keys, *params.ApiKey)
+	for _, usagePlanId := range f.usagePlanOf(*params.ApiKey) {
+		f.DeleteUsagePlanKey(ctx, &apigateway.DeleteUsagePlanKeyInput{KeyId: params.ApiKey, UsagePlanId: aws.String(usagePlanId)})
+	}
+	return &apigateway.DeleteApiKeyOutput{}, nil
+}
+
+func (f *fakeApiGateway) CreateUsagePlanKey(ctx context.Context, params *apigateway.CreateUsagePlanKeyInput, optFns ...func(*apigateway.Options)) (*apigateway.CreateUsagePlanKeyOutput, error) {
+	if f.linkErr != nil {
+		return nil, f.linkErr
+	}
+	if len(f.usagePlanOf(*params.KeyId)) > 0 {
+		return nil, &types.ConflictException{}
+	}
+	f.usagePlans[*params.UsagePlanId] = append(f.usagePlans[*params.UsagePlanId], *params.KeyId)
+	return &apigateway.CreateUsagePlanKeyOutput{}, nil
+}
+
+func (f *fakeApiGateway) DeleteUsagePlanKey(ctx context.Context, params *apigateway.DeleteUsagePlanKeyInput, optFns ...func(*apigateway.Options)) (*apigateway.DeleteUsagePlanKeyOutput, error) {
+	keyIds := f.usagePlans[*params.UsagePlanId]
+	for i, id := range keyIds {
+		if id == *params.KeyId {
+			f.usagePlans[*params.UsagePlanId] = append(keyIds[:i], keyIds[i+1:]...)
+			return &apigateway.DeleteUsagePlanKeyOutput{}, nil
+		}
+	}
+	return nil, &types.NotFoundException{}
+}
+
+func resetApiKeyCache() {
+	planSlugToApiKey = make(map[string]*types.ApiKey)
+	planSlugToUsagePlanId = make(map[string]string)
+}
+
+func TestAccountApiKeyLifecycle(t *testing.T) {
+	resetApiKeyCache()
+	client := newFakeApiGateway()
+
+	a := &Account{QuicknodeId: "qn-1", Plan: "plan-a"}
+	if err := a.CreateApiKey(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	if v := a.ApiKey.Name; v != "qn-account-qn-1" {
+		t.Fatal("wrong key name:", v)
+	}
+	if v := client.usagePlanOf(a.ApiKey.Id); !reflect.DeepEqual(v, []string{"usage-plan-a"}) {
+		t.Fatal("wrong usage plan:", v)
+	}
+	keyId := a.ApiKey.Id
+
+	// Provisioning another endpoint keeps the key
+	if err := a.CreateApiKey(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	if v := a.ApiKey.Id; v != keyId {
+		t.Fatal("key changed:", v)
+	}
+
+	// Accounts on the same plan get different keys
+	b := &Account{QuicknodeId: "qn-2", Plan: "plan-a"}
+	if err := b.CreateApiKey(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	if b.ApiKey.Id == keyId || b.ApiKey.Value == a.ApiKey.Value {
+		t.Fatal("key shared between accounts")
+	}
+
+	// Plan change moves the key
+	updated := &Account{QuicknodeId: "qn-1", Plan: "plan-b"}
+	if err := updated.UpdateApiKey(context.Background(), client, a); err != nil {
+		t.Fatal(err)
+	}
+	if v := updated.ApiKey.Id; v != keyId {
+		t.Fatal("key changed on update:", v)
+	}
+	if v := client.usagePlanOf(keyId); !reflect.DeepEqual(v, []string{"usage-plan-b"}) {
+		t.Fatal("wrong usage plan after update:", v)
+	}
+
+	// Deprovisioning removes the key, twice is fine
+	if err := updated.DeleteApiKey(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.keys[keyId]; ok {
+		t.Fatal("key not deleted")
+	}
+	if v := client.usagePlanOf(keyId); len(v) != 0 {
+		t.Fatal("key still linked:", v)
+	}
+	updated.ApiKey.Id = keyId
+	updated.ApiKey.Name = "qn-account-qn-1"
+	if err := updated.DeleteApiKey(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccountApiKeyShared(t *testing.T) {
+	resetApiKeyCache()
+	client := newFakeApiGateway()
+
+	// Older accounts use the plan key
+	previous := &Account{
+		QuicknodeId: "qn-1",
+		Plan:        "plan-a",
+		ApiKey:      ApiKey{Name: "plan-a", Value: "plan-a-value"},
+	}
+	if err := previous.DeleteApiKey(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.keys["plan-a"]; !ok {
+		t.Fatal("plan key deleted")
+	}
+
+	a := &Account{QuicknodeId: "qn-1", Plan: "plan-a"}
+	if err := a.UpdateApiKey(context.Background(), client, previous); err != nil {
+		t.Fatal(err)
+	}
+	if v := a.ApiKey.Name; v != "qn-account-qn-1" {
+		t.Fatal("wrong key name:", v)
+	}
+	if v := client.usagePlanOf(a.ApiKey.Id); !reflect.DeepEqual(v, []string{"usage-plan-a"}) {
+		t.Fatal("wrong usage plan:", v)
+	}
+}
+
+func TestAccountApiKeyLinkError(t *testing.T) {
+	resetApiKeyCache()
+	client := newFakeApiGateway()
+	client.linkErr = errors.New("link failed")
+
+	a := &Account{QuicknodeId: "qn-1", Plan: "plan-a"}
+	if err := a.CreateApiKey(context.Background(), client); err == nil {
+		t.Fatal("expected error")
+	}
+	if v := len(client.keys); v != 2 {
+		t.Fatal("unlinked key left behind:", v)
+	}
+	if v := a.ApiKey; v != (ApiKey{}) {
+		t.Fatal("key set on error:", v)
+	}
+}
+
+func TestAccountApiKeyRetry(t *testing.T) {
+	resetApiKeyCache()
+	client := newFakeApiGateway()
+
+	// The key was created, but saving the account failed
+	a := &Account{QuicknodeId: "qn-1", Plan: "plan-a"}
+	if err := a.CreateApiKey(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	keyId := a.ApiKey.Id
+	other := &Account{QuicknodeId: "qn-10", Plan: "plan-a"}
+	if err := other.CreateApiKey(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+
+	// QN retries with a new plan
+	retried := &Account{QuicknodeId: "qn-1", Plan: "plan-b"}
+	if err := retried.CreateApiKey(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	if v := retried.ApiKey; v != a.ApiKey {
+		t.Fatal("wrong key:", v)
+	}
+	if v := len(client.keys); v != 4 {
+		t.Fatal("wrong key count:", v)
+	}
+	if v := client.usagePlanOf(keyId); !reflect.DeepEqual(v, []string{"usage-plan-b"}) {
+		t.Fatal("wrong usage plan:", v)
+	}
+}
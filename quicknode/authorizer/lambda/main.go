@@ -9,6 +9,7 @@ import (
 	keyConfig "github.com/TrueBlocks/trueblocks-key/config/pkg"
 	qnaccount "github.com/TrueBlocks/trueblocks-key/quicknode/account"
 	keyDynamodb "github.com/TrueBlocks/trueblocks-key/quicknode/keyDynamodb"
+	usage "github.com/TrueBlocks/trueblocks-key/usage/pkg"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -106,11 +107,15 @@ func HandleRequest(ctx context.Context, event events.APIGatewayCustomAuthorizerR
 	// PrincipalID is something that uniquely identifies the account
 	result.PrincipalID = account.QuicknodeId
 	result.UsageIdentifierKey = account.ApiKey.Value
+	effect := "allow"
+	if usage.Authorize(ctx, cnf, dynamoClient, account.QuicknodeId, account.Plan, &result) == usage.StatusHardLimit {
+		effect = "deny"
+	}
 	result.PolicyDocument = events.APIGatewayCustomAuthorizerPolicy{
 		Version: "2012-10-17",
 		Statement: []events.IAMPolicyStatement{
 			{
-				Effect: "allow",
+				Effect: effect,
 				Action: []string{
 					"execute-api:Invoke",
 				},
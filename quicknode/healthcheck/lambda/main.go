@@ -2,9 +2,7 @@ package main
 
 import (
 	"context"
-	"log"
 
-	awshelper "github.com/TrueBlocks/trueblocks-key/awshelper/pkg"
 	keyConfig "github.com/TrueBlocks/trueblocks-key/config/pkg"
 	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
 	"github.com/aws/aws-lambda-go/events"
@@ -12,7 +10,7 @@ import (
 )
 
 var cnf *keyConfig.ConfigFile
-var dbConn *database.Connection
+var dbProvider *database.Provider
 
 func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (response events.APIGatewayProxyResponse, err error) {
 	// For now, we will just try to connect to the index database. If we can connect, then
@@ -23,11 +21,16 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 		}
 	}
 
-	if dbConn == nil {
-		if err = setupDbConnection(ctx); err != nil {
+	if dbProvider == nil {
+		if dbProvider, err = database.NewProvider(cnf, "default", "mainnet"); err != nil {
 			return
 		}
 	}
+	dbConn, err := dbProvider.Connection(ctx)
+	if err != nil {
+		return
+	}
+	defer dbProvider.Release(context.WithoutCancel(ctx), dbConn)
 
 	response = events.APIGatewayProxyResponse{
 		Body:       `{ "status": "ok" }`,
@@ -41,40 +44,6 @@ func loadConfig() (err error) {
 	return
 }
 
-func setupDbConnection(ctx context.Context) (err error) {
-	var user string
-	var password string
-	secretId := cnf.Database["default"].AwsSecret
-	if secretId != "" {
-		log.Println("using Secrets Manager secret as DB password")
-		secretValue, err := awshelper.FetchUsernamePasswordSecret(secretId)
-		if err != nil {
-			return err
-		}
-		user = secretValue.Username
-		password = secretValue.Password
-	} else {
-		log.Println("using configuration DB password")
-		user = cnf.Database["default"].User
-		password = cnf.Database["default"].Password
-	}
-
-	dbConn = &database.Connection{
-		Chain:    "mainnet",
-		Host:     cnf.Database["default"].Host,
-		Port:     cnf.Database["default"].Port,
-		Database: cnf.Database["default"].Database,
-		User:     user,
-		Password: password,
-	}
-
-	log.Println(dbConn.String())
-
-	return dbConn.Connect(ctx)
-}
-
 func main() {
-	defer dbConn.Close(context.TODO())
-
 	lambda.Start(HandleRequest)
 }
@@ -19,6 +19,13 @@ func HandleDeprovision(c *gin.Context) {
 		return
 	}
 
+	// Remove the API key first, so a failure leaves the account for QN to retry
+	if err = account.DeleteApiKey(c.Request.Context(), apiGatewayClient); err != nil {
+		log.Println("deprovision: deleting API key", account.QuicknodeId, ":", err)
+		resp.abortWithInternalError()
+		return
+	}
+
 	// Remove the account
 	if err = account.DynamoDelete(); err != nil {
 		log.Println("deprovision: saving account", account.QuicknodeId, ":", err)
@@ -36,7 +36,7 @@ func HandleProvision(c *gin.Context) {
 		account.ActivateEndpoint(eid)
 	}
 
-	if err := account.LoadApiKey(apiGatewayClient); err != nil {
+	if err := account.CreateApiKey(c.Request.Context(), apiGatewayClient); err != nil {
 		log.Println(err)
 		resp.abortWithInternalError()
 		return
@@ -33,8 +33,8 @@ func HandleUpdate(c *gin.Context) {
 		return
 	}
 
-	// load possibly new API key
-	if err := account.LoadApiKey(apiGatewayClient); err != nil {
+	// move the API key to the new plan
+	if err := account.UpdateApiKey(c.Request.Context(), apiGatewayClient, oldAccount); err != nil {
 		log.Println(err)
 		resp.abortWithInternalError()
 		return
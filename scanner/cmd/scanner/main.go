@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
-	"os"
+	"math"
 
+	manifest "github.com/TrueBlocks/trueblocks-key/manifest/pkg"
 	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query"
-	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query/chunk"
 )
 
 var runEnv *CmdRunEnv
@@ -15,25 +17,44 @@ func init() {
 }
 
 func main() {
-	chain := "mainnet"
-	address := os.Args[1]
+	chain := flag.String("chain", "mainnet", "chain name")
+	workers := flag.Int("workers", query.DefaultWorkers, "number of chunks to search at once")
+	manifestPath := flag.String("manifest", "", "path to manifest.json, missing chunks are downloaded before searching")
+	gateway := flag.String("gateway", manifest.DefaultGateway, "IPFS gateway used to download chunks")
+	flag.Parse()
+
+	address := flag.Arg(0)
 	if address == "" {
 		log.Fatalln("Address required")
 	}
-	indexPath := os.Args[2]
+	indexPath := flag.Arg(1)
 	if indexPath == "" {
 		log.Fatalln("indexPath required")
 	}
 	runEnv.IndexPath = indexPath
 
-	results := make(chan chunk.AppearanceRecord, 100)
-	go func() {
-		if err := query.Find(chain, address, runEnv, results); err != nil {
-			log.Fatalln("find error:", err)
+	if *manifestPath != "" {
+		m, err := manifest.ReadFile(*manifestPath)
+		if err != nil {
+			log.Fatalln("reading manifest:", err)
+		}
+		downloader := &manifest.Downloader{Gateway: *gateway, Workers: *workers, Blooms: true, Indexes: true}
+		if _, err = downloader.Download(context.Background(), m, indexPath); err != nil {
+			log.Fatalln("downloading chunks:", err)
 		}
-	}()
+	}
+
+	ranges, err := runEnv.Ranges(*chain)
+	if err != nil {
+		log.Fatalln("reading ranges:", err)
+	}
+
+	apps, err := query.Find(context.Background(), *chain, ranges, address, 0, math.MaxUint64, runEnv, *workers)
+	if err != nil {
+		log.Fatalln("find error:", err)
+	}
 
-	for app := range results {
+	for _, app := range apps {
 		log.Println(app.BlockNumber, app.TransactionId)
 	}
 }
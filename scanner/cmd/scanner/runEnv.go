@@ -1,58 +1,59 @@
 package main
 
 import (
+	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"path"
 	"strings"
+
+	manifest "github.com/TrueBlocks/trueblocks-key/manifest/pkg"
 )
 
 type CmdRunEnv struct {
 	IndexPath string
 }
 
-func (c *CmdRunEnv) Blooms(chain string) (map[string]string, error) {
+// Ranges reads ranges from manifest.json in IndexPath. If there is no manifest,
+// it lists the blooms directory.
+func (c *CmdRunEnv) Ranges(chain string) ([]string, error) {
+	m, err := manifest.ReadFile(path.Join(c.IndexPath, "manifest.json"))
+	if err == nil {
+		return m.Ranges(), nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
 	bloomPath := path.Join(c.IndexPath, "blooms/")
 	files, err := os.ReadDir(bloomPath)
 	if err != nil {
 		return nil, err
 	}
 
-	result := make(map[string]string, len(files))
+	result := make([]string, 0, len(files))
 
 	for _, info := range files {
 		if info.IsDir() {
 			continue
 		}
 
-		rawFileName := info.Name()
-		fileName := bloomPath + "/" + rawFileName
-
-		if !strings.Contains(fileName, ".bloom") {
+		fileName := info.Name()
+		if !strings.HasSuffix(fileName, ".bloom") {
 			continue // sometimes there are .gz files in this folder, for example
 		}
 
-		result[rawFileName] = fileName
+		result = append(result, strings.TrimSuffix(fileName, ".bloom"))
 	}
 
 	return result, nil
 }
 
-func (c *CmdRunEnv) ReadBloom(fileName string) (io.ReadSeekCloser, error) {
-	f, err := os.Open(fileName)
-	if err != nil {
-		return nil, err
-	}
-	return f, nil
+func (c *CmdRunEnv) ReadBloom(chain string, blockRange string) (io.ReadSeekCloser, error) {
+	return os.Open(path.Join(c.IndexPath, "blooms/", blockRange+".bloom"))
 }
 
 func (c *CmdRunEnv) ReadChunk(chain string, blockRange string) (io.ReadSeekCloser, error) {
-	indexFilename := path.Join(c.IndexPath, "finalized/", blockRange+".bin")
-	// indexFilename := config.GetPathToIndex(chain) + "finalized/" + index.ToIndexPath(blockRange)
-	f, err := os.Open(indexFilename)
-	if err != nil {
-		return nil, err
-	}
-
-	return f, nil
+	return os.Open(path.Join(c.IndexPath, "finalized/", blockRange+".bin"))
 }
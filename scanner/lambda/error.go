@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var ErrInternal = errors.New(http.StatusText(http.StatusInternalServerError))
+
+// RpcError works like the one in query lambda, so both report errors in the same way
+type RpcError struct {
+	PublicError string
+	internal    error
+	statusCode  int
+}
+
+func NewRpcError(internal error, statusCode int, public string) *RpcError {
+	return &RpcError{
+		PublicError: public,
+		internal:    internal,
+		statusCode:  statusCode,
+	}
+}
+
+func (r *RpcError) Error() string {
+	return r.internal.Error()
+}
+
+func (r *RpcError) Report(response *events.APIGatewayProxyResponse) {
+	log.Println(r.internal)
+	response.StatusCode = r.statusCode
+	response.Body = strconv.Quote(r.PublicError)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
+	queueItem "github.com/TrueBlocks/trueblocks-key/queue/consume/pkg/item"
+	scanner "github.com/TrueBlocks/trueblocks-key/searcher/pkg/query"
+)
+
+const chain = "mainnet"
+const defaultAppearancesLimit = 100
+
+var ErrTimestampsUnsupported = errors.New("fromTimestamp and toTimestamp are not supported")
+var ErrIncludeUnsupported = errors.New("include is not supported")
+
+func handleGetAppearances(ctx context.Context, rpcRequest *query.RpcRequest) (response *query.RpcResponse[[]database.PublicAppearance], err error) {
+	rpcParams, err := rpcRequest.AppearancesParams()
+	if err != nil {
+		err = NewRpcError(err, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if err = rpcParams.Validate(); err != nil {
+		err = NewRpcError(err, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	param := rpcParams.Get()
+	if err = param.Validate(); err != nil {
+		err = NewRpcError(err, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Chunks don't keep timestamps nor transaction hashes
+	if param.FromTimestamp != nil || param.ToTimestamp != nil {
+		err = NewRpcError(ErrTimestampsUnsupported, http.StatusBadRequest, ErrTimestampsUnsupported.Error())
+		return
+	}
+	if len(param.Include) > 0 {
+		err = NewRpcError(ErrIncludeUnsupported, http.StatusBadRequest, ErrIncludeUnsupported.Error())
+		return
+	}
+
+	limit := param.PerPage
+	if limit == 0 {
+		limit = defaultAppearancesLimit
+	}
+	limit = max(min(limit, MAX_LIMIT), query.MinSafePerPage)
+
+	ranges, err := runEnv.Ranges(chain)
+	if err != nil {
+		log.Println("reading ranges:", err)
+		err = ErrInternal
+		return
+	}
+	lastIndexedBlock, err := scanner.LastBlock(ranges)
+	if err != nil {
+		log.Println("reading last indexed block:", err)
+		err = ErrInternal
+		return
+	}
+
+	meta := &query.Meta{
+		Address: param.Address,
+	}
+	meta.SetLastIndexedBlock(uint(lastIndexedBlock))
+
+	lastBlock, err := param.LastBlockNumber()
+	if err != nil {
+		err = NewRpcError(err, http.StatusBadRequest, err.Error())
+		return
+	}
+	specialPageId, pageId, err := param.PageIdValue()
+	if err != nil {
+		err = NewRpcError(err, http.StatusBadRequest, "invalid pageId")
+		return
+	}
+	if lastBlock == nil {
+		// nil means "latest"
+		lbn := uint(lastIndexedBlock)
+		lastBlock = &lbn
+	}
+	if pageId != nil {
+		// pageId.LastBlock takes precedence before query's lastBlock
+		bn := uint(pageId.LastBlock)
+		lastBlock = &bn
+	}
+
+	// Only chunks that can hold the requested page are searched: the first page needs the
+	// whole set for its bounds, but the next (older) page ends at the last seen appearance
+	// and the previous (newer) one starts there.
+	scanFirstBlock, scanLastBlock := uint64(0), uint64(*lastBlock)
+	if pageId != nil {
+		if pageId.DirectionNextPage {
+			scanFirstBlock = uint64(pageId.EarliestInSet.BlockNumber)
+			scanLastBlock = min(scanLastBlock, uint64(pageId.LastSeen.BlockNumber))
+		} else {
+			scanFirstBlock = uint64(pageId.LastSeen.BlockNumber)
+		}
+	}
+
+	records, err := scanner.Find(ctx, chain, ranges, param.Address, scanFirstBlock, scanLastBlock, runEnv, WORKERS)
+	if err != nil {
+		log.Println("searching chunks:", err)
+		err = ErrInternal
+		return
+	}
+	log.Println("Found", len(records), "appearances of", param.Address)
+
+	// Chunks are immutable, so every page reads the same set and we can page the window
+	// like the database does. Watermark isn't needed for the same reason.
+	store := database.NewMemoryStore()
+	apps := make([]queueItem.Appearance, 0, len(records))
+	for _, record := range records {
+		apps = append(apps, queueItem.Appearance{
+			Address:          param.Address,
+			BlockNumber:      record.BlockNumber,
+			TransactionIndex: record.TransactionId,
+		})
+	}
+	if err = store.InsertAppearanceBatch(ctx, apps); err != nil {
+		log.Println("loading appearances:", err)
+		err = ErrInternal
+		return
+	}
+
+	var items []database.Appearance
+	var bounds database.AppearancesDatasetBounds
+	switch specialPageId {
+	case query.PageIdLatest, query.PageIdEarliest:
+		items, _, err = store.FetchAppearancesFirstPage(ctx, specialPageId == query.PageIdEarliest, param.Address, 0, *lastBlock, limit)
+		if err == nil && len(items) > 0 {
+			bounds, err = store.FetchAppearancesDatasetBounds(ctx, param.Address, 0, *lastBlock)
+		}
+	default:
+		firstBlock := uint(pageId.EarliestInSet.BlockNumber)
+		items, err = store.FetchAppearancesPage(ctx, pageId.DirectionNextPage, param.Address, firstBlock, *lastBlock, limit, uint(pageId.LastSeen.BlockNumber), uint(pageId.LastSeen.TransactionIndex), 0)
+		bounds = database.AppearancesDatasetBounds{
+			Latest:   pageId.LatestInSet,
+			Earliest: pageId.EarliestInSet,
+		}
+	}
+	if err != nil {
+		log.Println("paging appearances:", err)
+		err = ErrInternal
+		return
+	}
+
+	meta.PreviousPageId, meta.NextPageId = query.NewPageIds(items, *lastBlock, 0, &bounds)
+
+	response = &query.RpcResponse[[]database.PublicAppearance]{
+		JsonRpc: "2.0",
+		Id:      rpcRequest.Id,
+		Result: query.Result[[]database.PublicAppearance]{
+			Data: database.AppearanceSliceToPublicSlice(items),
+			Meta: meta,
+		},
+	}
+	return
+}
@@ -3,57 +3,62 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
 
-	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query"
-	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query/chunk"
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
-type Response struct {
-	Txs []chunk.AppearanceRecord `json:"txs"`
-}
-
 var runEnv *LambdaRunEnv
 
 func init() {
-	runEnv = &LambdaRunEnv{}
+	setup()
+	runEnv = &LambdaRunEnv{IndexPath: INDEX_PATH}
 }
 
+// HandleRequest serves tb_getAppearances from Unchained Index chunks, so we can answer
+// queries for ranges that are not in the database yet
 func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (response events.APIGatewayProxyResponse, err error) {
-	address := request.QueryStringParameters["address"]
-	results := make(chan chunk.AppearanceRecord, 100)
-
-	go func() {
-		if err := query.Find("mainnet", address, runEnv, results); err != nil {
-			log.Println("find error: %w", err)
-		}
-	}()
+	rpcRequest := &query.RpcRequest{}
+	if err = json.Unmarshal([]byte(request.Body), rpcRequest); err != nil {
+		NewRpcError(err, http.StatusBadRequest, "invalid JSON").Report(&response)
+		err = nil
+		return
+	}
 
-	var records []chunk.AppearanceRecord
-	for app := range results {
-		records = append(records, app)
+	var r any
+	switch rpcRequest.Method {
+	case query.MethodGetAppearances:
+		r, err = handleGetAppearances(ctx, rpcRequest)
+	default:
+		err = fmt.Errorf("unsupported method: %s", rpcRequest.Method)
+		err = NewRpcError(err, http.StatusBadRequest, err.Error())
 	}
 
-	log.Println("Found", len(records), "appearances of", address)
+	if err != nil {
+		if rpcErr, ok := err.(*RpcError); ok {
+			rpcErr.Report(&response)
+			err = nil
+		} else {
+			log.Println(err)
+		}
 
-	bodyResponse := &Response{
-		Txs: records,
+		return
 	}
 
-	body, err := json.Marshal(bodyResponse)
+	body, err := json.Marshal(r)
 	if err != nil {
+		log.Println("response marshal:", err)
+		err = ErrInternal
 		return
 	}
-
-	// TODO: would returning a simple object and rewriting response in API Gateway
-	// make this faster?
 	response = events.APIGatewayProxyResponse{
 		Body:       string(body),
 		StatusCode: 200,
 	}
-
 	return
 }
 
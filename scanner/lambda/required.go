@@ -1,15 +1,46 @@
 package main
 
 import (
-	"errors"
+	"log"
 	"os"
+	"strconv"
+
+	keyConfig "github.com/TrueBlocks/trueblocks-key/config/pkg"
+	"github.com/TrueBlocks/trueblocks-key/query/pkg/query"
+	scanner "github.com/TrueBlocks/trueblocks-key/searcher/pkg/query"
 )
 
-var BUCKET_NAME string
+// INDEX_PATH is where the index (manifest.json, blooms/ and finalized/) is mounted
+var INDEX_PATH = "/mnt/efs"
+
+// WORKERS is the number of chunks searched at once
+var WORKERS = scanner.DefaultWorkers
 
-func init() {
-	var ok bool
-	if BUCKET_NAME, ok = os.LookupEnv("SCNR_BUCKET_NAME"); !ok {
-		panic(errors.New("env variable SCNR_BUCKET_NAME required"))
+// MAX_LIMIT is the largest perPage that we honor, the same as the database API's
+// (query.maxLimit config)
+var MAX_LIMIT uint = query.MaxSafePerPage
+
+// setup reads the environment. Invalid values are logged and defaults are used instead,
+// so that a typo in the configuration doesn't take the function down.
+func setup() {
+	if value, ok := os.LookupEnv("SCNR_INDEX_PATH"); ok {
+		INDEX_PATH = value
+	}
+	if value, ok := os.LookupEnv("SCNR_WORKERS"); ok {
+		workers, err := strconv.Atoi(value)
+		if err != nil || workers <= 0 {
+			log.Println("env variable SCNR_WORKERS has to be a positive number, got", value, "using", WORKERS)
+		} else {
+			WORKERS = workers
+		}
+	}
+
+	cnf, err := keyConfig.Get("")
+	if err != nil {
+		log.Println("loading config, using default max perPage:", err)
+		return
+	}
+	if confLimit := cnf.Query.MaxLimit; confLimit > 0 && confLimit < MAX_LIMIT {
+		MAX_LIMIT = confLimit
 	}
 }
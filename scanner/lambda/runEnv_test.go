@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLambdaRunEnv_Ranges(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "manifest.json")
+	write := func(ranges string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(manifestPath, []byte(`{"chunks":[`+ranges+`]}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(manifestPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modTime := time.Now().Add(-time.Hour)
+	write(`{"range":"000000000-000000001"}`, modTime)
+
+	runEnv := &LambdaRunEnv{IndexPath: dir}
+	ranges, err := runEnv.Ranges("mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 1 {
+		t.Fatal("wrong ranges:", ranges)
+	}
+
+	// the file is not read again while it's not modified
+	write(`{"range":"000000000-000000001"},{"range":"000000002-000000003"}`, modTime)
+	if ranges, _ := runEnv.Ranges("mainnet"); len(ranges) != 1 {
+		t.Fatal("expected cached ranges:", ranges)
+	}
+
+	write(`{"range":"000000000-000000001"},{"range":"000000002-000000003"}`, modTime.Add(time.Minute))
+	if ranges, _ := runEnv.Ranges("mainnet"); len(ranges) != 2 {
+		t.Fatal("expected ranges of the new manifest:", ranges)
+	}
+}
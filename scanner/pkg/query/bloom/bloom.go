@@ -9,6 +9,7 @@ import (
 	"unsafe"
 
 	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/blkrange"
+	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query/chunk"
 )
 
 const (
@@ -18,8 +19,13 @@ const (
 	BLOOM_WIDTH_IN_BITS = (1048576)
 	// The maximum number of addresses to add to a BloomBytes before creating a new one
 	MAX_ADDRS_IN_BLOOM = 50000
+	// MagicNumber starts every bloom filter file
+	MagicNumber = 0xdead
 )
 
+// ErrInvalidBloom is returned for corrupted bloom filters and blooms in unsupported format
+var ErrInvalidBloom = errors.New("invalid bloom filter")
+
 type bitChecker struct {
 	whichBits [5]uint32
 	offset    uint32
@@ -59,14 +65,28 @@ func NewBloom(reader io.ReadSeeker, bloomName string) (b *Bloom, err error) {
 		Range:  r,
 	}
 
-	// Read header. We ignore it in this PoC
 	if err = binary.Read(b.Reader, binary.LittleEndian, &b.Header); err != nil {
 		return
 	}
+	if b.Header.Magic != MagicNumber {
+		return nil, fmt.Errorf("%w: wrong magic number %#x, expected %#x", ErrInvalidBloom, b.Header.Magic, MagicNumber)
+	}
+	if chunk.Hash(b.Header.Hash) != chunk.SpecVersionHash {
+		return nil, fmt.Errorf("%w: unsupported specification hash %x", ErrInvalidBloom, b.Header.Hash)
+	}
 
 	if err = binary.Read(b.Reader, binary.LittleEndian, &b.Count); err != nil {
 		return
 	}
+
+	fileSize, err := b.Reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	expectedSize := int64(unsafe.Sizeof(b.Header)) + 4 + int64(b.Count)*(4+BLOOM_WIDTH_IN_BYTES)
+	if b.Count < 0 || fileSize != expectedSize {
+		return nil, fmt.Errorf("%w: file size %d does not match header (%d bytes)", ErrInvalidBloom, fileSize, expectedSize)
+	}
 	b.HeaderSize = int64(unsafe.Sizeof(b.Header))
 	_, _ = b.Reader.Seek(int64(b.HeaderSize), io.SeekStart) // Point to the start of Count
 	b.Blooms = make([]BloomBytes, 0, b.Count)
@@ -84,9 +104,13 @@ func (bl *Bloom) WhichBits(addr string) (bits [5]uint32, err error) {
 		return
 	}
 
+	return whichBits([20]byte(slice)), nil
+}
+
+func whichBits(address [20]byte) (bits [5]uint32) {
 	cnt := 0
-	for i := 0; i < len(slice); i += 4 {
-		bytes := slice[i : i+4]
+	for i := 0; i < len(address); i += 4 {
+		bytes := address[i : i+4]
 		bits[cnt] = (binary.BigEndian.Uint32(bytes) % uint32(BLOOM_WIDTH_IN_BITS))
 		cnt++
 	}
@@ -0,0 +1,59 @@
+package bloom
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query/chunk"
+)
+
+// InsertAddress adds address to the last bloom filter. Like chifra, it starts a new
+// filter once the last one holds more than MAX_ADDRS_IN_BLOOM addresses, so the filters
+// depend on the order of inserts (generate inserts addresses in the chunk order).
+func (b *Bloom) InsertAddress(address [20]byte) {
+	if len(b.Blooms) == 0 {
+		b.addBloomBytes()
+	}
+
+	last := &b.Blooms[len(b.Blooms)-1]
+	for _, bit := range whichBits(address) {
+		which := bit / 8
+		index := BLOOM_WIDTH_IN_BYTES - which - 1
+		whence := bit % 8
+		last.Bytes[index] |= byte(1 << whence)
+	}
+	last.NInserted++
+	if last.NInserted > MAX_ADDRS_IN_BLOOM {
+		b.addBloomBytes()
+	}
+}
+
+func (b *Bloom) addBloomBytes() {
+	b.Blooms = append(b.Blooms, BloomBytes{Bytes: make([]byte, BLOOM_WIDTH_IN_BYTES)})
+	b.Count = int32(len(b.Blooms))
+}
+
+// Write writes bloom filters built by InsertAddress in the Unchained Index format
+func (b *Bloom) Write(w io.Writer) error {
+	buffered := bufio.NewWriter(w)
+	header := BloomHeader{
+		Magic: MagicNumber,
+		Hash:  Hash(chunk.SpecVersionHash),
+	}
+	if err := binary.Write(buffered, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	if err := binary.Write(buffered, binary.LittleEndian, int32(len(b.Blooms))); err != nil {
+		return err
+	}
+	for _, bloomBytes := range b.Blooms {
+		if err := binary.Write(buffered, binary.LittleEndian, bloomBytes.NInserted); err != nil {
+			return err
+		}
+		if _, err := buffered.Write(bloomBytes.Bytes); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
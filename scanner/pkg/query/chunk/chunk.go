@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"sort"
@@ -16,8 +17,26 @@ const (
 	HeaderWidth     = 44
 	AddrRecordWidth = 28
 	AppRecordWidth  = 8
+	// MagicNumber starts every chunk
+	MagicNumber = 0xdeadbeef
 )
 
+// ErrInvalidChunk is returned for corrupted chunks and chunks in unsupported format
+var ErrInvalidChunk = errors.New("invalid chunk")
+
+// SpecVersionHash is Keccak hash of "trueblocks-core@v2.0.0-release", the Unchained Index
+// specification version. It's stored in headers of both chunks and blooms.
+var SpecVersionHash = Hash(hashFromHex("6fc0c6dd027719f456c1e50a329f6157767325aa937411fa6e7be9359d9e0046"))
+
+func hashFromHex(s string) (b [32]byte) {
+	decoded, err := hex.DecodeString(s)
+	if err != nil || len(decoded) != 32 {
+		panic("invalid hash: " + s)
+	}
+	copy(b[:], decoded)
+	return
+}
+
 type AddressRecord struct {
 	Address [20]byte `json:"address"`
 	Offset  uint32   `json:"offset"`
@@ -75,17 +94,25 @@ func NewChunkData(reader io.ReadSeeker, fileName string) (chunk *ChunkData, err
 }
 
 func readIndexHeader(fl io.ReadSeeker) (header IndexHeaderRecord, err error) {
-	err = binary.Read(fl, binary.LittleEndian, &header)
-	// if err != nil {
-	// 	return
-	// }
-
-	// Because we call this frequently, we only check that the magic number is correct
-	// we let the caller check the hash if needed
-	// if header.Magic != file.MagicNumber {
-	// 	return header, fmt.Errorf("magic number in file %s is incorrect, expected %d, got %d", fl.Name(), file.MagicNumber, header.Magic)
-	// }
+	if err = binary.Read(fl, binary.LittleEndian, &header); err != nil {
+		return
+	}
+	if header.Magic != MagicNumber {
+		return header, fmt.Errorf("%w: wrong magic number %#x, expected %#x", ErrInvalidChunk, header.Magic, MagicNumber)
+	}
+	if header.Hash != SpecVersionHash {
+		return header, fmt.Errorf("%w: unsupported specification hash %x", ErrInvalidChunk, header.Hash)
+	}
 
+	// Truncated files would give us garbage appearances
+	fileSize, err := fl.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	expectedSize := HeaderWidth + int64(header.AddressCount)*AddrRecordWidth + int64(header.AppearanceCount)*AppRecordWidth
+	if fileSize != expectedSize {
+		return header, fmt.Errorf("%w: file size %d does not match header (%d bytes)", ErrInvalidChunk, fileSize, expectedSize)
+	}
 	return
 }
 
@@ -174,6 +201,10 @@ func (chunk *ChunkData) searchForAddressRecord(address string) int {
 }
 
 func (chunk *ChunkData) ReadAppearanceRecords(addrRecord *AddressRecord) (apps []AppearanceRecord, err error) {
+	if uint64(addrRecord.Offset)+uint64(addrRecord.Count) > uint64(chunk.Header.AppearanceCount) {
+		return nil, fmt.Errorf("%w: address %x appearances end past the appearance table", ErrInvalidChunk, addrRecord.Address)
+	}
+
 	readLocation := int64(HeaderWidth + AddrRecordWidth*chunk.Header.AddressCount + AppRecordWidth*addrRecord.Offset)
 
 	_, err = chunk.Reader.Seek(readLocation, io.SeekStart)
@@ -0,0 +1,74 @@
+package chunk
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// AddressAppearances are all appearances of a single address in a chunk
+type AddressAppearances struct {
+	Address     [20]byte
+	Appearances []AppearanceRecord
+}
+
+// SortAddressAppearances puts addresses and their appearances in the order required by
+// the specification: addresses ascending (as bytes), appearances by block number and
+// transaction id
+func SortAddressAppearances(addresses []AddressAppearances) {
+	slices.SortFunc(addresses, func(a, b AddressAppearances) int {
+		return bytes.Compare(a.Address[:], b.Address[:])
+	})
+	for _, address := range addresses {
+		slices.SortFunc(address.Appearances, func(a, b AppearanceRecord) int {
+			return cmp.Or(
+				cmp.Compare(a.BlockNumber, b.BlockNumber),
+				cmp.Compare(a.TransactionId, b.TransactionId),
+			)
+		})
+	}
+}
+
+// Write writes a chunk in the Unchained Index format. Addresses have to be sorted
+// (see SortAddressAppearances) and unique.
+func Write(w io.Writer, addresses []AddressAppearances) error {
+	header := IndexHeaderRecord{
+		Magic:        MagicNumber,
+		Hash:         SpecVersionHash,
+		AddressCount: uint32(len(addresses)),
+	}
+	for i, address := range addresses {
+		if i > 0 && bytes.Compare(addresses[i-1].Address[:], address.Address[:]) >= 0 {
+			return fmt.Errorf("%w: address %x is out of order or duplicated", ErrInvalidChunk, address.Address)
+		}
+		header.AppearanceCount += uint32(len(address.Appearances))
+	}
+
+	buffered := bufio.NewWriter(w)
+	if err := binary.Write(buffered, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+
+	var offset uint32
+	for _, address := range addresses {
+		record := AddressRecord{
+			Address: address.Address,
+			Offset:  offset,
+			Count:   uint32(len(address.Appearances)),
+		}
+		if err := binary.Write(buffered, binary.LittleEndian, &record); err != nil {
+			return err
+		}
+		offset += record.Count
+	}
+	for _, address := range addresses {
+		if err := binary.Write(buffered, binary.LittleEndian, address.Appearances); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
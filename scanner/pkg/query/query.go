@@ -1,8 +1,12 @@
 package query
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 	"sync"
 
@@ -11,89 +15,135 @@ import (
 	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query/chunk"
 )
 
-func Find(chain string, address string, runEnv RunEnv, results chan chunk.AppearanceRecord) (err error) {
-	defer close(results)
-	foundRangesCh := make(chan string, 100)
+const DefaultWorkers = 16
 
-	go func() {
-		if err := QueryBlooms(chain, address, foundRangesCh, runEnv); err != nil {
-			panic(fmt.Errorf("querying bloom filters: %w", err))
-		}
-	}()
+var ErrNoRanges = errors.New("no index chunks")
+
+// Find returns appearances of address between firstBlock and lastBlock (inclusive),
+// ordered latest first. Chunks outside of this window aren't read. At most workers
+// chunks are searched at once. The first error stops the search.
+func Find(ctx context.Context, chain string, ranges []string, address string, firstBlock uint64, lastBlock uint64, runEnv RunEnv, workers int) (results []chunk.AppearanceRecord, err error) {
+	address = strings.ToLower(address)
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	jobs := make(chan string)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileRange := range jobs {
+				apps, err := search(chain, fileRange, address, runEnv)
+				if err != nil {
+					cancel(err)
+					continue
+				}
+				mutex.Lock()
+				for _, app := range apps {
+					if bn := uint64(app.BlockNumber); bn >= firstBlock && bn <= lastBlock {
+						results = append(results, app)
+					}
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
 
-	for fileRange := range foundRangesCh {
-		apps, err := Extract(chain, fileRange, address, runEnv)
+send:
+	for _, fileRange := range ranges {
+		blkRange, err := blkrange.FromFilename(fileRange)
 		if err != nil {
-			panic(err)
+			cancel(fmt.Errorf("invalid chunk range %s: %w", fileRange, err))
+			break
 		}
-		for _, app := range apps {
-			results <- app
+		if blkRange[0] > lastBlock || blkRange[1] < firstBlock {
+			continue
+		}
+		select {
+		case jobs <- fileRange:
+		case <-ctx.Done():
+			break send
 		}
 	}
+	close(jobs)
+	wg.Wait()
+
+	if err = context.Cause(ctx); err != nil {
+		return nil, err
+	}
 
+	slices.SortFunc(results, func(a, b chunk.AppearanceRecord) int {
+		if c := cmp.Compare(b.BlockNumber, a.BlockNumber); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.TransactionId, a.TransactionId)
+	})
 	return
 }
 
-func QueryBlooms(chain string, address string, foundRangesCh chan string, runEnv RunEnv) error {
-	blooms, err := runEnv.Blooms(chain)
-	if err != nil {
-		return err
+// LastBlock returns the last block covered by ranges
+func LastBlock(ranges []string) (lastBlock uint64, err error) {
+	if len(ranges) == 0 {
+		return 0, ErrNoRanges
 	}
-
-	var wg sync.WaitGroup
-
-	for rawFileName, fileName := range blooms {
-		fileRange, err := blkrange.FromFilename(fileName)
+	for _, fileRange := range ranges {
+		blkRange, err := blkrange.FromFilename(fileRange)
 		if err != nil {
-			// don't respond further -- there may be foreign files in the folder
-			fmt.Println(err)
-			continue
+			return 0, fmt.Errorf("invalid chunk range %s: %w", fileRange, err)
 		}
+		lastBlock = max(lastBlock, blkRange[1])
+	}
+	return
+}
 
-		// Run a go routine for each index file
-		wg.Add(1)
-		fn := fileName
-		rfn := rawFileName
-		go func() {
-			f, err := runEnv.ReadBloom(fn)
-			if err != nil {
-				panic(err)
-			}
-			defer f.Close()
-			defer wg.Done()
-			b, err := bloom.NewBloom(f, fmt.Sprintf("%d-%d", fileRange[0], fileRange[1]))
-			if err != nil {
-				panic(err)
-			}
-
-			v, err := b.IsMember(address)
-			if err != nil {
-				panic(err)
-			}
-
-			if v {
-				nfn := strings.Replace(rfn, ".bloom", "", 1)
-				foundRangesCh <- nfn
-				log.Println("Bloom match:", rfn)
-			}
-		}()
+// search checks the bloom filter of fileRange chunk and, if it's a hit, reads
+// address appearances from the chunk
+func search(chain string, fileRange string, address string, runEnv RunEnv) ([]chunk.AppearanceRecord, error) {
+	member, err := QueryBloom(chain, fileRange, address, runEnv)
+	if err != nil {
+		return nil, fmt.Errorf("querying bloom %s: %w", fileRange, err)
+	}
+	if !member {
+		return nil, nil
+	}
+	log.Println("Bloom match:", fileRange)
 
+	apps, err := Extract(chain, fileRange, address, runEnv)
+	if err != nil {
+		return nil, fmt.Errorf("reading chunk %s: %w", fileRange, err)
 	}
+	return apps, nil
+}
 
-	wg.Wait()
-	close(foundRangesCh)
+// QueryBloom returns true if address may appear in fileRange chunk
+func QueryBloom(chain string, fileRange string, address string, runEnv RunEnv) (bool, error) {
+	f, err := runEnv.ReadBloom(chain, fileRange)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
 
-	return nil
+	b, err := bloom.NewBloom(f, fileRange)
+	if err != nil {
+		return false, err
+	}
+	return b.IsMember(address)
 }
 
-func Extract(chain string, fileName string, address string, runEnv RunEnv) (result []chunk.AppearanceRecord, err error) {
-	f, err := runEnv.ReadChunk(chain, fileName)
+func Extract(chain string, fileRange string, address string, runEnv RunEnv) (result []chunk.AppearanceRecord, err error) {
+	f, err := runEnv.ReadChunk(chain, fileRange)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	chunk, err := chunk.NewChunkData(f, fileName)
+	chunk, err := chunk.NewChunkData(f, fileRange)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,316 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query/bloom"
+	"github.com/TrueBlocks/trueblocks-key/searcher/pkg/query/chunk"
+)
+
+// memoryRunEnv keeps synthetic chunks and blooms in memory
+type memoryRunEnv struct {
+	blooms map[string][]byte
+	chunks map[string][]byte
+}
+
+func (m *memoryRunEnv) Ranges(chain string) ([]string, error) {
+	ranges := make([]string, 0, len(m.blooms))
+	for fileRange := range m.blooms {
+		ranges = append(ranges, fileRange)
+	}
+	sort.Strings(ranges)
+	return ranges, nil
+}
+
+func (m *memoryRunEnv) ReadBloom(chain string, fileRange string) (io.ReadSeekCloser, error) {
+	return readFile(m.blooms, fileRange)
+}
+
+func (m *memoryRunEnv) ReadChunk(chain string, fileRange string) (io.ReadSeekCloser, error) {
+	return readFile(m.chunks, fileRange)
+}
+
+func readFile(files map[string][]byte, fileRange string) (io.ReadSeekCloser, error) {
+	b, ok := files[fileRange]
+	if !ok {
+		return nil, errors.New("file not found: " + fileRange)
+	}
+	return nopCloser{bytes.NewReader(b)}, nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+// add builds bloom and chunk with appearances (address -> records) and adds them to m
+func (m *memoryRunEnv) add(t *testing.T, fileRange string, appearances map[string][]chunk.AppearanceRecord) {
+	t.Helper()
+
+	addresses := make([]string, 0, len(appearances))
+	for address := range appearances {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	// bloom: header, count and a single bloom
+	bloomBytes := make([]byte, bloom.BLOOM_WIDTH_IN_BYTES)
+	b := &bloom.Bloom{}
+	for _, address := range addresses {
+		bits, err := b.WhichBits(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, bit := range bits {
+			bloomBytes[bloom.BLOOM_WIDTH_IN_BYTES-bit/8-1] |= 1 << (bit % 8)
+		}
+	}
+	bloomFile := &bytes.Buffer{}
+	write(t, bloomFile, bloom.BloomHeader{Magic: bloom.MagicNumber, Hash: bloom.Hash(chunk.SpecVersionHash)})
+	write(t, bloomFile, int32(1))
+	write(t, bloomFile, uint32(len(addresses)))
+	write(t, bloomFile, bloomBytes)
+
+	// chunk: header, address table and appearance table
+	addressTable := &bytes.Buffer{}
+	appTable := &bytes.Buffer{}
+	var offset uint32
+	for _, address := range addresses {
+		record := chunk.AddressRecord{Offset: offset, Count: uint32(len(appearances[address]))}
+		decoded, err := hex.DecodeString(address[2:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(record.Address[:], decoded)
+		write(t, addressTable, record)
+		write(t, appTable, appearances[address])
+		offset += record.Count
+	}
+	chunkFile := &bytes.Buffer{}
+	write(t, chunkFile, chunk.IndexHeaderRecord{
+		Magic:           chunk.MagicNumber,
+		Hash:            chunk.SpecVersionHash,
+		AddressCount:    uint32(len(addresses)),
+		AppearanceCount: offset,
+	})
+	write(t, chunkFile, addressTable.Bytes())
+	write(t, chunkFile, appTable.Bytes())
+
+	if m.blooms == nil {
+		m.blooms = make(map[string][]byte)
+		m.chunks = make(map[string][]byte)
+	}
+	m.blooms[fileRange] = bloomFile.Bytes()
+	m.chunks[fileRange] = chunkFile.Bytes()
+}
+
+func write(t *testing.T, w io.Writer, data any) {
+	t.Helper()
+	if err := binary.Write(w, binary.LittleEndian, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const testAddress = "0x0000000000000000000000000000000000000042"
+const otherAddress = "0x1000000000000000000000000000000000000000"
+
+func testRunEnv(t *testing.T) *memoryRunEnv {
+	runEnv := &memoryRunEnv{}
+	runEnv.add(t, "000000000-000000099", map[string][]chunk.AppearanceRecord{
+		testAddress:  {{BlockNumber: 1, TransactionId: 0}, {BlockNumber: 50, TransactionId: 3}},
+		otherAddress: {{BlockNumber: 2, TransactionId: 1}},
+	})
+	runEnv.add(t, "000000100-000000199", map[string][]chunk.AppearanceRecord{
+		otherAddress: {{BlockNumber: 150, TransactionId: 0}},
+	})
+	runEnv.add(t, "000000200-000000299", map[string][]chunk.AppearanceRecord{
+		testAddress: {{BlockNumber: 200, TransactionId: 1}, {BlockNumber: 200, TransactionId: 7}},
+	})
+	return runEnv
+}
+
+func TestFind(t *testing.T) {
+	runEnv := testRunEnv(t)
+	ranges, _ := runEnv.Ranges("mainnet")
+
+	// address case doesn't matter
+	results, err := Find(context.Background(), "mainnet", ranges, "0x"+strings.ToUpper(testAddress[2:]), 0, 299, runEnv, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []chunk.AppearanceRecord{
+		{BlockNumber: 200, TransactionId: 7},
+		{BlockNumber: 200, TransactionId: 1},
+		{BlockNumber: 50, TransactionId: 3},
+		{BlockNumber: 1, TransactionId: 0},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatal("wrong results:", results)
+	}
+
+	results, err = Find(context.Background(), "mainnet", ranges, testAddress, 0, 49, runEnv, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, []chunk.AppearanceRecord{{BlockNumber: 1, TransactionId: 0}}) {
+		t.Fatal("wrong results with lastBlock:", results)
+	}
+
+	// the first chunk is out of the window, so it's not read at all
+	delete(runEnv.blooms, "000000000-000000099")
+	results, err = Find(context.Background(), "mainnet", ranges, testAddress, 100, 299, runEnv, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, expected[:2]) {
+		t.Fatal("wrong results with firstBlock:", results)
+	}
+	results, err = Find(context.Background(), "mainnet", ranges, testAddress, 200, 200, runEnv, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, expected[:2]) {
+		t.Fatal("wrong results with single block window:", results)
+	}
+
+	results, err = Find(context.Background(), "mainnet", ranges, "0x2000000000000000000000000000000000000000", 100, 299, runEnv, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Fatal("expected no results, got", results)
+	}
+}
+
+func TestFind_Errors(t *testing.T) {
+	runEnv := testRunEnv(t)
+	delete(runEnv.blooms, "000000100-000000199")
+	ranges := []string{"000000000-000000099", "000000100-000000199", "000000200-000000299"}
+
+	_, err := Find(context.Background(), "mainnet", ranges, testAddress, 0, 299, runEnv, 2)
+	if err == nil || !strings.Contains(err.Error(), "000000100-000000199") {
+		t.Fatal("expected error for missing bloom, got", err)
+	}
+
+	// truncated chunk
+	runEnv = testRunEnv(t)
+	truncated := runEnv.chunks["000000200-000000299"]
+	runEnv.chunks["000000200-000000299"] = truncated[:len(truncated)-1]
+	_, err = Find(context.Background(), "mainnet", ranges, testAddress, 0, 299, runEnv, 2)
+	if !errors.Is(err, chunk.ErrInvalidChunk) {
+		t.Fatal("expected ErrInvalidChunk, got", err)
+	}
+
+	// bloom with wrong magic number
+	runEnv = testRunEnv(t)
+	runEnv.blooms["000000000-000000099"][0] = 0
+	_, err = Find(context.Background(), "mainnet", ranges, testAddress, 0, 299, runEnv, 2)
+	if !errors.Is(err, bloom.ErrInvalidBloom) {
+		t.Fatal("expected ErrInvalidBloom, got", err)
+	}
+
+	_, err = Find(context.Background(), "mainnet", []string{"invalid"}, testAddress, 0, 299, runEnv, 2)
+	if err == nil {
+		t.Fatal("expected error for invalid range")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = Find(ctx, "mainnet", ranges, testAddress, 0, 299, runEnv, 2); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context error, got", err)
+	}
+}
+
+func TestLastBlock(t *testing.T) {
+	lastBlock, err := LastBlock([]string{"000000100-000000199", "000000000-000000099"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastBlock != 199 {
+		t.Fatal("wrong last block:", lastBlock)
+	}
+
+	if _, err = LastBlock(nil); !errors.Is(err, ErrNoRanges) {
+		t.Fatal("expected ErrNoRanges, got", err)
+	}
+}
+
+func TestWriteChunkAndBloom(t *testing.T) {
+	appearances := map[string][]chunk.AppearanceRecord{
+		"0xf503017d7baf7fbc0fff7492b751025c6a78179b": {{BlockNumber: 12, TransactionId: 1}, {BlockNumber: 10, TransactionId: 3}},
+		"0x0000000000000000000000000000000000000001": {{BlockNumber: 11, TransactionId: 0}},
+	}
+	expected := &memoryRunEnv{}
+	sorted := map[string][]chunk.AppearanceRecord{
+		"0xf503017d7baf7fbc0fff7492b751025c6a78179b": {{BlockNumber: 10, TransactionId: 3}, {BlockNumber: 12, TransactionId: 1}},
+		"0x0000000000000000000000000000000000000001": {{BlockNumber: 11, TransactionId: 0}},
+	}
+	expected.add(t, "000000010-000000012", sorted)
+
+	var addresses []chunk.AddressAppearances
+	for address, records := range appearances {
+		decoded, _ := hex.DecodeString(address[2:])
+		addresses = append(addresses, chunk.AddressAppearances{Address: [20]byte(decoded), Appearances: records})
+	}
+	chunk.SortAddressAppearances(addresses)
+
+	chunkFile := &bytes.Buffer{}
+	if err := chunk.Write(chunkFile, addresses); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(chunkFile.Bytes(), expected.chunks["000000010-000000012"]) {
+		t.Fatal("chunk does not match")
+	}
+
+	b := &bloom.Bloom{}
+	for _, address := range addresses {
+		b.InsertAddress(address.Address)
+	}
+	bloomFile := &bytes.Buffer{}
+	if err := b.Write(bloomFile); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bloomFile.Bytes(), expected.blooms["000000010-000000012"]) {
+		t.Fatal("bloom does not match")
+	}
+
+	// duplicates are rejected
+	if err := chunk.Write(io.Discard, append(addresses, addresses[1])); !errors.Is(err, chunk.ErrInvalidChunk) {
+		t.Fatal("expected ErrInvalidChunk, got", err)
+	}
+}
+
+func TestBloom_InsertAddress_NewBloom(t *testing.T) {
+	b := &bloom.Bloom{}
+	var address [20]byte
+	for i := 0; i <= bloom.MAX_ADDRS_IN_BLOOM+1; i++ {
+		binary.BigEndian.PutUint32(address[16:], uint32(i))
+		b.InsertAddress(address)
+	}
+	if b.Count != 2 || b.Blooms[0].NInserted != bloom.MAX_ADDRS_IN_BLOOM+1 || b.Blooms[1].NInserted != 1 {
+		t.Fatal("wrong blooms:", b.Count, b.Blooms[0].NInserted, b.Blooms[1].NInserted)
+	}
+
+	bloomFile := &bytes.Buffer{}
+	if err := b.Write(bloomFile); err != nil {
+		t.Fatal(err)
+	}
+	read, err := bloom.NewBloom(bytes.NewReader(bloomFile.Bytes()), "000000000-000000001.bloom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := read.IsMember("0x" + hex.EncodeToString(address[:]))
+	if err != nil || !member {
+		t.Fatal("expected member:", member, err)
+	}
+}
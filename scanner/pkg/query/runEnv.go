@@ -3,7 +3,8 @@ package query
 import "io"
 
 type RunEnv interface {
-	Blooms(chain string) (map[string]string, error)
-	ReadBloom(fileName string) (io.ReadSeekCloser, error)
-	ReadChunk(chain string, fileName string) (io.ReadSeekCloser, error)
+	// Ranges returns block ranges of the index chunks (e.g. 000000000-000000100)
+	Ranges(chain string) ([]string, error)
+	ReadBloom(chain string, fileRange string) (io.ReadSeekCloser, error)
+	ReadChunk(chain string, fileRange string) (io.ReadSeekCloser, error)
 }
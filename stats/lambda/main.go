@@ -7,7 +7,6 @@ import (
 	"log"
 	"net/http"
 
-	awshelper "github.com/TrueBlocks/trueblocks-key/awshelper/pkg"
 	keyConfig "github.com/TrueBlocks/trueblocks-key/config/pkg"
 	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
 	keyDynamodb "github.com/TrueBlocks/trueblocks-key/quicknode/keyDynamodb"
@@ -21,7 +20,7 @@ import (
 
 var cnf *keyConfig.ConfigFile
 var dynamoClient *dynamodb.Client
-var dbConn *database.Connection
+var dbProvider *database.Provider
 
 var ErrInternal = errors.New(http.StatusText(http.StatusInternalServerError))
 
@@ -34,16 +33,23 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 		}
 	}
 
-	if dbConn == nil {
-		if err = setupDbConnection(); err != nil {
-			log.Println("database connection:", err)
+	if dbProvider == nil {
+		if dbProvider, err = database.NewProvider(cnf, "default", "mainnet"); err != nil {
+			log.Println("database config:", err)
 			err = ErrInternal
 			return
 		}
 	}
+	dbConn, err := dbProvider.Connection(ctx)
+	if err != nil {
+		log.Println("database connection:", err)
+		err = ErrInternal
+		return
+	}
+	defer dbProvider.Release(context.WithoutCancel(ctx), dbConn)
 
 	if dynamoClient == nil {
-		if err = setupDynamo(); err != nil {
+		if err = setupDynamo(ctx); err != nil {
 			log.Println("dynamo connection:", err)
 			err = ErrInternal
 			return
@@ -118,9 +124,9 @@ func loadConfig() (err error) {
 	return
 }
 
-func setupDynamo() (err error) {
+func setupDynamo(ctx context.Context) (err error) {
 	var awsConfig aws.Config
-	awsConfig, err = config.LoadDefaultConfig(context.TODO())
+	awsConfig, err = config.LoadDefaultConfig(ctx)
 	if err != nil {
 		log.Println("error reading config:", err)
 		return
@@ -136,40 +142,6 @@ func setupDynamo() (err error) {
 	return
 }
 
-func setupDbConnection() (err error) {
-	var user string
-	var password string
-	secretId := cnf.Database["default"].AwsSecret
-	if secretId != "" {
-		log.Println("using Secrets Manager secret as DB password")
-		secretValue, err := awshelper.FetchUsernamePasswordSecret(secretId)
-		if err != nil {
-			return err
-		}
-		user = secretValue.Username
-		password = secretValue.Password
-	} else {
-		log.Println("using configuration DB password")
-		user = cnf.Database["default"].User
-		password = cnf.Database["default"].Password
-	}
-
-	dbConn = &database.Connection{
-		Chain:    "mainnet",
-		Host:     cnf.Database["default"].Host,
-		Port:     cnf.Database["default"].Port,
-		Database: cnf.Database["default"].Database,
-		User:     user,
-		Password: password,
-	}
-
-	log.Println(dbConn.String())
-
-	return dbConn.Connect(context.TODO())
-}
-
 func main() {
-	defer dbConn.Close(context.TODO())
-
 	lambda.Start(HandleRequest)
 }
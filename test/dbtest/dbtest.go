@@ -90,7 +90,7 @@ func NewTestConnection() (conn *database.Connection, done func() error, err erro
 		return
 	}
 
-	err = conn.Setup()
+	err = conn.Setup(ctx)
 	done = func() error {
 		defer conn.Close(context.TODO())
 		return terminateContainer()
@@ -3,7 +3,15 @@
 
 package dbtest
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	database "github.com/TrueBlocks/trueblocks-key/database/pkg"
+	"github.com/TrueBlocks/trueblocks-key/database/pkg/sql"
+)
 
 func TestDbtest(t *testing.T) {
 	_, done, err := NewTestConnection()
@@ -13,3 +21,203 @@ func TestDbtest(t *testing.T) {
 
 	done()
 }
+
+func TestWatermarkCommitOrder(t *testing.T) {
+	conn, done, err := NewTestConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+	ctx := context.Background()
+	address := "0x00000000000000000000000000000000000000aa"
+
+	committed := &database.Appearance{BlockNumber: 1}
+	if err := committed.Insert(ctx, conn, address); err != nil {
+		t.Fatal(err)
+	}
+
+	// pending transaction takes its ID before the next insert, but commits after
+	// we read the watermark
+	pending, err := conn.Db().Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pending.Rollback(ctx)
+	if _, err := pending.Exec(ctx, "SELECT pg_current_xact_id()"); err != nil {
+		t.Fatal(err)
+	}
+
+	later := &database.Appearance{BlockNumber: 2}
+	if err := later.Insert(ctx, conn, address); err != nil {
+		t.Fatal(err)
+	}
+
+	// the watermark is held back by the pending transaction, but the first page isn't
+	items, watermark, err := database.FetchAppearancesFirstPage(ctx, conn, false, address, 0, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatal("wrong first page:", items)
+	}
+
+	if _, err := pending.Exec(
+		ctx,
+		fmt.Sprintf("INSERT INTO %s (address_id, block_number, tx_id) SELECT address_id, 3, 0 FROM %[1]s LIMIT 1", conn.AppearancesTableName()),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := pending.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the following pages only show appearances up to the watermark
+	items, err = database.FetchAppearancesPage(ctx, conn, true, address, 0, 10, 10, 11, 0, watermark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].BlockNumber != 1 {
+		t.Fatal("wrong appearances at watermark:", items)
+	}
+
+	_, watermark, err = database.FetchAppearancesFirstPage(ctx, conn, false, address, 0, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err = database.FetchAppearancesPage(ctx, conn, true, address, 0, 10, 10, 11, 0, watermark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Fatal("wrong appearances at new watermark:", items)
+	}
+}
+
+func TestReclusterPendingInsert(t *testing.T) {
+	conn, done, err := NewTestConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+	ctx := context.Background()
+	address := "0x00000000000000000000000000000000000000bb"
+
+	for _, blockNumber := range []uint32{1, 2} {
+		app := &database.Appearance{BlockNumber: blockNumber}
+		if err := app.Insert(ctx, conn, address); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// pending transaction is running when Recluster reads its watermark and
+	// commits while Recluster is already working
+	pending, err := conn.Db().Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pending.Rollback(ctx)
+	if _, err := pending.Exec(
+		ctx,
+		fmt.Sprintf("INSERT INTO %s (address_id, block_number, tx_id) SELECT address_id, 3, 0 FROM %[1]s LIMIT 1", conn.AppearancesTableName()),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	var commitErr error
+	err = database.Recluster(ctx, conn, database.ReclusterOptions{
+		BatchAddresses: 1,
+		Progress: func(p database.ReclusterProgress) {
+			if p.Phase == database.ReclusterPhasePrepare {
+				commitErr = pending.Commit(ctx)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commitErr != nil {
+		t.Fatal(commitErr)
+	}
+
+	items, _, err := database.FetchAppearancesFirstPage(ctx, conn, false, address, 0, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Fatal("wrong appearances after recluster:", items)
+	}
+
+	tableName := conn.AppearancesTableName()
+	var hasSeqIndex bool
+	if err := conn.Db().QueryRow(ctx, sql.SelectRelationExists(sql.ReclusterSeqIndexName(tableName))).Scan(&hasSeqIndex); err != nil {
+		t.Fatal(err)
+	}
+	if !hasSeqIndex {
+		t.Fatal("swapped table has no seq index")
+	}
+
+	// the original table is kept, so another run has to wait until it's dropped
+	if err := database.Recluster(ctx, conn, database.ReclusterOptions{BatchAddresses: 1}); !errors.Is(err, database.ErrReclusterLeftover) {
+		t.Fatal("expected leftover error:", err)
+	}
+}
+
+func TestInsertHexAppearance(t *testing.T) {
+	conn, done, err := NewTestConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+	ctx := context.Background()
+	address := "0x00000000000000000000000000000000000000CC"
+
+	if !conn.BinaryAddresses() {
+		t.Fatal("expected binary addresses in a new database")
+	}
+	insert := sql.InsertHexAppearance(conn.AppearancesTableName(), conn.AddressesTableName(), conn.BinaryAddresses())
+	// the second insert finds the address inserted by the first one
+	for _, blockNumber := range []uint32{1, 2} {
+		if _, err := conn.Db().Exec(ctx, insert, address, blockNumber, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, _, err := database.FetchAppearancesFirstPage(ctx, conn, false, address, 0, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatal("wrong appearances:", items)
+	}
+}
+
+func TestCreateAddressIndexPartitioned(t *testing.T) {
+	conn, done, err := NewTestConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+	ctx := context.Background()
+
+	conn.AppearancesPartitionSize = 1000
+	if _, err := database.PartitionAppearances(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateAppearancesAddressIndex(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	// the partitioned table's index is valid only when all partitions are indexed
+	var valid bool
+	err = conn.Db().QueryRow(
+		ctx,
+		"SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass($1)",
+		sql.AppearancesAddressIndexName(conn.AppearancesTableName()),
+	).Scan(&valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid {
+		t.Fatal("address index is not valid")
+	}
+}
@@ -90,7 +90,7 @@ func TestLambdaQueueConsumeRequests(t *testing.T) {
 
 	// Make sure the appearance has been added to the db
 
-	dbAppearances, err = database.FetchAppearancesFirstPage(context.TODO(), dbConn, false, appearance.Address, uint(appearance.BlockNumber), 11154177)
+	dbAppearances, _, err = database.FetchAppearancesFirstPage(context.TODO(), dbConn, false, appearance.Address, 0, uint(appearance.BlockNumber), 11154177)
 	if err != nil {
 		t.Fatal("fetching appearances from db:", err)
 	}
@@ -144,7 +144,7 @@ func TestLambdaQueueConsumeRequests(t *testing.T) {
 	// Number of records in the DB should not change
 
 	var count int
-	count, err = dbConn.CountAppearances()
+	count, err = dbConn.CountAppearances(context.TODO())
 	if err != nil {
 		t.Fatal("fetching appearances from db:", err)
 	}
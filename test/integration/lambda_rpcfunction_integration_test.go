@@ -98,6 +98,108 @@ func TestLambdaRpcFunctionRequests(t *testing.T) {
 		t.Fatal("wrong meta address", a)
 	}
 
+	// Valid request with enrichment
+
+	err = database.InsertBlockBatch(context.TODO(), dbConn, []database.Block{
+		{
+			Number:            1,
+			Timestamp:         1438269988,
+			TransactionHashes: []string{"0x0", "0x1", "0x2", "0x3", "0x4", "0x5"},
+		},
+	})
+	if err != nil {
+		t.Fatal("inserting test block:", err)
+	}
+
+	request = &query.RpcRequest{
+		Id:     1,
+		Method: "tb_getAppearances",
+	}
+	err = query.SetParams(
+		request,
+		[]query.RpcGetAppearancesParam{
+			{
+				Address: address,
+				Include: []string{query.IncludeTimestamp, query.IncludeTxHash},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal("setting rpc request params:", err)
+	}
+	output = helpers.InvokeLambda(t, client, "RpcFunction", request)
+
+	helpers.AssertLambdaSuccessful(t, output)
+	helpers.UnmarshalLambdaOutput(t, output, response)
+
+	if l := len(response.Result.Data); l != 1 {
+		t.Fatal("wrong result count:", l)
+	}
+	if ts := response.Result.Data[0].Timestamp; ts != "1438269988" {
+		t.Fatal("wrong timestamp:", ts)
+	}
+	if h := response.Result.Data[0].TxHash; h != "0x5" {
+		t.Fatal("wrong tx hash:", h)
+	}
+
+	// Valid request, timestamp range without blocks
+
+	fromTimestamp := uint64(1438269989)
+	request = &query.RpcRequest{
+		Id:     1,
+		Method: "tb_getAppearances",
+	}
+	err = query.SetParams(
+		request,
+		[]query.RpcGetAppearancesParam{
+			{
+				Address:       address,
+				FromTimestamp: &fromTimestamp,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal("setting rpc request params:", err)
+	}
+	output = helpers.InvokeLambda(t, client, "RpcFunction", request)
+
+	helpers.AssertLambdaSuccessful(t, output)
+	helpers.UnmarshalLambdaOutput(t, output, response)
+
+	if l := len(response.Result.Data); l != 0 {
+		t.Fatal("wrong result count:", l)
+	}
+
+	// Block by timestamp
+
+	request = &query.RpcRequest{
+		Id:     1,
+		Method: "tb_getBlockByTimestamp",
+	}
+	err = query.SetParams(
+		request,
+		[]query.RpcGetBlockByTimestampParam{
+			{
+				Timestamp: fromTimestamp,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal("setting rpc request params:", err)
+	}
+	output = helpers.InvokeLambda(t, client, "RpcFunction", request)
+
+	helpers.AssertLambdaSuccessful(t, output)
+	blockResponse := &query.RpcResponse[*database.PublicBlock]{}
+	helpers.UnmarshalLambdaOutput(t, output, blockResponse)
+
+	if bn := blockResponse.Result.Data.BlockNumber; bn != "1" {
+		t.Fatal("wrong block number:", bn)
+	}
+	if ts := blockResponse.Result.Data.Timestamp; ts != "1438269988" {
+		t.Fatal("wrong timestamp:", ts)
+	}
+
 	// Valid request, no appearance found
 
 	request = &query.RpcRequest{
@@ -280,7 +382,7 @@ func TestLambdaRpcFunctionRequests(t *testing.T) {
 
 	// Status
 
-	statusResponse := &query.RpcResponse[*database.Status]{}
+	statusResponse := &query.RpcResponse[*query.Status]{}
 
 	// Valid request
 
@@ -297,6 +399,18 @@ func TestLambdaRpcFunctionRequests(t *testing.T) {
 	if l := statusResponse.Result.Meta.LastIndexedBlock; l != "1" {
 		t.Fatal("wrong max indexed block:", l)
 	}
+	if statusResponse.Result.Data == nil {
+		t.Fatal("status data is nil")
+	}
+	if l := statusResponse.Result.Data.LastIndexedBlock; l != "1" {
+		t.Fatal("wrong status last indexed block:", l)
+	}
+	if m := statusResponse.Result.Data.MaxPerPage; m != 1000 {
+		t.Fatal("wrong status max per page:", m)
+	}
+	if c := statusResponse.Result.Data.Chains; len(c["ethereum"]) == 0 {
+		t.Fatal("wrong status chains:", c)
+	}
 
 	// AddressesInTx
 
@@ -336,7 +450,7 @@ func TestLambdaRpcFunctionRequests(t *testing.T) {
 
 	// AddressesInBlock
 
-	getAddressesInBlockResponse := &query.RpcResponse[[]string]{}
+	getAddressesInBlockResponse := &query.RpcResponse[[]database.PublicAddressesInTx]{}
 
 	// Valid request
 
@@ -361,11 +475,14 @@ func TestLambdaRpcFunctionRequests(t *testing.T) {
 	t.Log(string(output.Payload))
 	helpers.UnmarshalLambdaOutput(t, output, getAddressesInBlockResponse)
 
-	expectedAddressesInTransaction = []string{
-		"0x74df56727d04f6f30c9f52d6ccc1ebfb6c93f687",
+	expectedAddressesInBlock := []database.PublicAddressesInTx{
+		{
+			TransactionIndex: "5",
+			Addresses:        []string{"0x74df56727d04f6f30c9f52d6ccc1ebfb6c93f687"},
+		},
 	}
 
-	if d := getAddressesInBlockResponse.Result.Data; !reflect.DeepEqual(d, expectedAddressesInTransaction) {
+	if d := getAddressesInBlockResponse.Result.Data; !reflect.DeepEqual(d, expectedAddressesInBlock) {
 		t.Fatalf("wrong result: %+v", d)
 	}
 }
@@ -457,6 +574,80 @@ func TestLambdaRpcFunctionAddressInRequests(t *testing.T) {
 	if !reflect.DeepEqual(response, hexResponse) {
 		t.Fatal("wrong response:", hexResponse)
 	}
+
+	// Addresses in block, paginated
+
+	blockResponse := &query.RpcResponse[[]database.PublicAddressesInTx]{}
+	request = &query.RpcRequest{
+		Method: "tb_getAddressesInBlock",
+	}
+	err = query.SetParams(
+		request,
+		[]query.RpcGetAddressesInParam{
+			{
+				BlockNumber: "4053179",
+				PerPage:     5,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal("setting rpc request params:", err)
+	}
+
+	output = helpers.InvokeLambda(t, client, "RpcFunction", request)
+
+	helpers.AssertLambdaSuccessful(t, output)
+	t.Log(string(output.Payload))
+	helpers.UnmarshalLambdaOutput(t, output, blockResponse)
+
+	expectedFirstPage := []database.PublicAddressesInTx{
+		{TransactionIndex: "1", Addresses: []string{"0x209c4784ab1e8183cf58ca33cb740efbf3fc18ef", "0xf503017d7baf7fbc0fff7492b751025c6a78179b"}},
+		{TransactionIndex: "2", Addresses: []string{"0x209c4784ab1e8183cf58ca33cb740efbf3fc18ef"}},
+		{TransactionIndex: "3", Addresses: []string{"0x209c4784ab1e8183cf58ca33cb740efbf3fc18ef"}},
+		{TransactionIndex: "4", Addresses: []string{"0x209c4784ab1e8183cf58ca33cb740efbf3fc18ef"}},
+	}
+	if d := blockResponse.Data; !reflect.DeepEqual(d, expectedFirstPage) {
+		t.Fatalf("wrong first page: %+v", d)
+	}
+	if blockResponse.Meta.NextCursor == nil {
+		t.Fatal("expected next cursor")
+	}
+
+	cursor, err := blockResponse.Meta.NextCursor.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = query.SetParams(
+		request,
+		[]query.RpcGetAddressesInParam{
+			{
+				BlockNumber: "4053179",
+				PerPage:     5,
+				Cursor:      string(cursor),
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal("setting rpc request params:", err)
+	}
+
+	output = helpers.InvokeLambda(t, client, "RpcFunction", request)
+
+	helpers.AssertLambdaSuccessful(t, output)
+	t.Log(string(output.Payload))
+	blockResponse = &query.RpcResponse[[]database.PublicAddressesInTx]{}
+	helpers.UnmarshalLambdaOutput(t, output, blockResponse)
+
+	expectedSecondPage := []database.PublicAddressesInTx{
+		{TransactionIndex: "5", Addresses: []string{"0x209c4784ab1e8183cf58ca33cb740efbf3fc18ef"}},
+		{TransactionIndex: "6", Addresses: []string{"0x209c4784ab1e8183cf58ca33cb740efbf3fc18ef"}},
+	}
+	if d := blockResponse.Data; !reflect.DeepEqual(d, expectedSecondPage) {
+		t.Fatalf("wrong second page: %+v", d)
+	}
+	if c := blockResponse.Meta.NextCursor; c != nil {
+		t.Fatal("unexpected next cursor:", c)
+	}
 }
 
 func TestLambdaRpcFunctionPagination(t *testing.T) {
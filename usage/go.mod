@@ -0,0 +1,3 @@
+module github.com/TrueBlocks/trueblocks-key/usage
+
+go 1.22
@@ -0,0 +1,47 @@
+package usage
+
+import (
+	"context"
+	"log"
+	"time"
+
+	config "github.com/TrueBlocks/trueblocks-key/config/pkg"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Authorize checks account's usage against its plan's quota and fills the authorizer
+// context, so that the query lambda can meter the request. API Gateway caches authorizer
+// responses, so limits are enforced with a delay of up to the cache TTL. If usage cannot
+// be read, the request is allowed: metering problems shouldn't take the API down.
+func Authorize(ctx context.Context, cnf *config.ConfigFile, client DynamoClient, account string, plan string, result *events.APIGatewayCustomAuthorizerResponse) (status Status) {
+	if result.Context == nil {
+		result.Context = make(map[string]any)
+	}
+	result.Context[ContextAccount] = account
+	result.Context[ContextPlan] = plan
+
+	if cnf.Usage.TableName == "" {
+		return
+	}
+	quota, found := QuotaForPlan(cnf, plan)
+	if !found {
+		return
+	}
+
+	meter := &Meter{Client: client, TableName: cnf.Usage.TableName}
+	daily, monthly, err := meter.Fetch(ctx, account, time.Now())
+	if err != nil {
+		log.Println("checking quota, allowing request:", err)
+		return
+	}
+
+	status, reason := quota.Check(daily, monthly)
+	switch status {
+	case StatusSoftLimit:
+		log.Println("soft quota exceeded:", account, plan, reason)
+		result.Context[ContextQuotaWarning] = reason
+	case StatusHardLimit:
+		log.Println("hard quota exceeded:", account, plan, reason)
+	}
+	return
+}
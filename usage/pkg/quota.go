@@ -0,0 +1,74 @@
+package usage
+
+import (
+	"fmt"
+	"strings"
+
+	config "github.com/TrueBlocks/trueblocks-key/config/pkg"
+)
+
+type Status int
+
+const (
+	StatusOk Status = iota
+	// StatusSoftLimit means that a soft limit is exceeded, requests are still served
+	StatusSoftLimit
+	// StatusHardLimit means that a hard limit is exceeded, requests are denied
+	StatusHardLimit
+)
+
+// Limits are maximum values of counters, zero means no limit
+type Limits struct {
+	Requests uint64
+	Rows     uint64
+}
+
+type Quota struct {
+	SoftDaily   Limits
+	HardDaily   Limits
+	SoftMonthly Limits
+	HardMonthly Limits
+}
+
+// QuotaForPlan returns quota configured for plan. Names are compared ignoring case and
+// hyphens, because environment variable names cannot contain hyphens, e.g.
+// KY_USAGE_QUOTAS_QNSTANDARD_HARDMONTHLYREQUESTS sets quota of qn-standard plan.
+func QuotaForPlan(cnf *config.ConfigFile, plan string) (quota Quota, found bool) {
+	for name, q := range cnf.Usage.Quotas {
+		if !strings.EqualFold(strings.ReplaceAll(name, "-", ""), strings.ReplaceAll(plan, "-", "")) {
+			continue
+		}
+		return Quota{
+			SoftDaily:   Limits{Requests: q.SoftDailyRequests, Rows: q.SoftDailyRows},
+			HardDaily:   Limits{Requests: q.HardDailyRequests, Rows: q.HardDailyRows},
+			SoftMonthly: Limits{Requests: q.SoftMonthlyRequests, Rows: q.SoftMonthlyRows},
+			HardMonthly: Limits{Requests: q.HardMonthlyRequests, Rows: q.HardMonthlyRows},
+		}, true
+	}
+	return
+}
+
+// Check returns the most severe status and a description of the exceeded limit. Counters
+// are compared before the current request is recorded, so a limit of N allows N requests.
+func (q *Quota) Check(daily Counters, monthly Counters) (status Status, reason string) {
+	limits := []struct {
+		status  Status
+		period  string
+		limits  Limits
+		current Counters
+	}{
+		{StatusHardLimit, "monthly", q.HardMonthly, monthly},
+		{StatusHardLimit, "daily", q.HardDaily, daily},
+		{StatusSoftLimit, "monthly", q.SoftMonthly, monthly},
+		{StatusSoftLimit, "daily", q.SoftDaily, daily},
+	}
+	for _, l := range limits {
+		if l.limits.Requests > 0 && l.current.Requests >= l.limits.Requests {
+			return l.status, fmt.Sprintf("%s requests limit (%d) reached", l.period, l.limits.Requests)
+		}
+		if l.limits.Rows > 0 && l.current.Rows >= l.limits.Rows {
+			return l.status, fmt.Sprintf("%s rows limit (%d) reached", l.period, l.limits.Rows)
+		}
+	}
+	return StatusOk, ""
+}
@@ -0,0 +1,95 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	config "github.com/TrueBlocks/trueblocks-key/config/pkg"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+func TestQuota_Check(t *testing.T) {
+	quota := &Quota{
+		SoftDaily:   Limits{Requests: 10},
+		HardDaily:   Limits{Requests: 20},
+		HardMonthly: Limits{Rows: 1000},
+	}
+	tests := []struct {
+		daily   Counters
+		monthly Counters
+		status  Status
+	}{
+		{Counters{Requests: 9}, Counters{}, StatusOk},
+		{Counters{Requests: 10}, Counters{}, StatusSoftLimit},
+		{Counters{Requests: 20}, Counters{}, StatusHardLimit},
+		{Counters{Requests: 1}, Counters{Rows: 1000}, StatusHardLimit},
+	}
+	for i, tt := range tests {
+		if status, reason := quota.Check(tt.daily, tt.monthly); status != tt.status {
+			t.Fatal(i, "wrong status:", status, reason)
+		}
+	}
+}
+
+type failingDynamo struct {
+	memoryDynamo
+}
+
+func (f *failingDynamo) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Setenv("KY_USAGE_TABLENAME", "usage")
+	t.Setenv("KY_USAGE_QUOTAS_PLAN1_SOFTDAILYREQUESTS", "1")
+	t.Setenv("KY_USAGE_QUOTAS_PLAN1_HARDDAILYREQUESTS", "2")
+	cnf, err := config.Get("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := QuotaForPlan(cnf, "Plan-1"); !found {
+		t.Fatal("quota not found")
+	}
+
+	client := &memoryDynamo{}
+	authorize := func(plan string) (Status, *events.APIGatewayCustomAuthorizerResponse) {
+		result := &events.APIGatewayCustomAuthorizerResponse{}
+		status := Authorize(context.Background(), cnf, client, "account1", plan, result)
+		if result.Context[ContextAccount] != "account1" || result.Context[ContextPlan] != plan {
+			t.Fatal("wrong context:", result.Context)
+		}
+		return status, result
+	}
+	record := func() {
+		meter := &Meter{Client: client, TableName: "usage"}
+		if err := meter.Record(context.Background(), Request{Account: "account1", Time: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if status, _ := authorize("Plan1"); status != StatusOk {
+		t.Fatal("wrong status:", status)
+	}
+	record()
+	status, result := authorize("Plan1")
+	if status != StatusSoftLimit || result.Context[ContextQuotaWarning] == nil {
+		t.Fatal("expected soft limit:", status, result.Context)
+	}
+	record()
+	if status, _ := authorize("Plan1"); status != StatusHardLimit {
+		t.Fatal("expected hard limit:", status)
+	}
+	// plans without quota are not limited
+	if status, _ := authorize("other"); status != StatusOk {
+		t.Fatal("wrong status without quota:", status)
+	}
+
+	// usage that cannot be read doesn't block requests
+	result = &events.APIGatewayCustomAuthorizerResponse{}
+	if status := Authorize(context.Background(), cnf, &failingDynamo{}, "account1", "plan1", result); status != StatusOk {
+		t.Fatal("wrong status when usage is unavailable:", status)
+	}
+}
@@ -0,0 +1,159 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Usage is kept in a DynamoDB table with partition key Account and sort key Period.
+// Every request updates three counters (rollups): the account's daily total, its daily
+// total per method and its monthly total. Each is a separate UpdateItem with ADD, so
+// concurrent requests don't conflict. Periods are UTC days and months:
+//
+//	day#2024-05-01
+//	day#2024-05-01#tb_getAppearances
+//	month#2024-05
+
+// Keys of the authorizer context (see events.APIGatewayCustomAuthorizerResponse.Context),
+// which API Gateway passes to the query lambda
+const (
+	ContextAccount      = "account"
+	ContextPlan         = "plan"
+	ContextQuotaWarning = "quotaWarning"
+)
+
+// DirectCustomersPlan is the plan of direct customers (they don't have plans yet)
+const DirectCustomersPlan = "direct"
+
+// dailyRetention sets ExpiresAt (DynamoDB TTL attribute) of daily counters
+const dailyRetention = 400 * 24 * time.Hour
+
+// DynamoClient is implemented by dynamodb.Client
+type DynamoClient interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+}
+
+// Request is a single metered request
+type Request struct {
+	Account string
+	Plan    string
+	Method  string
+	// Rows is the number of items returned
+	Rows int
+	// Duration is the time spent serving the request
+	Duration time.Duration
+	Time     time.Time
+}
+
+// Counters are values of a single rollup
+type Counters struct {
+	Requests uint64 `dynamodbav:"Requests"`
+	Rows     uint64 `dynamodbav:"Rows"`
+	// DurationMs is the total time spent serving requests
+	DurationMs uint64 `dynamodbav:"DurationMs"`
+}
+
+type Meter struct {
+	Client    DynamoClient
+	TableName string
+}
+
+func DayPeriod(t time.Time) string {
+	return "day#" + t.UTC().Format(time.DateOnly)
+}
+
+func MethodPeriod(t time.Time, method string) string {
+	return DayPeriod(t) + "#" + method
+}
+
+func MonthPeriod(t time.Time) string {
+	return "month#" + t.UTC().Format("2006-01")
+}
+
+// Record adds request to all rollups
+func (m *Meter) Record(ctx context.Context, r Request) error {
+	expiresAt := r.Time.Add(dailyRetention).Unix()
+	periods := []struct {
+		period    string
+		expiresAt int64
+	}{
+		{DayPeriod(r.Time), expiresAt},
+		{MethodPeriod(r.Time, r.Method), expiresAt},
+		{MonthPeriod(r.Time), 0},
+	}
+	for _, p := range periods {
+		if err := m.add(ctx, r, p.period, p.expiresAt); err != nil {
+			return fmt.Errorf("usage: recording %s %s: %w", r.Account, p.period, err)
+		}
+	}
+	return nil
+}
+
+func (m *Meter) add(ctx context.Context, r Request, period string, expiresAt int64) error {
+	update := "ADD #requests :one, #rows :rows, #durationMs :durationMs SET #plan = :plan"
+	names := map[string]string{
+		"#requests":   "Requests",
+		"#rows":       "Rows",
+		"#durationMs": "DurationMs",
+		"#plan":       "Plan",
+	}
+	values := map[string]types.AttributeValue{
+		":one":        &types.AttributeValueMemberN{Value: "1"},
+		":rows":       &types.AttributeValueMemberN{Value: strconv.Itoa(r.Rows)},
+		":durationMs": &types.AttributeValueMemberN{Value: strconv.FormatInt(r.Duration.Milliseconds(), 10)},
+		":plan":       &types.AttributeValueMemberS{Value: r.Plan},
+	}
+	if expiresAt > 0 {
+		update += ", #expiresAt = if_not_exists(#expiresAt, :expiresAt)"
+		names["#expiresAt"] = "ExpiresAt"
+		values[":expiresAt"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt, 10)}
+	}
+
+	_, err := m.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(m.TableName),
+		Key:                       key(r.Account, period),
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	return err
+}
+
+// Fetch returns account's counters of the day and month of t
+func (m *Meter) Fetch(ctx context.Context, account string, t time.Time) (daily Counters, monthly Counters, err error) {
+	if daily, err = m.fetch(ctx, account, DayPeriod(t)); err != nil {
+		return
+	}
+	monthly, err = m.fetch(ctx, account, MonthPeriod(t))
+	return
+}
+
+func (m *Meter) fetch(ctx context.Context, account string, period string) (counters Counters, err error) {
+	result, err := m.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(m.TableName),
+		Key:       key(account, period),
+	})
+	if err != nil {
+		return counters, fmt.Errorf("usage: fetching %s %s: %w", account, period, err)
+	}
+	if result == nil || result.Item == nil {
+		return
+	}
+	err = attributevalue.UnmarshalMap(result.Item, &counters)
+	return
+}
+
+func key(account string, period string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"Account": &types.AttributeValueMemberS{Value: account},
+		"Period":  &types.AttributeValueMemberS{Value: period},
+	}
+}
@@ -0,0 +1,101 @@
+package usage
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// memoryDynamo applies ADD of usage updates to in-memory items
+type memoryDynamo struct {
+	items   map[string]map[string]uint64
+	updates []*dynamodb.UpdateItemInput
+}
+
+func itemKey(key map[string]types.AttributeValue) string {
+	return key["Account"].(*types.AttributeValueMemberS).Value + "/" + key["Period"].(*types.AttributeValueMemberS).Value
+}
+
+func (m *memoryDynamo) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	item, ok := m.items[itemKey(params.Key)]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	result := make(map[string]types.AttributeValue)
+	for name, value := range item {
+		result[name] = &types.AttributeValueMemberN{Value: strconv.FormatUint(value, 10)}
+	}
+	return &dynamodb.GetItemOutput{Item: result}, nil
+}
+
+func (m *memoryDynamo) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	m.updates = append(m.updates, params)
+	if m.items == nil {
+		m.items = make(map[string]map[string]uint64)
+	}
+	key := itemKey(params.Key)
+	if m.items[key] == nil {
+		m.items[key] = make(map[string]uint64)
+	}
+	for name, placeholder := range map[string]string{"Requests": ":one", "Rows": ":rows", "DurationMs": ":durationMs"} {
+		value, _ := strconv.ParseUint(params.ExpressionAttributeValues[placeholder].(*types.AttributeValueMemberN).Value, 10, 64)
+		m.items[key][name] += value
+	}
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func TestMeter_Record(t *testing.T) {
+	client := &memoryDynamo{}
+	meter := &Meter{Client: client, TableName: "usage"}
+	now := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		err := meter.Record(context.Background(), Request{
+			Account:  "account1",
+			Plan:     "plan1",
+			Method:   "tb_getAppearances",
+			Rows:     10,
+			Duration: 15 * time.Millisecond,
+			Time:     now,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, period := range []string{"day#2024-05-01", "day#2024-05-01#tb_getAppearances", "month#2024-05"} {
+		item := client.items["account1/"+period]
+		if item["Requests"] != 2 || item["Rows"] != 20 || item["DurationMs"] != 30 {
+			t.Fatal("wrong counters of", period, item)
+		}
+	}
+	// every rollup is a separate update
+	if l := len(client.updates); l != 6 {
+		t.Fatal("wrong update count:", l)
+	}
+	// only daily counters expire
+	for _, update := range client.updates {
+		_, expires := update.ExpressionAttributeValues[":expiresAt"]
+		isMonth := update.Key["Period"].(*types.AttributeValueMemberS).Value == "month#2024-05"
+		if expires == isMonth {
+			t.Fatal("wrong expiration of", itemKey(update.Key))
+		}
+	}
+
+	daily, monthly, err := meter.Fetch(context.Background(), "account1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if daily.Requests != 2 || monthly.Rows != 20 || monthly.DurationMs != 30 {
+		t.Fatal("wrong fetched counters:", daily, monthly)
+	}
+
+	daily, _, err = meter.Fetch(context.Background(), "account2", now)
+	if err != nil || daily.Requests != 0 {
+		t.Fatal("expected empty counters:", daily, err)
+	}
+}